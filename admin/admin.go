@@ -0,0 +1,349 @@
+package admin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry-incubator/garden-linux/orphans"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// recordFileName is the snapshot the persistent container repository keeps
+// in each depot directory.
+const recordFileName = "snapshot.json"
+
+// Container is what the depot and the snapshots say about a container.
+type Container struct {
+	ID string
+
+	// empty when there is no snapshot of the container
+	Handle string
+	State  string
+
+	InDepot bool
+
+	// the path of the snapshot the container was read from, if any
+	Snapshot      string
+	SnapshotError error
+
+	RootFSProvider string
+
+	UserUID uint32
+	RootUID uint32
+
+	Bridge      string
+	Subnet      string
+	ContainerIP string
+	Ports       []uint32
+
+	FilterChain string
+	NATChain    string
+
+	// the container's cgroup in each subsystem that has one
+	CgroupPaths []string
+}
+
+type OrphanKind string
+
+const (
+	OrphanBridge = OrphanKind("bridge")
+	OrphanChain  = OrphanKind("iptables-chain")
+	OrphanCgroup = OrphanKind("cgroup")
+)
+
+// Orphan is a host resource garden-linux created for a container that no
+// longer exists.
+type Orphan struct {
+	Kind OrphanKind
+
+	// the bridge or chain name, or the cgroup path
+	Name string
+
+	// the iptables table the chain is in
+	Table string
+}
+
+// Inspector reads the state garden-linux leaves on the host, so that it can
+// be checked (and repaired) without a running daemon.
+type Inspector struct {
+	depotPath     string
+	snapshotsPath string
+	config        sysconfig.Config
+
+	runner  command_runner.CommandRunner
+	links   bridgemgr.Lister
+	bridges bridgemgr.Builder
+}
+
+func New(depotPath, snapshotsPath string, config sysconfig.Config, runner command_runner.CommandRunner, links bridgemgr.Lister, bridges bridgemgr.Builder) *Inspector {
+	return &Inspector{
+		depotPath:     depotPath,
+		snapshotsPath: snapshotsPath,
+		config:        config,
+
+		runner:  runner,
+		links:   links,
+		bridges: bridges,
+	}
+}
+
+// BridgePrefix is the prefix of the name of every bridge garden-linux
+// creates.
+func (i *Inspector) BridgePrefix() string {
+	return "w" + i.config.Tag + "b-"
+}
+
+// Containers returns every container found in the depot or the snapshots,
+// sorted by ID.
+func (i *Inspector) Containers() ([]Container, error) {
+	depotIDs, err := i.depotIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotIDs, err := i.snapshotIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for id := range depotIDs {
+		ids = append(ids, id)
+	}
+
+	for id := range snapshotIDs {
+		if !depotIDs[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	containers := []Container{}
+	for _, id := range ids {
+		containers = append(containers, i.container(id, depotIDs[id], snapshotIDs[id]))
+	}
+
+	return containers, nil
+}
+
+func (i *Inspector) container(id string, inDepot, hasSnapshot bool) Container {
+	container := Container{
+		ID:      id,
+		InDepot: inDepot,
+
+		FilterChain: i.config.IPTables.Filter.InstancePrefix + id,
+		NATChain:    i.config.IPTables.NAT.InstancePrefix + id,
+
+		CgroupPaths: i.cgroupPaths(id),
+	}
+
+	switch {
+	case hasSnapshot:
+		container.Snapshot = path.Join(i.snapshotsPath, id)
+	case inDepot && exists(path.Join(i.depotPath, id, recordFileName)):
+		container.Snapshot = path.Join(i.depotPath, id, recordFileName)
+	}
+
+	if container.Snapshot != "" {
+		container.SnapshotError = readSnapshot(container.Snapshot, &container)
+	}
+
+	if !inDepot {
+		return container
+	}
+
+	if provider, err := ioutil.ReadFile(path.Join(i.depotPath, id, "rootfs-provider")); err == nil {
+		container.RootFSProvider = string(provider)
+	}
+
+	// the depot records the bridge the container is actually attached to
+	if bridge, err := ioutil.ReadFile(path.Join(i.depotPath, id, "bridge-name")); err == nil {
+		container.Bridge = string(bridge)
+	}
+
+	return container
+}
+
+func readSnapshot(snapshotPath string, container *Container) error {
+	file, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	snapshot, err := linux_container.DecodeSnapshot(file)
+	if err != nil {
+		return err
+	}
+
+	container.Handle = snapshot.Handle
+	container.State = snapshot.State
+
+	resources := snapshot.Resources
+	container.UserUID = resources.UserUID
+	container.RootUID = resources.RootUID
+	container.Bridge = resources.Bridge
+	container.Ports = resources.Ports
+
+	if resources.Network != nil {
+		container.Subnet = resources.Network.Subnet.String()
+		container.ContainerIP = resources.Network.IP.String()
+	}
+
+	return nil
+}
+
+// Orphans returns the bridges, instance iptables chains and cgroups that
+// belong to no container.
+func (i *Inspector) Orphans() ([]Orphan, error) {
+	containers, err := i.Containers()
+	if err != nil {
+		return nil, err
+	}
+
+	depotIDs, err := i.depotIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, err := i.orphanBridges(containers)
+	if err != nil {
+		return nil, err
+	}
+
+	chains, err := i.orphanChains(depotIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans = append(orphans, chains...)
+
+	cgroups, err := i.orphanCgroups(depotIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(orphans, cgroups...), nil
+}
+
+func (i *Inspector) orphanBridges(containers []Container) ([]Orphan, error) {
+	owned := map[string]bool{}
+	for _, container := range containers {
+		owned[container.Bridge] = true
+	}
+
+	links, err := i.links.List()
+	if err != nil {
+		return nil, fmt.Errorf("admin: listing links: %v", err)
+	}
+
+	sort.Strings(links)
+
+	orphans := []Orphan{}
+	for _, link := range links {
+		if strings.HasPrefix(link, i.BridgePrefix()) && !owned[link] {
+			orphans = append(orphans, Orphan{Kind: OrphanBridge, Name: link})
+		}
+	}
+
+	return orphans, nil
+}
+
+func (i *Inspector) orphanChains(depotIDs map[string]bool) ([]Orphan, error) {
+	chains, err := orphans.Chains(i.runner, i.config, depotIDs)
+	if err != nil {
+		return nil, fmt.Errorf("admin: %v", err)
+	}
+
+	found := []Orphan{}
+	for _, chain := range chains {
+		found = append(found, Orphan{Kind: OrphanChain, Name: chain.Name, Table: string(chain.Table)})
+	}
+
+	return found, nil
+}
+
+func (i *Inspector) orphanCgroups(depotIDs map[string]bool) ([]Orphan, error) {
+	cgroupPaths, err := orphans.Cgroups(i.config.CgroupPath, depotIDs)
+	if err != nil {
+		return nil, fmt.Errorf("admin: %v", err)
+	}
+
+	found := []Orphan{}
+	for _, cgroupPath := range cgroupPaths {
+		found = append(found, Orphan{Kind: OrphanCgroup, Name: cgroupPath})
+	}
+
+	return found, nil
+}
+
+// Remove cleans up an orphan.
+func (i *Inspector) Remove(orphan Orphan) error {
+	switch orphan.Kind {
+	case OrphanBridge:
+		return i.bridges.Destroy(orphan.Name)
+	case OrphanChain:
+		return iptables.DeleteChain(i.runner, iptables.Type(orphan.Table), orphan.Name)
+	case OrphanCgroup:
+		return cgroups_manager.Remove(orphan.Name)
+	default:
+		return fmt.Errorf("admin: unknown orphan kind: %s", orphan.Kind)
+	}
+}
+
+func (i *Inspector) cgroupPaths(id string) []string {
+	paths, _ := filepath.Glob(path.Join(i.config.CgroupPath, "*", "instance-"+id))
+	return paths
+}
+
+func (i *Inspector) depotIDs() (map[string]bool, error) {
+	ids, err := orphans.DepotIDs(i.depotPath)
+	if err != nil {
+		return nil, fmt.Errorf("admin: %v", err)
+	}
+
+	return ids, nil
+}
+
+func (i *Inspector) snapshotIDs() (map[string]bool, error) {
+	ids := map[string]bool{}
+
+	if i.snapshotsPath == "" {
+		return ids, nil
+	}
+
+	entries, err := ioutil.ReadDir(i.snapshotsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ids, nil
+		}
+
+		return nil, fmt.Errorf("admin: reading snapshots: %v", err)
+	}
+
+	for _, entry := range entries {
+		// skips the quarantine directory
+		if !entry.IsDir() {
+			ids[entry.Name()] = true
+		}
+	}
+
+	return ids, nil
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
@@ -0,0 +1,286 @@
+package admin_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/cloudfoundry-incubator/garden-linux/admin"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inspector", func() {
+	var tmpdir string
+	var depotPath string
+	var snapshotsPath string
+	var config sysconfig.Config
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+	var fakeLinks *links
+	var fakeBridges *bridges
+	var inspector *admin.Inspector
+
+	filterRules := ""
+	natRules := ""
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "garden-linux-admin")
+		Expect(err).ToNot(HaveOccurred())
+
+		depotPath = path.Join(tmpdir, "depot")
+		snapshotsPath = path.Join(tmpdir, "snapshots")
+
+		Expect(os.MkdirAll(path.Join(depotPath, "tmp"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(snapshotsPath, 0755)).To(Succeed())
+
+		config = sysconfig.NewConfig("t", false)
+		config.CgroupPath = path.Join(tmpdir, "cgroup")
+
+		fakeRunner = fake_command_runner.New()
+		fakeLinks = &links{}
+		fakeBridges = &bridges{}
+
+		filterRules = ""
+		natRules = ""
+
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{"-w", "-t", "filter", "-S"},
+		}, func(cmd *exec.Cmd) error {
+			cmd.Stdout.Write([]byte(filterRules))
+			return nil
+		})
+
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{"-w", "-t", "nat", "-S"},
+		}, func(cmd *exec.Cmd) error {
+			cmd.Stdout.Write([]byte(natRules))
+			return nil
+		})
+	})
+
+	JustBeforeEach(func() {
+		inspector = admin.New(depotPath, snapshotsPath, config, fakeRunner, fakeLinks, fakeBridges)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	writeFile := func(contents string, elem ...string) {
+		p := path.Join(elem...)
+		Expect(os.MkdirAll(path.Dir(p), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(p, []byte(contents), 0644)).To(Succeed())
+	}
+
+	Describe("Containers", func() {
+		BeforeEach(func() {
+			writeFile("docker", depotPath, "depot-id", "rootfs-provider")
+			writeFile("wtb-depot", depotPath, "depot-id", "bridge-name")
+			writeFile(`{
+				"Version": 2,
+				"Handle": "depot-handle",
+				"State": "active",
+				"Resources": {
+					"UserUID": 10001,
+					"RootUID": 0,
+					"Network": {"IP": "10.254.0.2", "Subnet": "10.254.0.0/30"},
+					"Bridge": "wtb-stale",
+					"Ports": [61001, 61002]
+				}
+			}`, depotPath, "depot-id", "snapshot.json")
+
+			writeFile(`{"Handle": "snapshot-handle"}`, snapshotsPath, "snapshot-id")
+			writeFile("{", snapshotsPath, "broken-id")
+
+			Expect(os.MkdirAll(path.Join(config.CgroupPath, "cpu", "instance-depot-id"), 0755)).To(Succeed())
+			Expect(os.MkdirAll(path.Join(config.CgroupPath, "memory", "instance-depot-id"), 0755)).To(Succeed())
+		})
+
+		It("returns every container in the depot or the snapshots, by ID", func() {
+			containers, err := inspector.Containers()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(containers).To(HaveLen(3))
+			Expect(containers[0].ID).To(Equal("broken-id"))
+			Expect(containers[1].ID).To(Equal("depot-id"))
+			Expect(containers[2].ID).To(Equal("snapshot-id"))
+		})
+
+		It("describes the resources each one holds", func() {
+			containers, err := inspector.Containers()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(containers[1]).To(Equal(admin.Container{
+				ID:     "depot-id",
+				Handle: "depot-handle",
+				State:  "active",
+
+				InDepot:  true,
+				Snapshot: path.Join(depotPath, "depot-id", "snapshot.json"),
+
+				RootFSProvider: "docker",
+
+				UserUID: 10001,
+				RootUID: 0,
+
+				Bridge:      "wtb-depot",
+				Subnet:      "10.254.0.0/30",
+				ContainerIP: "10.254.0.2",
+				Ports:       []uint32{61001, 61002},
+
+				FilterChain: "w-t-instance-depot-id",
+				NATChain:    "w-t-instance-depot-id",
+
+				CgroupPaths: []string{
+					path.Join(config.CgroupPath, "cpu", "instance-depot-id"),
+					path.Join(config.CgroupPath, "memory", "instance-depot-id"),
+				},
+			}))
+
+			Expect(containers[2].InDepot).To(BeFalse())
+			Expect(containers[2].Handle).To(Equal("snapshot-handle"))
+			Expect(containers[2].Snapshot).To(Equal(path.Join(snapshotsPath, "snapshot-id")))
+		})
+
+		It("reports snapshots that cannot be read", func() {
+			containers, err := inspector.Containers()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(containers[0].SnapshotError).To(HaveOccurred())
+		})
+
+		Context("when the depot cannot be read", func() {
+			BeforeEach(func() {
+				depotPath = path.Join(tmpdir, "bogus")
+			})
+
+			It("returns an error", func() {
+				_, err := inspector.Containers()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Orphans", func() {
+		BeforeEach(func() {
+			writeFile("wtb-owned", depotPath, "live-id", "bridge-name")
+
+			fakeLinks.Names = []string{"eth0", "wtb-owned", "wtb-orphaned", "wotherb-foo"}
+
+			filterRules = `-P FORWARD ACCEPT
+-N w-t-forward
+-N w-t-instance-live-id
+-N w-t-instance-live-id-log
+-N w-t-instance-dead-id
+-N w-t-instance-dead-id-log
+-A w-t-forward -i wtb-orphaned -s 10.254.0.6/32 -g w-t-instance-dead-id
+-A w-t-instance-dead-id -g w-t-instance-dead-id-log
+`
+			natRules = `-N w-t-prerouting
+-N w-t-instance-dead-id
+-A w-t-prerouting -j w-t-instance-dead-id
+`
+
+			Expect(os.MkdirAll(path.Join(config.CgroupPath, "cpu", "instance-live-id"), 0755)).To(Succeed())
+			Expect(os.MkdirAll(path.Join(config.CgroupPath, "cpu", "instance-dead-id", "nested"), 0755)).To(Succeed())
+		})
+
+		It("returns the bridges, chains and cgroups of no container", func() {
+			orphans, err := inspector.Orphans()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(orphans).To(Equal([]admin.Orphan{
+				{Kind: admin.OrphanBridge, Name: "wtb-orphaned"},
+				{Kind: admin.OrphanChain, Name: "w-t-instance-dead-id", Table: "filter"},
+				{Kind: admin.OrphanChain, Name: "w-t-instance-dead-id-log", Table: "filter"},
+				{Kind: admin.OrphanChain, Name: "w-t-instance-dead-id", Table: "nat"},
+				{Kind: admin.OrphanCgroup, Name: path.Join(config.CgroupPath, "cpu", "instance-dead-id")},
+			}))
+		})
+
+		Context("when a bridge is only known from a snapshot", func() {
+			BeforeEach(func() {
+				writeFile(`{"Resources": {"Bridge": "wtb-orphaned"}}`, snapshotsPath, "snapshot-id")
+			})
+
+			It("is not an orphan", func() {
+				orphans, err := inspector.Orphans()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(orphans).ToNot(ContainElement(admin.Orphan{Kind: admin.OrphanBridge, Name: "wtb-orphaned"}))
+			})
+		})
+
+		Describe("removing them", func() {
+			It("destroys bridges", func() {
+				err := inspector.Remove(admin.Orphan{Kind: admin.OrphanBridge, Name: "wtb-orphaned"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeBridges.Destroyed).To(Equal([]string{"wtb-orphaned"}))
+			})
+
+			It("deletes chains, after the rules jumping to them", func() {
+				err := inspector.Remove(admin.Orphan{Kind: admin.OrphanChain, Name: "w-t-instance-dead-id", Table: "filter"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "filter", "-D", "w-t-forward", "-i", "wtb-orphaned", "-s", "10.254.0.6/32", "-g", "w-t-instance-dead-id"},
+					},
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "filter", "-F", "w-t-instance-dead-id"},
+					},
+					fake_command_runner.CommandSpec{
+						Path: "/sbin/iptables",
+						Args: []string{"-w", "-t", "filter", "-X", "w-t-instance-dead-id"},
+					},
+				))
+			})
+
+			It("removes cgroups, nested ones first", func() {
+				cgroupPath := path.Join(config.CgroupPath, "cpu", "instance-dead-id")
+
+				err := inspector.Remove(admin.Orphan{Kind: admin.OrphanCgroup, Name: cgroupPath})
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = os.Stat(cgroupPath)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+
+				_, err = os.Stat(path.Join(config.CgroupPath, "cpu", "instance-live-id"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	})
+})
+
+type links struct {
+	Names []string
+}
+
+func (l *links) List() ([]string, error) {
+	return l.Names, nil
+}
+
+type bridges struct {
+	Destroyed []string
+}
+
+func (b *bridges) Create(name string, ip net.IP, subnet *net.IPNet) (*net.Interface, error) {
+	return nil, nil
+}
+
+func (b *bridges) Destroy(name string) error {
+	b.Destroyed = append(b.Destroyed, name)
+	return nil
+}
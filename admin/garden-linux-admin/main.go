@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry-incubator/garden-linux/admin"
+	"github.com/cloudfoundry-incubator/garden-linux/network/devices"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry/gunk/command_runner/linux_command_runner"
+)
+
+const USAGE = `usage:
+
+	garden-linux-admin [flags] containers:
+		list the containers in the depot and the snapshots, and the resources
+		each one holds
+
+	garden-linux-admin [flags] orphans [-fix]:
+		list the bridges, instance iptables chains and cgroups that belong to
+		no container, removing them with -fix
+
+flags:
+`
+
+var depotPath = flag.String(
+	"depot",
+	"",
+	"directory in which containers are stored",
+)
+
+var snapshotsPath = flag.String(
+	"snapshots",
+	"",
+	"directory in which container snapshots are saved",
+)
+
+var tag = flag.String(
+	"tag",
+	"",
+	"the server's -tag, which bridges, chains and cgroups are named with",
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *depotPath == "" {
+		fmt.Fprintln(os.Stderr, "missing -depot")
+		usage()
+	}
+
+	inspector := admin.New(
+		*depotPath,
+		*snapshotsPath,
+		sysconfig.NewConfig(*tag, false),
+		linux_command_runner.New(),
+		devices.Link{},
+		&devices.Bridge{},
+	)
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	switch args[0] {
+	case "containers":
+		listContainers(inspector)
+
+	case "orphans":
+		orphans := flag.NewFlagSet("orphans", flag.ExitOnError)
+		fix := orphans.Bool("fix", false, "remove the orphans")
+		orphans.Parse(args[1:])
+
+		listOrphans(inspector, *fix)
+
+	default:
+		usage()
+	}
+}
+
+func listContainers(inspector *admin.Inspector) {
+	containers, err := inspector.Containers()
+	if err != nil {
+		fail(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+
+	for _, c := range containers {
+		fmt.Fprintf(w, "%s\n", c.ID)
+		fmt.Fprintf(w, "\thandle:\t%s\n", c.Handle)
+		fmt.Fprintf(w, "\tstate:\t%s\n", c.State)
+		fmt.Fprintf(w, "\tin depot:\t%t\n", c.InDepot)
+
+		if c.SnapshotError != nil {
+			fmt.Fprintf(w, "\tsnapshot:\t%s (unreadable: %s)\n", c.Snapshot, c.SnapshotError)
+		} else {
+			fmt.Fprintf(w, "\tsnapshot:\t%s\n", c.Snapshot)
+		}
+
+		fmt.Fprintf(w, "\trootfs provider:\t%s\n", c.RootFSProvider)
+		fmt.Fprintf(w, "\tuids:\tuser %d, root %d\n", c.UserUID, c.RootUID)
+		fmt.Fprintf(w, "\tsubnet:\t%s\n", c.Subnet)
+		fmt.Fprintf(w, "\tip:\t%s\n", c.ContainerIP)
+		fmt.Fprintf(w, "\tbridge:\t%s\n", c.Bridge)
+		fmt.Fprintf(w, "\tports:\t%s\n", strings.Trim(fmt.Sprint(c.Ports), "[]"))
+		fmt.Fprintf(w, "\tchains:\t%s (filter), %s (nat)\n", c.FilterChain, c.NATChain)
+		fmt.Fprintf(w, "\tcgroups:\t%s\n", strings.Join(c.CgroupPaths, ", "))
+	}
+
+	w.Flush()
+}
+
+func listOrphans(inspector *admin.Inspector, fix bool) {
+	orphans, err := inspector.Orphans()
+	if err != nil {
+		fail(err)
+	}
+
+	failed := false
+
+	for _, orphan := range orphans {
+		name := orphan.Name
+		if orphan.Table != "" {
+			name += " (" + orphan.Table + ")"
+		}
+
+		if !fix {
+			fmt.Printf("%s\t%s\n", orphan.Kind, name)
+			continue
+		}
+
+		err := inspector.Remove(orphan)
+		if err != nil {
+			fmt.Printf("%s\t%s\tfailed to remove: %s\n", orphan.Kind, name, err)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("%s\t%s\tremoved\n", orphan.Kind, name)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, USAGE)
+	flag.PrintDefaults()
+	os.Exit(1)
+}
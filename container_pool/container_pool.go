@@ -21,6 +21,7 @@ import (
 
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	"github.com/cloudfoundry-incubator/garden-linux/metrics_exporter"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
 	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
 	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
@@ -37,6 +38,17 @@ import (
 )
 
 var ErrUnknownRootFSProvider = errors.New("unknown rootfs provider")
+var ErrNoUniqueContainerID = errors.New("could not generate a unique container ID")
+
+// BindMountOptionsProperty is the property that holds the options of the
+// spec's bind mounts, as a JSON object from the destination path of each
+// mount that has options to a comma-separated list of them: "rbind" to bind
+// mount recursively, and at most one of linux_backend.BindMountPropagations,
+// e.g. {"/dst/path": "rbind,rslave"}. Every destination path must be that of
+// one of the spec's bind mounts.
+const BindMountOptionsProperty = "garden.bind-mount-options"
+
+const maxContainerIDAttempts = 10
 
 //go:generate counterfeiter -o fake_container_pool/FakeFilterProvider.go . FilterProvider
 type FilterProvider interface {
@@ -81,12 +93,21 @@ type LinuxContainerPool struct {
 
 	quotaManager quota_manager.QuotaManager
 
-	containerIDs chan string
+	idGenerator   IDGenerator
+	snapshotsPath string
+
+	// empty if checkpointing is disabled
+	criuPath string
+
+	createLatency  *metrics_exporter.Histogram
+	destroyLatency *metrics_exporter.Histogram
+
+	releaseFailures *metrics_exporter.Counter
 }
 
 func New(
 	logger lager.Logger,
-	binPath, depotPath string,
+	binPath, depotPath, snapshotsPath string,
 	sysconfig sysconfig.Config,
 	rootfsProviders map[string]rootfs_provider.RootFSProvider,
 	uidPool uid_pool.UIDPool,
@@ -100,6 +121,8 @@ func New(
 	denyNetworks, allowNetworks []string,
 	runner command_runner.CommandRunner,
 	quotaManager quota_manager.QuotaManager,
+	idGenerator IDGenerator,
+	criuPath string,
 ) *LinuxContainerPool {
 	pool := &LinuxContainerPool{
 		logger: logger.Session("pool"),
@@ -132,14 +155,44 @@ func New(
 
 		quotaManager: quotaManager,
 
-		containerIDs: make(chan string),
-	}
+		idGenerator:   idGenerator,
+		snapshotsPath: snapshotsPath,
+
+		criuPath: criuPath,
 
-	go pool.generateContainerIDs()
+		createLatency: metrics_exporter.NewHistogram(
+			"garden_container_create_duration_seconds",
+			"Time taken to create a container.",
+			metrics_exporter.LatencyBuckets,
+		),
+		destroyLatency: metrics_exporter.NewHistogram(
+			"garden_container_destroy_duration_seconds",
+			"Time taken to destroy a container.",
+			metrics_exporter.LatencyBuckets,
+		),
+
+		releaseFailures: metrics_exporter.NewCounter(
+			"garden_container_pool_release_failures",
+			"Number of container resources that could not be released when destroying a container or rolling back a failed create or restore, and so may have leaked.",
+			"resource",
+		),
+	}
 
 	return pool
 }
 
+// LatencyHistograms returns the histograms of the time taken by successful
+// creates and destroys.
+func (p *LinuxContainerPool) LatencyHistograms() []*metrics_exporter.Histogram {
+	return []*metrics_exporter.Histogram{p.createLatency, p.destroyLatency}
+}
+
+// Counters returns the counter of the resources that could not be released,
+// by resource.
+func (p *LinuxContainerPool) Counters() []*metrics_exporter.Counter {
+	return []*metrics_exporter.Counter{p.releaseFailures}
+}
+
 func (p *LinuxContainerPool) MaxContainers() int {
 	maxNet := p.subnetPool.Capacity()
 	maxUid := p.uidPool.InitialSize()
@@ -233,25 +286,44 @@ func (p *LinuxContainerPool) pruneEntry(id string) {
 }
 
 func (p *LinuxContainerPool) Create(spec garden.ContainerSpec) (c linux_backend.Container, err error) {
-	id := <-p.containerIDs
+	if err := p.validateSpec(spec); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	defer func() {
+		if err == nil {
+			p.createLatency.ObserveSince(start)
+		}
+	}()
+
+	id, err := p.generateContainerID()
+	if err != nil {
+		return nil, err
+	}
+
 	containerPath := path.Join(p.depotPath, id)
 	pLog := p.logger.Session(id)
 
 	pLog.Info("creating")
 
-	resources, err := p.acquirePoolResources(spec, id)
+	txn := newJournal(pLog.Session("rollback"), p.releaseFailures)
+	defer txn.rollbackOnError(&err)
+
+	txn.record("depot-dir", func() error {
+		return os.RemoveAll(containerPath)
+	})
+
+	resources, err := p.acquirePoolResources(txn, spec, id)
 	if err != nil {
 		return nil, err
 	}
-	defer cleanup(&err, func() {
-		p.releasePoolResources(resources)
-	})
 
 	pLog.Info("acquired-pool-resources")
 
 	handle := getHandle(spec.Handle, id)
 
-	rootFSEnv, err := p.acquireSystemResources(id, handle, containerPath, spec.RootFSPath, resources, spec.BindMounts, pLog)
+	rootFSEnv, err := p.acquireSystemResources(txn, id, handle, containerPath, spec.RootFSPath, resources, spec.BindMounts, spec.Properties, pLog)
 	if err != nil {
 		return nil, err
 	}
@@ -283,9 +355,122 @@ func (p *LinuxContainerPool) Create(spec garden.ContainerSpec) (c linux_backend.
 		process_tracker.New(containerPath, p.runner),
 		rootFSEnv.Merge(specEnv),
 		p.filterProvider.ProvideFilter(id),
+		p.criuPath,
+	), nil
+}
+
+// prebuiltContainer is a container that has been created, but not yet handed
+// out by Create: it has no handle, properties or environment of its own.
+type prebuiltContainer struct {
+	id        string
+	resources *linux_backend.Resources
+	rootFSEnv process.Env
+	builtAt   time.Time
+}
+
+// prebuild creates an unprivileged container with the given rootfs and the
+// default network, to be handed out later by adopt.
+func (p *LinuxContainerPool) prebuild(rootFSPath string) (pc *prebuiltContainer, err error) {
+	id, err := p.generateContainerID()
+	if err != nil {
+		return nil, err
+	}
+
+	containerPath := path.Join(p.depotPath, id)
+	pLog := p.logger.Session("prebuild", lager.Data{
+		"id":     id,
+		"rootfs": rootFSPath,
+	})
+
+	pLog.Info("creating")
+
+	spec := garden.ContainerSpec{RootFSPath: rootFSPath}
+
+	txn := newJournal(pLog.Session("rollback"), p.releaseFailures)
+	defer txn.rollbackOnError(&err)
+
+	txn.record("depot-dir", func() error {
+		return os.RemoveAll(containerPath)
+	})
+
+	resources, err := p.acquirePoolResources(txn, spec, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rootFSEnv, err := p.acquireSystemResources(txn, id, id, containerPath, rootFSPath, resources, nil, nil, pLog)
+	if err != nil {
+		return nil, err
+	}
+
+	pLog.Info("created")
+
+	return &prebuiltContainer{
+		id:        id,
+		resources: resources,
+		rootFSEnv: rootFSEnv,
+	}, nil
+}
+
+// adopt turns a prebuilt container into one for the given spec, which must
+// not ask for anything the container was not built with.
+func (p *LinuxContainerPool) adopt(pc *prebuiltContainer, spec garden.ContainerSpec) (linux_backend.Container, error) {
+	containerPath := path.Join(p.depotPath, pc.id)
+	pLog := p.logger.Session(pc.id)
+
+	handle := getHandle(spec.Handle, pc.id)
+
+	specEnv, err := process.NewEnv(spec.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	// the filter was set up to log with the container's ID
+	if err := p.filterProvider.ProvideFilter(pc.id).Setup(handle); err != nil {
+		pLog.Error("set-up-filter-failed", err)
+		return nil, fmt.Errorf("container_pool: set up filter: %v", err)
+	}
+
+	pLog.Info("adopted", lager.Data{
+		"handle": handle,
+	})
+
+	return linux_container.NewLinuxContainer(
+		pLog,
+		pc.id,
+		handle,
+		containerPath,
+		spec.Properties,
+		spec.GraceTime,
+		pc.resources,
+		p.portPool,
+		p.runner,
+		cgroups_manager.New(p.sysconfig.CgroupPath, pc.id),
+		p.quotaManager,
+		bandwidth_manager.New(containerPath, pc.id, p.runner),
+		process_tracker.New(containerPath, p.runner),
+		pc.rootFSEnv.Merge(specEnv),
+		p.filterProvider.ProvideFilter(pc.id),
+		p.criuPath,
 	), nil
 }
 
+// discard destroys a prebuilt container that will not be handed out.
+func (p *LinuxContainerPool) discard(pc *prebuiltContainer) error {
+	pLog := p.logger.Session("discard", lager.Data{
+		"id": pc.id,
+	})
+
+	err := p.releaseSystemResources(pLog, pc.id)
+	if err != nil {
+		return err
+	}
+
+	p.releasePoolResources(pc.resources)
+
+	return nil
+}
+
 func (p *LinuxContainerPool) releaseUIDs(userUID, rootUID uint32) {
 	if userUID != 0 {
 		p.uidPool.Release(userUID)
@@ -296,10 +481,8 @@ func (p *LinuxContainerPool) releaseUIDs(userUID, rootUID uint32) {
 	}
 }
 
-func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Container, error) {
-	var containerSnapshot linux_container.ContainerSnapshot
-
-	err := json.NewDecoder(snapshot).Decode(&containerSnapshot)
+func (p *LinuxContainerPool) Restore(snapshot io.Reader) (c linux_backend.Container, err error) {
+	containerSnapshot, err := linux_container.DecodeSnapshot(snapshot)
 	if err != nil {
 		return nil, err
 	}
@@ -314,41 +497,40 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 
 	resources := containerSnapshot.Resources
 
-	err = p.uidPool.Remove(resources.UserUID)
-	if err != nil {
+	txn := newJournal(rLog.Session("rollback"), p.releaseFailures)
+	defer txn.rollbackOnError(&err)
+
+	if err = p.uidPool.Remove(resources.UserUID); err != nil {
 		return nil, err
 	}
+	txn.record("user-uid", p.uidReleaser(resources.UserUID))
 
 	if resources.RootUID != 0 {
-		err = p.uidPool.Remove(resources.RootUID)
-		if err != nil {
+		if err = p.uidPool.Remove(resources.RootUID); err != nil {
 			return nil, err
 		}
+		txn.record("root-uid", p.uidReleaser(resources.RootUID))
 	}
 
 	if err = p.subnetPool.Remove(resources.Network); err != nil {
-		p.releaseUIDs(resources.UserUID, resources.RootUID)
 		return nil, err
 	}
+	txn.record("subnet", func() error {
+		return p.subnetPool.Release(resources.Network)
+	})
 
 	if err = p.bridges.Rereserve(resources.Bridge, resources.Network.Subnet, id); err != nil {
-		p.releaseUIDs(resources.UserUID, resources.RootUID)
-		p.subnetPool.Release(resources.Network)
 		return nil, err
 	}
+	txn.record("bridge", func() error {
+		return p.bridges.Release(resources.Bridge, id)
+	})
 
 	for _, port := range resources.Ports {
-		err = p.portPool.Remove(port)
-		if err != nil {
-			p.releaseUIDs(resources.UserUID, resources.RootUID)
-			p.subnetPool.Release(resources.Network)
-
-			for _, port := range resources.Ports {
-				p.portPool.Release(port)
-			}
-
+		if err = p.portPool.Remove(port); err != nil {
 			return nil, err
 		}
+		txn.record("port", p.portReleaser(port))
 	}
 
 	containerPath := path.Join(p.depotPath, id)
@@ -387,8 +569,16 @@ func (p *LinuxContainerPool) Restore(snapshot io.Reader) (linux_backend.Containe
 		process_tracker.New(containerPath, p.runner),
 		containerEnv,
 		p.filterProvider.ProvideFilter(id),
+		p.criuPath,
 	)
 
+	if linux_container.State(containerSnapshot.State) == linux_container.StateCheckpointed {
+		// the rootfs does not survive a reboot, or a move to another host
+		if err = p.reprovideRootFS(rLog, id); err != nil {
+			return nil, err
+		}
+	}
+
 	err = container.Restore(containerSnapshot)
 	if err != nil {
 		return nil, err
@@ -406,77 +596,157 @@ func (p *LinuxContainerPool) Destroy(container linux_backend.Container) error {
 
 	pLog.Info("destroying")
 
-	err := p.releaseSystemResources(pLog, container.ID())
+	start := time.Now()
+
+	linuxContainer := container.(*linux_container.LinuxContainer)
+
+	// kill the container's processes before its bridge is released from
+	// under them; this also thaws a paused container, whose frozen wshd
+	// would otherwise not die when destroy.sh kills it
+	err := linuxContainer.KillProcesses()
+	if err != nil {
+		pLog.Error("failed-to-kill-processes", err)
+	}
+
+	err = p.releaseSystemResources(pLog, container.ID())
 	if err != nil {
 		return err
 	}
 
-	linuxContainer := container.(*linux_container.LinuxContainer)
 	resources := linuxContainer.Resources()
 	p.releasePoolResources(resources)
 
+	p.destroyLatency.ObserveSince(start)
+
 	pLog.Info("destroyed")
 
 	return nil
 }
 
-func (p *LinuxContainerPool) generateContainerIDs() string {
-	for containerNum := time.Now().UnixNano(); ; containerNum++ {
-		containerID := []byte{}
+// generateContainerID generates IDs until it finds one that is not used by a
+// container in the depot or a snapshot, which is possible e.g. after the
+// clock has stepped backwards. It reserves the ID by creating the container's
+// depot directory, which the caller must remove if it fails to create the
+// container.
+func (p *LinuxContainerPool) generateContainerID() (string, error) {
+	for attempt := 0; attempt < maxContainerIDAttempts; attempt++ {
+		id, err := p.idGenerator.Generate()
+		if err != nil {
+			return "", err
+		}
 
-		var i uint
-		for i = 0; i < 11; i++ {
-			containerID = strconv.AppendInt(
-				containerID,
-				(containerNum>>(55-(i+1)*5))&31,
-				32,
-			)
+		if !p.containerIDInSnapshots(id) {
+			err = os.Mkdir(path.Join(p.depotPath, id), 0755)
+			if err == nil {
+				return id, nil
+			}
+
+			if !os.IsExist(err) {
+				return "", fmt.Errorf("containerpool: creating container directory: %v", err)
+			}
 		}
 
-		p.containerIDs <- string(containerID)
+		p.logger.Info("container-id-collision", lager.Data{
+			"id": id,
+		})
+	}
+
+	return "", ErrNoUniqueContainerID
+}
+
+func (p *LinuxContainerPool) containerIDInSnapshots(id string) bool {
+	if p.snapshotsPath == "" {
+		return false
+	}
+
+	used := []string{
+		path.Join(p.snapshotsPath, id),
+		path.Join(p.snapshotsPath, "quarantine", id),
 	}
+
+	for _, usedPath := range used {
+		if _, err := os.Lstat(usedPath); err == nil {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (p *LinuxContainerPool) writeBindMounts(containerPath string,
 	rootfsPath string,
-	bindMounts []garden.BindMount) error {
-	hook := path.Join(containerPath, "lib", "hook-parent-before-clone.sh")
+	bindMounts []garden.BindMount,
+	properties garden.Properties) error {
+	options, err := parseBindMountOptions(properties)
+	if err != nil {
+		return err
+	}
+
+	mounts := []linux_backend.BindMount{}
 
 	for _, bm := range bindMounts {
-		dstMount := path.Join(rootfsPath, bm.DstPath)
 		srcPath := bm.SrcPath
 
 		if bm.Origin == garden.BindMountOriginContainer {
 			srcPath = path.Join(rootfsPath, srcPath)
 		}
 
-		mode := "ro"
-		if bm.Mode == garden.BindMountModeRW {
-			mode = "rw"
+		mount := linux_backend.BindMount{
+			SrcPath:  srcPath,
+			DstPath:  path.Join(rootfsPath, bm.DstPath),
+			ReadOnly: bm.Mode != garden.BindMountModeRW,
 		}
 
-		linebreak := exec.Command("bash", "-c", "echo >> "+hook)
-		err := p.runner.Run(linebreak)
-		if err != nil {
+		if err := applyBindMountOptions(&mount, options[bm.DstPath]); err != nil {
 			return err
 		}
 
-		mkdir := exec.Command("bash", "-c", "echo mkdir -p "+dstMount+" >> "+hook)
-		err = p.runner.Run(mkdir)
-		if err != nil {
+		if err := mount.Validate(); err != nil {
 			return err
 		}
 
-		mount := exec.Command("bash", "-c", "echo mount -n --bind "+srcPath+" "+dstMount+" >> "+hook)
-		err = p.runner.Run(mount)
-		if err != nil {
-			return err
-		}
+		mounts = append(mounts, mount)
+	}
 
-		remount := exec.Command("bash", "-c", "echo mount -n --bind -o remount,"+mode+" "+srcPath+" "+dstMount+" >> "+hook)
-		err = p.runner.Run(remount)
-		if err != nil {
-			return err
+	return linux_backend.WriteBindMounts(containerPath, mounts)
+}
+
+// parseBindMountOptions returns the options of the spec's bind mounts, by
+// destination path; see BindMountOptionsProperty.
+func parseBindMountOptions(properties garden.Properties) (map[string]string, error) {
+	options := map[string]string{}
+
+	value, found := properties[BindMountOptionsProperty]
+	if !found {
+		return options, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &options)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s property: %v", BindMountOptionsProperty, err)
+	}
+
+	return options, nil
+}
+
+// applyBindMountOptions applies a comma-separated list of options to the
+// mount: "rbind" to bind mount recursively, and anything else as the
+// propagation type, which Validate checks.
+func applyBindMountOptions(mount *linux_backend.BindMount, options string) error {
+	if options == "" {
+		return nil
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		switch {
+		case option == "":
+			return linux_backend.InvalidBindMountError{*mount, fmt.Sprintf("empty option in %q", options)}
+		case option == "rbind":
+			mount.Recursive = true
+		case mount.Propagation != "":
+			return linux_backend.InvalidBindMountError{*mount, fmt.Sprintf("more than one propagation in %q", options)}
+		default:
+			mount.Propagation = option
 		}
 	}
 
@@ -488,12 +758,47 @@ func (p *LinuxContainerPool) saveBridgeName(id string, bridgeName string) error
 	return ioutil.WriteFile(bridgeNameFile, []byte(bridgeName), 0644)
 }
 
-func (p *LinuxContainerPool) saveRootFSProvider(id string, provider string) error {
+func (p *LinuxContainerPool) saveRootFSProvider(id string, rootfsURL *url.URL) error {
 	providerFile := path.Join(p.depotPath, id, "rootfs-provider")
-	return ioutil.WriteFile(providerFile, []byte(provider), 0644)
+	err := ioutil.WriteFile(providerFile, []byte(rootfsURL.Scheme), 0644)
+	if err != nil {
+		return err
+	}
+
+	// to provide the rootfs again when restoring a checkpoint
+	urlFile := path.Join(p.depotPath, id, "rootfs-url")
+	return ioutil.WriteFile(urlFile, []byte(rootfsURL.String()), 0644)
 }
 
-func (p *LinuxContainerPool) acquirePoolResources(spec garden.ContainerSpec, id string) (*linux_backend.Resources, error) {
+// reprovideRootFS mounts a container's rootfs again, at the same place, for
+// restoring its checkpoint after a reboot or on another host. Providers leave
+// a rootfs that is still in place alone.
+func (p *LinuxContainerPool) reprovideRootFS(logger lager.Logger, id string) error {
+	rootfsURLStr, err := ioutil.ReadFile(path.Join(p.depotPath, id, "rootfs-url"))
+	if err != nil {
+		return fmt.Errorf("containerpool: reprovide rootfs: %v", err)
+	}
+
+	rootfsURL, err := url.Parse(string(rootfsURLStr))
+	if err != nil {
+		return fmt.Errorf("containerpool: reprovide rootfs: %v", err)
+	}
+
+	provider, found := p.rootfsProviders[rootfsURL.Scheme]
+	if !found {
+		return ErrUnknownRootFSProvider
+	}
+
+	_, _, err = provider.ProvideRootFS(logger.Session("reprovide-rootfs"), id, rootfsURL)
+	if err != nil {
+		logger.Error("reprovide-rootfs-failed", err)
+		return err
+	}
+
+	return nil
+}
+
+func (p *LinuxContainerPool) acquirePoolResources(txn *journal, spec garden.ContainerSpec, id string) (*linux_backend.Resources, error) {
 	resources := linux_backend.NewResources(0, 1, nil, "", nil, p.externalIP)
 
 	subnet, ip, err := parseNetworkSpec(spec.Network)
@@ -501,25 +806,28 @@ func (p *LinuxContainerPool) acquirePoolResources(spec garden.ContainerSpec, id
 		return nil, fmt.Errorf("create container: invalid network spec: %v", err)
 	}
 
-	if err := p.acquireUID(resources, spec.Privileged); err != nil {
+	if err := p.acquireUID(txn, resources, spec.Privileged); err != nil {
 		return nil, err
 	}
 
 	if resources.Network, err = p.subnetPool.Acquire(subnet, ip); err != nil {
-		p.releasePoolResources(resources)
 		return nil, err
 	}
+	txn.record("subnet", func() error {
+		return p.subnetPool.Release(resources.Network)
+	})
 
 	return resources, nil
 }
 
-func (p *LinuxContainerPool) acquireUID(resources *linux_backend.Resources, privileged bool) error {
+func (p *LinuxContainerPool) acquireUID(txn *journal, resources *linux_backend.Resources, privileged bool) error {
 	var err error
 	resources.UserUID, err = p.uidPool.Acquire()
 	if err != nil {
 		p.logger.Error("uid-acquire-failed", err)
 		return err
 	}
+	txn.record("user-uid", p.uidReleaser(resources.UserUID))
 
 	resources.RootUID = 0
 	if !privileged {
@@ -528,11 +836,26 @@ func (p *LinuxContainerPool) acquireUID(resources *linux_backend.Resources, priv
 			p.logger.Error("uid-acquire-failed", err)
 			return err
 		}
+		txn.record("root-uid", p.uidReleaser(resources.RootUID))
 	}
 
 	return nil
 }
 
+func (p *LinuxContainerPool) uidReleaser(uid uint32) func() error {
+	return func() error {
+		p.uidPool.Release(uid)
+		return nil
+	}
+}
+
+func (p *LinuxContainerPool) portReleaser(port uint32) func() error {
+	return func() error {
+		p.portPool.Release(port)
+		return nil
+	}
+}
+
 func (p *LinuxContainerPool) releasePoolResources(resources *linux_backend.Resources) {
 	for _, port := range resources.Ports {
 		p.portPool.Release(port)
@@ -545,11 +868,7 @@ func (p *LinuxContainerPool) releasePoolResources(resources *linux_backend.Resou
 	}
 }
 
-func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, rootFSPath string, resources *linux_backend.Resources, bindMounts []garden.BindMount, pLog lager.Logger) (process.Env, error) {
-	if err := os.MkdirAll(containerPath, 0755); err != nil {
-		return nil, fmt.Errorf("containerpool: creating container directory: %v", err)
-	}
-
+func (p *LinuxContainerPool) acquireSystemResources(txn *journal, id, handle, containerPath, rootFSPath string, resources *linux_backend.Resources, bindMounts []garden.BindMount, properties garden.Properties, pLog lager.Logger) (process.Env, error) {
 	rootfsURL, err := url.Parse(rootFSPath)
 	if err != nil {
 		pLog.Error("parse-rootfs-path-failed", err, lager.Data{
@@ -571,6 +890,9 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 		pLog.Error("provide-rootfs-failed", err)
 		return nil, err
 	}
+	txn.record("rootfs", func() error {
+		return provider.CleanupRootFS(pLog, id)
+	})
 
 	if resources.Bridge, err = p.bridges.Reserve(resources.Network.Subnet, id); err != nil {
 		pLog.Error("reserve-bridge-failed", err, lager.Data{
@@ -579,9 +901,11 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 			"Bridge": resources.Bridge,
 		})
 
-		provider.CleanupRootFS(pLog, rootfsPath)
 		return nil, err
 	}
+	txn.record("bridge", func() error {
+		return p.bridges.Release(resources.Bridge, id)
+	})
 
 	if err = p.saveBridgeName(id, resources.Bridge); err != nil {
 		pLog.Error("save-bridge-name-failed", err, lager.Data{
@@ -589,7 +913,6 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 			"Bridge": resources.Bridge,
 		})
 
-		provider.CleanupRootFS(pLog, rootfsPath)
 		return nil, err
 	}
 
@@ -617,9 +940,12 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 		Logger:        pLog.Session("create-script"),
 	}
 
+	// create.sh may fail part way through, so destroy.sh has to run either
+	// way; if it fails, the container's rootfs, depot directory and the rest
+	// are left for Prune to clean up on the next start
 	err = pRunner.Run(create)
-	defer cleanup(&err, func() {
-		p.tryReleaseSystemResources(p.logger, id)
+	txn.recordFatal("container", func() error {
+		return pRunner.Run(exec.Command(path.Join(p.binPath, "destroy.sh"), containerPath))
 	})
 
 	if err != nil {
@@ -630,7 +956,7 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 		return nil, err
 	}
 
-	err = p.saveRootFSProvider(id, rootfsURL.Scheme)
+	err = p.saveRootFSProvider(id, rootfsURL)
 	if err != nil {
 		p.logger.Error("save-rootfs-provider-failed", err, lager.Data{
 			"Id":     id,
@@ -639,7 +965,7 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 		return nil, err
 	}
 
-	err = p.writeBindMounts(containerPath, rootfsPath, bindMounts)
+	err = p.writeBindMounts(containerPath, rootfsPath, bindMounts, properties)
 	if err != nil {
 		p.logger.Error("bind-mounts-failed", err)
 		return nil, err
@@ -648,7 +974,15 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 	filterLog := pLog.Session("setup-filter")
 
 	filterLog.Debug("starting")
-	if err = p.filterProvider.ProvideFilter(id).Setup(handle); err != nil {
+
+	// a failed setup may leave some of the chains behind
+	filter := p.filterProvider.ProvideFilter(id)
+	txn.record("iptables-chain", func() error {
+		filter.TearDown()
+		return nil
+	})
+
+	if err = filter.Setup(handle); err != nil {
 		p.logger.Error("set-up-filter-failed", err)
 		return nil, fmt.Errorf("container_pool: set up filter: %v", err)
 	}
@@ -657,32 +991,22 @@ func (p *LinuxContainerPool) acquireSystemResources(id, handle, containerPath, r
 	return rootFSEnvVars, nil
 }
 
-func (p *LinuxContainerPool) tryReleaseSystemResources(logger lager.Logger, id string) {
-	err := p.releaseSystemResources(logger, id)
-	if err != nil {
-		logger.Error("failed-to-undo-failed-create", err)
-	}
-}
-
+// releaseSystemResources releases as many of the container's resources as it
+// can, returning a ReleaseError listing those it could not. The rootfs is
+// only cleaned up once destroy.sh has succeeded, as the container may still
+// be using it otherwise.
 func (p *LinuxContainerPool) releaseSystemResources(logger lager.Logger, id string) error {
 	pRunner := logging.Runner{
 		CommandRunner: p.runner,
 		Logger:        logger,
 	}
-	rootfsProvider, err := ioutil.ReadFile(path.Join(p.depotPath, id, "rootfs-provider"))
-	if err != nil {
-		rootfsProvider = []byte("")
-	}
 
-	provider, found := p.rootfsProviders[string(rootfsProvider)]
-	if !found {
-		return ErrUnknownRootFSProvider
-	}
+	failures := &releaseFailures{logger: logger, counter: p.releaseFailures}
 
 	bridgeName, err := ioutil.ReadFile(path.Join(p.depotPath, id, "bridge-name"))
 	if err == nil {
 		if err := p.bridges.Release(string(bridgeName), id); err != nil {
-			return fmt.Errorf("containerpool: release bridge %s: %v", bridgeName, err)
+			failures.add("bridge", fmt.Errorf("%s: %v", bridgeName, err))
 		}
 	}
 
@@ -690,15 +1014,28 @@ func (p *LinuxContainerPool) releaseSystemResources(logger lager.Logger, id stri
 
 	err = pRunner.Run(destroy)
 	if err != nil {
-		return err
+		failures.add("container", err)
+	} else if err := p.cleanupRootFS(logger, id); err != nil {
+		failures.add("rootfs", err)
 	}
 
-	if err = provider.CleanupRootFS(logger, id); err != nil {
-		return err
+	p.filterProvider.ProvideFilter(id).TearDown()
+
+	return failures.err()
+}
+
+func (p *LinuxContainerPool) cleanupRootFS(logger lager.Logger, id string) error {
+	rootfsProvider, err := ioutil.ReadFile(path.Join(p.depotPath, id, "rootfs-provider"))
+	if err != nil {
+		rootfsProvider = []byte("")
 	}
 
-	p.filterProvider.ProvideFilter(id).TearDown()
-	return nil
+	provider, found := p.rootfsProviders[string(rootfsProvider)]
+	if !found {
+		return ErrUnknownRootFSProvider
+	}
+
+	return provider.CleanupRootFS(logger, id)
 }
 
 func getHandle(handle, id string) string {
@@ -708,12 +1045,6 @@ func getHandle(handle, id string) string {
 	return id
 }
 
-func cleanup(err *error, undo func()) {
-	if *err != nil {
-		undo()
-	}
-}
-
 func parseNetworkSpec(spec string) (subnets.SubnetSelector, subnets.IPSelector, error) {
 	var ipSelector subnets.IPSelector = subnets.DynamicIPSelector
 	var subnetSelector subnets.SubnetSelector = subnets.DynamicSubnetSelector
@@ -54,6 +54,8 @@ var _ = Describe("Container pool", func() {
 	var fakeBridges *fake_bridge_manager.FakeBridgeManager
 	var fakeFilterProvider *fake_container_pool.FakeFilterProvider
 	var fakeFilter *fakes.FakeFilter
+	var fakeIDGenerator *fake_container_pool.FakeIDGenerator
+	var snapshotsPath string
 	var pool *container_pool.LinuxContainerPool
 	var config sysconfig.Config
 
@@ -92,12 +94,19 @@ var _ = Describe("Container pool", func() {
 		depotPath, err = ioutil.TempDir("", "depot-path")
 		Expect(err).ToNot(HaveOccurred())
 
+		snapshotsPath, err = ioutil.TempDir("", "snapshots-path")
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeIDGenerator = new(fake_container_pool.FakeIDGenerator)
+		fakeIDGenerator.GenerateStub = container_pool.NewTimeIDGenerator().Generate
+
 		config = sysconfig.NewConfig("0", false)
 		logger := lagertest.NewTestLogger("test")
 		pool = container_pool.New(
 			logger,
 			"/root/path",
 			depotPath,
+			snapshotsPath,
 			config,
 			map[string]rootfs_provider.RootFSProvider{
 				"":     defaultFakeRootFSProvider,
@@ -115,11 +124,14 @@ var _ = Describe("Container pool", func() {
 			[]string{"1.1.1.1/32", "", "2.2.2.2/32"},
 			fakeRunner,
 			fakeQuotaManager,
+			fakeIDGenerator,
+			"",
 		)
 	})
 
 	AfterEach(func() {
 		os.RemoveAll(depotPath)
+		os.RemoveAll(snapshotsPath)
 	})
 
 	Describe("MaxContainer", func() {
@@ -236,8 +248,8 @@ var _ = Describe("Container pool", func() {
 
 	Describe("creating", func() {
 		itReleasesTheUserIDs := func() {
-			It("returns the container's user ID and root ID to the pool", func() {
-				Expect(fakeUIDPool.Released).To(Equal([]uint32{10000, 10001}))
+			It("returns the container's root ID and user ID to the pool", func() {
+				Expect(fakeUIDPool.Released).To(Equal([]uint32{10001, 10000}))
 			})
 		}
 
@@ -271,6 +283,16 @@ var _ = Describe("Container pool", func() {
 			})
 		}
 
+		itDoesNotAcquireAnyResources := func() {
+			It("does not acquire any resources", func() {
+				Expect(fakeUIDPool.Acquired).To(HaveLen(0))
+				Expect(fakePortPool.Acquired).To(HaveLen(0))
+				Expect(fakeSubnetPool.AcquireCallCount()).To(Equal(0))
+				Expect(fakeBridges.ReserveCallCount()).To(Equal(0))
+				Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+			})
+		}
+
 		itReleasesAndDestroysTheBridge := func() {
 			It("releases the bridge", func() {
 				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
@@ -293,6 +315,64 @@ var _ = Describe("Container pool", func() {
 			Expect(container1.ID()).ToNot(Equal(container2.ID()))
 		})
 
+		Context("when the generated ID is already in use", func() {
+			var ids []string
+
+			BeforeEach(func() {
+				ids = []string{"in-depot", "in-snapshots", "quarantined", "some-free-id"}
+
+				fakeIDGenerator.GenerateStub = func() (string, error) {
+					id := ids[0]
+					ids = ids[1:]
+					return id, nil
+				}
+
+				Expect(os.MkdirAll(path.Join(depotPath, "in-depot"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(path.Join(snapshotsPath, "in-snapshots"), []byte("{}"), 0644)).To(Succeed())
+				Expect(os.MkdirAll(path.Join(snapshotsPath, "quarantine"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(path.Join(snapshotsPath, "quarantine", "quarantined"), []byte("{}"), 0644)).To(Succeed())
+			})
+
+			It("generates IDs until it finds one that is free", func() {
+				container, err := pool.Create(garden.ContainerSpec{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.ID()).To(Equal("some-free-id"))
+				Expect(fakeIDGenerator.GenerateCallCount()).To(Equal(4))
+			})
+		})
+
+		Context("when every generated ID is already in use", func() {
+			BeforeEach(func() {
+				fakeIDGenerator.GenerateStub = nil
+				fakeIDGenerator.GenerateReturns("in-depot", nil)
+
+				Expect(os.MkdirAll(path.Join(depotPath, "in-depot"), 0755)).To(Succeed())
+			})
+
+			It("gives up, without acquiring any resources", func() {
+				_, err := pool.Create(garden.ContainerSpec{})
+				Expect(err).To(Equal(container_pool.ErrNoUniqueContainerID))
+
+				Expect(fakeIDGenerator.GenerateCallCount()).To(Equal(10))
+				Expect(fakeUIDPool.Acquired).To(BeEmpty())
+			})
+		})
+
+		Context("when generating an ID fails", func() {
+			disaster := errors.New("no entropy")
+
+			BeforeEach(func() {
+				fakeIDGenerator.GenerateStub = nil
+				fakeIDGenerator.GenerateReturns("", disaster)
+			})
+
+			It("returns the error", func() {
+				_, err := pool.Create(garden.ContainerSpec{})
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
 		It("creates containers with the correct grace time", func() {
 			container, err := pool.Create(garden.ContainerSpec{
 				GraceTime: 1 * time.Second,
@@ -637,13 +717,43 @@ var _ = Describe("Container pool", func() {
 				Expect(string(body)).To(Equal("fake"))
 			})
 
+			It("saves the rootfs URL to the depot", func() {
+				container, err := pool.Create(garden.ContainerSpec{
+					RootFSPath: "fake:///path/to/custom-rootfs",
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				body, err := ioutil.ReadFile(path.Join(depotPath, container.ID(), "rootfs-url"))
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(string(body)).To(Equal("fake:///path/to/custom-rootfs"))
+			})
+
 			It("returns an error if the supplied environment is invalid", func() {
 				_, err := pool.Create(garden.ContainerSpec{
 					Env: []string{
 						"hello",
 					},
 				})
-				Expect(err).To(MatchError(HavePrefix("process: malformed environment")))
+				Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+				Expect(err).To(MatchError(ContainSubstring("process: malformed environment")))
+			})
+
+			It("gives the container the stop grace time in its properties", func() {
+				container, err := pool.Create(garden.ContainerSpec{
+					Properties: garden.Properties{linux_container.StopGraceTimeProperty: "30s"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.(*linux_container.LinuxContainer).StopGraceTime()).To(Equal(30 * time.Second))
+			})
+
+			It("returns an error if the stop grace time is invalid", func() {
+				_, err := pool.Create(garden.ContainerSpec{
+					Properties: garden.Properties{linux_container.StopGraceTimeProperty: "-1s"},
+				})
+				Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+				Expect(err).To(MatchError(ContainSubstring(`invalid garden.stop-grace-time "-1s"`)))
 			})
 
 			It("merges the env vars associated with the rootfs with those in the spec", func() {
@@ -677,16 +787,12 @@ var _ = Describe("Container pool", func() {
 					})
 				})
 
-				It("returns an error", func() {
-					Expect(err).To(BeAssignableToTypeOf(&url.Error{}))
+				It("returns an InvalidSpecError", func() {
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err.(container_pool.InvalidSpecError).Problems).To(HaveLen(1))
 				})
 
-				itReleasesTheUserIDs()
-				itReleasesTheIPBlock()
-
-				It("does not acquire a bridge", func() {
-					Expect(fakeBridges.ReserveCallCount()).To(Equal(0))
-				})
+				itDoesNotAcquireAnyResources()
 			})
 
 			Context("when its scheme is unknown", func() {
@@ -698,16 +804,12 @@ var _ = Describe("Container pool", func() {
 					})
 				})
 
-				It("returns ErrUnknownRootFSProvider", func() {
-					Expect(err).To(Equal(container_pool.ErrUnknownRootFSProvider))
+				It("returns an InvalidSpecError naming the rootfs provider", func() {
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err).To(MatchError(`create container: invalid rootfs path "unknown:///path/to/custom-rootfs": unknown rootfs provider`))
 				})
 
-				itReleasesTheUserIDs()
-				itReleasesTheIPBlock()
-
-				It("does not acquire a bridge", func() {
-					Expect(fakeBridges.ReserveCallCount()).To(Equal(0))
-				})
+				itDoesNotAcquireAnyResources()
 			})
 
 			Context("when providing the mount point fails", func() {
@@ -763,8 +865,9 @@ var _ = Describe("Container pool", func() {
 
 			It("cleans up the rootfs", func() {
 				Expect(fakeRootFSProvider.CleanupRootFSCallCount()).To(Equal(1))
-				_, rootfsPath := fakeRootFSProvider.CleanupRootFSArgsForCall(0)
-				Expect(rootfsPath).To(Equal("the-rootfs"))
+				_, providedID, _ := fakeRootFSProvider.ProvideRootFSArgsForCall(0)
+				_, cleanedUpID := fakeRootFSProvider.CleanupRootFSArgsForCall(0)
+				Expect(cleanedUpID).To(Equal(providedID))
 			})
 
 			It("returns an error", func() {
@@ -773,16 +876,43 @@ var _ = Describe("Container pool", func() {
 		})
 
 		Context("when bind mounts are specified", func() {
-			It("appends mount commands to hook-parent-before-clone.sh", func() {
+			var rootfsPath, srcPath string
+
+			readBindMounts := func(container linux_backend.Container) []linux_backend.BindMount {
+				mounts, err := linux_backend.ReadBindMounts(path.Join(depotPath, container.ID()))
+				Expect(err).ToNot(HaveOccurred())
+
+				return mounts
+			}
+
+			BeforeEach(func() {
+				var err error
+				rootfsPath, err = ioutil.TempDir("", "rootfs")
+				Expect(err).ToNot(HaveOccurred())
+
+				srcPath, err = ioutil.TempDir("", "bind-mount-src")
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(os.MkdirAll(path.Join(rootfsPath, "src", "path-rw"), 0755)).To(Succeed())
+
+				defaultFakeRootFSProvider.ProvideRootFSReturns(rootfsPath, nil, nil)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(rootfsPath)
+				os.RemoveAll(srcPath)
+			})
+
+			It("records them in the depot for the hook to perform", func() {
 				container, err := pool.Create(garden.ContainerSpec{
 					BindMounts: []garden.BindMount{
 						{
-							SrcPath: "/src/path-ro",
+							SrcPath: srcPath,
 							DstPath: "/dst/path-ro",
 							Mode:    garden.BindMountModeRO,
 						},
 						{
-							SrcPath: "/src/path-rw",
+							SrcPath: srcPath,
 							DstPath: "/dst/path-rw",
 							Mode:    garden.BindMountModeRW,
 						},
@@ -794,131 +924,163 @@ var _ = Describe("Container pool", func() {
 						},
 					},
 				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(readBindMounts(container)).To(Equal([]linux_backend.BindMount{
+					{
+						SrcPath:  srcPath,
+						DstPath:  rootfsPath + "/dst/path-ro",
+						ReadOnly: true,
+					},
+					{
+						SrcPath: srcPath,
+						DstPath: rootfsPath + "/dst/path-rw",
+					},
+					{
+						SrcPath: rootfsPath + "/src/path-rw",
+						DstPath: rootfsPath + "/dst/path-rw",
+					},
+				}))
+			})
 
+			It("does not run any commands to set them up", func() {
+				_, err := pool.Create(garden.ContainerSpec{
+					BindMounts: []garden.BindMount{
+						{SrcPath: srcPath, DstPath: "/dst/path"},
+					},
+				})
 				Expect(err).ToNot(HaveOccurred())
 
-				containerPath := path.Join(depotPath, container.ID())
-				rootfsPath := "/provided/rootfs/path"
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(fake_command_runner.CommandSpec{
+					Path: "bash",
+				}))
+			})
 
-				Expect(fakeRunner).To(HaveExecutedSerially(
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+			Context("when options are given for a bind mount", func() {
+				It("records them with the mount", func() {
+					container, err := pool.Create(garden.ContainerSpec{
+						BindMounts: []garden.BindMount{
+							{SrcPath: srcPath, DstPath: "/dst/path"},
+							{SrcPath: srcPath, DstPath: "/dst/other-path"},
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mkdir -p " + rootfsPath + "/dst/path-ro" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+						Properties: garden.Properties{
+							container_pool.BindMountOptionsProperty: `{"/dst/path": "rbind,rslave"}`,
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mount -n --bind /src/path-ro " + rootfsPath + "/dst/path-ro" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(readBindMounts(container)).To(Equal([]linux_backend.BindMount{
+						{
+							SrcPath:     srcPath,
+							DstPath:     rootfsPath + "/dst/path",
+							ReadOnly:    true,
+							Recursive:   true,
+							Propagation: "rslave",
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mount -n --bind -o remount,ro /src/path-ro " + rootfsPath + "/dst/path-ro" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+						{
+							SrcPath:  srcPath,
+							DstPath:  rootfsPath + "/dst/other-path",
+							ReadOnly: true,
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+					}))
+				})
+			})
+
+			Context("when an option is not known", func() {
+				It("returns an InvalidSpecError", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						BindMounts: []garden.BindMount{
+							{SrcPath: srcPath, DstPath: "/dst/path"},
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mkdir -p " + rootfsPath + "/dst/path-rw" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+						Properties: garden.Properties{
+							container_pool.BindMountOptionsProperty: `{"/dst/path": "rbind,sideways"}`,
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mount -n --bind /src/path-rw " + rootfsPath + "/dst/path-rw" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+					})
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err).To(MatchError(ContainSubstring(`unknown propagation "sideways"`)))
+				})
+			})
+
+			Context("when more than one propagation is given for a bind mount", func() {
+				It("returns an InvalidSpecError", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						BindMounts: []garden.BindMount{
+							{SrcPath: srcPath, DstPath: "/dst/path"},
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mount -n --bind -o remount,rw /src/path-rw " + rootfsPath + "/dst/path-rw" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+						Properties: garden.Properties{
+							container_pool.BindMountOptionsProperty: `{"/dst/path": "rslave,rshared"}`,
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mkdir -p " + rootfsPath + "/dst/path-rw" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+					})
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err).To(MatchError(ContainSubstring(`more than one propagation in "rslave,rshared"`)))
+				})
+			})
+
+			Context("when options are given for a destination with no bind mount", func() {
+				It("returns an InvalidSpecError", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						BindMounts: []garden.BindMount{
+							{SrcPath: srcPath, DstPath: "/dst/path"},
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mount -n --bind " + rootfsPath + "/src/path-rw " + rootfsPath + "/dst/path-rw" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+						Properties: garden.Properties{
+							container_pool.BindMountOptionsProperty: `{"/dst/typo": "rbind"}`,
 						},
-					},
-					fake_command_runner.CommandSpec{
-						Path: "bash",
-						Args: []string{
-							"-c",
-							"echo mount -n --bind -o remount,rw " + rootfsPath + "/src/path-rw " + rootfsPath + "/dst/path-rw" +
-								" >> " + containerPath + "/lib/hook-parent-before-clone.sh",
+					})
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err).To(MatchError(ContainSubstring("invalid garden.bind-mount-options property: no bind mount to /dst/typo")))
+				})
+			})
+
+			Context("when the options are not a JSON object", func() {
+				It("returns an InvalidSpecError", func() {
+					_, err := pool.Create(garden.ContainerSpec{
+						BindMounts: []garden.BindMount{
+							{SrcPath: srcPath, DstPath: "/dst/path"},
 						},
-					},
-				))
+						Properties: garden.Properties{
+							container_pool.BindMountOptionsProperty: "rbind,rslave",
+						},
+					})
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err).To(MatchError(ContainSubstring("invalid garden.bind-mount-options property")))
+				})
 			})
 
-			Context("when appending to hook-parent-before-clone.sh", func() {
+			Context("when the source of a bind mount does not exist on the host", func() {
 				var err error
-				disaster := errors.New("oh no!")
 
 				BeforeEach(func() {
-					fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
-						Path: "bash",
-					}, func(*exec.Cmd) error {
-						return disaster
+					_, err = pool.Create(garden.ContainerSpec{
+						BindMounts: []garden.BindMount{
+							{SrcPath: srcPath, DstPath: "/dst/path"},
+							{SrcPath: "/does/not/exist", DstPath: "/dst/missing"},
+						},
 					})
+				})
+
+				It("returns an InvalidSpecError", func() {
+					Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+					Expect(err).To(MatchError(ContainSubstring("/does/not/exist")))
+				})
 
+				itDoesNotAcquireAnyResources()
+			})
+
+			Context("when the source of a bind mount does not exist in the rootfs", func() {
+				var err error
+
+				BeforeEach(func() {
 					_, err = pool.Create(garden.ContainerSpec{
 						BindMounts: []garden.BindMount{
-							{
-								SrcPath: "/src/path-ro",
-								DstPath: "/dst/path-ro",
-								Mode:    garden.BindMountModeRO,
-							},
-							{
-								SrcPath: "/src/path-rw",
-								DstPath: "/dst/path-rw",
-								Mode:    garden.BindMountModeRW,
-							},
+							{SrcPath: srcPath, DstPath: "/dst/path"},
+							{SrcPath: "/does/not/exist", DstPath: "/dst/missing", Origin: garden.BindMountOriginContainer},
 						},
 					})
 				})
 
-				It("returns the error", func() {
-					Expect(err).To(Equal(disaster))
+				It("returns an InvalidBindMountError", func() {
+					Expect(err).To(BeAssignableToTypeOf(linux_backend.InvalidBindMountError{}))
+					Expect(err.(linux_backend.InvalidBindMountError).Mount.SrcPath).To(Equal(rootfsPath + "/does/not/exist"))
 				})
 
 				itReleasesTheUserIDs()
@@ -928,6 +1090,42 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when the spec is invalid in several ways", func() {
+			var err error
+
+			BeforeEach(func() {
+				_, err = pool.Create(garden.ContainerSpec{
+					Handle:     "some handle",
+					RootFSPath: "unknown:///path",
+					Network:    "not a network",
+					Env:        []string{"hello"},
+					BindMounts: []garden.BindMount{
+						{SrcPath: "relative/src", DstPath: "/dst", Mode: 42},
+					},
+				})
+			})
+
+			It("returns an InvalidSpecError listing every problem", func() {
+				Expect(err).To(BeAssignableToTypeOf(container_pool.InvalidSpecError{}))
+
+				problems := []string{}
+				for _, problem := range err.(container_pool.InvalidSpecError).Problems {
+					problems = append(problems, problem.Error())
+				}
+
+				Expect(problems).To(Equal([]string{
+					`invalid handle "some handle": contains ' '`,
+					`invalid rootfs path "unknown:///path": unknown rootfs provider`,
+					"invalid network spec: invalid CIDR address: not a network/30",
+					"invalid env: process: malformed environment: invalid format (not key=value): \"hello\"",
+					"invalid bind mount relative/src -> /dst: paths must be absolute",
+					"invalid bind mount relative/src -> /dst: unknown mode 42",
+				}))
+			})
+
+			itDoesNotAcquireAnyResources()
+		})
+
 		Context("when acquiring a UID fails", func() {
 			nastyError := errors.New("oh no!")
 
@@ -939,6 +1137,15 @@ var _ = Describe("Container pool", func() {
 				_, err := pool.Create(garden.ContainerSpec{})
 				Expect(err).To(Equal(nastyError))
 			})
+
+			It("removes the container directory that reserved its ID", func() {
+				_, err := pool.Create(garden.ContainerSpec{})
+				Expect(err).To(HaveOccurred())
+
+				entries, err := ioutil.ReadDir(depotPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+			})
 		})
 
 		Context("when executing create.sh fails", func() {
@@ -973,6 +1180,106 @@ var _ = Describe("Container pool", func() {
 			itReleasesAndDestroysTheBridge()
 		})
 
+		Context("when saving the bridge name fails", func() {
+			var err error
+
+			BeforeEach(func() {
+				fakeBridges.ReserveStub = func(subnet *net.IPNet, id string) (string, error) {
+					// creating a directory with this name will cause the write to the
+					// file to fail.
+					Expect(os.MkdirAll(path.Join(depotPath, id, "bridge-name"), 0755)).To(Succeed())
+
+					return "the-bridge", nil
+				}
+
+				_, err = pool.Create(garden.ContainerSpec{})
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("releases the bridge", func() {
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+
+				bridgeName, containerID := fakeBridges.ReleaseArgsForCall(0)
+				_, reservedID := fakeBridges.ReserveArgsForCall(0)
+				Expect(bridgeName).To(Equal("the-bridge"))
+				Expect(containerID).To(Equal(reservedID))
+			})
+
+			It("removes the container's directory", func() {
+				entries, err := ioutil.ReadDir(depotPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entries).To(BeEmpty())
+			})
+
+			itReleasesTheUserIDs()
+			itReleasesTheIPBlock()
+			itCleansUpTheRootfs()
+		})
+
+		Context("when releasing a resource fails while rolling back", func() {
+			var err error
+
+			BeforeEach(func() {
+				fakeFilter.SetupReturns(errors.New("iptables says no"))
+				fakeBridges.ReleaseReturns(errors.New("bridge says no"))
+
+				_, err = pool.Create(garden.ContainerSpec{})
+			})
+
+			It("returns the original error", func() {
+				Expect(err).To(MatchError("container_pool: set up filter: iptables says no"))
+			})
+
+			It("counts the failure", func() {
+				Expect(pool.Counters()[0].Count("bridge")).To(Equal(uint64(1)))
+			})
+
+			itReleasesTheUserIDs()
+			itReleasesTheIPBlock()
+			itCleansUpTheRootfs()
+			itDeletesTheContainerDirectory()
+		})
+
+		Context("when destroy.sh fails while rolling back", func() {
+			var err error
+
+			BeforeEach(func() {
+				fakeFilter.SetupReturns(errors.New("iptables says no"))
+
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: "/root/path/destroy.sh",
+					}, func(*exec.Cmd) error {
+						return errors.New("destroy.sh says no")
+					},
+				)
+
+				_, err = pool.Create(garden.ContainerSpec{})
+			})
+
+			It("returns the original error", func() {
+				Expect(err).To(MatchError("container_pool: set up filter: iptables says no"))
+			})
+
+			It("counts the failure", func() {
+				Expect(pool.Counters()[0].Count("container")).To(Equal(uint64(1)))
+			})
+
+			It("leaves the container's other resources alone, as it may still be using them", func() {
+				Expect(defaultFakeRootFSProvider.CleanupRootFSCallCount()).To(BeZero())
+				Expect(fakeBridges.ReleaseCallCount()).To(BeZero())
+				Expect(fakeUIDPool.Released).To(BeEmpty())
+				Expect(fakeSubnetPool.ReleaseCallCount()).To(BeZero())
+
+				entries, err := ioutil.ReadDir(depotPath)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+			})
+		})
+
 		Context("when saving the rootfs provider fails", func() {
 			var err error
 
@@ -1014,9 +1321,11 @@ var _ = Describe("Container pool", func() {
 		var containerNetwork *linux_backend.Network
 		var rootUID uint32
 		var bridgeName string
+		var state string
 
 		BeforeEach(func() {
 			rootUID = 10001
+			state = "some-restored-state"
 
 			buf = new(bytes.Buffer)
 			snapshot = buf
@@ -1037,10 +1346,16 @@ var _ = Describe("Container pool", func() {
 
 					GraceTime: 1 * time.Second,
 
-					State: "some-restored-state",
-					Events: []string{
-						"some-restored-event",
-						"some-other-restored-event",
+					State: state,
+					Events: []linux_container.ContainerEvent{
+						{
+							Kind:    linux_container.EventKindMessage,
+							Details: map[string]string{"message": "some-restored-event"},
+						},
+						{
+							Kind:    linux_container.EventKindMessage,
+							Details: map[string]string{"message": "some-other-restored-event"},
+						},
 					},
 
 					Resources: linux_container.ResourcesSnapshot{
@@ -1082,6 +1397,64 @@ var _ = Describe("Container pool", func() {
 			Expect(linuxContainer.Resources().Bridge).To(Equal("some-bridge"))
 		})
 
+		It("does not provide its rootfs again", func() {
+			_, err := pool.Restore(snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeRootFSProvider.ProvideRootFSCallCount()).To(BeZero())
+			Expect(defaultFakeRootFSProvider.ProvideRootFSCallCount()).To(BeZero())
+		})
+
+		Context("when the container was checkpointed", func() {
+			BeforeEach(func() {
+				state = "checkpointed"
+
+				err := os.MkdirAll(path.Join(depotPath, "some-restored-id"), 0755)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = ioutil.WriteFile(path.Join(depotPath, "some-restored-id", "rootfs-url"), []byte("fake:///path/to/custom-rootfs"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("provides its rootfs again before restoring its processes", func() {
+				// the pool has no criu to restore them with
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(Equal(linux_container.ErrCheckpointingDisabled))
+
+				Expect(fakeRootFSProvider.ProvideRootFSCallCount()).To(Equal(1))
+				_, id, uri := fakeRootFSProvider.ProvideRootFSArgsForCall(0)
+				Expect(id).To(Equal("some-restored-id"))
+				Expect(uri.String()).To(Equal("fake:///path/to/custom-rootfs"))
+			})
+
+			Context("when providing the rootfs fails", func() {
+				disaster := errors.New("oh no!")
+
+				BeforeEach(func() {
+					fakeRootFSProvider.ProvideRootFSReturns("", nil, disaster)
+				})
+
+				It("returns the error, and gives back the container's resources", func() {
+					_, err := pool.Restore(snapshot)
+					Expect(err).To(Equal(disaster))
+
+					Expect(fakeUIDPool.Released).To(ContainElement(uint32(10000)))
+				})
+			})
+
+			Context("when the rootfs URL was not saved", func() {
+				BeforeEach(func() {
+					err := os.Remove(path.Join(depotPath, "some-restored-id", "rootfs-url"))
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("returns an error", func() {
+					_, err := pool.Restore(snapshot)
+					Expect(err).To(MatchError(HavePrefix("containerpool: reprovide rootfs:")))
+				})
+			})
+		})
+
 		It("removes its UID from the pool", func() {
 			_, err := pool.Restore(snapshot)
 			Expect(err).ToNot(HaveOccurred())
@@ -1152,6 +1525,54 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when removing a port from the pool fails", func() {
+			JustBeforeEach(func() {
+				fakePortPool.RemoveError = errors.New("oh no!")
+			})
+
+			It("releases the bridge", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+				bridgeName, containerId := fakeBridges.ReleaseArgsForCall(0)
+				Expect(bridgeName).To(Equal("some-bridge"))
+				Expect(containerId).To(Equal("some-restored-id"))
+			})
+		})
+
+		Context("when restoring the container fails", func() {
+			var err error
+
+			JustBeforeEach(func() {
+				fakeRunner.WhenRunning(
+					fake_command_runner.CommandSpec{
+						Path: path.Join(depotPath, "some-restored-id", "net.sh"),
+					},
+					func(*exec.Cmd) error {
+						return errors.New("oh no!")
+					},
+				)
+
+				_, err = pool.Restore(snapshot)
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("returns the UIDs, subnet, bridge and ports to the pool", func() {
+				Expect(fakeUIDPool.Released).To(ConsistOf(uint32(10000), uint32(10001)))
+
+				Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
+				Expect(fakeSubnetPool.ReleaseArgsForCall(0)).To(Equal(containerNetwork))
+
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+
+				Expect(fakePortPool.Released).To(ConsistOf(uint32(61001), uint32(61002), uint32(61003)))
+			})
+		})
+
 		Context("when decoding the snapshot fails", func() {
 			BeforeEach(func() {
 				snapshot = new(bytes.Buffer)
@@ -1163,6 +1584,23 @@ var _ = Describe("Container pool", func() {
 			})
 		})
 
+		Context("when the snapshot is of an unsupported version", func() {
+			BeforeEach(func() {
+				snapshot = bytes.NewBufferString(fmt.Sprintf(
+					`{"Version": %d, "ID": "some-restored-id"}`,
+					linux_container.CurrentSnapshotVersion+1,
+				))
+			})
+
+			It("fails without acquiring any resources", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(BeAssignableToTypeOf(linux_container.UnsupportedSnapshotVersionError{}))
+
+				Expect(fakeUIDPool.Removed).To(BeEmpty())
+				Expect(fakeSubnetPool.RemoveCallCount()).To(Equal(0))
+			})
+		})
+
 		Context("when removing the UID from the pool fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -1198,7 +1636,7 @@ var _ = Describe("Container pool", func() {
 				fakePortPool.RemoveError = disaster
 			})
 
-			It("returns the error and releases the uid, network, and all ports", func() {
+			It("returns the error and releases the uid, network and bridge", func() {
 				_, err := pool.Restore(snapshot)
 				Expect(err).To(Equal(disaster))
 
@@ -1207,9 +1645,14 @@ var _ = Describe("Container pool", func() {
 				Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(1))
 				Expect(fakeSubnetPool.ReleaseArgsForCall(0)).To(Equal(containerNetwork))
 
-				Expect(fakePortPool.Released).To(ContainElement(uint32(61001)))
-				Expect(fakePortPool.Released).To(ContainElement(uint32(61002)))
-				Expect(fakePortPool.Released).To(ContainElement(uint32(61003)))
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+			})
+
+			It("does not release the ports that it never removed", func() {
+				_, err := pool.Restore(snapshot)
+				Expect(err).To(Equal(disaster))
+
+				Expect(fakePortPool.Released).To(BeEmpty())
 			})
 
 			Context("when the container is privileged", func() {
@@ -1488,11 +1931,31 @@ var _ = Describe("Container pool", func() {
 			})
 
 			Context("when the releasing the bridge fails", func() {
+				BeforeEach(func() {
+					fakeBridges.ReleaseReturns(errors.New("jam in the bridge"))
+				})
+
 				It("returns the error", func() {
-					releaseErr := errors.New("jam in the bridge")
-					fakeBridges.ReleaseReturns(releaseErr)
 					err := pool.Destroy(createdContainer)
-					Expect(err).To(MatchError("containerpool: release bridge the-bridge: jam in the bridge"))
+					Expect(err).To(MatchError("containerpool: release bridge: the-bridge: jam in the bridge"))
+				})
+
+				It("still releases the container's other system resources", func() {
+					pool.Destroy(createdContainer)
+
+					Expect(fakeRunner).To(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: "/root/path/destroy.sh",
+						},
+					))
+
+					Expect(fakeFilter.TearDownCallCount()).To(Equal(1))
+				})
+
+				It("counts the failure", func() {
+					pool.Destroy(createdContainer)
+
+					Expect(pool.Counters()[0].Count("bridge")).To(Equal(uint64(1)))
 				})
 			})
 		})
@@ -1533,7 +1996,9 @@ var _ = Describe("Container pool", func() {
 
 				It("returns the error", func() {
 					err := pool.Destroy(createdContainer)
-					Expect(err).To(Equal(disaster))
+					Expect(err).To(Equal(container_pool.ReleaseError{
+						Failures: []container_pool.ReleaseFailure{{"rootfs", disaster}},
+					}))
 				})
 
 				It("does not release the container's ports or uid", func() {
@@ -1550,9 +2015,9 @@ var _ = Describe("Container pool", func() {
 					Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(0))
 				})
 
-				It("does not tear down the filter", func() {
+				It("still tears down the filter", func() {
 					pool.Destroy(createdContainer)
-					Expect(fakeFilter.TearDownCallCount()).To(Equal(0))
+					Expect(fakeFilter.TearDownCallCount()).To(Equal(1))
 				})
 			})
 		})
@@ -1574,7 +2039,9 @@ var _ = Describe("Container pool", func() {
 
 			It("returns the error", func() {
 				err := pool.Destroy(createdContainer)
-				Expect(err).To(Equal(disaster))
+				Expect(err).To(Equal(container_pool.ReleaseError{
+					Failures: []container_pool.ReleaseFailure{{"container", disaster}},
+				}))
 			})
 
 			It("does not clean up the container's rootfs", func() {
@@ -1599,9 +2066,11 @@ var _ = Describe("Container pool", func() {
 				Expect(fakeSubnetPool.ReleaseCallCount()).To(Equal(0))
 			})
 
-			It("does not tear down the filter", func() {
+			It("still releases the bridge and tears down the filter", func() {
 				pool.Destroy(createdContainer)
-				Expect(fakeFilter.TearDownCallCount()).To(Equal(0))
+
+				Expect(fakeBridges.ReleaseCallCount()).To(Equal(1))
+				Expect(fakeFilter.TearDownCallCount()).To(Equal(1))
 			})
 		})
 	})
@@ -0,0 +1,45 @@
+// This file was generated by counterfeiter
+package fake_container_pool
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
+)
+
+type FakeIDGenerator struct {
+	GenerateStub        func() (string, error)
+	generateMutex       sync.RWMutex
+	generateArgsForCall []struct{}
+	generateReturns     struct {
+		result1 string
+		result2 error
+	}
+}
+
+func (fake *FakeIDGenerator) Generate() (string, error) {
+	fake.generateMutex.Lock()
+	fake.generateArgsForCall = append(fake.generateArgsForCall, struct{}{})
+	fake.generateMutex.Unlock()
+	if fake.GenerateStub != nil {
+		return fake.GenerateStub()
+	} else {
+		return fake.generateReturns.result1, fake.generateReturns.result2
+	}
+}
+
+func (fake *FakeIDGenerator) GenerateCallCount() int {
+	fake.generateMutex.RLock()
+	defer fake.generateMutex.RUnlock()
+	return len(fake.generateArgsForCall)
+}
+
+func (fake *FakeIDGenerator) GenerateReturns(result1 string, result2 error) {
+	fake.GenerateStub = nil
+	fake.generateReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+var _ container_pool.IDGenerator = new(FakeIDGenerator)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 	"github.com/cloudfoundry-incubator/garden/fakes"
 )
 
@@ -18,10 +19,27 @@ type FakeContainer struct {
 	SavedSnapshots []io.Writer
 	snapshotMutex  *sync.RWMutex
 
+	// called as each snapshot is taken, e.g. to hold it up
+	SnapshotHook func()
+
 	StartError error
 	Started    bool
 
 	CleanedUp bool
+
+	CheckpointError error
+	Checkpointed    bool
+
+	ActiveProcessesValue []garden.Process
+
+	Limits               linux_backend.AdmissionLimits
+	MemoryLimitsValue    linux_backend.MemoryLimits
+	SetMemoryLimitsError error
+	ChangeGuard          linux_backend.ChangeGuard
+	limitsMutex          *sync.RWMutex
+
+	ChangeHandlers []func()
+	EventHandlers  []func(linux_backend.Event)
 }
 
 func NewFakeContainer(spec garden.ContainerSpec) *FakeContainer {
@@ -31,6 +49,7 @@ func NewFakeContainer(spec garden.ContainerSpec) *FakeContainer {
 		FakeContainer: new(fakes.FakeContainer),
 
 		snapshotMutex: new(sync.RWMutex),
+		limitsMutex:   new(sync.RWMutex),
 	}
 }
 
@@ -42,6 +61,10 @@ func (c *FakeContainer) Handle() string {
 	return c.Spec.Handle
 }
 
+func (c *FakeContainer) GetProperties() (garden.Properties, error) {
+	return c.Spec.Properties, nil
+}
+
 func (c *FakeContainer) HasProperties(ps garden.Properties) bool {
 	containerProps := c.Spec.Properties
 
@@ -64,6 +87,11 @@ func (c *FakeContainer) Start() error {
 	return c.StartError
 }
 
+func (c *FakeContainer) Checkpoint() error {
+	c.Checkpointed = true
+	return c.CheckpointError
+}
+
 func (c *FakeContainer) Cleanup() {
 	c.CleanedUp = true
 }
@@ -73,6 +101,10 @@ func (c *FakeContainer) GraceTime() time.Duration {
 }
 
 func (c *FakeContainer) Snapshot(snapshot io.Writer) error {
+	if c.SnapshotHook != nil {
+		c.SnapshotHook()
+	}
+
 	if c.SnapshotError != nil {
 		return c.SnapshotError
 	}
@@ -84,3 +116,117 @@ func (c *FakeContainer) Snapshot(snapshot io.Writer) error {
 
 	return nil
 }
+
+func (c *FakeContainer) OnChange(handler func()) {
+	c.ChangeHandlers = append(c.ChangeHandlers, handler)
+}
+
+func (c *FakeContainer) ActiveProcesses() []garden.Process {
+	return c.ActiveProcessesValue
+}
+
+func (c *FakeContainer) OnEvent(handler func(linux_backend.Event)) {
+	c.EventHandlers = append(c.EventHandlers, handler)
+}
+
+func (c *FakeContainer) AppliedLimits() linux_backend.AdmissionLimits {
+	c.limitsMutex.RLock()
+	defer c.limitsMutex.RUnlock()
+
+	return c.Limits
+}
+
+func (c *FakeContainer) Guard(guard linux_backend.ChangeGuard) {
+	c.ChangeGuard = guard
+}
+
+func (c *FakeContainer) LimitMemory(limits garden.MemoryLimits) error {
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.MemoryInBytes = limits.LimitInBytes
+	}, func() error {
+		return c.FakeContainer.LimitMemory(limits)
+	})
+}
+
+func (c *FakeContainer) LimitDisk(limits garden.DiskLimits) error {
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.DiskInBytes = limits.ByteHard
+	}, func() error {
+		return c.FakeContainer.LimitDisk(limits)
+	})
+}
+
+func (c *FakeContainer) LimitCPU(limits garden.CPULimits) error {
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.CPUShares = limits.LimitInShares
+	}, func() error {
+		return c.FakeContainer.LimitCPU(limits)
+	})
+}
+
+func (c *FakeContainer) changeLimits(change func(*linux_backend.AdmissionLimits), limit func() error) error {
+	limits := c.AppliedLimits()
+	change(&limits)
+
+	apply := func() error {
+		err := limit()
+		if err != nil {
+			return err
+		}
+
+		c.limitsMutex.Lock()
+		c.Limits = limits
+		c.limitsMutex.Unlock()
+
+		return nil
+	}
+
+	if c.ChangeGuard == nil {
+		return apply()
+	}
+
+	return c.ChangeGuard.ChangeLimits(c, limits, apply)
+}
+
+func (c *FakeContainer) SetMemoryLimits(limits linux_backend.MemoryLimits) error {
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.MemoryInBytes = limits.LimitInBytes
+	}, func() error {
+		if c.SetMemoryLimitsError != nil {
+			return c.SetMemoryLimitsError
+		}
+
+		c.limitsMutex.Lock()
+		c.MemoryLimitsValue = limits
+		c.limitsMutex.Unlock()
+
+		return nil
+	})
+}
+
+func (c *FakeContainer) MemoryLimits() (linux_backend.MemoryLimits, error) {
+	c.limitsMutex.RLock()
+	defer c.limitsMutex.RUnlock()
+
+	return c.MemoryLimitsValue, nil
+}
+
+func (c *FakeContainer) SetProperty(key string, value string) error {
+	if c.ChangeGuard != nil {
+		if err := c.ChangeGuard.ChangeProperty(c, key); err != nil {
+			return err
+		}
+	}
+
+	return c.FakeContainer.SetProperty(key, value)
+}
+
+func (c *FakeContainer) RemoveProperty(key string) error {
+	if c.ChangeGuard != nil {
+		if err := c.ChangeGuard.ChangeProperty(c, key); err != nil {
+			return err
+		}
+	}
+
+	return c.FakeContainer.RemoveProperty(key)
+}
@@ -3,6 +3,8 @@ package fake_container_pool
 import (
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
@@ -20,6 +22,7 @@ type FakeContainerPool struct {
 
 	CreateError  error
 	RestoreError error
+	RestoreDelay time.Duration
 	DestroyError error
 
 	ContainerSetup func(*FakeContainer)
@@ -27,6 +30,12 @@ type FakeContainerPool struct {
 	CreatedContainers   []linux_backend.Container
 	DestroyedContainers []linux_backend.Container
 	RestoredSnapshots   []io.Reader
+
+	// the highest number of restores that were in flight at once
+	MaxConcurrentRestores int
+	restoresInFlight      int
+
+	mutex sync.Mutex
 }
 
 func New() *FakeContainerPool {
@@ -82,6 +91,21 @@ func (p *FakeContainerPool) Create(spec garden.ContainerSpec) (linux_backend.Con
 }
 
 func (p *FakeContainerPool) Restore(snapshot io.Reader) (linux_backend.Container, error) {
+	p.mutex.Lock()
+	p.restoresInFlight++
+	if p.restoresInFlight > p.MaxConcurrentRestores {
+		p.MaxConcurrentRestores = p.restoresInFlight
+	}
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		p.restoresInFlight--
+		p.mutex.Unlock()
+	}()
+
+	time.Sleep(p.RestoreDelay)
+
 	if p.RestoreError != nil {
 		return nil, p.RestoreError
 	}
@@ -99,7 +123,9 @@ func (p *FakeContainerPool) Restore(snapshot io.Reader) (linux_backend.Container
 		},
 	)
 
+	p.mutex.Lock()
 	p.RestoredSnapshots = append(p.RestoredSnapshots, snapshot)
+	p.mutex.Unlock()
 
 	return container, nil
 }
@@ -109,7 +135,16 @@ func (p *FakeContainerPool) Destroy(container linux_backend.Container) error {
 		return p.DestroyError
 	}
 
+	p.mutex.Lock()
 	p.DestroyedContainers = append(p.DestroyedContainers, container)
+	p.mutex.Unlock()
 
 	return nil
 }
+
+func (p *FakeContainerPool) Destroyed() []linux_backend.Container {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.DestroyedContainers
+}
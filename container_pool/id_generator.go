@@ -0,0 +1,83 @@
+package container_pool
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// container IDs end up in iptables chain and network interface names, which
+// are limited in length, so every generator produces IDs of this many base 32
+// digits
+const containerIDLength = 11
+
+//go:generate counterfeiter -o fake_container_pool/FakeIDGenerator.go . IDGenerator
+type IDGenerator interface {
+	Generate() (string, error)
+}
+
+// TimeIDGenerator derives IDs from the current time in nanoseconds, never
+// handing out the same time twice. IDs sort in the order they were generated
+// until the clock steps backwards.
+type TimeIDGenerator struct {
+	last  int64
+	mutex *sync.Mutex
+}
+
+func NewTimeIDGenerator() *TimeIDGenerator {
+	return &TimeIDGenerator{
+		mutex: &sync.Mutex{},
+	}
+}
+
+func (g *TimeIDGenerator) Generate() (string, error) {
+	g.mutex.Lock()
+
+	containerNum := time.Now().UnixNano()
+	if containerNum <= g.last {
+		containerNum = g.last + 1
+	}
+
+	g.last = containerNum
+
+	g.mutex.Unlock()
+
+	containerID := []byte{}
+
+	var i uint
+	for i = 0; i < containerIDLength; i++ {
+		containerID = strconv.AppendInt(
+			containerID,
+			(containerNum>>(55-(i+1)*5))&31,
+			32,
+		)
+	}
+
+	return string(containerID), nil
+}
+
+// RandomIDGenerator generates IDs from 55 random bits, so that they do not
+// depend on the clock at all.
+type RandomIDGenerator struct{}
+
+func NewRandomIDGenerator() *RandomIDGenerator {
+	return &RandomIDGenerator{}
+}
+
+func (g *RandomIDGenerator) Generate() (string, error) {
+	random := make([]byte, containerIDLength)
+
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", fmt.Errorf("container_pool: generating random ID: %v", err)
+	}
+
+	containerID := []byte{}
+	for _, b := range random {
+		containerID = strconv.AppendInt(containerID, int64(b&31), 32)
+	}
+
+	return string(containerID), nil
+}
@@ -0,0 +1,54 @@
+package container_pool_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
+)
+
+var _ = Describe("ID generators", func() {
+	itGeneratesUniqueShortIDs := func(generator func() container_pool.IDGenerator) {
+		It("generates unique IDs of 11 base 32 digits", func() {
+			g := generator()
+
+			seen := map[string]bool{}
+			for i := 0; i < 1000; i++ {
+				id, err := g.Generate()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(id).To(MatchRegexp("^[0-9a-v]{11}$"))
+				Expect(seen).ToNot(HaveKey(id))
+
+				seen[id] = true
+			}
+		})
+	}
+
+	Describe("TimeIDGenerator", func() {
+		itGeneratesUniqueShortIDs(func() container_pool.IDGenerator {
+			return container_pool.NewTimeIDGenerator()
+		})
+
+		It("generates IDs in increasing order", func() {
+			g := container_pool.NewTimeIDGenerator()
+
+			previous, err := g.Generate()
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := 0; i < 100; i++ {
+				id, err := g.Generate()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(id > previous).To(BeTrue())
+				previous = id
+			}
+		})
+	})
+
+	Describe("RandomIDGenerator", func() {
+		itGeneratesUniqueShortIDs(func() container_pool.IDGenerator {
+			return container_pool.NewRandomIDGenerator()
+		})
+	})
+})
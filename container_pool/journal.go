@@ -0,0 +1,131 @@
+package container_pool
+
+import (
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden-linux/metrics_exporter"
+	"github.com/pivotal-golang/lager"
+)
+
+// ReleaseError is returned when some of a container's resources could not be
+// released, and so may have leaked. The others were released regardless.
+type ReleaseError struct {
+	Failures []ReleaseFailure
+}
+
+type ReleaseFailure struct {
+	Resource string
+	Err      error
+}
+
+func (e ReleaseError) Error() string {
+	failures := []string{}
+	for _, failure := range e.Failures {
+		failures = append(failures, "release "+failure.Resource+": "+failure.Err.Error())
+	}
+
+	return "containerpool: " + strings.Join(failures, "; ")
+}
+
+// releaseFailures collects the failures to release a container's resources,
+// logging and counting each one by resource.
+type releaseFailures struct {
+	logger   lager.Logger
+	counter  *metrics_exporter.Counter
+	failures []ReleaseFailure
+}
+
+func (f *releaseFailures) add(resource string, err error) {
+	f.logger.Error("release-failed", err, lager.Data{
+		"resource": resource,
+	})
+
+	f.counter.Increment(resource)
+
+	f.failures = append(f.failures, ReleaseFailure{resource, err})
+}
+
+func (f *releaseFailures) err() error {
+	if len(f.failures) == 0 {
+		return nil
+	}
+
+	return ReleaseError{f.failures}
+}
+
+// journal records how to release each resource acquired while creating or
+// restoring a container, so that if the operation fails part way through,
+// everything acquired so far can be released, most recent first.
+type journal struct {
+	logger   lager.Logger
+	failures *metrics_exporter.Counter
+	entries  []journalEntry
+}
+
+type journalEntry struct {
+	resource string
+	release  func() error
+	fatal    bool
+}
+
+func newJournal(logger lager.Logger, failures *metrics_exporter.Counter) *journal {
+	return &journal{
+		logger:   logger,
+		failures: failures,
+	}
+}
+
+// record must be called as soon as the resource has been acquired.
+func (j *journal) record(resource string, release func() error) {
+	j.entries = append(j.entries, journalEntry{resource, release, false})
+}
+
+// recordFatal is like record, but if releasing the resource fails, the
+// resources recorded before it are not released either, as whatever is left
+// of it may still be using them.
+func (j *journal) recordFatal(resource string, release func() error) {
+	j.entries = append(j.entries, journalEntry{resource, release, true})
+}
+
+// rollbackOnError is intended to be deferred, with a pointer to the
+// function's named error result.
+func (j *journal) rollbackOnError(err *error) {
+	if *err != nil {
+		j.rollback()
+	}
+}
+
+// rollback releases every recorded resource in reverse order. A failure to
+// release one resource does not stop the others from being released, unless
+// it was recorded with recordFatal; it is logged and counted instead, as the
+// original error is the one returned.
+func (j *journal) rollback() {
+	failures := &releaseFailures{logger: j.logger, counter: j.failures}
+
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := j.entries[i]
+
+		err := entry.release()
+		if err == nil {
+			continue
+		}
+
+		failures.add(entry.resource, err)
+
+		if entry.fatal {
+			left := []string{}
+			for _, earlier := range j.entries[:i] {
+				left = append(left, earlier.resource)
+			}
+
+			j.logger.Info("release-stopped", lager.Data{
+				"resource": entry.resource,
+				"left":     left,
+			})
+
+			break
+		}
+	}
+
+	j.entries = nil
+}
@@ -0,0 +1,158 @@
+package container_pool
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cloudfoundry-incubator/garden"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
+)
+
+// InvalidSpecError is returned by Create when the spec itself is at fault,
+// as opposed to the pool running out of resources. It lists every problem
+// with the spec, not just the first.
+type InvalidSpecError struct {
+	Problems []error
+}
+
+func (e InvalidSpecError) Error() string {
+	problems := []string{}
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.Error())
+	}
+
+	return "create container: " + strings.Join(problems, "; ")
+}
+
+// validateSpec checks everything about the spec that can be checked before
+// any resources are acquired for the container.
+func (p *LinuxContainerPool) validateSpec(spec garden.ContainerSpec) error {
+	problems := []error{}
+
+	if err := validateHandle(spec.Handle); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := p.validateRootFSPath(spec.RootFSPath); err != nil {
+		problems = append(problems, err)
+	}
+
+	if _, _, err := parseNetworkSpec(spec.Network); err != nil {
+		problems = append(problems, fmt.Errorf("invalid network spec: %v", err))
+	}
+
+	if _, err := process.NewEnv(spec.Env); err != nil {
+		problems = append(problems, fmt.Errorf("invalid env: %v", err))
+	}
+
+	problems = append(problems, validateBindMounts(spec.BindMounts, spec.Properties)...)
+
+	if _, err := linux_container.ParseStopGraceTime(spec.Properties); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(problems) > 0 {
+		return InvalidSpecError{problems}
+	}
+
+	return nil
+}
+
+// handles end up in iptables log prefixes and snapshots, so they are limited
+// to printable characters other than spaces and slashes
+func validateHandle(handle string) error {
+	for _, r := range handle {
+		if !unicode.IsPrint(r) || unicode.IsSpace(r) || r == '/' {
+			return fmt.Errorf("invalid handle %q: contains %q", handle, r)
+		}
+	}
+
+	return nil
+}
+
+func (p *LinuxContainerPool) validateRootFSPath(rootFSPath string) error {
+	rootfsURL, err := url.Parse(rootFSPath)
+	if err != nil {
+		return fmt.Errorf("invalid rootfs path: %v", err)
+	}
+
+	if _, found := p.rootfsProviders[rootfsURL.Scheme]; !found {
+		return fmt.Errorf("invalid rootfs path %q: %v", rootFSPath, ErrUnknownRootFSProvider)
+	}
+
+	return nil
+}
+
+// validateBindMounts checks the spec's bind mounts, and that the options
+// given for them are each for one of them.
+func validateBindMounts(bindMounts []garden.BindMount, properties garden.Properties) []error {
+	options, err := parseBindMountOptions(properties)
+	if err != nil {
+		return []error{err}
+	}
+
+	problems := []error{}
+
+	destinations := map[string]bool{}
+	for _, bm := range bindMounts {
+		destinations[bm.DstPath] = true
+
+		problems = append(problems, validateBindMount(bm, options[bm.DstPath])...)
+	}
+
+	unknown := []string{}
+	for dstPath := range options {
+		if !destinations[dstPath] {
+			unknown = append(unknown, dstPath)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	for _, dstPath := range unknown {
+		problems = append(problems, fmt.Errorf("invalid %s property: no bind mount to %s", BindMountOptionsProperty, dstPath))
+	}
+
+	return problems
+}
+
+// validateBindMount checks the parts of a bind mount that do not depend on
+// the container's rootfs, which has not been provided yet; the source of a
+// mount from the container is only checked once it has been.
+func validateBindMount(bm garden.BindMount, options string) []error {
+	problems := []error{}
+
+	mount := linux_backend.BindMount{
+		SrcPath: bm.SrcPath,
+		DstPath: bm.DstPath,
+	}
+
+	if err := applyBindMountOptions(&mount, options); err != nil {
+		return []error{err}
+	}
+
+	switch bm.Origin {
+	case garden.BindMountOriginHost:
+		if err := mount.Validate(); err != nil {
+			problems = append(problems, err)
+		}
+	case garden.BindMountOriginContainer:
+		if err := mount.ValidateSpec(); err != nil {
+			problems = append(problems, err)
+		}
+	default:
+		problems = append(problems, linux_backend.InvalidBindMountError{mount, fmt.Sprintf("unknown origin %d", bm.Origin)})
+	}
+
+	if bm.Mode != garden.BindMountModeRO && bm.Mode != garden.BindMountModeRW {
+		problems = append(problems, linux_backend.InvalidBindMountError{mount, fmt.Sprintf("unknown mode %d", bm.Mode)})
+	}
+
+	return problems
+}
@@ -0,0 +1,288 @@
+package container_pool
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+// WarmContainerPool keeps a number of prebuilt containers for each of a set
+// of rootfs paths, so that Create can hand one of them out instead of
+// building a container from scratch.
+//
+// Only specs that could have been satisfied by the prebuilt container are
+// served from the warm pool: unprivileged, with no static network and no
+// bind mounts. Everything else is created by the underlying pool.
+//
+// Prebuilt containers are built in the background once the pool has been
+// pruned, refilled after each one is handed out, and replaced once they are
+// older than the TTL, so that e.g. docker images do not go stale, until Stop
+// is called.
+type WarmContainerPool struct {
+	*LinuxContainerPool
+
+	logger lager.Logger
+	clock  clock.Clock
+
+	rootFSPaths []string
+	size        int
+	ttl         time.Duration
+
+	idle      map[string][]*prebuiltContainer
+	idleMutex *sync.Mutex
+
+	refill    chan struct{}
+	startOnce *sync.Once
+
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func NewWarm(
+	logger lager.Logger,
+	pool *LinuxContainerPool,
+	rootFSPaths []string,
+	size int,
+	ttl time.Duration,
+	clock clock.Clock,
+) *WarmContainerPool {
+	idle := map[string][]*prebuiltContainer{}
+	for _, rootFSPath := range rootFSPaths {
+		idle[rootFSPath] = nil
+	}
+
+	return &WarmContainerPool{
+		LinuxContainerPool: pool,
+
+		logger: logger.Session("warm-pool"),
+		clock:  clock,
+
+		rootFSPaths: rootFSPaths,
+		size:        size,
+		ttl:         ttl,
+
+		idle:      idle,
+		idleMutex: &sync.Mutex{},
+
+		refill:    make(chan struct{}, 1),
+		startOnce: &sync.Once{},
+
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+	}
+}
+
+// Prune keeps the prebuilt containers, and starts building them the first
+// time it is called: anything built before then would have been pruned.
+func (w *WarmContainerPool) Prune(keep map[string]bool) error {
+	keepWarm := map[string]bool{}
+	for id := range keep {
+		keepWarm[id] = true
+	}
+
+	w.idleMutex.Lock()
+	for _, idle := range w.idle {
+		for _, pc := range idle {
+			keepWarm[pc.id] = true
+		}
+	}
+	w.idleMutex.Unlock()
+
+	err := w.LinuxContainerPool.Prune(keepWarm)
+
+	w.startOnce.Do(func() {
+		go w.run()
+	})
+
+	return err
+}
+
+// Stop stops building and replacing prebuilt containers, e.g. when draining
+// or shutting down. Those already built can still be handed out.
+func (w *WarmContainerPool) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+func (w *WarmContainerPool) Create(spec garden.ContainerSpec) (linux_backend.Container, error) {
+	if err := w.validateSpec(spec); err != nil {
+		return nil, err
+	}
+
+	if !isWarmable(spec) {
+		return w.LinuxContainerPool.Create(spec)
+	}
+
+	start := time.Now()
+
+	pc := w.take(spec.RootFSPath)
+	if pc == nil {
+		return w.LinuxContainerPool.Create(spec)
+	}
+
+	w.triggerRefill()
+
+	container, err := w.adopt(pc, spec)
+	if err != nil {
+		w.logger.Error("failed-to-adopt", err, lager.Data{
+			"id": pc.id,
+		})
+
+		w.discardLogged(pc)
+
+		return w.LinuxContainerPool.Create(spec)
+	}
+
+	w.createLatency.ObserveSince(start)
+
+	return container, nil
+}
+
+// Idle returns the number of prebuilt containers ready to be handed out, for
+// each rootfs path.
+func (w *WarmContainerPool) Idle() map[string]int {
+	w.idleMutex.Lock()
+	defer w.idleMutex.Unlock()
+
+	counts := map[string]int{}
+	for rootFSPath, idle := range w.idle {
+		counts[rootFSPath] = len(idle)
+	}
+
+	return counts
+}
+
+func isWarmable(spec garden.ContainerSpec) bool {
+	return !spec.Privileged && spec.Network == "" && len(spec.BindMounts) == 0
+}
+
+func (w *WarmContainerPool) take(rootFSPath string) *prebuiltContainer {
+	w.idleMutex.Lock()
+	defer w.idleMutex.Unlock()
+
+	idle := w.idle[rootFSPath]
+	if len(idle) == 0 {
+		return nil
+	}
+
+	w.idle[rootFSPath] = idle[1:]
+
+	return idle[0]
+}
+
+func (w *WarmContainerPool) triggerRefill() {
+	select {
+	case w.refill <- struct{}{}:
+	default:
+	}
+}
+
+func (w *WarmContainerPool) run() {
+	var expired <-chan time.Time
+	if w.ttl > 0 {
+		ticker := w.clock.NewTicker(w.ttl / 2)
+		defer ticker.Stop()
+
+		expired = ticker.C()
+	}
+
+	for {
+		w.fill()
+
+		select {
+		case <-w.refill:
+		case <-expired:
+			w.evict()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *WarmContainerPool) stopped() bool {
+	select {
+	case <-w.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// fill builds containers until every rootfs path has enough of them; it
+// gives up on a rootfs path at the first failure, until the next refill, and
+// on every rootfs path once the pool is stopped.
+func (w *WarmContainerPool) fill() {
+	for _, rootFSPath := range w.rootFSPaths {
+		for w.idleCount(rootFSPath) < w.size {
+			if w.stopped() {
+				return
+			}
+
+			pc, err := w.prebuild(rootFSPath)
+			if err != nil {
+				w.logger.Error("failed-to-prebuild", err, lager.Data{
+					"rootfs": rootFSPath,
+				})
+
+				break
+			}
+
+			pc.builtAt = w.clock.Now()
+
+			w.idleMutex.Lock()
+			w.idle[rootFSPath] = append(w.idle[rootFSPath], pc)
+			w.idleMutex.Unlock()
+		}
+	}
+}
+
+func (w *WarmContainerPool) evict() {
+	expired := []*prebuiltContainer{}
+
+	w.idleMutex.Lock()
+	for rootFSPath, idle := range w.idle {
+		fresh := []*prebuiltContainer{}
+
+		for _, pc := range idle {
+			if w.clock.Now().Sub(pc.builtAt) >= w.ttl {
+				expired = append(expired, pc)
+			} else {
+				fresh = append(fresh, pc)
+			}
+		}
+
+		w.idle[rootFSPath] = fresh
+	}
+	w.idleMutex.Unlock()
+
+	for _, pc := range expired {
+		w.logger.Info("evicting", lager.Data{
+			"id": pc.id,
+		})
+
+		w.discardLogged(pc)
+	}
+}
+
+func (w *WarmContainerPool) idleCount(rootFSPath string) int {
+	w.idleMutex.Lock()
+	defer w.idleMutex.Unlock()
+
+	return len(w.idle[rootFSPath])
+}
+
+// discardLogged does not report errors, only log them
+func (w *WarmContainerPool) discardLogged(pc *prebuiltContainer) {
+	err := w.discard(pc)
+	if err != nil {
+		w.logger.Error("failed-to-discard", err, lager.Data{
+			"id": pc.id,
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package container_pool_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_subnet_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	"github.com/cloudfoundry-incubator/garden-linux/network"
+	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr/fake_bridge_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager/fake_quota_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider"
+	"github.com/cloudfoundry-incubator/garden-linux/old/rootfs_provider/fake_rootfs_provider"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry-incubator/garden-linux/old/uid_pool/fake_uid_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+)
+
+var _ = Describe("Warm container pool", func() {
+	var depotPath string
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+	var fakeFilter *fakes.FakeFilter
+	var fakeRootFSProvider *fake_rootfs_provider.FakeRootFSProvider
+	var fakeClock *fakeclock.FakeClock
+	var warmPool *container_pool.WarmContainerPool
+
+	ttl := 10 * time.Minute
+
+	commandsRun := func(path string) int {
+		count := 0
+		for _, cmd := range fakeRunner.ExecutedCommands() {
+			if cmd.Path == path {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	prebuiltIDs := func() []string {
+		entries, err := ioutil.ReadDir(depotPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		ids := []string{}
+		for _, entry := range entries {
+			ids = append(ids, entry.Name())
+		}
+
+		return ids
+	}
+
+	BeforeEach(func() {
+		containerNetwork := &linux_backend.Network{}
+
+		var err error
+		containerNetwork.IP, containerNetwork.Subnet, err = net.ParseCIDR("10.2.0.1/30")
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeSubnetPool := new(fake_subnet_pool.FakeSubnetPool)
+		fakeSubnetPool.AcquireReturns(containerNetwork, nil)
+
+		fakeBridges := new(fake_bridge_manager.FakeBridgeManager)
+
+		fakeFilter = new(fakes.FakeFilter)
+		fakeFilterProvider := new(fake_container_pool.FakeFilterProvider)
+		fakeFilterProvider.ProvideFilterStub = func(id string) network.Filter {
+			return fakeFilter
+		}
+
+		fakeRunner = fake_command_runner.New()
+
+		defaultFakeRootFSProvider := new(fake_rootfs_provider.FakeRootFSProvider)
+		defaultFakeRootFSProvider.ProvideRootFSReturns("/provided/rootfs/path", nil, nil)
+
+		fakeRootFSProvider = new(fake_rootfs_provider.FakeRootFSProvider)
+		fakeRootFSProvider.ProvideRootFSReturns("/provided/fake/rootfs/path", process.Env{"rootfs": "env"}, nil)
+
+		depotPath, err = ioutil.TempDir("", "depot-path")
+		Expect(err).ToNot(HaveOccurred())
+
+		logger := lagertest.NewTestLogger("test")
+
+		pool := container_pool.New(
+			logger,
+			"/root/path",
+			depotPath,
+			"",
+			sysconfig.NewConfig("0", false),
+			map[string]rootfs_provider.RootFSProvider{
+				"":     defaultFakeRootFSProvider,
+				"fake": fakeRootFSProvider,
+			},
+			fake_uid_pool.New(10000),
+			net.ParseIP("1.2.3.4"),
+			345,
+			fakeSubnetPool,
+			fakeBridges,
+			fakeFilterProvider,
+			iptables.NewGlobalChain("global-default-chain", fakeRunner, logger),
+			fake_port_pool.New(1000),
+			nil,
+			nil,
+			fakeRunner,
+			fake_quota_manager.New(),
+			container_pool.NewTimeIDGenerator(),
+			"",
+		)
+
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		warmPool = container_pool.NewWarm(
+			logger,
+			pool,
+			[]string{"", "fake:///some-image"},
+			2,
+			ttl,
+			fakeClock,
+		)
+	})
+
+	AfterEach(func() {
+		warmPool.Stop()
+		os.RemoveAll(depotPath)
+	})
+
+	It("does not build any containers before the pool has been pruned", func() {
+		Consistently(warmPool.Idle, 100*time.Millisecond).Should(Equal(map[string]int{
+			"":                   0,
+			"fake:///some-image": 0,
+		}))
+
+		Expect(commandsRun("/root/path/create.sh")).To(BeZero())
+	})
+
+	Context("once the pool has been pruned", func() {
+		JustBeforeEach(func() {
+			Expect(warmPool.Prune(map[string]bool{})).To(Succeed())
+
+			Eventually(warmPool.Idle).Should(Equal(map[string]int{
+				"":                   2,
+				"fake:///some-image": 2,
+			}))
+		})
+
+		It("builds containers for each rootfs", func() {
+			Expect(commandsRun("/root/path/create.sh")).To(Equal(4))
+			Expect(fakeRootFSProvider.ProvideRootFSCallCount()).To(Equal(2))
+		})
+
+		It("keeps the prebuilt containers when pruning again", func() {
+			Expect(warmPool.Prune(map[string]bool{})).To(Succeed())
+
+			Expect(commandsRun("/root/path/destroy.sh")).To(BeZero())
+		})
+
+		Describe("creating a container that the warm pool can serve", func() {
+			var container linux_backend.Container
+			var prebuilt []string
+
+			JustBeforeEach(func() {
+				prebuilt = prebuiltIDs()
+
+				var err error
+				container, err = warmPool.Create(garden.ContainerSpec{
+					Handle:     "some-handle",
+					RootFSPath: "fake:///some-image",
+					GraceTime:  time.Second,
+					Properties: garden.Properties{"foo": "bar"},
+					Env:        []string{"spec=env"},
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("hands out a prebuilt container with the spec's handle, properties and env", func() {
+				Expect(prebuilt).To(ContainElement(container.ID()))
+
+				Expect(container.Handle()).To(Equal("some-handle"))
+				Expect(container.GraceTime()).To(Equal(time.Second))
+				Expect(container.GetProperties()).To(Equal(garden.Properties{"foo": "bar"}))
+
+				linuxContainer := container.(*linux_container.LinuxContainer)
+				Expect(linuxContainer.CurrentEnvVars()).To(Equal(process.Env{
+					"rootfs": "env",
+					"spec":   "env",
+				}))
+			})
+
+			It("sets up the filter to log with the handle", func() {
+				Expect(fakeFilter.SetupArgsForCall(fakeFilter.SetupCallCount() - 1)).To(Equal("some-handle"))
+			})
+
+			It("builds a replacement in the background", func() {
+				Eventually(func() int {
+					return commandsRun("/root/path/create.sh")
+				}).Should(Equal(5))
+
+				Eventually(warmPool.Idle).Should(Equal(map[string]int{
+					"":                   2,
+					"fake:///some-image": 2,
+				}))
+			})
+		})
+
+		Context("when adopting the prebuilt container fails", func() {
+			It("discards it and creates a container from scratch", func() {
+				fakeFilter.SetupReturns(errors.New("iptables says no"))
+
+				_, err := warmPool.Create(garden.ContainerSpec{
+					RootFSPath: "fake:///some-image",
+				})
+				Expect(err).To(MatchError("container_pool: set up filter: iptables says no"))
+
+				// one for the discarded container, one for the failed create
+				Expect(commandsRun("/root/path/destroy.sh")).To(BeNumerically(">=", 2))
+				Expect(commandsRun("/root/path/create.sh")).To(BeNumerically(">=", 5))
+			})
+		})
+
+		for _, incompatible := range []garden.ContainerSpec{
+			{RootFSPath: "fake:///some-image", Privileged: true},
+			{RootFSPath: "fake:///some-image", Network: "10.3.0.0/30"},
+			{RootFSPath: "fake:///some-image", BindMounts: []garden.BindMount{{SrcPath: "/", DstPath: "/b"}}},
+			{RootFSPath: "fake:///some-other-image"},
+		} {
+			spec := incompatible
+
+			Context(fmt.Sprintf("when the spec cannot be served by the warm pool: %#v", spec), func() {
+				It("creates a container from scratch", func() {
+					prebuilt := prebuiltIDs()
+
+					container, err := warmPool.Create(spec)
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(prebuilt).ToNot(ContainElement(container.ID()))
+					Expect(warmPool.Idle()).To(Equal(map[string]int{
+						"":                   2,
+						"fake:///some-image": 2,
+					}))
+				})
+			})
+		}
+
+		Context("once the pool has been stopped", func() {
+			JustBeforeEach(func() {
+				Eventually(fakeClock.WatcherCount).Should(Equal(1))
+
+				warmPool.Stop()
+
+				Eventually(fakeClock.WatcherCount).Should(Equal(0))
+			})
+
+			It("still hands out the prebuilt containers, but does not replace them", func() {
+				prebuilt := prebuiltIDs()
+
+				container, err := warmPool.Create(garden.ContainerSpec{
+					RootFSPath: "fake:///some-image",
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(prebuilt).To(ContainElement(container.ID()))
+
+				Consistently(func() int {
+					return commandsRun("/root/path/create.sh")
+				}, 100*time.Millisecond).Should(Equal(4))
+			})
+		})
+
+		Context("when the prebuilt containers are older than the TTL", func() {
+			It("replaces them", func() {
+				Eventually(fakeClock.WatcherCount).Should(Equal(1))
+
+				fakeClock.Increment(ttl)
+
+				Eventually(func() int {
+					return commandsRun("/root/path/destroy.sh")
+				}).Should(Equal(4))
+
+				Eventually(func() int {
+					return commandsRun("/root/path/create.sh")
+				}).Should(Equal(8))
+			})
+		})
+	})
+})
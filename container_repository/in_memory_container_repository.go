@@ -67,3 +67,8 @@ func (cr *InMemoryContainerRepository) Query(filter func(linux_backend.Container
 
 	return matches
 }
+
+// Records always returns no records, since nothing outlives the process.
+func (cr *InMemoryContainerRepository) Records() (map[string][]byte, error) {
+	return nil, nil
+}
@@ -0,0 +1,118 @@
+package container_repository
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+const recordFileName = "snapshot.json"
+
+// PersistentContainerRepository keeps containers in memory, and additionally
+// writes a snapshot of each container into its depot directory whenever the
+// container is added or changed, so that it can be restored after a crash.
+type PersistentContainerRepository struct {
+	*InMemoryContainerRepository
+
+	logger    lager.Logger
+	depotPath string
+
+	writeMutex *sync.Mutex
+}
+
+func NewPersistent(logger lager.Logger, depotPath string) *PersistentContainerRepository {
+	return &PersistentContainerRepository{
+		InMemoryContainerRepository: New(),
+
+		logger:    logger.Session("container-repository"),
+		depotPath: depotPath,
+
+		writeMutex: &sync.Mutex{},
+	}
+}
+
+func (cr *PersistentContainerRepository) Add(container linux_backend.Container) {
+	cr.InMemoryContainerRepository.Add(container)
+
+	container.OnChange(func() {
+		cr.persist(container)
+	})
+
+	cr.persist(container)
+}
+
+func (cr *PersistentContainerRepository) Delete(container linux_backend.Container) {
+	cr.writeMutex.Lock()
+	defer cr.writeMutex.Unlock()
+
+	cr.InMemoryContainerRepository.Delete(container)
+
+	err := os.Remove(cr.recordPath(container.ID()))
+	if err != nil && !os.IsNotExist(err) {
+		cr.logger.Error("failed-to-remove-record", err, lager.Data{
+			"id": container.ID(),
+		})
+	}
+}
+
+// Records returns the record of every container in the depot, keyed by ID,
+// leaving out (and logging) any that cannot be read.
+func (cr *PersistentContainerRepository) Records() (map[string][]byte, error) {
+	entries, err := ioutil.ReadDir(cr.depotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	records := map[string][]byte{}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		record, err := ioutil.ReadFile(cr.recordPath(entry.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			// the rest can still be recovered
+			cr.logger.Error("failed-to-read-record", err, lager.Data{
+				"id": entry.Name(),
+			})
+			continue
+		}
+
+		records[entry.Name()] = record
+	}
+
+	return records, nil
+}
+
+// persist does not report errors, only log them
+func (cr *PersistentContainerRepository) persist(container linux_backend.Container) {
+	cr.writeMutex.Lock()
+	defer cr.writeMutex.Unlock()
+
+	pLog := cr.logger.Session("persist", lager.Data{
+		"id": container.ID(),
+	})
+
+	if _, err := cr.InMemoryContainerRepository.FindByHandle(container.Handle()); err != nil {
+		pLog.Debug("skipping-deleted-container")
+		return
+	}
+
+	err := linux_backend.SaveSnapshot(container, cr.recordPath(container.ID()))
+	if err != nil {
+		pLog.Error("failed-to-write-record", err)
+	}
+}
+
+func (cr *PersistentContainerRepository) recordPath(id string) string {
+	return path.Join(cr.depotPath, id, recordFileName)
+}
@@ -0,0 +1,159 @@
+package container_repository_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend/fakes"
+)
+
+var _ = Describe("PersistentContainerRepository", func() {
+	var depotPath string
+	var repo *container_repository.PersistentContainerRepository
+	var container *fakes.FakeContainer
+	var snapshotContents string
+	var logger *lagertest.TestLogger
+
+	BeforeEach(func() {
+		var err error
+		depotPath, err = ioutil.TempDir("", "depot")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.Mkdir(path.Join(depotPath, "some-id"), 0755)).To(Succeed())
+
+		snapshotContents = "snapshot-1"
+
+		container = new(fakes.FakeContainer)
+		container.IDReturns("some-id")
+		container.HandleReturns("some-handle")
+		container.SnapshotStub = func(out io.Writer) error {
+			_, err := out.Write([]byte(snapshotContents))
+			return err
+		}
+
+		logger = lagertest.NewTestLogger("test")
+		repo = container_repository.NewPersistent(logger, depotPath)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(depotPath)
+	})
+
+	recordPath := func() string {
+		return path.Join(depotPath, "some-id", "snapshot.json")
+	}
+
+	Describe("Add", func() {
+		It("makes the container available", func() {
+			repo.Add(container)
+
+			found, err := repo.FindByHandle("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(Equal(container))
+		})
+
+		It("writes a snapshot of the container into its depot directory", func() {
+			repo.Add(container)
+
+			Expect(ioutil.ReadFile(recordPath())).To(Equal([]byte("snapshot-1")))
+		})
+
+		Context("when the container changes", func() {
+			It("rewrites the snapshot", func() {
+				repo.Add(container)
+
+				Expect(container.OnChangeCallCount()).To(Equal(1))
+
+				snapshotContents = "snapshot-2"
+				container.OnChangeArgsForCall(0)()
+
+				Expect(ioutil.ReadFile(recordPath())).To(Equal([]byte("snapshot-2")))
+			})
+		})
+
+		Context("when taking the snapshot fails", func() {
+			BeforeEach(func() {
+				container.SnapshotReturns(errors.New("oh no!"))
+			})
+
+			It("still adds the container", func() {
+				repo.Add(container)
+
+				_, err := repo.FindByHandle("some-handle")
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Delete", func() {
+		BeforeEach(func() {
+			repo.Add(container)
+		})
+
+		It("removes the container's record", func() {
+			repo.Delete(container)
+
+			_, err := os.Stat(recordPath())
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("does not rewrite the record if the container changes afterwards", func() {
+			repo.Delete(container)
+
+			container.OnChangeArgsForCall(0)()
+
+			_, err := os.Stat(recordPath())
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Records", func() {
+		BeforeEach(func() {
+			Expect(os.Mkdir(path.Join(depotPath, "no-record-id"), 0755)).To(Succeed())
+			Expect(os.Mkdir(path.Join(depotPath, "other-id"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(depotPath, "other-id", "snapshot.json"), []byte("other-snapshot"), 0644)).To(Succeed())
+
+			repo.Add(container)
+		})
+
+		It("returns the record of every container in the depot, keyed by ID", func() {
+			Expect(repo.Records()).To(Equal(map[string][]byte{
+				"some-id":  []byte("snapshot-1"),
+				"other-id": []byte("other-snapshot"),
+			}))
+		})
+
+		Context("when a record cannot be read", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(path.Join(depotPath, "unreadable-id", "snapshot.json"), 0755)).To(Succeed())
+			})
+
+			It("logs it, and returns the rest", func() {
+				Expect(repo.Records()).To(Equal(map[string][]byte{
+					"some-id":  []byte("snapshot-1"),
+					"other-id": []byte("other-snapshot"),
+				}))
+
+				Expect(logger).To(gbytes.Say("failed-to-read-record.*unreadable-id"))
+			})
+		})
+
+		Context("when the depot cannot be read", func() {
+			It("returns an error", func() {
+				repo = container_repository.NewPersistent(lagertest.NewTestLogger("test"), path.Join(depotPath, "bogus"))
+
+				_, err := repo.Records()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
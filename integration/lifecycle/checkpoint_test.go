@@ -0,0 +1,100 @@
+package lifecycle_test
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+var _ = Describe("Checkpointing containers through a restart", func() {
+	criuPath := os.Getenv("GARDEN_TEST_CRIU")
+	if criuPath == "" {
+		log.Println("GARDEN_TEST_CRIU undefined; skipping checkpointing test")
+		return
+	}
+
+	var container garden.Container
+	var gardenArgs []string
+
+	BeforeEach(func() {
+		gardenArgs = []string{"-criuBin", criuPath, "-checkpointOnStop"}
+
+		client = startGarden(gardenArgs...)
+
+		var err error
+
+		container, err = client.Create(garden.ContainerSpec{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if container != nil {
+			err := client.Destroy(container.Handle())
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	It("stops its processes while garden is down, and carries them on where they left off", func() {
+		info, err := container.Info()
+		Expect(err).ToNot(HaveOccurred())
+
+		counterPath := filepath.Join(info.ContainerPath, "rootfs", "tmp", "counter")
+
+		readCounter := func() int {
+			contents, err := ioutil.ReadFile(counterPath)
+			if err != nil {
+				return 0
+			}
+
+			count, _ := strconv.Atoi(strings.TrimSpace(string(contents)))
+			return count
+		}
+
+		process, _ := runInContainer(container, `i=0; while [ ! -e /tmp/stop ]; do i=$((i+1)); echo $i > /tmp/counter; echo counted; sleep 0.1; done; exit 42`)
+
+		Eventually(readCounter).Should(BeNumerically(">", 0))
+
+		gardenProcess.Signal(syscall.SIGTERM)
+		Eventually(gardenProcess.Wait(), 30).Should(Receive())
+
+		_, err = os.Stat(filepath.Join(info.ContainerPath, "checkpoint", "container"))
+		Expect(err).ToNot(HaveOccurred())
+
+		checkpointed := readCounter()
+		Consistently(readCounter, time.Second).Should(Equal(checkpointed))
+
+		client = startGarden(gardenArgs...)
+
+		Eventually(readCounter, 10).Should(BeNumerically(">", checkpointed))
+
+		By("re-attaching to its processes")
+		out := gbytes.NewBuffer()
+		reattached, err := container.Attach(process.ID(), garden.ProcessIO{
+			Stdout: io.MultiWriter(out, GinkgoWriter),
+			Stderr: GinkgoWriter,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(out, 10).Should(gbytes.Say("counted"))
+
+		err = ioutil.WriteFile(filepath.Join(info.ContainerPath, "rootfs", "tmp", "stop"), nil, 0644)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(reattached.Wait()).To(Equal(42))
+
+		By("running processes in it afterwards")
+		runEcho(container)
+	})
+})
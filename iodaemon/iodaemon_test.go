@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/binary"
 	"time"
 
 	"io/ioutil"
@@ -220,6 +221,87 @@ var _ = Describe("Iodaemon", func() {
 		})
 	})
 
+	Context("re-attaching to a running process", func() {
+		var (
+			stdinR, stdoutW, stderrW, exitStatusW *os.File
+		)
+
+		BeforeEach(func() {
+			var stdinW, stdoutR, stderrR, exitStatusR *os.File
+			var err error
+
+			stdinR, stdinW, err = os.Pipe()
+			Expect(err).ToNot(HaveOccurred())
+			stdoutR, stdoutW, err = os.Pipe()
+			Expect(err).ToNot(HaveOccurred())
+			stderrR, stderrW, err = os.Pipe()
+			Expect(err).ToNot(HaveOccurred())
+			exitStatusR, exitStatusW, err = os.Pipe()
+			Expect(err).ToNot(HaveOccurred())
+
+			go reattach(socketPath, stdinW, stdoutR, stderrR, exitStatusR, time.Second, false, terminate, fakeOut, fakeErr)
+		})
+
+		exit := func(status int32) {
+			Expect(binary.Write(exitStatusW, binary.LittleEndian, status)).To(Succeed())
+			exitStatusW.Close()
+		}
+
+		It("times out when no listeners connect", func() {
+			expectedExitCode = 2
+			exit(0)
+		})
+
+		It("reports back stdout and stderr", func() {
+			stdoutW.Write([]byte("hello\n"))
+			stderrW.Write([]byte("error\n"))
+
+			_, linkStdout, linkStderr, err := createLink(socketPath)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(linkStdout).Should(gbytes.Say("hello\n"))
+			Eventually(linkStderr).Should(gbytes.Say("error\n"))
+
+			exit(0)
+		})
+
+		It("sends stdin to the process", func() {
+			l, _, _, err := createLink(socketPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			l.Write([]byte("hello\n"))
+
+			stdin := make([]byte, 6)
+			_, err = io.ReadFull(stdinR, stdin)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(stdin)).To(Equal("hello\n"))
+
+			exit(0)
+		})
+
+		It("reports back the exit status once the process exits", func() {
+			l, _, _, err := createLink(socketPath)
+			Expect(err).ToNot(HaveOccurred())
+
+			exit(42)
+			stdoutW.Close()
+			stderrW.Close()
+
+			Expect(l.Wait()).To(Equal(42))
+		})
+
+		Context("when the process is killed by a signal", func() {
+			It("reports back an exit status of 255", func() {
+				l, _, _, err := createLink(socketPath)
+				Expect(err).ToNot(HaveOccurred())
+
+				exitStatusW.Close()
+				stdoutW.Close()
+				stderrW.Close()
+
+				Expect(l.Wait()).To(Equal(255))
+			})
+		})
+	})
 })
 
 func createLink(socketPath string) (*linkpkg.Link, io.WriteCloser, io.WriteCloser, error) {
@@ -11,6 +11,11 @@ const USAGE = `usage:
 	iodaemon spawn [-timeout timeout] [-tty] <socket> <path> <args...>:
 		spawn a subprocess, making its stdio and exit status available via
 		the given socket
+
+	iodaemon reattach [-timeout timeout] <socket>:
+		make the stdio and exit status of a process that is already running
+		available via the given socket, from the pipes to its stdin, stdout,
+		stderr and exit status passed as file descriptors 3 to 6
 `
 
 var timeout = flag.Duration(
@@ -63,6 +68,27 @@ func main() {
 		//block & allow goroutine to handle the exit
 		select {}
 
+	case "reattach":
+		if len(args) < 2 {
+			usage()
+		}
+
+		terminate := make(chan int, 1)
+		go func() {
+			os.Exit(<-terminate)
+		}()
+
+		reattach(
+			args[1],
+			os.NewFile(3, "stdin"),
+			os.NewFile(4, "stdout"),
+			os.NewFile(5, "stderr"),
+			os.NewFile(6, "exit-status"),
+			*timeout, *debug, terminate, os.Stdout, os.Stderr,
+		)
+		//block & allow goroutine to handle the exit
+		select {}
+
 	default:
 		usage()
 	}
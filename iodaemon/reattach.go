@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	debugPkg "runtime/debug"
+	"sync"
+	"time"
+)
+
+// reattach listens on a unix socket at the given socketPath and serves the
+// host's ends of the pipes of a process that is already running, such as one
+// restored from a checkpoint, in place of the iodaemon that spawned it.
+//
+// The exit status is read as wshd writes it: an int in the host's byte order,
+// which is little-endian wherever garden-linux runs, or EOF if the process was
+// killed by a signal.
+func reattach(
+	socketPath string,
+	stdinW, stdoutR, stderrR, exitStatusR *os.File,
+	timeout time.Duration,
+	debug bool,
+	terminate chan int,
+	notifyStream io.WriteCloser,
+	errStream io.WriteCloser,
+) {
+	var listener net.Listener
+
+	fatal := func(err error) {
+		if debug {
+			debugPkg.PrintStack()
+		}
+		fmt.Fprintln(errStream, "fatal: "+err.Error())
+		if listener != nil {
+			listener.Close()
+		}
+		terminate <- 1
+	}
+
+	if debug {
+		enableTracing(socketPath, fatal)
+	}
+
+	listener, err := listen(socketPath)
+	if err != nil {
+		fatal(err)
+		return
+	}
+
+	statusR, statusW, err := os.Pipe()
+	if err != nil {
+		fatal(err)
+		return
+	}
+
+	exited := make(chan bool)
+	go func() {
+		fmt.Fprintf(statusW, "%d\n", readExitStatus(exitStatusR))
+		close(exited)
+	}()
+
+	notify(notifyStream, "ready")
+	notifyStream.Close()
+
+	stopAccepting, connected := make(chan bool), make(chan bool)
+
+	go func() {
+		var once sync.Once
+
+		for {
+			conn, err := acceptConnection(listener, stdoutR, stderrR, statusR)
+			if err != nil {
+				select {
+				case <-stopAccepting:
+				default:
+					fatal(err)
+				}
+				return
+			}
+
+			once.Do(func() { close(connected) })
+
+			processLinkRequests(conn, stdinW, nil, false)
+		}
+	}()
+
+	exitCode := 1
+
+	// the process's output waits in its pipes for the first link, so keep
+	// serving it until then even if the process has already exited
+	select {
+	case <-connected:
+		<-exited
+		exitCode = 0
+	case <-time.After(timeout):
+		exitCode = 2
+	}
+
+	errStream.Close()
+	close(stopAccepting)
+	listener.Close()
+	terminate <- exitCode
+}
+
+func readExitStatus(exitStatusR *os.File) int {
+	var status int32
+
+	err := binary.Read(exitStatusR, binary.LittleEndian, &status)
+	if err != nil {
+		// as wsh reports a process killed by a signal
+		return 255
+	}
+
+	return int(status)
+}
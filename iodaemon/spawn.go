@@ -236,6 +236,7 @@ func acceptConnection(listener net.Listener, stdoutR, stderrR, statusR *os.File)
 
 // Loop receiving and processing link requests on the given connection.
 // The loop terminates when the connection is closed or an error occurs.
+// cmd is nil for a re-attached process, which is not iodaemon's child.
 func processLinkRequests(conn net.Conn, stdinW *os.File, cmd *exec.Cmd, withTty bool) {
 	decoder := gob.NewDecoder(conn)
 
@@ -247,6 +248,11 @@ func processLinkRequests(conn net.Conn, stdinW *os.File, cmd *exec.Cmd, withTty
 		}
 
 		if input.WindowSize != nil {
+			// a re-attached process has no tty to resize
+			if cmd == nil {
+				continue
+			}
+
 			setWinSize(stdinW, input.WindowSize.Columns, input.WindowSize.Rows)
 			cmd.Process.Signal(syscall.SIGWINCH)
 		} else if input.EOF {
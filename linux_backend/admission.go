@@ -0,0 +1,406 @@
+package linux_backend
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/old/system_info"
+)
+
+// Containers declare the limits they are created with through these
+// properties. The limits a container is held to, whether applied at create
+// or changed later, count against the backend's admission policy for as long
+// as the container exists. The properties cannot be changed after create.
+const (
+	MemoryLimitProperty = "garden.limits.memory_in_bytes"
+	DiskLimitProperty   = "garden.limits.disk_in_bytes"
+	CPULimitProperty    = "garden.limits.cpu_shares"
+)
+
+// AdmissionPolicy bounds how far the declared limits of all containers may
+// add up, and optionally those of each tenant's containers. The zero value
+// admits everything.
+type AdmissionPolicy struct {
+	// the memory and disk limits of all containers may add up to this many
+	// times the machine's total; 0 does not bound them
+	MemoryOvercommitRatio float64
+	DiskOvercommitRatio   float64
+
+	// the CPU shares of all containers may add up to this; 0 does not bound
+	// them
+	MaxCPUShares uint64
+
+	// containers with the same value for this property belong to the same
+	// tenant, whose containers' limits may add up to at most TenantQuota
+	TenantProperty string
+	TenantQuota    AdmissionLimits
+
+	// how long a create waits for other containers to be destroyed when
+	// there is not enough headroom for it; 0 rejects it straight away
+	QueueTimeout time.Duration
+}
+
+// AdmissionLimits is an amount of each resource covered by admission
+// control; 0 means unbounded when used as a quota.
+type AdmissionLimits struct {
+	MemoryInBytes uint64
+	DiskInBytes   uint64
+	CPUShares     uint64
+}
+
+func (l AdmissionLimits) add(other AdmissionLimits) AdmissionLimits {
+	return AdmissionLimits{
+		MemoryInBytes: l.MemoryInBytes + other.MemoryInBytes,
+		DiskInBytes:   l.DiskInBytes + other.DiskInBytes,
+		CPUShares:     l.CPUShares + other.CPUShares,
+	}
+}
+
+type InsufficientCapacityError struct {
+	Resource  string
+	Tenant    string
+	Requested uint64
+	Available uint64
+}
+
+func (e InsufficientCapacityError) Error() string {
+	if e.Tenant != "" {
+		return fmt.Sprintf("insufficient %s for tenant %s: requested %d, available %d", e.Resource, e.Tenant, e.Requested, e.Available)
+	}
+
+	return fmt.Sprintf("insufficient %s: requested %d, available %d", e.Resource, e.Requested, e.Available)
+}
+
+type ReadOnlyPropertyError struct {
+	Property string
+}
+
+func (e ReadOnlyPropertyError) Error() string {
+	return fmt.Sprintf("property %s cannot be changed after create", e.Property)
+}
+
+type InvalidLimitPropertyError struct {
+	Property string
+	Value    string
+}
+
+func (e InvalidLimitPropertyError) Error() string {
+	return fmt.Sprintf("invalid value for %s: %q", e.Property, e.Value)
+}
+
+// admissionController decides whether a container can be created, or its
+// limits raised, given the limits applied to the containers that already
+// exist and those declared by the containers that are being created.
+type admissionController struct {
+	policy     AdmissionPolicy
+	systemInfo system_info.Provider
+	containers func() []Container
+
+	mutex   *sync.Mutex
+	pending map[*reservation]bool
+
+	// closed, and replaced, whenever headroom may have been freed up
+	released chan struct{}
+}
+
+type reservation struct {
+	tenant string
+	limits AdmissionLimits
+}
+
+func newAdmissionController(policy AdmissionPolicy, systemInfo system_info.Provider, containers func() []Container) *admissionController {
+	return &admissionController{
+		policy:     policy,
+		systemInfo: systemInfo,
+		containers: containers,
+
+		mutex:   &sync.Mutex{},
+		pending: map[*reservation]bool{},
+
+		released: make(chan struct{}),
+	}
+}
+
+// admit reserves the limits declared by the spec, waiting for headroom if
+// the policy allows it. The reservation must be given back with done once
+// the container has been created, or has failed to be.
+func (a *admissionController) admit(spec garden.ContainerSpec) (*reservation, error) {
+	limits, err := declaredLimits(spec.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reservation{
+		tenant: a.tenantOf(spec.Properties),
+		limits: limits,
+	}
+
+	var timeout <-chan time.Time
+	if a.policy.QueueTimeout > 0 {
+		timeout = time.After(a.policy.QueueTimeout)
+	}
+
+	for {
+		a.mutex.Lock()
+
+		err := a.check(r.limits, r.tenant)
+		if err == nil {
+			a.pending[r] = true
+			a.mutex.Unlock()
+			return r, nil
+		}
+
+		released := a.released
+
+		a.mutex.Unlock()
+
+		if timeout == nil {
+			return nil, err
+		}
+
+		select {
+		case <-released:
+		case <-timeout:
+			return nil, err
+		}
+	}
+}
+
+// done gives back a reservation made by admit. A container that was created
+// keeps counting against the policy through the limits applied to it.
+func (a *admissionController) done(r *reservation) {
+	a.mutex.Lock()
+	delete(a.pending, r)
+	a.mutex.Unlock()
+
+	a.release()
+}
+
+// release wakes up the creates that are waiting for headroom.
+func (a *admissionController) release() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.wake()
+}
+
+// wake must be called with the mutex held.
+func (a *admissionController) wake() {
+	close(a.released)
+	a.released = make(chan struct{})
+}
+
+// ChangeLimits applies a change to a container's limits if the policy has
+// headroom for however much they grow. Lowering a limit is always allowed.
+func (a *admissionController) ChangeLimits(container Container, limits AdmissionLimits, apply func() error) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	current := container.AppliedLimits()
+
+	growth := AdmissionLimits{
+		MemoryInBytes: remaining(limits.MemoryInBytes, current.MemoryInBytes),
+		DiskInBytes:   remaining(limits.DiskInBytes, current.DiskInBytes),
+		CPUShares:     remaining(limits.CPUShares, current.CPUShares),
+	}
+
+	properties, err := container.GetProperties()
+	if err != nil {
+		return err
+	}
+
+	err = a.check(growth, a.tenantOf(properties))
+	if err != nil {
+		return err
+	}
+
+	err = apply()
+	if err != nil {
+		return err
+	}
+
+	// a lowered limit frees up headroom for the creates waiting on it
+	if limits != current.add(growth) {
+		a.wake()
+	}
+
+	return nil
+}
+
+// ChangeProperty keeps the properties admission was decided on from being
+// changed after create.
+func (a *admissionController) ChangeProperty(container Container, key string) error {
+	switch key {
+	case MemoryLimitProperty, DiskLimitProperty, CPULimitProperty:
+		return ReadOnlyPropertyError{key}
+	}
+
+	if a.policy.TenantProperty != "" && key == a.policy.TenantProperty {
+		return ReadOnlyPropertyError{key}
+	}
+
+	return nil
+}
+
+// headroom returns how much memory and disk can still be admitted, for the
+// resources whose overcommit is bounded.
+func (a *admissionController) headroom() (AdmissionLimits, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	allowed, err := a.allowed()
+	if err != nil {
+		return AdmissionLimits{}, err
+	}
+
+	committed := a.committed(func(string) bool { return true })
+
+	return AdmissionLimits{
+		MemoryInBytes: remaining(allowed.MemoryInBytes, committed.MemoryInBytes),
+		DiskInBytes:   remaining(allowed.DiskInBytes, committed.DiskInBytes),
+		CPUShares:     remaining(allowed.CPUShares, committed.CPUShares),
+	}, nil
+}
+
+// check reports whether the requested limits fit in the headroom left, and
+// in the tenant's. It must be called with the mutex held.
+func (a *admissionController) check(requested AdmissionLimits, tenant string) error {
+	allowed, err := a.allowed()
+	if err != nil {
+		return err
+	}
+
+	committed := a.committed(func(string) bool { return true })
+	if err := exceeds(requested, committed, allowed, ""); err != nil {
+		return err
+	}
+
+	if tenant == "" {
+		return nil
+	}
+
+	tenantCommitted := a.committed(func(t string) bool { return t == tenant })
+
+	return exceeds(requested, tenantCommitted, a.policy.TenantQuota, tenant)
+}
+
+// allowed returns the bound on each resource; 0 for unbounded.
+func (a *admissionController) allowed() (AdmissionLimits, error) {
+	var allowed AdmissionLimits
+
+	if a.policy.MemoryOvercommitRatio > 0 {
+		totalMemory, err := a.systemInfo.TotalMemory()
+		if err != nil {
+			return AdmissionLimits{}, err
+		}
+
+		allowed.MemoryInBytes = uint64(float64(totalMemory) * a.policy.MemoryOvercommitRatio)
+	}
+
+	if a.policy.DiskOvercommitRatio > 0 {
+		totalDisk, err := a.systemInfo.TotalDisk()
+		if err != nil {
+			return AdmissionLimits{}, err
+		}
+
+		allowed.DiskInBytes = uint64(float64(totalDisk) * a.policy.DiskOvercommitRatio)
+	}
+
+	allowed.CPUShares = a.policy.MaxCPUShares
+
+	return allowed, nil
+}
+
+// committed adds up the limits of the existing and pending containers of
+// the tenants matching the filter. It must be called with the mutex held.
+func (a *admissionController) committed(tenantFilter func(string) bool) AdmissionLimits {
+	var committed AdmissionLimits
+
+	for _, container := range a.containers() {
+		properties, err := container.GetProperties()
+		if err != nil || !tenantFilter(a.tenantOf(properties)) {
+			continue
+		}
+
+		committed = committed.add(container.AppliedLimits())
+	}
+
+	for r := range a.pending {
+		if tenantFilter(r.tenant) {
+			committed = committed.add(r.limits)
+		}
+	}
+
+	return committed
+}
+
+func (a *admissionController) tenantOf(properties garden.Properties) string {
+	if a.policy.TenantProperty == "" {
+		return ""
+	}
+
+	return properties[a.policy.TenantProperty]
+}
+
+func exceeds(requested, committed, allowed AdmissionLimits, tenant string) error {
+	checks := []struct {
+		resource                      string
+		requested, committed, allowed uint64
+	}{
+		{"memory", requested.MemoryInBytes, committed.MemoryInBytes, allowed.MemoryInBytes},
+		{"disk", requested.DiskInBytes, committed.DiskInBytes, allowed.DiskInBytes},
+		{"cpu shares", requested.CPUShares, committed.CPUShares, allowed.CPUShares},
+	}
+
+	for _, check := range checks {
+		if check.allowed == 0 || check.requested == 0 {
+			continue
+		}
+
+		if check.committed+check.requested > check.allowed {
+			return InsufficientCapacityError{
+				Resource:  check.resource,
+				Tenant:    tenant,
+				Requested: check.requested,
+				Available: remaining(check.allowed, check.committed),
+			}
+		}
+	}
+
+	return nil
+}
+
+func remaining(allowed, committed uint64) uint64 {
+	if committed > allowed {
+		return 0
+	}
+
+	return allowed - committed
+}
+
+func declaredLimits(properties garden.Properties) (AdmissionLimits, error) {
+	var limits AdmissionLimits
+
+	for property, limit := range map[string]*uint64{
+		MemoryLimitProperty: &limits.MemoryInBytes,
+		DiskLimitProperty:   &limits.DiskInBytes,
+		CPULimitProperty:    &limits.CPUShares,
+	} {
+		value, found := properties[property]
+		if !found {
+			continue
+		}
+
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return AdmissionLimits{}, InvalidLimitPropertyError{property, value}
+		}
+
+		*limit = parsed
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,389 @@
+package linux_backend_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/system_info/fake_system_info"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("Admission control", func() {
+	var fakeContainerPool *fake_container_pool.FakeContainerPool
+	var fakeSystemInfo *fake_system_info.FakeProvider
+	var policy linux_backend.AdmissionPolicy
+	var linuxBackend *linux_backend.LinuxBackend
+
+	withLimits := func(handle string, memory, disk, cpu string, extra ...string) garden.ContainerSpec {
+		properties := garden.Properties{}
+
+		if memory != "" {
+			properties[linux_backend.MemoryLimitProperty] = memory
+		}
+
+		if disk != "" {
+			properties[linux_backend.DiskLimitProperty] = disk
+		}
+
+		if cpu != "" {
+			properties[linux_backend.CPULimitProperty] = cpu
+		}
+
+		for i := 0; i+1 < len(extra); i += 2 {
+			properties[extra[i]] = extra[i+1]
+		}
+
+		return garden.ContainerSpec{
+			Handle:     handle,
+			Properties: properties,
+		}
+	}
+
+	BeforeEach(func() {
+		fakeContainerPool = fake_container_pool.New()
+
+		fakeSystemInfo = fake_system_info.NewFakeProvider()
+		fakeSystemInfo.TotalMemoryResult = 1000
+		fakeSystemInfo.TotalDiskResult = 2000
+
+		policy = linux_backend.AdmissionPolicy{
+			MemoryOvercommitRatio: 1.5,
+			DiskOvercommitRatio:   1,
+			MaxCPUShares:          100,
+		}
+	})
+
+	JustBeforeEach(func() {
+		linuxBackend = linux_backend.New(
+			lagertest.NewTestLogger("test"),
+			fakeContainerPool,
+			container_repository.New(),
+			fakeSystemInfo,
+			"",
+			1,
+			0,
+			1,
+			0,
+			nil,
+			nil,
+			policy,
+			false,
+		)
+	})
+
+	It("applies the declared limits to the container", func() {
+		container, err := linuxBackend.Create(withLimits("a", "100", "200", "10"))
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeContainer := container.(*fake_container_pool.FakeContainer)
+		Expect(fakeContainer.LimitMemoryArgsForCall(0)).To(Equal(garden.MemoryLimits{LimitInBytes: 100}))
+		Expect(fakeContainer.LimitDiskArgsForCall(0)).To(Equal(garden.DiskLimits{ByteHard: 200}))
+		Expect(fakeContainer.LimitCPUArgsForCall(0)).To(Equal(garden.CPULimits{LimitInShares: 10}))
+	})
+
+	It("does not apply limits that were not declared", func() {
+		container, err := linuxBackend.Create(garden.ContainerSpec{})
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeContainer := container.(*fake_container_pool.FakeContainer)
+		Expect(fakeContainer.LimitMemoryCallCount()).To(BeZero())
+		Expect(fakeContainer.LimitDiskCallCount()).To(BeZero())
+		Expect(fakeContainer.LimitCPUCallCount()).To(BeZero())
+	})
+
+	Context("when applying a limit fails", func() {
+		It("destroys the container", func() {
+			var setupContainer *fake_container_pool.FakeContainer
+			fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+				c.LimitMemoryReturns(errors.New("no memory cgroup"))
+				setupContainer = c
+			}
+
+			_, err := linuxBackend.Create(withLimits("a", "100", "", ""))
+			Expect(err).To(MatchError("no memory cgroup"))
+			Expect(fakeContainerPool.DestroyedContainers).To(ContainElement(setupContainer))
+		})
+	})
+
+	It("admits containers up to the overcommit ratio", func() {
+		_, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = linuxBackend.Create(withLimits("b", "500", "", ""))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects a container that would overcommit memory", func() {
+		_, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = linuxBackend.Create(withLimits("b", "501", "", ""))
+		Expect(err).To(Equal(linux_backend.InsufficientCapacityError{
+			Resource:  "memory",
+			Requested: 501,
+			Available: 500,
+		}))
+
+		Expect(fakeContainerPool.CreatedContainers).To(HaveLen(1))
+	})
+
+	It("rejects a container that would overcommit disk", func() {
+		_, err := linuxBackend.Create(withLimits("a", "", "2001", ""))
+		Expect(err).To(BeAssignableToTypeOf(linux_backend.InsufficientCapacityError{}))
+		Expect(err.(linux_backend.InsufficientCapacityError).Resource).To(Equal("disk"))
+	})
+
+	It("rejects a container that would exceed the CPU shares", func() {
+		_, err := linuxBackend.Create(withLimits("a", "", "", "101"))
+		Expect(err).To(BeAssignableToTypeOf(linux_backend.InsufficientCapacityError{}))
+		Expect(err.(linux_backend.InsufficientCapacityError).Resource).To(Equal("cpu shares"))
+	})
+
+	It("admits containers again once others are destroyed", func() {
+		_, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(linuxBackend.Destroy("a")).To(Succeed())
+
+		_, err = linuxBackend.Create(withLimits("b", "1500", "", ""))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("does not count containers that failed to be created", func() {
+		fakeContainerPool.CreateError = errors.New("oh no!")
+
+		_, err := linuxBackend.Create(withLimits("a", "1500", "", ""))
+		Expect(err).To(HaveOccurred())
+
+		fakeContainerPool.CreateError = nil
+
+		_, err = linuxBackend.Create(withLimits("b", "1500", "", ""))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("rejects limit properties that are not numbers", func() {
+		_, err := linuxBackend.Create(withLimits("a", "lots", "", ""))
+		Expect(err).To(Equal(linux_backend.InvalidLimitPropertyError{
+			Property: linux_backend.MemoryLimitProperty,
+			Value:    "lots",
+		}))
+	})
+
+	It("reports the remaining headroom as the capacity", func() {
+		_, err := linuxBackend.Create(withLimits("a", "600", "500", ""))
+		Expect(err).ToNot(HaveOccurred())
+
+		capacity, err := linuxBackend.Capacity()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(capacity.MemoryInBytes).To(Equal(uint64(900)))
+		Expect(capacity.DiskInBytes).To(Equal(uint64(1500)))
+	})
+
+	Describe("changing the limits of a container", func() {
+		It("counts the limits applied after create", func() {
+			container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "a"})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitMemory(garden.MemoryLimits{LimitInBytes: 1000})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = linuxBackend.Create(withLimits("b", "501", "", ""))
+			Expect(err).To(Equal(linux_backend.InsufficientCapacityError{
+				Resource:  "memory",
+				Requested: 501,
+				Available: 500,
+			}))
+		})
+
+		It("rejects raising a limit past the headroom", func() {
+			_, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			container, err := linuxBackend.Create(withLimits("b", "400", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitMemory(garden.MemoryLimits{LimitInBytes: 600})
+			Expect(err).To(Equal(linux_backend.InsufficientCapacityError{
+				Resource:  "memory",
+				Requested: 200,
+				Available: 100,
+			}))
+
+			fakeContainer := container.(*fake_container_pool.FakeContainer)
+			Expect(fakeContainer.LimitMemoryCallCount()).To(Equal(1))
+		})
+
+		It("rejects raising the limit past the headroom through the full memory limits", func() {
+			container, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = linuxBackend.SetMemoryLimits("a", linux_backend.MemoryLimits{LimitInBytes: 1600})
+			Expect(err).To(BeAssignableToTypeOf(linux_backend.InsufficientCapacityError{}))
+
+			fakeContainer := container.(*fake_container_pool.FakeContainer)
+			Expect(fakeContainer.MemoryLimitsValue).To(BeZero())
+		})
+
+		It("rejects raising the CPU shares past the maximum", func() {
+			container, err := linuxBackend.Create(withLimits("a", "", "", "50"))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitCPU(garden.CPULimits{LimitInShares: 101})
+			Expect(err).To(BeAssignableToTypeOf(linux_backend.InsufficientCapacityError{}))
+		})
+
+		It("always allows lowering a limit", func() {
+			container, err := linuxBackend.Create(withLimits("a", "1500", "2000", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeSystemInfo.TotalMemoryResult = 500
+
+			err = container.LimitMemory(garden.MemoryLimits{LimitInBytes: 1000})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitDisk(garden.DiskLimits{ByteHard: 2000})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("frees up the headroom of a lowered limit", func() {
+			container, err := linuxBackend.Create(withLimits("a", "1500", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitMemory(garden.MemoryLimits{LimitInBytes: 500})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = linuxBackend.Create(withLimits("b", "1000", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		Context("when applying the limit fails", func() {
+			It("keeps counting the limit the container had", func() {
+				container, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+				Expect(err).ToNot(HaveOccurred())
+
+				fakeContainer := container.(*fake_container_pool.FakeContainer)
+				fakeContainer.LimitMemoryReturns(errors.New("no memory cgroup"))
+
+				err = container.LimitMemory(garden.MemoryLimits{LimitInBytes: 100})
+				Expect(err).To(MatchError("no memory cgroup"))
+
+				_, err = linuxBackend.Create(withLimits("b", "501", "", ""))
+				Expect(err).To(BeAssignableToTypeOf(linux_backend.InsufficientCapacityError{}))
+			})
+		})
+	})
+
+	Describe("changing the properties admission was decided on", func() {
+		BeforeEach(func() {
+			policy.TenantProperty = "tenant"
+		})
+
+		It("refuses to set or remove the limit properties", func() {
+			container, err := linuxBackend.Create(withLimits("a", "1000", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.SetProperty(linux_backend.MemoryLimitProperty, "1")
+			Expect(err).To(Equal(linux_backend.ReadOnlyPropertyError{linux_backend.MemoryLimitProperty}))
+
+			err = container.RemoveProperty(linux_backend.MemoryLimitProperty)
+			Expect(err).To(Equal(linux_backend.ReadOnlyPropertyError{linux_backend.MemoryLimitProperty}))
+
+			fakeContainer := container.(*fake_container_pool.FakeContainer)
+			Expect(fakeContainer.SetPropertyCallCount()).To(BeZero())
+			Expect(fakeContainer.RemovePropertyCallCount()).To(BeZero())
+		})
+
+		It("refuses to set or remove the tenant property", func() {
+			container, err := linuxBackend.Create(withLimits("a", "", "", "", "tenant", "blue"))
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.SetProperty("tenant", "green")
+			Expect(err).To(Equal(linux_backend.ReadOnlyPropertyError{"tenant"}))
+
+			err = container.RemoveProperty("tenant")
+			Expect(err).To(Equal(linux_backend.ReadOnlyPropertyError{"tenant"}))
+		})
+
+		It("allows other properties to change", func() {
+			container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "a"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.SetProperty("colour", "blue")).To(Succeed())
+			Expect(container.RemoveProperty("colour")).To(Succeed())
+		})
+	})
+
+	Context("when tenants have quotas", func() {
+		BeforeEach(func() {
+			policy.TenantProperty = "tenant"
+			policy.TenantQuota = linux_backend.AdmissionLimits{MemoryInBytes: 400}
+		})
+
+		It("holds each tenant to its quota", func() {
+			_, err := linuxBackend.Create(withLimits("a", "300", "", "", "tenant", "blue"))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = linuxBackend.Create(withLimits("b", "300", "", "", "tenant", "green"))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = linuxBackend.Create(withLimits("c", "200", "", "", "tenant", "blue"))
+			Expect(err).To(Equal(linux_backend.InsufficientCapacityError{
+				Resource:  "memory",
+				Tenant:    "blue",
+				Requested: 200,
+				Available: 100,
+			}))
+		})
+
+		It("does not hold containers without a tenant to a quota", func() {
+			_, err := linuxBackend.Create(withLimits("a", "500", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when creates may queue", func() {
+		BeforeEach(func() {
+			policy.QueueTimeout = time.Second
+		})
+
+		It("admits a waiting create once enough containers are destroyed", func() {
+			_, err := linuxBackend.Create(withLimits("a", "1500", "", ""))
+			Expect(err).ToNot(HaveOccurred())
+
+			created := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+
+				_, err := linuxBackend.Create(withLimits("b", "1000", "", ""))
+				created <- err
+			}()
+
+			Consistently(created, 100*time.Millisecond).ShouldNot(Receive())
+
+			Expect(linuxBackend.Destroy("a")).To(Succeed())
+
+			Eventually(created).Should(Receive(BeNil()))
+		})
+
+		Context("when no headroom frees up in time", func() {
+			BeforeEach(func() {
+				policy.QueueTimeout = 50 * time.Millisecond
+			})
+
+			It("rejects the create", func() {
+				_, err := linuxBackend.Create(withLimits("a", "1500", "", ""))
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = linuxBackend.Create(withLimits("b", "1000", "", ""))
+				Expect(err).To(BeAssignableToTypeOf(linux_backend.InsufficientCapacityError{}))
+			})
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package linux_backend
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+)
+
+type bindMounter struct{}
+
+func NewBindMounter() BindMounter {
+	return &bindMounter{}
+}
+
+var propagationFlags = map[string]uintptr{
+	"private":  syscall.MS_PRIVATE,
+	"rprivate": syscall.MS_PRIVATE | syscall.MS_REC,
+	"slave":    syscall.MS_SLAVE,
+	"rslave":   syscall.MS_SLAVE | syscall.MS_REC,
+	"shared":   syscall.MS_SHARED,
+	"rshared":  syscall.MS_SHARED | syscall.MS_REC,
+}
+
+func (*bindMounter) BindMount(m BindMount) error {
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("linux_backend: BindMount: %s", err)
+	}
+
+	if err := createMountPoint(m.SrcPath, m.DstPath); err != nil {
+		return fmt.Errorf("linux_backend: BindMount: creating %s: %s", m.DstPath, err)
+	}
+
+	flags := uintptr(syscall.MS_BIND)
+	if m.Recursive {
+		flags |= syscall.MS_REC
+	}
+
+	if err := syscall.Mount(m.SrcPath, m.DstPath, "", flags, ""); err != nil {
+		return fmt.Errorf("linux_backend: BindMount: mounting %s on %s: %s", m.SrcPath, m.DstPath, err)
+	}
+
+	// the read-only flag is ignored by the initial bind mount, so it has to be
+	// applied with a remount
+	if m.ReadOnly {
+		if err := syscall.Mount(m.SrcPath, m.DstPath, "", flags|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("linux_backend: BindMount: remounting %s read-only: %s", m.DstPath, err)
+		}
+	}
+
+	if m.Propagation != "" {
+		if err := syscall.Mount("", m.DstPath, "", propagationFlags[m.Propagation], ""); err != nil {
+			return fmt.Errorf("linux_backend: BindMount: making %s %s: %s", m.DstPath, m.Propagation, err)
+		}
+	}
+
+	return nil
+}
+
+// createMountPoint creates a directory to mount a directory on, or an empty
+// file to mount a file on.
+func createMountPoint(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return os.MkdirAll(dst, 0755)
+	}
+
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(dst, os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
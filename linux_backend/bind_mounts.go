@@ -0,0 +1,120 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// BindMountsFile is where a container's bind mounts are recorded, relative to
+// the container's depot directory.
+const BindMountsFile = "etc/bind-mounts.json"
+
+// BindMount is a bind mount to be performed before the container is cloned.
+// Both paths are absolute paths on the host.
+type BindMount struct {
+	SrcPath string `json:"src_path"`
+	DstPath string `json:"dst_path"`
+
+	ReadOnly  bool `json:"read_only"`
+	Recursive bool `json:"recursive"`
+
+	// one of the mount propagation types, e.g. "rslave"; empty leaves the
+	// propagation of the mount as it is
+	Propagation string `json:"propagation,omitempty"`
+}
+
+var BindMountPropagations = []string{
+	"private", "rprivate",
+	"slave", "rslave",
+	"shared", "rshared",
+}
+
+type InvalidBindMountError struct {
+	Mount  BindMount
+	Reason string
+}
+
+func (e InvalidBindMountError) Error() string {
+	return fmt.Sprintf("invalid bind mount %s -> %s: %s", e.Mount.SrcPath, e.Mount.DstPath, e.Reason)
+}
+
+// Validate checks that the mount's source exists and that its options are
+// known, so that mistakes surface when the container is created rather than
+// when it is started.
+func (m BindMount) Validate() error {
+	if err := m.ValidateSpec(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(m.SrcPath); err != nil {
+		return InvalidBindMountError{m, fmt.Sprintf("source: %v", err)}
+	}
+
+	return nil
+}
+
+// ValidateSpec checks everything Validate does but whether the source exists,
+// for mounts whose source is not in place yet, e.g. because it is in a rootfs
+// that has not been provided.
+func (m BindMount) ValidateSpec() error {
+	if !path.IsAbs(m.SrcPath) || !path.IsAbs(m.DstPath) {
+		return InvalidBindMountError{m, "paths must be absolute"}
+	}
+
+	if m.Propagation != "" && !isBindMountPropagation(m.Propagation) {
+		return InvalidBindMountError{m, fmt.Sprintf("unknown propagation %q", m.Propagation)}
+	}
+
+	return nil
+}
+
+func isBindMountPropagation(propagation string) bool {
+	for _, p := range BindMountPropagations {
+		if p == propagation {
+			return true
+		}
+	}
+
+	return false
+}
+
+func WriteBindMounts(containerPath string, mounts []BindMount) error {
+	mountsPath := path.Join(containerPath, BindMountsFile)
+
+	err := os.MkdirAll(path.Dir(mountsPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(mounts)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(mountsPath, data, 0644)
+}
+
+// ReadBindMounts returns the container's recorded bind mounts, or none for
+// containers that were created before bind mounts were recorded.
+func ReadBindMounts(containerPath string) ([]BindMount, error) {
+	data, err := ioutil.ReadFile(path.Join(containerPath, BindMountsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var mounts []BindMount
+
+	err = json.Unmarshal(data, &mounts)
+	if err != nil {
+		return nil, fmt.Errorf("linux_backend: decoding bind mounts: %v", err)
+	}
+
+	return mounts, nil
+}
@@ -0,0 +1,97 @@
+package linux_backend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bind mounts", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "bind-mounts")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("Validate", func() {
+		It("accepts an existing source and a known propagation", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath:     tmpDir,
+				DstPath:     "/some/dst",
+				Propagation: "rslave",
+			}.Validate()).To(Succeed())
+		})
+
+		It("rejects relative paths", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath: tmpDir,
+				DstPath: "some/dst",
+			}.Validate()).To(BeAssignableToTypeOf(linux_backend.InvalidBindMountError{}))
+		})
+
+		It("rejects a source that does not exist", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath: filepath.Join(tmpDir, "missing"),
+				DstPath: "/some/dst",
+			}.Validate()).To(BeAssignableToTypeOf(linux_backend.InvalidBindMountError{}))
+		})
+
+		It("rejects an unknown propagation", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath:     tmpDir,
+				DstPath:     "/some/dst",
+				Propagation: "sideways",
+			}.Validate()).To(MatchError(ContainSubstring(`unknown propagation "sideways"`)))
+		})
+	})
+
+	Describe("ValidateSpec", func() {
+		It("accepts a source that does not exist yet", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath: filepath.Join(tmpDir, "missing"),
+				DstPath: "/some/dst",
+			}.ValidateSpec()).To(Succeed())
+		})
+
+		It("rejects relative paths", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath: "some/src",
+				DstPath: "/some/dst",
+			}.ValidateSpec()).To(BeAssignableToTypeOf(linux_backend.InvalidBindMountError{}))
+		})
+
+		It("rejects an unknown propagation", func() {
+			Expect(linux_backend.BindMount{
+				SrcPath:     filepath.Join(tmpDir, "missing"),
+				DstPath:     "/some/dst",
+				Propagation: "sideways",
+			}.ValidateSpec()).To(MatchError(ContainSubstring(`unknown propagation "sideways"`)))
+		})
+	})
+
+	Describe("writing and reading", func() {
+		It("round-trips the mounts", func() {
+			mounts := []linux_backend.BindMount{
+				{SrcPath: "/a", DstPath: "/b", ReadOnly: true},
+				{SrcPath: "/c", DstPath: "/d", Recursive: true, Propagation: "shared"},
+			}
+
+			Expect(linux_backend.WriteBindMounts(tmpDir, mounts)).To(Succeed())
+			Expect(linux_backend.ReadBindMounts(tmpDir)).To(Equal(mounts))
+		})
+
+		It("reads no mounts when none were recorded", func() {
+			Expect(linux_backend.ReadBindMounts(tmpDir)).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,118 @@
+package linux_backend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+type BulkOperationTimedOutError struct {
+	Operation string
+	Handle    string
+	Timeout   time.Duration
+}
+
+func (e BulkOperationTimedOutError) Error() string {
+	return fmt.Sprintf("%s for %s timed out after %s", e.Operation, e.Handle, e.Timeout)
+}
+
+type bulkResult struct {
+	value interface{}
+	err   error
+}
+
+// bulk calls operation for the container with each of the handles, on a pool
+// of bulkConcurrency workers, and returns the outcome for each handle.
+//
+// Handles without a container get a ContainerNotFoundError. An operation that
+// takes longer than bulkTimeout gets a BulkOperationTimedOutError, and is
+// left to finish in the background so that it does not hold up the others.
+func (b *LinuxBackend) bulk(name string, handles []string, operation func(Container) (interface{}, error)) map[string]bulkResult {
+	bLog := b.logger.Session(name, lager.Data{
+		"handles": len(handles),
+	})
+
+	workers := b.bulkConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := map[string]bulkResult{}
+	resultsMutex := new(sync.Mutex)
+
+	queue := make(chan string)
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for handle := range queue {
+				result := b.bulkOne(name, handle, operation)
+
+				if _, timedOut := result.err.(BulkOperationTimedOutError); timedOut {
+					bLog.Error("timed-out", result.err, lager.Data{
+						"handle": handle,
+					})
+				}
+
+				resultsMutex.Lock()
+				results[handle] = result
+				resultsMutex.Unlock()
+			}
+		}()
+	}
+
+	queued := map[string]bool{}
+	for _, handle := range handles {
+		if queued[handle] {
+			continue
+		}
+
+		queued[handle] = true
+		queue <- handle
+	}
+
+	close(queue)
+
+	wg.Wait()
+
+	return results
+}
+
+func (b *LinuxBackend) bulkOne(name, handle string, operation func(Container) (interface{}, error)) bulkResult {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return bulkResult{err: err}
+	}
+
+	done := make(chan bulkResult, 1)
+
+	go func() {
+		value, err := operation(container)
+		done <- bulkResult{value, err}
+	}()
+
+	var timeout <-chan time.Time
+	if b.bulkTimeout > 0 {
+		timeout = time.After(b.bulkTimeout)
+	}
+
+	select {
+	case result := <-done:
+		return result
+	case <-timeout:
+		return bulkResult{
+			err: BulkOperationTimedOutError{
+				Operation: name,
+				Handle:    handle,
+				Timeout:   b.bulkTimeout,
+			},
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package linux_backend
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// DrainingError is returned by Create once the backend is draining.
+type DrainingError struct{}
+
+func (e DrainingError) Error() string {
+	return "draining: not accepting new containers"
+}
+
+// drainState tracks whether the backend is draining, and the creates still
+// in flight, which must finish before the backend can be considered empty.
+type drainState struct {
+	mutex *sync.Mutex
+
+	draining bool
+	creating int
+
+	drained chan struct{}
+}
+
+func newDrainState() *drainState {
+	return &drainState{
+		mutex:   &sync.Mutex{},
+		drained: make(chan struct{}),
+	}
+}
+
+// Drain stops the backend from accepting new containers, while leaving the
+// existing ones to be used and destroyed as usual. The returned channel is
+// closed once no containers remain. Draining cannot be undone; calling Drain
+// again returns the same channel.
+func (b *LinuxBackend) Drain() <-chan struct{} {
+	b.drain.mutex.Lock()
+	defer b.drain.mutex.Unlock()
+
+	if !b.drain.draining {
+		b.drain.draining = true
+
+		b.logger.Info("draining", lager.Data{
+			"containers": len(b.containerRepo.All()),
+		})
+
+		b.checkDrained()
+	}
+
+	return b.drain.drained
+}
+
+func (b *LinuxBackend) Draining() bool {
+	b.drain.mutex.Lock()
+	defer b.drain.mutex.Unlock()
+
+	return b.drain.draining
+}
+
+// beginCreate registers a create in flight, unless the backend is draining.
+func (b *LinuxBackend) beginCreate() error {
+	b.drain.mutex.Lock()
+	defer b.drain.mutex.Unlock()
+
+	if b.drain.draining {
+		return DrainingError{}
+	}
+
+	b.drain.creating++
+
+	return nil
+}
+
+func (b *LinuxBackend) endCreate() {
+	b.drain.mutex.Lock()
+	defer b.drain.mutex.Unlock()
+
+	b.drain.creating--
+	b.checkDrained()
+}
+
+func (b *LinuxBackend) containerRemoved() {
+	b.drain.mutex.Lock()
+	defer b.drain.mutex.Unlock()
+
+	b.checkDrained()
+}
+
+// checkDrained closes the drained channel if the backend is draining and
+// empty. It must be called with the drain mutex held.
+func (b *LinuxBackend) checkDrained() {
+	if !b.drain.draining || b.drain.creating > 0 || len(b.containerRepo.All()) > 0 {
+		return
+	}
+
+	select {
+	case <-b.drain.drained:
+	default:
+		b.logger.Info("drained")
+		close(b.drain.drained)
+	}
+}
+
+// DrainHandler starts draining the daemon on POST, by calling drain.
+type DrainHandler struct {
+	logger lager.Logger
+	drain  func()
+}
+
+func NewDrainHandler(logger lager.Logger, drain func()) *DrainHandler {
+	return &DrainHandler{
+		logger: logger.Session("drain-handler"),
+		drain:  drain,
+	}
+}
+
+func (h *DrainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.logger.Info("requested")
+	h.drain()
+
+	w.WriteHeader(http.StatusAccepted)
+}
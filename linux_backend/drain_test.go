@@ -0,0 +1,45 @@
+package linux_backend_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("DrainHandler", func() {
+	var drains int
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		drains = 0
+		server = httptest.NewServer(linux_backend.NewDrainHandler(lagertest.NewTestLogger("test"), func() {
+			drains++
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("starts draining on POST", func() {
+		response, err := http.Post(server.URL+"/drain", "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusAccepted))
+		Expect(drains).To(Equal(1))
+	})
+
+	It("rejects other methods", func() {
+		response, err := http.Get(server.URL + "/drain")
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		Expect(drains).To(BeZero())
+	})
+})
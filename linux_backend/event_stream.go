@@ -0,0 +1,110 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/pivotal-golang/lager"
+)
+
+// EventStreamHandler streams the events published on the bus as
+// server-sent events, each one named after its type with the event as JSON
+// for its data.
+//
+// The stream can be narrowed with the query parameters "handle", "property"
+// (as key=value) and "type", each of which may be given more than once.
+// Events must match any of the handles, all of the properties, and any of the
+// types.
+type EventStreamHandler struct {
+	logger lager.Logger
+	events *EventBus
+}
+
+func NewEventStreamHandler(logger lager.Logger, events *EventBus) *EventStreamHandler {
+	return &EventStreamHandler{
+		logger: logger.Session("event-stream"),
+		events: events,
+	}
+}
+
+func (h *EventStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	subscription := h.events.Subscribe(filter)
+	defer subscription.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				h.logger.Error("failed-to-marshal-event", err)
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+		case <-closed:
+			return
+		}
+	}
+}
+
+func parseEventFilter(r *http.Request) (EventFilter, error) {
+	query := r.URL.Query()
+
+	filter := EventFilter{
+		Handles: query["handle"],
+	}
+
+	for _, t := range query["type"] {
+		filter.Types = append(filter.Types, EventType(t))
+	}
+
+	for _, property := range query["property"] {
+		kv := strings.SplitN(property, "=", 2)
+		if len(kv) != 2 {
+			return EventFilter{}, fmt.Errorf("invalid property filter %q: must be key=value", property)
+		}
+
+		if filter.Properties == nil {
+			filter.Properties = garden.Properties{}
+		}
+
+		filter.Properties[kv[0]] = kv[1]
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,200 @@
+package linux_backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/pivotal-golang/lager"
+)
+
+type EventType string
+
+const (
+	EventCreated        = EventType("created")
+	EventStarted        = EventType("started")
+	EventStopped        = EventType("stopped")
+	EventPaused         = EventType("paused")
+	EventResumed        = EventType("resumed")
+	EventCheckpointed   = EventType("checkpointed")
+	EventDestroyed      = EventType("destroyed")
+	EventOutOfMemory    = EventType("oom")
+	EventProcessSpawned = EventType("process-spawned")
+	EventProcessExited  = EventType("process-exited")
+	EventLimitChanged   = EventType("limit-changed")
+	EventNetInAdded     = EventType("net-in-added")
+	EventSnapshotSaved  = EventType("snapshot-saved")
+	EventRestoreFailed  = EventType("restore-failed")
+	EventReaped         = EventType("reaped")
+)
+
+// Event is something that happened to a container. Only the fields relevant
+// to the event's type are set.
+type Event struct {
+	Type       EventType         `json:"type"`
+	Time       time.Time         `json:"time"`
+	Handle     string            `json:"handle"`
+	Properties garden.Properties `json:"properties,omitempty"`
+
+	// process-spawned and process-exited; ExitStatus is nil if waiting for
+	// the process failed
+	ProcessID  uint32 `json:"process_id,omitempty"`
+	ExitStatus *int   `json:"exit_status,omitempty"`
+
+	// limit-changed: one of "memory", "cpu", "disk" or "bandwidth"
+	Limit string `json:"limit,omitempty"`
+
+	// net-in-added
+	HostPort      uint32 `json:"host_port,omitempty"`
+	ContainerPort uint32 `json:"container_port,omitempty"`
+
+	// stopped: the processes that had to be killed, having not exited within
+	// the container's stop grace time
+	ForceKilledProcessIDs []uint32 `json:"force_killed_process_ids,omitempty"`
+
+	// reaped: "destroy" or "stop", and whether it was only a dry run
+	Action string `json:"action,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+
+	// restore-failed: what could not be restored, and why; reaped: how long
+	// the container had been idle
+	Message string `json:"message,omitempty"`
+}
+
+// EventFilter selects the events a subscriber receives. An empty field
+// matches every event.
+type EventFilter struct {
+	Handles    []string
+	Properties garden.Properties
+	Types      []EventType
+}
+
+func (f EventFilter) Matches(event Event) bool {
+	if len(f.Handles) > 0 && !containsString(f.Handles, event.Handle) {
+		return false
+	}
+
+	for key, value := range f.Properties {
+		if actual, found := event.Properties[key]; !found || actual != value {
+			return false
+		}
+	}
+
+	if len(f.Types) > 0 {
+		for _, t := range f.Types {
+			if t == event.Type {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// eventBufferSize is how many events a subscriber may fall behind by before
+// further events are dropped for it.
+const eventBufferSize = 256
+
+// EventBus fans the events published by the backend and its containers out
+// to in-process subscribers. Publishing never blocks: a subscriber that falls
+// too far behind misses events.
+type EventBus struct {
+	logger lager.Logger
+
+	mutex         *sync.RWMutex
+	subscriptions map[*EventSubscription]bool
+}
+
+func NewEventBus(logger lager.Logger) *EventBus {
+	return &EventBus{
+		logger: logger.Session("events"),
+
+		mutex:         &sync.RWMutex{},
+		subscriptions: map[*EventSubscription]bool{},
+	}
+}
+
+type EventSubscription struct {
+	bus    *EventBus
+	filter EventFilter
+	events chan Event
+}
+
+// Events is closed once the subscription is closed.
+func (s *EventSubscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *EventSubscription) Close() {
+	s.bus.mutex.Lock()
+	defer s.bus.mutex.Unlock()
+
+	if s.bus.subscriptions[s] {
+		delete(s.bus.subscriptions, s)
+		close(s.events)
+	}
+}
+
+func (b *EventBus) Subscribe(filter EventFilter) *EventSubscription {
+	subscription := &EventSubscription{
+		bus:    b,
+		filter: filter,
+		events: make(chan Event, eventBufferSize),
+	}
+
+	b.mutex.Lock()
+	b.subscriptions[subscription] = true
+	b.mutex.Unlock()
+
+	return subscription
+}
+
+// Publish stamps the event with the current time, unless it already has one,
+// and delivers it to every matching subscriber.
+func (b *EventBus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for subscription := range b.subscriptions {
+		if !subscription.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case subscription.events <- event:
+		default:
+			b.logger.Info("dropped-event-for-slow-subscriber", lager.Data{
+				"type":   event.Type,
+				"handle": event.Handle,
+			})
+		}
+	}
+}
+
+// publishFor publishes an event about the container, filling in its handle
+// and properties.
+func (b *EventBus) publishFor(container Container, event Event) {
+	event.Handle = container.Handle()
+
+	if properties, err := container.GetProperties(); err == nil {
+		event.Properties = properties
+	}
+
+	b.Publish(event)
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,176 @@
+package linux_backend_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("EventBus", func() {
+	var bus *linux_backend.EventBus
+
+	BeforeEach(func() {
+		bus = linux_backend.NewEventBus(lagertest.NewTestLogger("test"))
+	})
+
+	It("delivers published events to subscribers", func() {
+		subscription := bus.Subscribe(linux_backend.EventFilter{})
+		defer subscription.Close()
+
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStarted, Handle: "some-handle"})
+
+		var event linux_backend.Event
+		Expect(subscription.Events()).To(Receive(&event))
+		Expect(event.Type).To(Equal(linux_backend.EventStarted))
+		Expect(event.Handle).To(Equal("some-handle"))
+	})
+
+	It("stamps events without a time", func() {
+		subscription := bus.Subscribe(linux_backend.EventFilter{})
+		defer subscription.Close()
+
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStarted})
+
+		var event linux_backend.Event
+		Expect(subscription.Events()).To(Receive(&event))
+		Expect(event.Time).ToNot(BeZero())
+	})
+
+	It("only delivers events matching the subscriber's filter", func() {
+		subscription := bus.Subscribe(linux_backend.EventFilter{
+			Handles:    []string{"a", "b"},
+			Properties: garden.Properties{"tenant": "blue"},
+			Types:      []linux_backend.EventType{linux_backend.EventStopped},
+		})
+		defer subscription.Close()
+
+		blue := garden.Properties{"tenant": "blue", "other": "x"}
+
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStopped, Handle: "c", Properties: blue})
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStopped, Handle: "a", Properties: garden.Properties{"tenant": "green"}})
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStarted, Handle: "a", Properties: blue})
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStopped, Handle: "b", Properties: blue})
+
+		var event linux_backend.Event
+		Expect(subscription.Events()).To(Receive(&event))
+		Expect(event.Handle).To(Equal("b"))
+
+		Expect(subscription.Events()).ToNot(Receive())
+	})
+
+	It("does not block on subscribers that have fallen behind", func() {
+		subscription := bus.Subscribe(linux_backend.EventFilter{})
+		defer subscription.Close()
+
+		published := make(chan struct{})
+		go func() {
+			for i := 0; i < 1000; i++ {
+				bus.Publish(linux_backend.Event{Type: linux_backend.EventStarted})
+			}
+
+			close(published)
+		}()
+
+		Eventually(published).Should(BeClosed())
+	})
+
+	It("stops delivering events once the subscription is closed", func() {
+		subscription := bus.Subscribe(linux_backend.EventFilter{})
+		subscription.Close()
+
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventStarted})
+
+		Expect(subscription.Events()).To(BeClosed())
+	})
+})
+
+var _ = Describe("EventStreamHandler", func() {
+	var bus *linux_backend.EventBus
+	var server *httptest.Server
+	var responses []*http.Response
+
+	BeforeEach(func() {
+		bus = linux_backend.NewEventBus(lagertest.NewTestLogger("test"))
+		server = httptest.NewServer(linux_backend.NewEventStreamHandler(lagertest.NewTestLogger("test"), bus))
+		responses = nil
+	})
+
+	AfterEach(func() {
+		for _, response := range responses {
+			response.Body.Close()
+		}
+
+		server.Close()
+	})
+
+	stream := func(query string) *bufio.Reader {
+		response, err := http.Get(server.URL + "/events?" + query)
+		Expect(err).ToNot(HaveOccurred())
+
+		responses = append(responses, response)
+
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+
+		return bufio.NewReader(response.Body)
+	}
+
+	readEvent := func(reader *bufio.Reader) (string, linux_backend.Event) {
+		name, err := reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+
+		data, err := reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+
+		blank, err := reader.ReadString('\n')
+		Expect(err).ToNot(HaveOccurred())
+		Expect(blank).To(Equal("\n"))
+
+		var event linux_backend.Event
+		err = json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &event)
+		Expect(err).ToNot(HaveOccurred())
+
+		return strings.TrimSpace(strings.TrimPrefix(name, "event: ")), event
+	}
+
+	It("streams the published events matching the query", func() {
+		reader := stream("handle=some-handle&property=tenant=blue&type=process-exited")
+
+		exitStatus := 42
+
+		// the subscription is made before the response headers are sent
+		bus.Publish(linux_backend.Event{Type: linux_backend.EventProcessExited, Handle: "other-handle"})
+		bus.Publish(linux_backend.Event{
+			Type:       linux_backend.EventProcessExited,
+			Time:       time.Unix(1000, 0),
+			Handle:     "some-handle",
+			Properties: garden.Properties{"tenant": "blue"},
+			ProcessID:  3,
+			ExitStatus: &exitStatus,
+		})
+
+		name, event := readEvent(reader)
+		Expect(name).To(Equal("process-exited"))
+		Expect(event.Handle).To(Equal("some-handle"))
+		Expect(event.ProcessID).To(Equal(uint32(3)))
+		Expect(*event.ExitStatus).To(Equal(42))
+		Expect(event.Time.Equal(time.Unix(1000, 0))).To(BeTrue())
+	})
+
+	Context("when a property filter is malformed", func() {
+		It("responds with 400", func() {
+			response, err := http.Get(server.URL + "/events?property=tenant")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,53 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+)
+
+type FakeBindMounter struct {
+	BindMountStub        func(linux_backend.BindMount) error
+	bindMountMutex       sync.RWMutex
+	bindMountArgsForCall []struct {
+		arg1 linux_backend.BindMount
+	}
+	bindMountReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeBindMounter) BindMount(arg1 linux_backend.BindMount) error {
+	fake.bindMountMutex.Lock()
+	fake.bindMountArgsForCall = append(fake.bindMountArgsForCall, struct {
+		arg1 linux_backend.BindMount
+	}{arg1})
+	fake.bindMountMutex.Unlock()
+	if fake.BindMountStub != nil {
+		return fake.BindMountStub(arg1)
+	} else {
+		return fake.bindMountReturns.result1
+	}
+}
+
+func (fake *FakeBindMounter) BindMountCallCount() int {
+	fake.bindMountMutex.RLock()
+	defer fake.bindMountMutex.RUnlock()
+	return len(fake.bindMountArgsForCall)
+}
+
+func (fake *FakeBindMounter) BindMountArgsForCall(i int) linux_backend.BindMount {
+	fake.bindMountMutex.RLock()
+	defer fake.bindMountMutex.RUnlock()
+	return fake.bindMountArgsForCall[i].arg1
+}
+
+func (fake *FakeBindMounter) BindMountReturns(result1 error) {
+	fake.BindMountStub = nil
+	fake.bindMountReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ linux_backend.BindMounter = new(FakeBindMounter)
@@ -37,6 +37,12 @@ type FakeContainer struct {
 	startReturns     struct {
 		result1 error
 	}
+	CheckpointStub        func() error
+	checkpointMutex       sync.RWMutex
+	checkpointArgsForCall []struct{}
+	checkpointReturns     struct {
+		result1 error
+	}
 	SnapshotStub        func(io.Writer) error
 	snapshotMutex       sync.RWMutex
 	snapshotArgsForCall []struct {
@@ -45,13 +51,55 @@ type FakeContainer struct {
 	snapshotReturns struct {
 		result1 error
 	}
-	CleanupStub        func()
-	cleanupMutex       sync.RWMutex
-	cleanupArgsForCall []struct{}
-	HandleStub         func() string
-	handleMutex        sync.RWMutex
-	handleArgsForCall  []struct{}
-	handleReturns      struct {
+	CleanupStub         func()
+	cleanupMutex        sync.RWMutex
+	cleanupArgsForCall  []struct{}
+	OnChangeStub        func(handler func())
+	onChangeMutex       sync.RWMutex
+	onChangeArgsForCall []struct {
+		handler func()
+	}
+	OnEventStub        func(handler func(linux_backend.Event))
+	onEventMutex       sync.RWMutex
+	onEventArgsForCall []struct {
+		handler func(linux_backend.Event)
+	}
+	ActiveProcessesStub        func() []garden.Process
+	activeProcessesMutex       sync.RWMutex
+	activeProcessesArgsForCall []struct{}
+	activeProcessesReturns     struct {
+		result1 []garden.Process
+	}
+	SetMemoryLimitsStub        func(linux_backend.MemoryLimits) error
+	setMemoryLimitsMutex       sync.RWMutex
+	setMemoryLimitsArgsForCall []struct {
+		arg1 linux_backend.MemoryLimits
+	}
+	setMemoryLimitsReturns struct {
+		result1 error
+	}
+	MemoryLimitsStub        func() (linux_backend.MemoryLimits, error)
+	memoryLimitsMutex       sync.RWMutex
+	memoryLimitsArgsForCall []struct{}
+	memoryLimitsReturns     struct {
+		result1 linux_backend.MemoryLimits
+		result2 error
+	}
+	AppliedLimitsStub        func() linux_backend.AdmissionLimits
+	appliedLimitsMutex       sync.RWMutex
+	appliedLimitsArgsForCall []struct{}
+	appliedLimitsReturns     struct {
+		result1 linux_backend.AdmissionLimits
+	}
+	GuardStub        func(linux_backend.ChangeGuard)
+	guardMutex       sync.RWMutex
+	guardArgsForCall []struct {
+		arg1 linux_backend.ChangeGuard
+	}
+	HandleStub        func() string
+	handleMutex       sync.RWMutex
+	handleArgsForCall []struct{}
+	handleReturns     struct {
 		result1 string
 	}
 	StopStub        func(kill bool) error
@@ -332,6 +380,30 @@ func (fake *FakeContainer) StartReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainer) Checkpoint() error {
+	fake.checkpointMutex.Lock()
+	fake.checkpointArgsForCall = append(fake.checkpointArgsForCall, struct{}{})
+	fake.checkpointMutex.Unlock()
+	if fake.CheckpointStub != nil {
+		return fake.CheckpointStub()
+	} else {
+		return fake.checkpointReturns.result1
+	}
+}
+
+func (fake *FakeContainer) CheckpointCallCount() int {
+	fake.checkpointMutex.RLock()
+	defer fake.checkpointMutex.RUnlock()
+	return len(fake.checkpointArgsForCall)
+}
+
+func (fake *FakeContainer) CheckpointReturns(result1 error) {
+	fake.CheckpointStub = nil
+	fake.checkpointReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainer) Snapshot(arg1 io.Writer) error {
 	fake.snapshotMutex.Lock()
 	fake.snapshotArgsForCall = append(fake.snapshotArgsForCall, struct {
@@ -379,6 +451,180 @@ func (fake *FakeContainer) CleanupCallCount() int {
 	return len(fake.cleanupArgsForCall)
 }
 
+func (fake *FakeContainer) OnChange(handler func()) {
+	fake.onChangeMutex.Lock()
+	fake.onChangeArgsForCall = append(fake.onChangeArgsForCall, struct {
+		handler func()
+	}{handler})
+	fake.onChangeMutex.Unlock()
+	if fake.OnChangeStub != nil {
+		fake.OnChangeStub(handler)
+	}
+}
+
+func (fake *FakeContainer) OnChangeCallCount() int {
+	fake.onChangeMutex.RLock()
+	defer fake.onChangeMutex.RUnlock()
+	return len(fake.onChangeArgsForCall)
+}
+
+func (fake *FakeContainer) OnChangeArgsForCall(i int) func() {
+	fake.onChangeMutex.RLock()
+	defer fake.onChangeMutex.RUnlock()
+	return fake.onChangeArgsForCall[i].handler
+}
+
+func (fake *FakeContainer) OnEvent(handler func(linux_backend.Event)) {
+	fake.onEventMutex.Lock()
+	fake.onEventArgsForCall = append(fake.onEventArgsForCall, struct {
+		handler func(linux_backend.Event)
+	}{handler})
+	fake.onEventMutex.Unlock()
+	if fake.OnEventStub != nil {
+		fake.OnEventStub(handler)
+	}
+}
+
+func (fake *FakeContainer) OnEventCallCount() int {
+	fake.onEventMutex.RLock()
+	defer fake.onEventMutex.RUnlock()
+	return len(fake.onEventArgsForCall)
+}
+
+func (fake *FakeContainer) OnEventArgsForCall(i int) func(linux_backend.Event) {
+	fake.onEventMutex.RLock()
+	defer fake.onEventMutex.RUnlock()
+	return fake.onEventArgsForCall[i].handler
+}
+
+func (fake *FakeContainer) ActiveProcesses() []garden.Process {
+	fake.activeProcessesMutex.Lock()
+	fake.activeProcessesArgsForCall = append(fake.activeProcessesArgsForCall, struct{}{})
+	fake.activeProcessesMutex.Unlock()
+	if fake.ActiveProcessesStub != nil {
+		return fake.ActiveProcessesStub()
+	} else {
+		return fake.activeProcessesReturns.result1
+	}
+}
+
+func (fake *FakeContainer) ActiveProcessesCallCount() int {
+	fake.activeProcessesMutex.RLock()
+	defer fake.activeProcessesMutex.RUnlock()
+	return len(fake.activeProcessesArgsForCall)
+}
+
+func (fake *FakeContainer) ActiveProcessesReturns(result1 []garden.Process) {
+	fake.ActiveProcessesStub = nil
+	fake.activeProcessesReturns = struct {
+		result1 []garden.Process
+	}{result1}
+}
+
+func (fake *FakeContainer) SetMemoryLimits(arg1 linux_backend.MemoryLimits) error {
+	fake.setMemoryLimitsMutex.Lock()
+	fake.setMemoryLimitsArgsForCall = append(fake.setMemoryLimitsArgsForCall, struct {
+		arg1 linux_backend.MemoryLimits
+	}{arg1})
+	fake.setMemoryLimitsMutex.Unlock()
+	if fake.SetMemoryLimitsStub != nil {
+		return fake.SetMemoryLimitsStub(arg1)
+	} else {
+		return fake.setMemoryLimitsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) SetMemoryLimitsCallCount() int {
+	fake.setMemoryLimitsMutex.RLock()
+	defer fake.setMemoryLimitsMutex.RUnlock()
+	return len(fake.setMemoryLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) SetMemoryLimitsArgsForCall(i int) linux_backend.MemoryLimits {
+	fake.setMemoryLimitsMutex.RLock()
+	defer fake.setMemoryLimitsMutex.RUnlock()
+	return fake.setMemoryLimitsArgsForCall[i].arg1
+}
+
+func (fake *FakeContainer) SetMemoryLimitsReturns(result1 error) {
+	fake.SetMemoryLimitsStub = nil
+	fake.setMemoryLimitsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainer) MemoryLimits() (linux_backend.MemoryLimits, error) {
+	fake.memoryLimitsMutex.Lock()
+	fake.memoryLimitsArgsForCall = append(fake.memoryLimitsArgsForCall, struct{}{})
+	fake.memoryLimitsMutex.Unlock()
+	if fake.MemoryLimitsStub != nil {
+		return fake.MemoryLimitsStub()
+	} else {
+		return fake.memoryLimitsReturns.result1, fake.memoryLimitsReturns.result2
+	}
+}
+
+func (fake *FakeContainer) MemoryLimitsCallCount() int {
+	fake.memoryLimitsMutex.RLock()
+	defer fake.memoryLimitsMutex.RUnlock()
+	return len(fake.memoryLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) MemoryLimitsReturns(result1 linux_backend.MemoryLimits, result2 error) {
+	fake.MemoryLimitsStub = nil
+	fake.memoryLimitsReturns = struct {
+		result1 linux_backend.MemoryLimits
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainer) AppliedLimits() linux_backend.AdmissionLimits {
+	fake.appliedLimitsMutex.Lock()
+	fake.appliedLimitsArgsForCall = append(fake.appliedLimitsArgsForCall, struct{}{})
+	fake.appliedLimitsMutex.Unlock()
+	if fake.AppliedLimitsStub != nil {
+		return fake.AppliedLimitsStub()
+	} else {
+		return fake.appliedLimitsReturns.result1
+	}
+}
+
+func (fake *FakeContainer) AppliedLimitsCallCount() int {
+	fake.appliedLimitsMutex.RLock()
+	defer fake.appliedLimitsMutex.RUnlock()
+	return len(fake.appliedLimitsArgsForCall)
+}
+
+func (fake *FakeContainer) AppliedLimitsReturns(result1 linux_backend.AdmissionLimits) {
+	fake.AppliedLimitsStub = nil
+	fake.appliedLimitsReturns = struct {
+		result1 linux_backend.AdmissionLimits
+	}{result1}
+}
+
+func (fake *FakeContainer) Guard(arg1 linux_backend.ChangeGuard) {
+	fake.guardMutex.Lock()
+	fake.guardArgsForCall = append(fake.guardArgsForCall, struct {
+		arg1 linux_backend.ChangeGuard
+	}{arg1})
+	fake.guardMutex.Unlock()
+	if fake.GuardStub != nil {
+		fake.GuardStub(arg1)
+	}
+}
+
+func (fake *FakeContainer) GuardCallCount() int {
+	fake.guardMutex.RLock()
+	defer fake.guardMutex.RUnlock()
+	return len(fake.guardArgsForCall)
+}
+
+func (fake *FakeContainer) GuardArgsForCall(i int) linux_backend.ChangeGuard {
+	fake.guardMutex.RLock()
+	defer fake.guardMutex.RUnlock()
+	return fake.guardArgsForCall[i].arg1
+}
+
 func (fake *FakeContainer) Handle() string {
 	fake.handleMutex.Lock()
 	fake.handleArgsForCall = append(fake.handleArgsForCall, struct{}{})
@@ -22,9 +22,15 @@ type ContainerInitializer interface {
 	MountTmp() error
 }
 
-func RegisterHooks(hs hook.HookSet, runner Runner, config process.Env, containerInitializer ContainerInitializer, configurer network.Configurer) {
+//go:generate counterfeiter . BindMounter
+type BindMounter interface {
+	BindMount(BindMount) error
+}
+
+func RegisterHooks(hs hook.HookSet, runner Runner, config process.Env, containerInitializer ContainerInitializer, bindMounter BindMounter, configurer network.Configurer) {
 	hs.Register(hook.PARENT_BEFORE_CLONE, func() {
 		must(runner.Run(exec.Command("./hook-parent-before-clone.sh")))
+		must(performBindMounts(bindMounter))
 	})
 
 	hs.Register(hook.PARENT_AFTER_CLONE, func() {
@@ -45,6 +51,21 @@ func RegisterHooks(hs hook.HookSet, runner Runner, config process.Env, container
 	})
 }
 
+func performBindMounts(bindMounter BindMounter) error {
+	mounts, err := ReadBindMounts("..")
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mounts {
+		if err := bindMounter.BindMount(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func configureHostNetwork(config process.Env, configurer network.Configurer) error {
 	_, ipNet, err := net.ParseCIDR(config["network_cidr"])
 	if err != nil {
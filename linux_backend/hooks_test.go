@@ -30,6 +30,7 @@ var _ = Describe("Hooks", func() {
 	var fakeRunner *fake_command_runner.FakeCommandRunner
 	var config process.Env
 	var fakeContainerInitializer *linuxBackendFakes.FakeContainerInitializer
+	var fakeBindMounter *linuxBackendFakes.FakeBindMounter
 	var fakeNetworkConfigurer *networkFakes.FakeConfigurer
 
 	BeforeEach(func() {
@@ -46,12 +47,13 @@ var _ = Describe("Hooks", func() {
 			"bridge_iface":            "bridgeName",
 		}
 		fakeContainerInitializer = &linuxBackendFakes.FakeContainerInitializer{}
+		fakeBindMounter = &linuxBackendFakes.FakeBindMounter{}
 		fakeNetworkConfigurer = &networkFakes.FakeConfigurer{}
 	})
 
 	Context("After RegisterHooks has been run", func() {
 		JustBeforeEach(func() {
-			linux_backend.RegisterHooks(hooks, fakeRunner, config, fakeContainerInitializer, fakeNetworkConfigurer)
+			linux_backend.RegisterHooks(hooks, fakeRunner, config, fakeContainerInitializer, fakeBindMounter, fakeNetworkConfigurer)
 		})
 
 		Context("Inside the host", func() {
@@ -76,6 +78,53 @@ var _ = Describe("Hooks", func() {
 						Expect(func() { hooks.Main(hook.PARENT_BEFORE_CLONE) }).To(Panic())
 					})
 				})
+
+				Context("when bind mounts have been recorded in the depot", func() {
+					var oldWd, testDir string
+
+					mounts := []linux_backend.BindMount{
+						{SrcPath: "/src/a", DstPath: "/rootfs/a", ReadOnly: true},
+						{SrcPath: "/src/b", DstPath: "/rootfs/b", Recursive: true, Propagation: "rslave"},
+					}
+
+					BeforeEach(func() {
+						var err error
+						oldWd, err = os.Getwd()
+						Expect(err).NotTo(HaveOccurred())
+
+						testDir, err = ioutil.TempDir("", "test")
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(linux_backend.WriteBindMounts(testDir, mounts)).To(Succeed())
+
+						libDir := filepath.Join(testDir, "lib")
+						os.MkdirAll(libDir, 0755)
+						os.Chdir(libDir)
+					})
+
+					AfterEach(func() {
+						os.Chdir(oldWd)
+						os.RemoveAll(testDir)
+					})
+
+					It("performs them in order", func() {
+						Expect(func() { hooks.Main(hook.PARENT_BEFORE_CLONE) }).ToNot(Panic())
+
+						Expect(fakeBindMounter.BindMountCallCount()).To(Equal(2))
+						Expect(fakeBindMounter.BindMountArgsForCall(0)).To(Equal(mounts[0]))
+						Expect(fakeBindMounter.BindMountArgsForCall(1)).To(Equal(mounts[1]))
+					})
+
+					Context("when a bind mount fails", func() {
+						BeforeEach(func() {
+							fakeBindMounter.BindMountReturns(errors.New("o no"))
+						})
+
+						It("panics", func() {
+							Expect(func() { hooks.Main(hook.PARENT_BEFORE_CLONE) }).To(Panic())
+						})
+					})
+				})
 			})
 
 			Context("after container creation", func() {
@@ -1,11 +1,13 @@
 package linux_backend
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
@@ -21,12 +23,52 @@ type Container interface {
 
 	Start() error
 
+	// Checkpoint dumps the container's processes to its depot, to be restored
+	// with it.
+	Checkpoint() error
+
 	Snapshot(io.Writer) error
 	Cleanup()
 
+	// OnChange registers a handler to be called after any change to the
+	// state the container saves in its snapshot.
+	OnChange(handler func())
+
+	// OnEvent registers a handler to be called with each event the container
+	// emits, e.g. when a process exits.
+	OnEvent(handler func(Event))
+
+	// ActiveProcesses returns the processes that have not yet exited.
+	ActiveProcesses() []garden.Process
+
+	// SetMemoryLimits and MemoryLimits cover all of the memory cgroup's
+	// limits, rather than only the hard limit like LimitMemory and
+	// CurrentMemoryLimits.
+	SetMemoryLimits(MemoryLimits) error
+	MemoryLimits() (MemoryLimits, error)
+
+	// AppliedLimits returns the memory, disk and CPU limits the container is
+	// held to.
+	AppliedLimits() AdmissionLimits
+
+	// Guard has every later change to the container's limits and properties
+	// vetted by the guard.
+	Guard(ChangeGuard)
+
 	garden.Container
 }
 
+// ChangeGuard vets changes to a container before they are made; an error
+// rejects the change.
+type ChangeGuard interface {
+	// ChangeLimits is given the limits the container would be held to, and
+	// calls apply to make the change if they are allowed.
+	ChangeLimits(container Container, limits AdmissionLimits, apply func() error) error
+
+	// ChangeProperty is called before the property is set or removed.
+	ChangeProperty(container Container, key string) error
+}
+
 type ContainerPool interface {
 	Setup() error
 	Create(garden.ContainerSpec) (Container, error)
@@ -42,6 +84,10 @@ type ContainerRepository interface {
 	FindByHandle(string) (Container, error)
 	Query(filter func(Container) bool) []Container
 	Delete(Container)
+
+	// Records returns the persisted snapshot of each container known to a
+	// previous run, keyed by container ID.
+	Records() (map[string][]byte, error)
 }
 
 type LinuxBackend struct {
@@ -51,7 +97,35 @@ type LinuxBackend struct {
 	systemInfo    system_info.Provider
 	snapshotsPath string
 
+	restoreConcurrency int
+	restoreTimeout     time.Duration
+
+	bulkConcurrency int
+	bulkTimeout     time.Duration
+
+	// nil when metrics are not sampled in the background
+	metricsSampler *MetricsSampler
+
+	// nil when idle containers are not reaped
+	reaper *Reaper
+
+	// serialize each container's snapshot writes, so that its latest state
+	// always wins, without holding up any other container's
+	snapshotLocks      map[string]*sync.Mutex
+	snapshotLocksMutex *sync.Mutex
+
 	containerRepo ContainerRepository
+
+	admissionPolicy AdmissionPolicy
+	admission       *admissionController
+
+	// whether Stop checkpoints the containers, so that their processes are
+	// restored with them rather than left running
+	checkpointOnStop bool
+
+	events *EventBus
+
+	drain *drainState
 }
 
 type HandleExistsError struct {
@@ -62,6 +136,15 @@ func (e HandleExistsError) Error() string {
 	return fmt.Sprintf("handle already exists: %s", e.Handle)
 }
 
+type RestoreTimedOutError struct {
+	Name    string
+	Timeout time.Duration
+}
+
+func (e RestoreTimedOutError) Error() string {
+	return fmt.Sprintf("restoring %s timed out after %s", e.Name, e.Timeout)
+}
+
 type FailedToSnapshotError struct {
 	OriginalError error
 }
@@ -76,28 +159,68 @@ func New(
 	containerRepo ContainerRepository,
 	systemInfo system_info.Provider,
 	snapshotsPath string,
+	restoreConcurrency int,
+	restoreTimeout time.Duration,
+	bulkConcurrency int,
+	bulkTimeout time.Duration,
+	metricsSampler *MetricsSampler,
+	reaper *Reaper,
+	admissionPolicy AdmissionPolicy,
+	checkpointOnStop bool,
 ) *LinuxBackend {
 	return &LinuxBackend{
 		logger: logger.Session("backend"),
 
-		containerPool: containerPool,
-		systemInfo:    systemInfo,
-		snapshotsPath: snapshotsPath,
+		containerPool:      containerPool,
+		systemInfo:         systemInfo,
+		snapshotsPath:      snapshotsPath,
+		snapshotLocks:      make(map[string]*sync.Mutex),
+		snapshotLocksMutex: &sync.Mutex{},
+
+		restoreConcurrency: restoreConcurrency,
+		restoreTimeout:     restoreTimeout,
+
+		bulkConcurrency: bulkConcurrency,
+		bulkTimeout:     bulkTimeout,
+
+		metricsSampler: metricsSampler,
+		reaper:         reaper,
 
 		containerRepo: containerRepo,
+
+		admissionPolicy: admissionPolicy,
+		admission:       newAdmissionController(admissionPolicy, systemInfo, containerRepo.All),
+
+		checkpointOnStop: checkpointOnStop,
+
+		events: NewEventBus(logger),
+
+		drain: newDrainState(),
 	}
 }
 
+// Events returns the bus on which the lifecycle events of every container
+// are published.
+func (b *LinuxBackend) Events() *EventBus {
+	return b.events
+}
+
 func (b *LinuxBackend) Setup() error {
 	return b.containerPool.Setup()
 }
 
 func (b *LinuxBackend) Start() error {
+	keep := map[string]bool{}
+
 	if b.snapshotsPath != "" {
 		_, err := os.Stat(b.snapshotsPath)
 		if err == nil {
-			b.restoreSnapshots()
-			os.RemoveAll(b.snapshotsPath)
+			summary := b.restoreSnapshots()
+
+			// timed out restores may still be using their depot directories
+			for _, id := range summary.TimedOut {
+				keep[id] = true
+			}
 		}
 
 		err = os.MkdirAll(b.snapshotsPath, 0755)
@@ -106,7 +229,10 @@ func (b *LinuxBackend) Start() error {
 		}
 	}
 
-	keep := map[string]bool{}
+	summary := b.recoverContainers()
+	for _, id := range summary.TimedOut {
+		keep[id] = true
+	}
 
 	containers := b.containerRepo.All()
 
@@ -114,14 +240,33 @@ func (b *LinuxBackend) Start() error {
 		keep[container.ID()] = true
 	}
 
-	return b.containerPool.Prune(keep)
+	err := b.containerPool.Prune(keep)
+	if err != nil {
+		return err
+	}
+
+	if b.metricsSampler != nil {
+		b.metricsSampler.Start(b.sampleMetrics)
+	}
+
+	if b.reaper != nil {
+		b.reaper.Start(b)
+	}
+
+	return nil
 }
 
 func (b *LinuxBackend) Ping() error {
 	return nil
 }
 
+// Capacity reports no capacity at all while the backend is draining, so that
+// schedulers place containers elsewhere.
 func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
+	if b.Draining() {
+		return garden.Capacity{}, nil
+	}
+
 	totalMemory, err := b.systemInfo.TotalMemory()
 	if err != nil {
 		return garden.Capacity{}, err
@@ -132,6 +277,20 @@ func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
 		return garden.Capacity{}, err
 	}
 
+	headroom, err := b.admission.headroom()
+	if err != nil {
+		return garden.Capacity{}, err
+	}
+
+	// where overcommit is bounded, report what can still be admitted
+	if b.admissionPolicy.MemoryOvercommitRatio > 0 {
+		totalMemory = headroom.MemoryInBytes
+	}
+
+	if b.admissionPolicy.DiskOvercommitRatio > 0 {
+		totalDisk = headroom.DiskInBytes
+	}
+
 	return garden.Capacity{
 		MemoryInBytes: totalMemory,
 		DiskInBytes:   totalDisk,
@@ -140,26 +299,83 @@ func (b *LinuxBackend) Capacity() (garden.Capacity, error) {
 }
 
 func (b *LinuxBackend) Create(spec garden.ContainerSpec) (garden.Container, error) {
+	if err := b.beginCreate(); err != nil {
+		return nil, err
+	}
+	defer b.endCreate()
+
 	if _, err := b.containerRepo.FindByHandle(spec.Handle); spec.Handle != "" && err == nil {
 		return nil, HandleExistsError{Handle: spec.Handle}
 	}
 
+	reservation, err := b.admission.admit(spec)
+	if err != nil {
+		return nil, err
+	}
+	defer b.admission.done(reservation)
+
 	container, err := b.containerPool.Create(spec)
 	if err != nil {
 		return nil, err
 	}
 
+	container.OnEvent(b.events.Publish)
+	b.events.publishFor(container, Event{Type: EventCreated})
+
 	err = container.Start()
 	if err != nil {
-		b.containerPool.Destroy(container)
+		b.discard(container)
 		return nil, err
 	}
 
+	err = applyLimits(container, reservation.limits)
+	if err != nil {
+		b.discard(container)
+		return nil, err
+	}
+
+	container.Guard(b.admission)
+
 	b.containerRepo.Add(container)
+	b.trackSnapshot(container)
+	b.touch(container.Handle())
 
 	return container, nil
 }
 
+// discard destroys a container that was created but could not be set up.
+func (b *LinuxBackend) discard(container Container) {
+	b.containerPool.Destroy(container)
+	b.events.publishFor(container, Event{Type: EventDestroyed})
+}
+
+// applyLimits holds the container to the limits it declared when it was
+// admitted.
+func applyLimits(container Container, limits AdmissionLimits) error {
+	if limits.MemoryInBytes > 0 {
+		err := container.LimitMemory(garden.MemoryLimits{LimitInBytes: limits.MemoryInBytes})
+		if err != nil {
+			return err
+		}
+	}
+
+	if limits.DiskInBytes > 0 {
+		err := container.LimitDisk(garden.DiskLimits{ByteHard: limits.DiskInBytes})
+		if err != nil {
+			return err
+		}
+	}
+
+	if limits.CPUShares > 0 {
+		err := container.LimitCPU(garden.CPULimits{LimitInShares: limits.CPUShares})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *LinuxBackend) Destroy(handle string) error {
 	container, err := b.containerRepo.FindByHandle(handle)
 	if err != nil {
@@ -172,6 +388,16 @@ func (b *LinuxBackend) Destroy(handle string) error {
 	}
 
 	b.containerRepo.Delete(container)
+	b.removeSnapshot(container)
+
+	b.admission.release()
+	b.containerRemoved()
+
+	if b.reaper != nil {
+		b.reaper.Forget(handle)
+	}
+
+	b.events.publishFor(container, Event{Type: EventDestroyed})
 
 	return nil
 }
@@ -180,38 +406,121 @@ func (b *LinuxBackend) Containers(props garden.Properties) ([]garden.Container,
 	return toGardenContainers(b.containerRepo.Query(withProperties(props))), nil
 }
 
+// Lookup is called for every API call about a container, so it counts as
+// activity in the container.
 func (b *LinuxBackend) Lookup(handle string) (garden.Container, error) {
-	return b.containerRepo.FindByHandle(handle)
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	b.touch(handle)
+
+	return container, nil
+}
+
+func (b *LinuxBackend) touch(handle string) {
+	if b.reaper != nil {
+		b.reaper.Touch(handle)
+	}
 }
 
 func (b *LinuxBackend) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
-	containers := b.containerRepo.Query(withHandles(handles))
+	results := b.bulk("bulk-info", handles, func(container Container) (interface{}, error) {
+		return container.Info()
+	})
 
 	infos := make(map[string]garden.ContainerInfoEntry)
-	for _, container := range containers {
-		info, err := container.Info()
-		infos[container.Handle()] = garden.ContainerInfoEntry{
+	for handle, result := range results {
+		info, _ := result.value.(garden.ContainerInfo)
+		infos[handle] = garden.ContainerInfoEntry{
 			Info: info,
-			Err:  err,
+			Err:  result.err,
 		}
 	}
 
 	return infos, nil
 }
 
+// BulkMetrics returns the latest sampled metrics of each container, falling
+// back to collecting them there and then for containers that have not been
+// sampled yet.
 func (b *LinuxBackend) BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
-	containers := b.containerRepo.Query(withHandles(handles))
+	metrics := make(map[string]garden.ContainerMetricsEntry)
+
+	unsampled := []string{}
+	for _, handle := range handles {
+		container, err := b.containerRepo.FindByHandle(handle)
+		if err != nil {
+			metrics[handle] = garden.ContainerMetricsEntry{Err: err}
+			continue
+		}
+
+		if b.metricsSampler != nil {
+			if sample, found := b.metricsSampler.Latest(container.ID()); found {
+				metrics[handle] = garden.ContainerMetricsEntry{Metrics: sample.Metrics}
+				continue
+			}
+		}
+
+		unsampled = append(unsampled, handle)
+	}
+
+	for handle, entry := range b.collectMetrics(unsampled) {
+		metrics[handle] = entry
+	}
+
+	return metrics, nil
+}
+
+// MetricsHistory returns the container's sampled metrics within the window;
+// none if metrics are not being sampled.
+func (b *LinuxBackend) MetricsHistory(handle string, window time.Duration) (MetricsHistory, error) {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return MetricsHistory{}, err
+	}
+
+	if b.metricsSampler == nil {
+		return MetricsHistory{Samples: []MetricsSample{}}, nil
+	}
+
+	return b.metricsSampler.History(container.ID(), window), nil
+}
+
+func (b *LinuxBackend) collectMetrics(handles []string) map[string]garden.ContainerMetricsEntry {
+	results := b.bulk("bulk-metrics", handles, func(container Container) (interface{}, error) {
+		return container.Metrics()
+	})
 
 	metrics := make(map[string]garden.ContainerMetricsEntry)
-	for _, container := range containers {
-		metric, err := container.Metrics()
-		metrics[container.Handle()] = garden.ContainerMetricsEntry{
+	for handle, result := range results {
+		metric, _ := result.value.(garden.Metrics)
+		metrics[handle] = garden.ContainerMetricsEntry{
 			Metrics: metric,
-			Err:     err,
+			Err:     result.err,
 		}
 	}
 
-	return metrics, nil
+	return metrics
+}
+
+// sampleMetrics collects the metrics of every container, keyed by ID.
+func (b *LinuxBackend) sampleMetrics() map[string]garden.ContainerMetricsEntry {
+	ids := map[string]string{}
+
+	handles := []string{}
+	for _, container := range b.containerRepo.All() {
+		handles = append(handles, container.Handle())
+		ids[container.Handle()] = container.ID()
+	}
+
+	metrics := make(map[string]garden.ContainerMetricsEntry)
+	for handle, entry := range b.collectMetrics(handles) {
+		metrics[ids[handle]] = entry
+	}
+
+	return metrics
 }
 
 func (b *LinuxBackend) GraceTime(container garden.Container) time.Duration {
@@ -219,7 +528,19 @@ func (b *LinuxBackend) GraceTime(container garden.Container) time.Duration {
 }
 
 func (b *LinuxBackend) Stop() {
+	if b.metricsSampler != nil {
+		b.metricsSampler.Stop()
+	}
+
+	if b.reaper != nil {
+		b.reaper.Stop()
+	}
+
 	for _, container := range b.containerRepo.All() {
+		if b.checkpointOnStop {
+			b.checkpoint(container)
+		}
+
 		container.Cleanup()
 		err := b.saveSnapshot(container)
 		if err != nil {
@@ -230,7 +551,18 @@ func (b *LinuxBackend) Stop() {
 	}
 }
 
-func (b *LinuxBackend) restoreSnapshots() {
+// checkpoint checkpoints the container, if it is running. A container that
+// cannot be checkpointed is left as it is, its processes still running.
+func (b *LinuxBackend) checkpoint(container Container) {
+	err := container.Checkpoint()
+	if err != nil {
+		b.logger.Error("failed-to-checkpoint", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+}
+
+func (b *LinuxBackend) restoreSnapshots() restoreSummary {
 	sLog := b.logger.Session("restore")
 
 	entries, err := ioutil.ReadDir(b.snapshotsPath)
@@ -240,25 +572,96 @@ func (b *LinuxBackend) restoreSnapshots() {
 		})
 	}
 
+	jobs := []restoreJob{}
+
 	for _, entry := range entries {
-		snapshot := path.Join(b.snapshotsPath, entry.Name())
+		if entry.IsDir() {
+			continue
+		}
 
-		lLog := sLog.Session("load", lager.Data{
-			"snapshot": entry.Name(),
+		name := entry.Name()
+		snapshot := path.Join(b.snapshotsPath, name)
+
+		if IsSnapshotTempFile(name) {
+			os.Remove(snapshot)
+			continue
+		}
+
+		jobs = append(jobs, restoreJob{
+			name: name,
+
+			open: func() (io.ReadCloser, error) {
+				return os.Open(snapshot)
+			},
+
+			// the restored container keeps its own snapshot up to date from now on
+			restored: func(container Container) {
+				if container.ID() != name {
+					os.Remove(snapshot)
+				}
+			},
+
+			failed: func(logger lager.Logger) {
+				b.quarantineSnapshot(logger, name)
+			},
 		})
+	}
 
-		lLog.Debug("loading")
+	return b.restoreAll(sLog, jobs)
+}
 
-		file, err := os.Open(snapshot)
-		if err != nil {
-			lLog.Error("failed-to-open", err)
-		}
+// quarantineSnapshot moves a snapshot that could not be restored out of the
+// way, keeping it for inspection rather than deleting it.
+func (b *LinuxBackend) quarantineSnapshot(logger lager.Logger, name string) {
+	quarantinePath := path.Join(b.snapshotsPath, "quarantine")
 
-		_, err = b.restore(file)
-		if err != nil {
-			lLog.Error("failed-to-restore", err)
+	err := os.MkdirAll(quarantinePath, 0755)
+	if err != nil {
+		logger.Error("failed-to-create-quarantine", err)
+		return
+	}
+
+	err = os.Rename(path.Join(b.snapshotsPath, name), path.Join(quarantinePath, name))
+	if err != nil {
+		logger.Error("failed-to-quarantine", err)
+		return
+	}
+
+	logger.Info("quarantined", lager.Data{
+		"to": quarantinePath,
+	})
+}
+
+// recoverContainers restores containers that were persisted by the container
+// repository but not restored from a snapshot, e.g. after an unclean exit.
+func (b *LinuxBackend) recoverContainers() restoreSummary {
+	rLog := b.logger.Session("recover")
+
+	records, err := b.containerRepo.Records()
+	if err != nil {
+		rLog.Error("failed-to-read-records", err)
+		return restoreSummary{}
+	}
+
+	jobs := []restoreJob{}
+
+	for id, record := range records {
+		if len(b.containerRepo.Query(withID(id))) > 0 {
+			continue
 		}
+
+		record := record
+
+		jobs = append(jobs, restoreJob{
+			name: id,
+
+			open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(record)), nil
+			},
+		})
 	}
+
+	return b.restoreAll(rLog, jobs)
 }
 
 func (b *LinuxBackend) saveSnapshot(container Container) error {
@@ -266,32 +669,97 @@ func (b *LinuxBackend) saveSnapshot(container Container) error {
 		return nil
 	}
 
+	lock := b.snapshotLock(container)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return b.writeSnapshot(container)
+}
+
+func (b *LinuxBackend) writeSnapshot(container Container) error {
 	b.logger.Info("save-snapshot", lager.Data{
 		"container": container.ID(),
 	})
 
-	snapshotPath := path.Join(b.snapshotsPath, container.ID())
-
-	snapshot, err := os.Create(snapshotPath)
+	err := SaveSnapshot(container, path.Join(b.snapshotsPath, container.ID()))
 	if err != nil {
 		return &FailedToSnapshotError{err}
 	}
 
-	err = container.Snapshot(snapshot)
-	if err != nil {
-		return &FailedToSnapshotError{err}
+	b.events.publishFor(container, Event{Type: EventSnapshotSaved})
+
+	return nil
+}
+
+// trackSnapshot saves a snapshot of the container now and after every
+// change to it, so that the snapshot is current even after a crash.
+func (b *LinuxBackend) trackSnapshot(container Container) {
+	if b.snapshotsPath == "" {
+		return
+	}
+
+	save := func() {
+		lock := b.snapshotLock(container)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// the container may have been destroyed since it changed
+		if _, err := b.containerRepo.FindByHandle(container.Handle()); err != nil {
+			return
+		}
+
+		err := b.writeSnapshot(container)
+		if err != nil {
+			b.logger.Error("failed-to-save-snapshot", err, lager.Data{
+				"container": container.ID(),
+			})
+		}
 	}
 
-	return snapshot.Close()
+	container.OnChange(save)
+	save()
 }
 
-func (b *LinuxBackend) restore(snapshot io.Reader) (garden.Container, error) {
-	container, err := b.containerPool.Restore(snapshot)
-	if err != nil {
-		return nil, err
+func (b *LinuxBackend) removeSnapshot(container Container) {
+	if b.snapshotsPath == "" {
+		return
+	}
+
+	lock := b.snapshotLock(container)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := os.Remove(path.Join(b.snapshotsPath, container.ID()))
+	if err != nil && !os.IsNotExist(err) {
+		b.logger.Error("failed-to-remove-snapshot", err, lager.Data{
+			"container": container.ID(),
+		})
+	}
+
+	// any save from here on finds the container gone from the repository
+	// before writing, whichever lock it takes
+	b.snapshotLocksMutex.Lock()
+	delete(b.snapshotLocks, container.ID())
+	b.snapshotLocksMutex.Unlock()
+}
+
+func (b *LinuxBackend) snapshotLock(container Container) *sync.Mutex {
+	b.snapshotLocksMutex.Lock()
+	defer b.snapshotLocksMutex.Unlock()
+
+	lock, found := b.snapshotLocks[container.ID()]
+	if !found {
+		lock = &sync.Mutex{}
+		b.snapshotLocks[container.ID()] = lock
+	}
+
+	return lock
+}
+
+func withID(id string) func(Container) bool {
+	return func(c Container) bool {
+		return c.ID() == id
 	}
-	b.containerRepo.Add(container)
-	return container, nil
 }
 
 func withHandles(handles []string) func(Container) bool {
@@ -6,10 +6,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pivotal-golang/clock/fakeclock"
 	"github.com/pivotal-golang/lager/lagertest"
 
 	"github.com/cloudfoundry-incubator/garden"
@@ -28,6 +31,14 @@ var _ = Describe("LinuxBackend", func() {
 	var containerRepo linux_backend.ContainerRepository
 	var linuxBackend *linux_backend.LinuxBackend
 	var snapshotsPath string
+	var restoreConcurrency int
+	var restoreTimeout time.Duration
+	var bulkConcurrency int
+	var bulkTimeout time.Duration
+	var metricsSampler *linux_backend.MetricsSampler
+	var reaper *linux_backend.Reaper
+	var admissionPolicy linux_backend.AdmissionPolicy
+	var checkpointOnStop bool
 
 	BeforeEach(func() {
 		logger = lagertest.NewTestLogger("test")
@@ -36,6 +47,14 @@ var _ = Describe("LinuxBackend", func() {
 		fakeSystemInfo = fake_system_info.NewFakeProvider()
 
 		snapshotsPath = ""
+		restoreConcurrency = 4
+		restoreTimeout = 0
+		bulkConcurrency = 4
+		bulkTimeout = 0
+		metricsSampler = nil
+		reaper = nil
+		admissionPolicy = linux_backend.AdmissionPolicy{}
+		checkpointOnStop = false
 	})
 
 	JustBeforeEach(func() {
@@ -45,6 +64,14 @@ var _ = Describe("LinuxBackend", func() {
 			containerRepo,
 			fakeSystemInfo,
 			snapshotsPath,
+			restoreConcurrency,
+			restoreTimeout,
+			bulkConcurrency,
+			bulkTimeout,
+			metricsSampler,
+			reaper,
+			admissionPolicy,
+			checkpointOnStop,
 		)
 	})
 
@@ -131,7 +158,7 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
 			})
 
-			It("removes the snapshots", func() {
+			It("replaces the snapshots with current snapshots of the restored containers", func() {
 				Expect(fakeContainerPool.RestoredSnapshots).To(BeEmpty())
 
 				err := linuxBackend.Start()
@@ -142,6 +169,26 @@ var _ = Describe("LinuxBackend", func() {
 
 				_, err = os.Stat(path.Join(snapshotsPath, "some-other-id"))
 				Expect(err).To(HaveOccurred())
+
+				// the fake container pool uses the handle as the container's ID
+				_, err = os.Stat(path.Join(snapshotsPath, "handle-a"))
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = os.Stat(path.Join(snapshotsPath, "handle-b"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("removes temporary files left over from interrupted snapshots", func() {
+				err := ioutil.WriteFile(path.Join(snapshotsPath, ".some-id.tmp123"), []byte("handle-"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
+
+				_, err = os.Stat(path.Join(snapshotsPath, ".some-id.tmp123"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
 			})
 
 			It("registers the containers", func() {
@@ -176,6 +223,190 @@ var _ = Describe("LinuxBackend", func() {
 					err := linuxBackend.Start()
 					Expect(err).ToNot(HaveOccurred())
 				})
+
+				It("moves the snapshots into the quarantine directory", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					_, err = os.Stat(path.Join(snapshotsPath, "some-id"))
+					Expect(os.IsNotExist(err)).To(BeTrue())
+
+					Expect(ioutil.ReadFile(path.Join(snapshotsPath, "quarantine", "some-id"))).To(Equal([]byte("handle-a")))
+					Expect(ioutil.ReadFile(path.Join(snapshotsPath, "quarantine", "some-other-id"))).To(Equal([]byte("handle-b")))
+				})
+
+				It("publishes a restore-failed event for each snapshot", func() {
+					subscription := linuxBackend.Events().Subscribe(linux_backend.EventFilter{})
+					defer subscription.Close()
+
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					messages := []string{}
+					for i := 0; i < 2; i++ {
+						var event linux_backend.Event
+						Eventually(subscription.Events()).Should(Receive(&event))
+						Expect(event.Type).To(Equal(linux_backend.EventRestoreFailed))
+
+						messages = append(messages, event.Message)
+					}
+
+					Expect(messages).To(ConsistOf(
+						"some-id: failed to restore",
+						"some-other-id: failed to restore",
+					))
+				})
+			})
+		})
+
+		Describe("when many snapshots are present", func() {
+			BeforeEach(func() {
+				err := os.MkdirAll(snapshotsPath, 0755)
+				Expect(err).ToNot(HaveOccurred())
+
+				for i := 0; i < 12; i++ {
+					handle := fmt.Sprintf("handle-%d", i)
+
+					err := ioutil.WriteFile(path.Join(snapshotsPath, handle), []byte(handle), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				fakeContainerPool.RestoreDelay = 10 * time.Millisecond
+			})
+
+			It("restores them in parallel, but no more than the concurrency limit at once", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(12))
+				Expect(fakeContainerPool.MaxConcurrentRestores).To(BeNumerically(">", 1))
+				Expect(fakeContainerPool.MaxConcurrentRestores).To(BeNumerically("<=", 4))
+
+				containers, err := linuxBackend.Containers(nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers).To(HaveLen(12))
+			})
+
+			It("logs a summary of the restore", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(logger).To(gbytes.Say("restore.summary"))
+			})
+
+			Context("when restoring takes longer than the timeout", func() {
+				BeforeEach(func() {
+					restoreTimeout = time.Millisecond
+					fakeContainerPool.RestoreDelay = 50 * time.Millisecond
+				})
+
+				It("starts without the containers, quarantining their snapshots", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					containers, err := linuxBackend.Containers(nil)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(containers).To(BeEmpty())
+
+					_, err = os.Stat(path.Join(snapshotsPath, "quarantine", "handle-0"))
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("does not prune the containers that may still be restoring", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeContainerPool.KeptContainers).To(HaveLen(12))
+					Expect(fakeContainerPool.KeptContainers).To(HaveKey("handle-0"))
+				})
+
+				It("adopts the containers, leaving their quarantined snapshots alone, when their restores eventually finish", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Eventually(func() []garden.Container {
+						containers, err := linuxBackend.Containers(nil)
+						Expect(err).ToNot(HaveOccurred())
+						return containers
+					}).Should(HaveLen(12))
+
+					Expect(fakeContainerPool.Destroyed()).To(BeEmpty())
+
+					_, err = os.Stat(path.Join(snapshotsPath, "quarantine", "handle-0"))
+					Expect(err).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		Describe("when the container repository has records from a previous run", func() {
+			var depotPath string
+
+			BeforeEach(func() {
+				depotPath = path.Join(tmpdir, "depot")
+
+				// the fake container pool uses the handle as the container's ID
+				for _, id := range []string{"handle-a", "handle-b"} {
+					err := os.MkdirAll(path.Join(depotPath, id), 0755)
+					Expect(err).ToNot(HaveOccurred())
+
+					err = ioutil.WriteFile(path.Join(depotPath, id, "snapshot.json"), []byte(id), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				containerRepo = container_repository.NewPersistent(logger, depotPath)
+			})
+
+			It("restores them via the container pool", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
+			})
+
+			It("registers the containers and keeps them when pruning", func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+
+				containers, err := linuxBackend.Containers(nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(containers).To(HaveLen(2))
+
+				Expect(fakeContainerPool.KeptContainers).To(Equal(map[string]bool{
+					"handle-a": true,
+					"handle-b": true,
+				}))
+			})
+
+			Context("when a container was already restored from a snapshot", func() {
+				BeforeEach(func() {
+					err := os.MkdirAll(snapshotsPath, 0755)
+					Expect(err).ToNot(HaveOccurred())
+
+					err = ioutil.WriteFile(path.Join(snapshotsPath, "handle-a"), []byte("handle-a"), 0644)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("does not restore it again", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(fakeContainerPool.RestoredSnapshots).To(HaveLen(2))
+
+					containers, err := linuxBackend.Containers(nil)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(containers).To(HaveLen(2))
+				})
+			})
+
+			Context("when restoring a container fails", func() {
+				BeforeEach(func() {
+					fakeContainerPool.RestoreError = errors.New("failed to restore")
+				})
+
+				It("successfully starts anyway", func() {
+					err := linuxBackend.Start()
+					Expect(err).ToNot(HaveOccurred())
+				})
 			})
 		})
 
@@ -259,6 +490,42 @@ var _ = Describe("LinuxBackend", func() {
 				Expect(container1.CleanedUp).To(BeTrue())
 				Expect(container2.CleanedUp).To(BeTrue())
 			})
+
+			It("does not checkpoint the containers", func() {
+				linuxBackend.Stop()
+
+				Expect(container1.Checkpointed).To(BeFalse())
+				Expect(container2.Checkpointed).To(BeFalse())
+			})
+
+			Context("when checkpointing on stop", func() {
+				BeforeEach(func() {
+					checkpointOnStop = true
+				})
+
+				It("checkpoints each container, and takes its snapshot", func() {
+					linuxBackend.Stop()
+
+					Expect(container1.Checkpointed).To(BeTrue())
+					Expect(container2.Checkpointed).To(BeTrue())
+
+					Expect(container1.SavedSnapshots).To(HaveLen(1))
+					Expect(container2.SavedSnapshots).To(HaveLen(1))
+				})
+
+				Context("when a container fails to checkpoint", func() {
+					BeforeEach(func() {
+						container1.CheckpointError = errors.New("oh no!")
+					})
+
+					It("still snapshots it, and carries on with the others", func() {
+						linuxBackend.Stop()
+
+						Expect(container1.SavedSnapshots).To(HaveLen(1))
+						Expect(container2.Checkpointed).To(BeTrue())
+					})
+				})
+			})
 		})
 	})
 
@@ -333,6 +600,44 @@ var _ = Describe("LinuxBackend", func() {
 			})
 		})
 
+		It("publishes a created event", func() {
+			subscription := linuxBackend.Events().Subscribe(linux_backend.EventFilter{})
+			defer subscription.Close()
+
+			_, err := linuxBackend.Create(garden.ContainerSpec{
+				Handle:     "some-handle",
+				Properties: garden.Properties{"a": "b"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			var event linux_backend.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventCreated))
+			Expect(event.Handle).To(Equal("some-handle"))
+			Expect(event.Properties).To(Equal(garden.Properties{"a": "b"}))
+			Expect(event.Time).ToNot(BeZero())
+		})
+
+		It("publishes the container's own events", func() {
+			subscription := linuxBackend.Events().Subscribe(linux_backend.EventFilter{
+				Types: []linux_backend.EventType{linux_backend.EventOutOfMemory},
+			})
+			defer subscription.Close()
+
+			container, err := linuxBackend.Create(garden.ContainerSpec{})
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeContainer := container.(*fake_container_pool.FakeContainer)
+			Expect(fakeContainer.EventHandlers).To(HaveLen(1))
+
+			oom := linux_backend.Event{Type: linux_backend.EventOutOfMemory, Handle: container.Handle()}
+			fakeContainer.EventHandlers[0](oom)
+
+			var event linux_backend.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventOutOfMemory))
+		})
+
 		It("registers the container", func() {
 			container, err := linuxBackend.Create(garden.ContainerSpec{})
 			Expect(err).ToNot(HaveOccurred())
@@ -359,6 +664,102 @@ var _ = Describe("LinuxBackend", func() {
 			})
 		})
 
+		Context("when a snapshots directory is given", func() {
+			BeforeEach(func() {
+				tmpdir, err := ioutil.TempDir(os.TempDir(), "garden-server-test")
+				Expect(err).ToNot(HaveOccurred())
+
+				snapshotsPath = path.Join(tmpdir, "snapshots")
+			})
+
+			JustBeforeEach(func() {
+				err := linuxBackend.Start()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("saves a snapshot of the container", func() {
+				container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.(*fake_container_pool.FakeContainer).SavedSnapshots).To(HaveLen(1))
+
+				_, err = os.Stat(path.Join(snapshotsPath, "some-handle"))
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("publishes a snapshot-saved event", func() {
+				subscription := linuxBackend.Events().Subscribe(linux_backend.EventFilter{
+					Types: []linux_backend.EventType{linux_backend.EventSnapshotSaved},
+				})
+				defer subscription.Close()
+
+				_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				var event linux_backend.Event
+				Expect(subscription.Events()).To(Receive(&event))
+				Expect(event.Handle).To(Equal("some-handle"))
+			})
+
+			It("saves a new snapshot whenever the container changes", func() {
+				container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				fakeContainer := container.(*fake_container_pool.FakeContainer)
+				Expect(fakeContainer.ChangeHandlers).To(HaveLen(1))
+
+				fakeContainer.ChangeHandlers[0]()
+				Expect(fakeContainer.SavedSnapshots).To(HaveLen(2))
+			})
+
+			It("saves one container's snapshot while another's is still being saved", func() {
+				slow, err := linuxBackend.Create(garden.ContainerSpec{Handle: "slow-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				fast, err := linuxBackend.Create(garden.ContainerSpec{Handle: "fast-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				saving := make(chan struct{})
+				release := make(chan struct{})
+				defer close(release)
+
+				slowContainer := slow.(*fake_container_pool.FakeContainer)
+				slowContainer.SnapshotHook = func() {
+					close(saving)
+					<-release
+				}
+
+				go slowContainer.ChangeHandlers[0]()
+				Eventually(saving).Should(BeClosed())
+
+				saved := make(chan struct{})
+				go func() {
+					fast.(*fake_container_pool.FakeContainer).ChangeHandlers[0]()
+					close(saved)
+				}()
+
+				Eventually(saved).Should(BeClosed())
+			})
+
+			It("removes the snapshot when the container is destroyed", func() {
+				container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				err = linuxBackend.Destroy("some-handle")
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = os.Stat(path.Join(snapshotsPath, "some-handle"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+
+				fakeContainer := container.(*fake_container_pool.FakeContainer)
+				fakeContainer.ChangeHandlers[0]()
+				Expect(fakeContainer.SavedSnapshots).To(HaveLen(1))
+
+				_, err = os.Stat(path.Join(snapshotsPath, "some-handle"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+
 		Context("when a container with the given handle already exists", func() {
 			It("returns a HandleExistsError", func() {
 				container, err := linuxBackend.Create(garden.ContainerSpec{})
@@ -395,6 +796,124 @@ var _ = Describe("LinuxBackend", func() {
 
 				Expect(containers).To(BeEmpty())
 			})
+
+			It("publishes a destroyed event after the created event", func() {
+				subscription := linuxBackend.Events().Subscribe(linux_backend.EventFilter{})
+				defer subscription.Close()
+
+				_, err := linuxBackend.Create(garden.ContainerSpec{})
+				Expect(err).To(HaveOccurred())
+
+				var created, destroyed linux_backend.Event
+				Expect(subscription.Events()).To(Receive(&created))
+				Expect(subscription.Events()).To(Receive(&destroyed))
+
+				Expect(created.Type).To(Equal(linux_backend.EventCreated))
+				Expect(destroyed.Type).To(Equal(linux_backend.EventDestroyed))
+			})
+		})
+	})
+
+	Describe("Drain", func() {
+		It("refuses to create containers", func() {
+			linuxBackend.Drain()
+
+			_, err := linuxBackend.Create(garden.ContainerSpec{})
+			Expect(err).To(Equal(linux_backend.DrainingError{}))
+
+			Expect(fakeContainerPool.CreatedContainers).To(BeEmpty())
+		})
+
+		It("reports no capacity", func() {
+			fakeSystemInfo.TotalMemoryResult = 1111
+			fakeSystemInfo.TotalDiskResult = 2222
+			fakeContainerPool.MaxContainersValue = 42
+
+			linuxBackend.Drain()
+
+			capacity, err := linuxBackend.Capacity()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(capacity).To(Equal(garden.Capacity{}))
+		})
+
+		It("leaves existing containers in place", func() {
+			container, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+			Expect(err).ToNot(HaveOccurred())
+
+			linuxBackend.Drain()
+
+			found, err := linuxBackend.Lookup("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(Equal(container))
+		})
+
+		Context("when there are no containers", func() {
+			It("is drained immediately", func() {
+				Expect(linuxBackend.Drain()).To(BeClosed())
+			})
+		})
+
+		Context("when there are containers", func() {
+			JustBeforeEach(func() {
+				_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "handle-a"})
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = linuxBackend.Create(garden.ContainerSpec{Handle: "handle-b"})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("is drained once the last of them is destroyed", func() {
+				drained := linuxBackend.Drain()
+
+				err := linuxBackend.Destroy("handle-a")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(drained).ToNot(BeClosed())
+
+				err = linuxBackend.Destroy("handle-b")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(drained).To(BeClosed())
+			})
+		})
+
+		Context("when a container is being created", func() {
+			var creating chan struct{}
+			var proceed chan struct{}
+
+			BeforeEach(func() {
+				creating = make(chan struct{})
+				proceed = make(chan struct{})
+
+				fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+					close(creating)
+					<-proceed
+				}
+			})
+
+			It("is not drained until the create has finished", func() {
+				created := make(chan error)
+				go func() {
+					_, err := linuxBackend.Create(garden.ContainerSpec{Handle: "some-handle"})
+					created <- err
+				}()
+
+				Eventually(creating).Should(BeClosed())
+
+				drained := linuxBackend.Drain()
+				Consistently(drained).ShouldNot(BeClosed())
+
+				close(proceed)
+				Eventually(created).Should(Receive(BeNil()))
+				Expect(drained).ToNot(BeClosed())
+
+				err := linuxBackend.Destroy("some-handle")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(drained).To(BeClosed())
+			})
+		})
+
+		It("returns the same channel when called again", func() {
+			Expect(linuxBackend.Drain()).To(Equal(linuxBackend.Drain()))
+			Expect(linuxBackend.Draining()).To(BeTrue())
 		})
 	})
 
@@ -415,6 +934,19 @@ var _ = Describe("LinuxBackend", func() {
 			Expect(fakeContainerPool.DestroyedContainers).To(ContainElement(container))
 		})
 
+		It("publishes a destroyed event", func() {
+			subscription := linuxBackend.Events().Subscribe(linux_backend.EventFilter{})
+			defer subscription.Close()
+
+			err := linuxBackend.Destroy("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			var event linux_backend.Event
+			Expect(subscription.Events()).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventDestroyed))
+			Expect(event.Handle).To(Equal("some-handle"))
+		})
+
 		It("unregisters the container", func() {
 			err := linuxBackend.Destroy("some-handle")
 			Expect(err).ToNot(HaveOccurred())
@@ -536,12 +1068,110 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 		})
+
+		Context("when a handle has no container", func() {
+			It("returns ContainerNotFoundError for that handle", func() {
+				bulkInfo, err := linuxBackend.BulkInfo([]string{"handle1", "bogus-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkInfo).To(HaveLen(2))
+				Expect(bulkInfo["bogus-handle"].Err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+			})
+		})
+
+		Context("when getting the info for a container takes too long", func() {
+			var slowContainer *fakes.FakeContainer
+			var unblock chan struct{}
+
+			BeforeEach(func() {
+				bulkTimeout = 50 * time.Millisecond
+
+				unblock = make(chan struct{})
+
+				slowContainer = newContainer("slow-handle")
+				slowContainer.InfoStub = func() (garden.ContainerInfo, error) {
+					<-unblock
+					return garden.ContainerInfo{}, nil
+				}
+
+				containerRepo.Add(slowContainer)
+			})
+
+			AfterEach(func() {
+				close(unblock)
+			})
+
+			It("returns a timeout error for that handle only", func() {
+				bulkInfo, err := linuxBackend.BulkInfo([]string{"handle1", "slow-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkInfo["handle1"].Err).ToNot(HaveOccurred())
+				Expect(bulkInfo["slow-handle"].Err).To(Equal(linux_backend.BulkOperationTimedOutError{
+					Operation: "bulk-info",
+					Handle:    "slow-handle",
+					Timeout:   50 * time.Millisecond,
+				}))
+			})
+		})
+
+		Context("when many handles are requested", func() {
+			var inFlight, maxInFlight int
+			var inFlightMutex *sync.Mutex
+			var manyHandles []string
+
+			BeforeEach(func() {
+				bulkConcurrency = 3
+
+				inFlight, maxInFlight = 0, 0
+				inFlightMutex = new(sync.Mutex)
+				manyHandles = []string{}
+
+				for i := 0; i < 10; i++ {
+					handle := fmt.Sprintf("concurrent-handle%d", i)
+					manyHandles = append(manyHandles, handle)
+
+					container := newContainer(handle)
+					container.InfoStub = func() (garden.ContainerInfo, error) {
+						inFlightMutex.Lock()
+						inFlight++
+						if inFlight > maxInFlight {
+							maxInFlight = inFlight
+						}
+						inFlightMutex.Unlock()
+
+						time.Sleep(10 * time.Millisecond)
+
+						inFlightMutex.Lock()
+						inFlight--
+						inFlightMutex.Unlock()
+
+						return garden.ContainerInfo{HostIP: "hostip for " + handle}, nil
+					}
+
+					containerRepo.Add(container)
+				}
+			})
+
+			It("gets the infos in parallel, on at most bulkConcurrency containers at a time", func() {
+				bulkInfo, err := linuxBackend.BulkInfo(manyHandles)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(bulkInfo).To(HaveLen(10))
+
+				for _, handle := range manyHandles {
+					Expect(bulkInfo[handle].Info.HostIP).To(Equal("hostip for " + handle))
+				}
+
+				Expect(maxInFlight).To(BeNumerically(">", 1))
+				Expect(maxInFlight).To(BeNumerically("<=", 3))
+			})
+		})
 	})
 
 	Describe("BulkMetrics", func() {
 		newContainer := func(n uint64) *fakes.FakeContainer {
 			fakeContainer := &fakes.FakeContainer{}
 			fakeContainer.HandleReturns(fmt.Sprintf("handle%d", n))
+			fakeContainer.IDReturns(fmt.Sprintf("id%d", n))
 			fakeContainer.MetricsReturns(
 				garden.Metrics{
 					DiskStat: garden.ContainerDiskStat{
@@ -628,6 +1258,137 @@ var _ = Describe("LinuxBackend", func() {
 				}))
 			})
 		})
+
+		Context("when a handle has no container", func() {
+			It("returns ContainerNotFoundError for that handle", func() {
+				bulkMetrics, err := linuxBackend.BulkMetrics([]string{"handle1", "bogus-handle"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkMetrics).To(HaveLen(2))
+				Expect(bulkMetrics["bogus-handle"].Err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+			})
+		})
+
+		Context("when getting the metrics for a container takes too long", func() {
+			var unblock chan struct{}
+
+			BeforeEach(func() {
+				bulkTimeout = 50 * time.Millisecond
+
+				unblock = make(chan struct{})
+
+				slowContainer := newContainer(99)
+				slowContainer.MetricsStub = func() (garden.Metrics, error) {
+					<-unblock
+					return garden.Metrics{}, nil
+				}
+
+				containerRepo.Add(slowContainer)
+			})
+
+			AfterEach(func() {
+				close(unblock)
+			})
+
+			It("returns a timeout error for that handle only", func() {
+				bulkMetrics, err := linuxBackend.BulkMetrics([]string{"handle1", "handle99"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkMetrics["handle1"].Err).ToNot(HaveOccurred())
+				Expect(bulkMetrics["handle99"].Err).To(BeAssignableToTypeOf(linux_backend.BulkOperationTimedOutError{}))
+			})
+		})
+
+		Context("when metrics are sampled in the background", func() {
+			var fakeClock *fakeclock.FakeClock
+			var sampledContainer *fakes.FakeContainer
+
+			BeforeEach(func() {
+				fakeClock = fakeclock.NewFakeClock(time.Now())
+				metricsSampler = linux_backend.NewMetricsSampler(logger, fakeClock, time.Second, 10)
+
+				sampledContainer = newContainer(42)
+				containerRepo.Add(sampledContainer)
+			})
+
+			JustBeforeEach(func() {
+				Expect(linuxBackend.Start()).To(Succeed())
+
+				Eventually(func() []linux_backend.MetricsSample {
+					history, err := linuxBackend.MetricsHistory("handle42", time.Minute)
+					Expect(err).ToNot(HaveOccurred())
+					return history.Samples
+				}).Should(HaveLen(1))
+			})
+
+			AfterEach(func() {
+				linuxBackend.Stop()
+			})
+
+			It("returns the latest sample rather than collecting the metrics again", func() {
+				sampledContainer.MetricsReturns(garden.Metrics{}, errors.New("should not be called"))
+
+				bulkMetrics, err := linuxBackend.BulkMetrics([]string{"handle42"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkMetrics).To(Equal(map[string]garden.ContainerMetricsEntry{
+					"handle42": garden.ContainerMetricsEntry{
+						Metrics: garden.Metrics{
+							DiskStat: garden.ContainerDiskStat{
+								InodesUsed: 42,
+							},
+						},
+					},
+				}))
+			})
+
+			It("collects the metrics of containers that have not been sampled yet", func() {
+				unsampledContainer := newContainer(43)
+				containerRepo.Add(unsampledContainer)
+
+				bulkMetrics, err := linuxBackend.BulkMetrics([]string{"handle43"})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(bulkMetrics["handle43"].Metrics.DiskStat.InodesUsed).To(Equal(uint64(43)))
+				Expect(unsampledContainer.MetricsCallCount()).To(Equal(1))
+			})
+
+			It("serves the history of each container", func() {
+				fakeClock.Increment(time.Second)
+
+				Eventually(func() []linux_backend.MetricsSample {
+					history, err := linuxBackend.MetricsHistory("handle42", time.Minute)
+					Expect(err).ToNot(HaveOccurred())
+					return history.Samples
+				}).Should(HaveLen(2))
+			})
+
+			Context("when sampling a container fails", func() {
+				JustBeforeEach(func() {
+					sampledContainer.MetricsReturns(garden.Metrics{}, errors.New("oh no!"))
+
+					fakeClock.Increment(time.Second)
+
+					Eventually(func() bool {
+						history, err := linuxBackend.MetricsHistory("handle42", time.Minute)
+						Expect(err).ToNot(HaveOccurred())
+						return history.Stale
+					}).Should(BeTrue())
+				})
+
+				It("collects its metrics again rather than returning the stale sample", func() {
+					bulkMetrics, err := linuxBackend.BulkMetrics([]string{"handle42"})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(bulkMetrics["handle42"].Err).To(MatchError("oh no!"))
+				})
+			})
+
+			It("returns ContainerNotFoundError for the history of an unknown handle", func() {
+				_, err := linuxBackend.MetricsHistory("bogus-handle", time.Minute)
+				Expect(err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+			})
+		})
 	})
 
 	Describe("Lookup", func() {
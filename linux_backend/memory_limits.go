@@ -0,0 +1,151 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/pivotal-golang/lager"
+)
+
+// MemoryLimits covers the controls of the memory cgroup that garden's
+// MemoryLimits has no fields for. The JSON for the hard limit is the same as
+// garden's.
+type MemoryLimits struct {
+	// memory usage limit in bytes
+	LimitInBytes uint64 `json:"limit_in_bytes,omitempty"`
+
+	// memory usage the container is pushed back down to when the host runs
+	// short of memory; 0 for no soft limit
+	SoftLimitInBytes uint64 `json:"soft_limit_in_bytes,omitempty"`
+
+	// swap the container may use on top of LimitInBytes
+	SwapInBytes uint64 `json:"swap_in_bytes,omitempty"`
+
+	// kernel memory usage limit in bytes; 0 for no limit
+	KernelLimitInBytes uint64 `json:"kernel_limit_in_bytes,omitempty"`
+
+	// how readily the container's memory is swapped out, from 0 to 100; nil
+	// leaves the host's default
+	Swappiness *uint64 `json:"swappiness,omitempty"`
+}
+
+// InvalidMemoryLimitsError is returned when a set of memory limits cannot
+// be enforced as given.
+type InvalidMemoryLimitsError struct {
+	Limits MemoryLimits
+	Reason string
+}
+
+func (e InvalidMemoryLimitsError) Error() string {
+	return fmt.Sprintf("container: invalid memory limits: %s", e.Reason)
+}
+
+func (l MemoryLimits) Validate() error {
+	if l.SoftLimitInBytes > l.LimitInBytes && l.LimitInBytes != 0 {
+		return InvalidMemoryLimitsError{l, "soft limit exceeds limit"}
+	}
+
+	if l.Swappiness != nil && *l.Swappiness > 100 {
+		return InvalidMemoryLimitsError{l, "swappiness exceeds 100"}
+	}
+
+	return nil
+}
+
+func (b *LinuxBackend) SetMemoryLimits(handle string, limits MemoryLimits) error {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return err
+	}
+
+	return container.SetMemoryLimits(limits)
+}
+
+func (b *LinuxBackend) MemoryLimits(handle string) (MemoryLimits, error) {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return MemoryLimits{}, err
+	}
+
+	return container.MemoryLimits()
+}
+
+type memoryLimiter interface {
+	SetMemoryLimits(handle string, limits MemoryLimits) error
+	MemoryLimits(handle string) (MemoryLimits, error)
+}
+
+// MemoryLimitsHandler reports a container's memory limits as JSON on GET,
+// and sets them from a JSON body on PUT, e.g. PUT /memory-limits?handle=
+// some-handle.
+type MemoryLimitsHandler struct {
+	logger  lager.Logger
+	limiter memoryLimiter
+}
+
+func NewMemoryLimitsHandler(logger lager.Logger, limiter memoryLimiter) *MemoryLimitsHandler {
+	return &MemoryLimitsHandler{
+		logger:  logger.Session("memory-limits-handler"),
+		limiter: limiter,
+	}
+}
+
+func (h *MemoryLimitsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handle := r.URL.Query().Get("handle")
+
+	hLog := h.logger.Session("memory-limits", lager.Data{
+		"handle": handle,
+		"method": r.Method,
+	})
+
+	switch r.Method {
+	case "GET":
+		limits, err := h.limiter.MemoryLimits(handle)
+		if err != nil {
+			respondWithError(hLog, w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(limits)
+
+	case "PUT":
+		var limits MemoryLimits
+		err := json.NewDecoder(r.Body).Decode(&limits)
+		if err != nil {
+			http.Error(w, "invalid memory limits: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = limits.Validate()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = h.limiter.SetMemoryLimits(handle, limits)
+		if err != nil {
+			respondWithError(hLog, w, err)
+			return
+		}
+
+		hLog.Info("set", lager.Data{"limits": limits})
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func respondWithError(logger lager.Logger, w http.ResponseWriter, err error) {
+	if _, ok := err.(garden.ContainerNotFoundError); ok {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	logger.Error("failed", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
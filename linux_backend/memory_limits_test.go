@@ -0,0 +1,125 @@
+package linux_backend_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+type fakeMemoryLimiter struct {
+	set      map[string]linux_backend.MemoryLimits
+	limits   linux_backend.MemoryLimits
+	setError error
+	getError error
+}
+
+func (l *fakeMemoryLimiter) SetMemoryLimits(handle string, limits linux_backend.MemoryLimits) error {
+	if l.setError != nil {
+		return l.setError
+	}
+
+	l.set[handle] = limits
+	return nil
+}
+
+func (l *fakeMemoryLimiter) MemoryLimits(handle string) (linux_backend.MemoryLimits, error) {
+	return l.limits, l.getError
+}
+
+var _ = Describe("MemoryLimitsHandler", func() {
+	var limiter *fakeMemoryLimiter
+	var server *httptest.Server
+
+	put := func(body string) int {
+		request, err := http.NewRequest("PUT", server.URL+"/memory-limits?handle=some-handle", bytes.NewBufferString(body))
+		Expect(err).ToNot(HaveOccurred())
+
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		return response.StatusCode
+	}
+
+	BeforeEach(func() {
+		limiter = &fakeMemoryLimiter{set: map[string]linux_backend.MemoryLimits{}}
+		server = httptest.NewServer(linux_backend.NewMemoryLimitsHandler(lagertest.NewTestLogger("test"), limiter))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sets the limits on PUT", func() {
+		Expect(put(`{"limit_in_bytes":1024,"soft_limit_in_bytes":512,"swappiness":0}`)).To(Equal(http.StatusNoContent))
+
+		swappiness := uint64(0)
+		Expect(limiter.set).To(Equal(map[string]linux_backend.MemoryLimits{
+			"some-handle": {
+				LimitInBytes:     1024,
+				SoftLimitInBytes: 512,
+				Swappiness:       &swappiness,
+			},
+		}))
+	})
+
+	It("rejects limits that cannot be enforced", func() {
+		Expect(put(`{"limit_in_bytes":1024,"soft_limit_in_bytes":2048}`)).To(Equal(http.StatusBadRequest))
+		Expect(limiter.set).To(BeEmpty())
+	})
+
+	It("rejects malformed limits", func() {
+		Expect(put(`{"limit_in_bytes":"lots"}`)).To(Equal(http.StatusBadRequest))
+		Expect(limiter.set).To(BeEmpty())
+	})
+
+	It("reports the limits on GET", func() {
+		limiter.limits = linux_backend.MemoryLimits{LimitInBytes: 1024, SwapInBytes: 4096}
+
+		response, err := http.Get(server.URL + "/memory-limits?handle=some-handle")
+		Expect(err).ToNot(HaveOccurred())
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+		var limits linux_backend.MemoryLimits
+		Expect(json.NewDecoder(response.Body).Decode(&limits)).To(Succeed())
+		Expect(limits).To(Equal(limiter.limits))
+	})
+
+	It("rejects other methods", func() {
+		response, err := http.Post(server.URL+"/memory-limits?handle=some-handle", "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Context("when the container is not found", func() {
+		BeforeEach(func() {
+			limiter.setError = garden.ContainerNotFoundError{"some-handle"}
+		})
+
+		It("responds not found", func() {
+			Expect(put(`{"limit_in_bytes":1024}`)).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when setting the limits fails", func() {
+		BeforeEach(func() {
+			limiter.setError = errors.New("oh no!")
+		})
+
+		It("responds with an internal server error", func() {
+			Expect(put(`{"limit_in_bytes":1024}`)).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package linux_backend
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// MetricsHistoryHandler serves a container's sampled metrics, with the rates
+// computed between samples, as JSON on GET, e.g.
+// GET /metrics-history?handle=some-handle&window=5m, by calling history. The
+// window defaults to every sample kept.
+type MetricsHistoryHandler struct {
+	logger  lager.Logger
+	history func(handle string, window time.Duration) (MetricsHistory, error)
+}
+
+func NewMetricsHistoryHandler(logger lager.Logger, history func(string, time.Duration) (MetricsHistory, error)) *MetricsHistoryHandler {
+	return &MetricsHistoryHandler{
+		logger:  logger.Session("metrics-history-handler"),
+		history: history,
+	}
+}
+
+func (h *MetricsHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	handle := query.Get("handle")
+
+	window := time.Duration(math.MaxInt64)
+	if query.Get("window") != "" {
+		var err error
+
+		window, err = time.ParseDuration(query.Get("window"))
+		if err != nil || window < 0 {
+			http.Error(w, "invalid window: "+query.Get("window"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	hLog := h.logger.Session("metrics-history", lager.Data{
+		"handle": handle,
+		"window": window.String(),
+	})
+
+	history, err := h.history(handle, window)
+	if err != nil {
+		respondWithError(hLog, w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,124 @@
+package linux_backend_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("MetricsHistoryHandler", func() {
+	var requestedHandle string
+	var requestedWindow time.Duration
+	var history linux_backend.MetricsHistory
+	var historyError error
+	var server *httptest.Server
+
+	get := func(query string) *http.Response {
+		response, err := http.Get(server.URL + "/metrics-history?" + query)
+		Expect(err).ToNot(HaveOccurred())
+
+		return response
+	}
+
+	BeforeEach(func() {
+		requestedHandle = ""
+		requestedWindow = 0
+		historyError = nil
+
+		history = linux_backend.MetricsHistory{
+			Samples: []linux_backend.MetricsSample{
+				{
+					Time: time.Unix(1000, 0).UTC(),
+					Metrics: garden.Metrics{
+						DiskStat: garden.ContainerDiskStat{BytesUsed: 1000},
+					},
+					CPUPercent:         50,
+					DiskBytesPerSecond: 500,
+				},
+			},
+			Stale: true,
+			Error: "oh no!",
+		}
+
+		server = httptest.NewServer(linux_backend.NewMetricsHistoryHandler(lagertest.NewTestLogger("test"), func(handle string, window time.Duration) (linux_backend.MetricsHistory, error) {
+			requestedHandle = handle
+			requestedWindow = window
+			return history, historyError
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("serves the history within the window, with its rates, as JSON", func() {
+		response := get("handle=some-handle&window=5m")
+		defer response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var served linux_backend.MetricsHistory
+		Expect(json.NewDecoder(response.Body).Decode(&served)).To(Succeed())
+		Expect(served).To(Equal(history))
+
+		Expect(requestedHandle).To(Equal("some-handle"))
+		Expect(requestedWindow).To(Equal(5 * time.Minute))
+	})
+
+	It("serves every sample kept when no window is given", func() {
+		get("handle=some-handle").Body.Close()
+
+		Expect(requestedWindow).To(Equal(time.Duration(math.MaxInt64)))
+	})
+
+	It("rejects invalid windows", func() {
+		response := get("handle=some-handle&window=bogus")
+		response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects other methods", func() {
+		response, err := http.Post(server.URL+"/metrics-history?handle=some-handle", "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Context("when the container is not found", func() {
+		BeforeEach(func() {
+			historyError = garden.ContainerNotFoundError{"some-handle"}
+		})
+
+		It("responds not found", func() {
+			response := get("handle=some-handle")
+			response.Body.Close()
+
+			Expect(response.StatusCode).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when getting the history fails", func() {
+		BeforeEach(func() {
+			historyError = errors.New("oh no!")
+		})
+
+		It("responds with an internal server error", func() {
+			response := get("handle=some-handle")
+			response.Body.Close()
+
+			Expect(response.StatusCode).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
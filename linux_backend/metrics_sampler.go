@@ -0,0 +1,232 @@
+package linux_backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/ring"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+// MetricsSample is a container's metrics at one point in time, along with
+// the rates at which they changed since the previous sample.
+type MetricsSample struct {
+	Time    time.Time
+	Metrics garden.Metrics
+
+	// zero for a container's first sample, or when a counter went backwards
+	CPUPercent         float64
+	DiskBytesPerSecond float64
+}
+
+// MetricsHistory is a container's recent samples, oldest first.
+type MetricsHistory struct {
+	Samples []MetricsSample
+
+	// set when the most recent attempt to sample the container failed, in
+	// which case the samples are out of date
+	Stale bool
+	Error string
+}
+
+// MetricsSampler periodically collects the metrics of every container, and
+// keeps the most recent samples of each, so that reading them is cheap.
+type MetricsSampler struct {
+	logger   lager.Logger
+	clock    clock.Clock
+	interval time.Duration
+	size     int
+
+	samples      map[string]*sampleRing
+	samplesMutex *sync.RWMutex
+
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func NewMetricsSampler(logger lager.Logger, clock clock.Clock, interval time.Duration, size int) *MetricsSampler {
+	if size < 1 {
+		size = 1
+	}
+
+	return &MetricsSampler{
+		logger:   logger.Session("metrics-sampler"),
+		clock:    clock,
+		interval: interval,
+		size:     size,
+
+		samples:      map[string]*sampleRing{},
+		samplesMutex: &sync.RWMutex{},
+
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+	}
+}
+
+// Start samples the metrics returned by source, keyed by container ID (so
+// that a container re-created with the same handle starts afresh), straight
+// away and then once every interval until Stop is called.
+func (s *MetricsSampler) Start(source func() map[string]garden.ContainerMetricsEntry) {
+	ticker := s.clock.NewTicker(s.interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			s.sample(source())
+
+			select {
+			case <-ticker.C():
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *MetricsSampler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Latest returns the most recent sample for the container, unless the most
+// recent attempt to sample it failed.
+func (s *MetricsSampler) Latest(id string) (MetricsSample, bool) {
+	s.samplesMutex.RLock()
+	defer s.samplesMutex.RUnlock()
+
+	samples, found := s.samples[id]
+	if !found || samples.err != nil {
+		return MetricsSample{}, false
+	}
+
+	return samples.latest()
+}
+
+// History returns the container's samples taken within the window.
+func (s *MetricsSampler) History(id string, window time.Duration) MetricsHistory {
+	s.samplesMutex.RLock()
+	defer s.samplesMutex.RUnlock()
+
+	samples, found := s.samples[id]
+	if !found {
+		return MetricsHistory{Samples: []MetricsSample{}}
+	}
+
+	history := MetricsHistory{
+		Samples: samples.since(s.clock.Now().Add(-window)),
+	}
+
+	if samples.err != nil {
+		history.Stale = true
+		history.Error = samples.err.Error()
+	}
+
+	return history
+}
+
+func (s *MetricsSampler) sample(entries map[string]garden.ContainerMetricsEntry) {
+	now := s.clock.Now()
+
+	s.samplesMutex.Lock()
+	defer s.samplesMutex.Unlock()
+
+	for id, entry := range entries {
+		samples, found := s.samples[id]
+		if !found {
+			samples = newSampleRing(s.size)
+			s.samples[id] = samples
+		}
+
+		if entry.Err != nil {
+			s.logger.Error("failed-to-sample", entry.Err, lager.Data{
+				"id": id,
+			})
+
+			samples.err = entry.Err
+			continue
+		}
+
+		samples.err = nil
+
+		sample := MetricsSample{
+			Time:    now,
+			Metrics: entry.Metrics,
+		}
+
+		if previous, found := samples.latest(); found {
+			sample.CPUPercent, sample.DiskBytesPerSecond = rates(previous, sample)
+		}
+
+		samples.push(sample)
+	}
+
+	// forget the containers that have gone away
+	for id := range s.samples {
+		if _, found := entries[id]; !found {
+			delete(s.samples, id)
+		}
+	}
+}
+
+func rates(previous, current MetricsSample) (cpuPercent float64, diskBytesPerSecond float64) {
+	elapsed := current.Time.Sub(previous.Time)
+	if elapsed <= 0 {
+		return 0, 0
+	}
+
+	// cpuacct.usage is in nanoseconds, as is elapsed
+	if usage, previousUsage := current.Metrics.CPUStat.Usage, previous.Metrics.CPUStat.Usage; usage >= previousUsage {
+		cpuPercent = float64(usage-previousUsage) / float64(elapsed) * 100
+	}
+
+	bytesUsed := float64(current.Metrics.DiskStat.BytesUsed)
+	previousBytesUsed := float64(previous.Metrics.DiskStat.BytesUsed)
+	diskBytesPerSecond = (bytesUsed - previousBytesUsed) / elapsed.Seconds()
+
+	return cpuPercent, diskBytesPerSecond
+}
+
+// sampleRing holds the most recent samples of a container, overwriting the
+// oldest once it is full.
+type sampleRing struct {
+	samples *ring.Ring
+
+	// why the most recent attempt to sample the container failed, if it did
+	err error
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{
+		samples: ring.New(size),
+	}
+}
+
+func (r *sampleRing) push(sample MetricsSample) {
+	r.samples.Push(sample)
+}
+
+func (r *sampleRing) latest() (MetricsSample, bool) {
+	sample, found := r.samples.Latest()
+	if !found {
+		return MetricsSample{}, false
+	}
+
+	return sample.(MetricsSample), true
+}
+
+func (r *sampleRing) since(start time.Time) []MetricsSample {
+	samples := []MetricsSample{}
+
+	for _, item := range r.samples.Items() {
+		sample := item.(MetricsSample)
+		if !sample.Time.Before(start) {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples
+}
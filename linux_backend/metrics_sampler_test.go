@@ -0,0 +1,198 @@
+package linux_backend_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("MetricsSampler", func() {
+	var fakeClock *fakeclock.FakeClock
+	var sampler *linux_backend.MetricsSampler
+
+	var entries map[string]garden.ContainerMetricsEntry
+	var entriesMutex *sync.Mutex
+	var sampled chan struct{}
+
+	setEntries := func(e map[string]garden.ContainerMetricsEntry) {
+		entriesMutex.Lock()
+		entries = e
+		entriesMutex.Unlock()
+	}
+
+	metrics := func(cpuUsage, bytesUsed uint64) garden.ContainerMetricsEntry {
+		return garden.ContainerMetricsEntry{
+			Metrics: garden.Metrics{
+				CPUStat:  garden.ContainerCPUStat{Usage: cpuUsage},
+				DiskStat: garden.ContainerDiskStat{BytesUsed: bytesUsed},
+			},
+		}
+	}
+
+	// tick advances the clock by a second and waits for the resulting sample
+	tick := func() {
+		fakeClock.Increment(time.Second)
+		Eventually(sampled).Should(Receive())
+	}
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+		entriesMutex = new(sync.Mutex)
+		sampled = make(chan struct{}, 10)
+
+		setEntries(map[string]garden.ContainerMetricsEntry{
+			"some-id": metrics(0, 1000),
+		})
+
+		sampler = linux_backend.NewMetricsSampler(lagertest.NewTestLogger("test"), fakeClock, time.Second, 3)
+		sampler.Start(func() map[string]garden.ContainerMetricsEntry {
+			entriesMutex.Lock()
+			defer entriesMutex.Unlock()
+
+			defer func() { sampled <- struct{}{} }()
+
+			return entries
+		})
+
+		Eventually(sampled).Should(Receive())
+		Eventually(fakeClock.WatcherCount).Should(Equal(1))
+	})
+
+	AfterEach(func() {
+		sampler.Stop()
+	})
+
+	It("samples straight away", func() {
+		Eventually(func() bool {
+			_, found := sampler.Latest("some-id")
+			return found
+		}).Should(BeTrue())
+
+		sample, _ := sampler.Latest("some-id")
+		Expect(sample.Time).To(Equal(time.Unix(1000, 0)))
+		Expect(sample.Metrics.DiskStat.BytesUsed).To(Equal(uint64(1000)))
+	})
+
+	It("computes rates from consecutive samples", func() {
+		// half a second of CPU time, and 500 bytes written, in a second
+		setEntries(map[string]garden.ContainerMetricsEntry{
+			"some-id": metrics(uint64(500*time.Millisecond), 1500),
+		})
+
+		tick()
+
+		Eventually(func() float64 {
+			sample, _ := sampler.Latest("some-id")
+			return sample.CPUPercent
+		}).Should(Equal(50.0))
+
+		sample, _ := sampler.Latest("some-id")
+		Expect(sample.DiskBytesPerSecond).To(Equal(500.0))
+	})
+
+	It("keeps only the most recent samples", func() {
+		for i := uint64(1); i <= 4; i++ {
+			setEntries(map[string]garden.ContainerMetricsEntry{
+				"some-id": metrics(0, 1000+i),
+			})
+
+			tick()
+		}
+
+		Eventually(func() []uint64 {
+			bytesUsed := []uint64{}
+			for _, sample := range sampler.History("some-id", time.Hour).Samples {
+				bytesUsed = append(bytesUsed, sample.Metrics.DiskStat.BytesUsed)
+			}
+
+			return bytesUsed
+		}).Should(Equal([]uint64{1002, 1003, 1004}))
+	})
+
+	It("serves the samples within the window", func() {
+		tick()
+		tick()
+
+		Eventually(func() int {
+			return len(sampler.History("some-id", time.Hour).Samples)
+		}).Should(Equal(3))
+
+		Expect(sampler.History("some-id", time.Second).Samples).To(HaveLen(2))
+	})
+
+	It("forgets containers that have gone away", func() {
+		setEntries(map[string]garden.ContainerMetricsEntry{})
+
+		tick()
+
+		Eventually(func() bool {
+			_, found := sampler.Latest("some-id")
+			return found
+		}).Should(BeFalse())
+	})
+
+	It("does not serve the samples of a container that has gone away to one with the same handle", func() {
+		setEntries(map[string]garden.ContainerMetricsEntry{
+			"some-other-id": metrics(0, 2000),
+		})
+
+		tick()
+
+		Expect(sampler.History("some-id", time.Hour).Samples).To(BeEmpty())
+
+		samples := sampler.History("some-other-id", time.Hour).Samples
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Metrics.DiskStat.BytesUsed).To(Equal(uint64(2000)))
+	})
+
+	Context("when collecting a container's metrics fails", func() {
+		BeforeEach(func() {
+			setEntries(map[string]garden.ContainerMetricsEntry{
+				"some-id": {Err: errors.New("oh no!")},
+			})
+
+			tick()
+		})
+
+		It("no longer serves its latest sample", func() {
+			_, found := sampler.Latest("some-id")
+			Expect(found).To(BeFalse())
+		})
+
+		It("marks its history stale, keeping the previous samples", func() {
+			history := sampler.History("some-id", time.Hour)
+			Expect(history.Stale).To(BeTrue())
+			Expect(history.Error).To(Equal("oh no!"))
+
+			Expect(history.Samples).To(HaveLen(1))
+			Expect(history.Samples[0].Metrics.DiskStat.BytesUsed).To(Equal(uint64(1000)))
+		})
+
+		Context("and then succeeds again", func() {
+			BeforeEach(func() {
+				setEntries(map[string]garden.ContainerMetricsEntry{
+					"some-id": metrics(0, 1500),
+				})
+
+				tick()
+			})
+
+			It("is no longer stale", func() {
+				history := sampler.History("some-id", time.Hour)
+				Expect(history.Stale).To(BeFalse())
+				Expect(history.Samples).To(HaveLen(2))
+
+				sample, found := sampler.Latest("some-id")
+				Expect(found).To(BeTrue())
+				Expect(sample.Metrics.DiskStat.BytesUsed).To(Equal(uint64(1500)))
+			})
+		})
+	})
+})
@@ -12,8 +12,10 @@ import (
 	"github.com/cloudfoundry/gunk/command_runner"
 )
 
-// Kills a process by invoking ./bin/wsh in the given container path using
-// a PID read from the given pidFile
+// Signals a process, and every process in its group, by invoking ./bin/wsh in
+// the given container path using a PID read from the given pidFile. wshd
+// starts each process in a session of its own, so its PID is also its
+// group's.
 type NamespacedSignaller struct {
 	Runner        command_runner.CommandRunner
 	ContainerPath string
@@ -28,7 +30,7 @@ func (n *NamespacedSignaller) Signal(signal os.Signal) error {
 
 	return n.Runner.Run(exec.Command(filepath.Join(n.ContainerPath, "bin/wsh"),
 		"--socket", filepath.Join(n.ContainerPath, "run/wshd.sock"),
-		"kill", fmt.Sprintf("-%d", signal), fmt.Sprintf("%d", pid)))
+		"kill", fmt.Sprintf("-%d", signal), "--", fmt.Sprintf("-%d", pid)))
 }
 
 func pidFromFile(pidFilePath string) (int, error) {
@@ -14,7 +14,7 @@ import (
 )
 
 var _ = Describe("Namespaced Signaller", func() {
-	It("kills a process using ./bin/wsh based on its pid", func() {
+	It("kills a process group using ./bin/wsh based on its leader's pid", func() {
 		tmp, err := ioutil.TempDir("", "namespacedsignaller")
 		Expect(err).ToNot(HaveOccurred())
 		defer os.RemoveAll(tmp)
@@ -36,7 +36,7 @@ var _ = Describe("Namespaced Signaller", func() {
 				Path: "/fish/finger/bin/wsh",
 				Args: []string{
 					"--socket", "/fish/finger/run/wshd.sock",
-					"kill", "-9", "12345",
+					"kill", "-9", "--", "-12345",
 				},
 			}))
 	})
@@ -0,0 +1,235 @@
+package linux_backend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+type ReapAction string
+
+const (
+	ReapDestroy = ReapAction("destroy")
+	ReapStop    = ReapAction("stop")
+)
+
+// ReaperPolicy decides which containers are abandoned, and what is done with
+// them.
+type ReaperPolicy struct {
+	// how long a container may go without active processes or API calls
+	// before it is reaped; zero only reaps containers with a TTL property
+	IdleTimeout time.Duration
+
+	// the property that overrides IdleTimeout for a container, given as a
+	// duration such as "72h"; empty disables the override
+	TTLProperty string
+
+	Action ReapAction
+
+	// only publish the reaped events, without acting on them
+	DryRun bool
+}
+
+// Reaper periodically destroys (or stops) the containers that have had no
+// active processes and no API calls for longer than the policy allows.
+//
+// Activity is only tracked in memory: containers restored after a restart
+// are idle from the time the reaper first sees them.
+type Reaper struct {
+	logger   lager.Logger
+	clock    clock.Clock
+	interval time.Duration
+	policy   ReaperPolicy
+
+	// last activity of each container, by handle
+	activity      map[string]time.Time
+	reaped        map[string]bool
+	activityMutex *sync.Mutex
+
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func NewReaper(logger lager.Logger, clock clock.Clock, interval time.Duration, policy ReaperPolicy) *Reaper {
+	if policy.Action == "" {
+		policy.Action = ReapDestroy
+	}
+
+	return &Reaper{
+		logger:   logger.Session("reaper"),
+		clock:    clock,
+		interval: interval,
+		policy:   policy,
+
+		activity:      map[string]time.Time{},
+		reaped:        map[string]bool{},
+		activityMutex: &sync.Mutex{},
+
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+	}
+}
+
+// Start sweeps the backend's containers once every interval until Stop is
+// called.
+func (r *Reaper) Start(backend *LinuxBackend) {
+	ticker := r.clock.NewTicker(r.interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				r.sweep(backend)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Reaper) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// Touch records activity in the container, restarting its idle timeout.
+func (r *Reaper) Touch(handle string) {
+	r.activityMutex.Lock()
+	defer r.activityMutex.Unlock()
+
+	r.activity[handle] = r.clock.Now()
+	delete(r.reaped, handle)
+}
+
+// Forget stops tracking a container once it is destroyed.
+func (r *Reaper) Forget(handle string) {
+	r.activityMutex.Lock()
+	defer r.activityMutex.Unlock()
+
+	delete(r.activity, handle)
+	delete(r.reaped, handle)
+}
+
+func (r *Reaper) sweep(backend *LinuxBackend) {
+	for _, container := range backend.containerRepo.All() {
+		handle := container.Handle()
+
+		if len(container.ActiveProcesses()) > 0 {
+			r.Touch(handle)
+			continue
+		}
+
+		timeout, err := r.timeoutFor(container)
+		if err != nil {
+			r.logger.Error("failed-to-determine-ttl", err, lager.Data{
+				"handle": handle,
+			})
+
+			continue
+		}
+
+		if timeout <= 0 {
+			continue
+		}
+
+		idle, due := r.idleFor(handle, timeout)
+		if !due {
+			continue
+		}
+
+		r.reap(backend, container, idle)
+	}
+}
+
+func (r *Reaper) timeoutFor(container Container) (time.Duration, error) {
+	if r.policy.TTLProperty == "" {
+		return r.policy.IdleTimeout, nil
+	}
+
+	properties, err := container.GetProperties()
+	if err != nil {
+		return 0, err
+	}
+
+	ttl, found := properties[r.policy.TTLProperty]
+	if !found {
+		return r.policy.IdleTimeout, nil
+	}
+
+	return time.ParseDuration(ttl)
+}
+
+// idleFor returns how long the container has been idle, and whether it is due
+// to be reaped: idle for at least the timeout, and not yet reaped since its
+// last activity.
+func (r *Reaper) idleFor(handle string, timeout time.Duration) (time.Duration, bool) {
+	r.activityMutex.Lock()
+	defer r.activityMutex.Unlock()
+
+	now := r.clock.Now()
+
+	last, found := r.activity[handle]
+	if !found {
+		r.activity[handle] = now
+		return 0, false
+	}
+
+	idle := now.Sub(last)
+	if idle < timeout || r.reaped[handle] {
+		return idle, false
+	}
+
+	r.reaped[handle] = true
+
+	return idle, true
+}
+
+func (r *Reaper) reap(backend *LinuxBackend, container Container, idle time.Duration) {
+	rLog := r.logger.Session("reap", lager.Data{
+		"handle":  container.Handle(),
+		"action":  r.policy.Action,
+		"idle":    idle.String(),
+		"dry-run": r.policy.DryRun,
+	})
+
+	event := Event{
+		Type:    EventReaped,
+		Action:  string(r.policy.Action),
+		DryRun:  r.policy.DryRun,
+		Message: fmt.Sprintf("idle for %s", idle),
+	}
+
+	if r.policy.DryRun {
+		rLog.Info("skipped")
+		backend.events.publishFor(container, event)
+		return
+	}
+
+	var err error
+	switch r.policy.Action {
+	case ReapStop:
+		err = container.Stop(false)
+	default:
+		err = backend.Destroy(container.Handle())
+	}
+
+	if err != nil {
+		rLog.Error("failed", err)
+
+		// try again on the next sweep
+		r.activityMutex.Lock()
+		delete(r.reaped, container.Handle())
+		r.activityMutex.Unlock()
+
+		return
+	}
+
+	rLog.Info("reaped")
+	backend.events.publishFor(container, event)
+}
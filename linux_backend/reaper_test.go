@@ -0,0 +1,203 @@
+package linux_backend_test
+
+import (
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/system_info/fake_system_info"
+	"github.com/cloudfoundry-incubator/garden/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("Reaping idle containers", func() {
+	var fakeContainerPool *fake_container_pool.FakeContainerPool
+	var fakeClock *fakeclock.FakeClock
+	var policy linux_backend.ReaperPolicy
+	var linuxBackend *linux_backend.LinuxBackend
+	var subscription *linux_backend.EventSubscription
+
+	BeforeEach(func() {
+		fakeContainerPool = fake_container_pool.New()
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+
+		policy = linux_backend.ReaperPolicy{
+			IdleTimeout: time.Minute,
+		}
+	})
+
+	JustBeforeEach(func() {
+		logger := lagertest.NewTestLogger("test")
+
+		linuxBackend = linux_backend.New(
+			logger,
+			fakeContainerPool,
+			container_repository.New(),
+			fake_system_info.NewFakeProvider(),
+			"",
+			1,
+			0,
+			1,
+			0,
+			nil,
+			linux_backend.NewReaper(logger, fakeClock, time.Minute, policy),
+			linux_backend.AdmissionPolicy{},
+			false,
+		)
+
+		subscription = linuxBackend.Events().Subscribe(linux_backend.EventFilter{
+			Types: []linux_backend.EventType{linux_backend.EventReaped},
+		})
+
+		err := linuxBackend.Start()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		subscription.Close()
+		linuxBackend.Stop()
+	})
+
+	handles := func() []string {
+		containers, err := linuxBackend.Containers(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		handles := []string{}
+		for _, container := range containers {
+			handles = append(handles, container.Handle())
+		}
+
+		return handles
+	}
+
+	create := func(spec garden.ContainerSpec) *fake_container_pool.FakeContainer {
+		container, err := linuxBackend.Create(spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		return container.(*fake_container_pool.FakeContainer)
+	}
+
+	It("destroys containers that have been idle for the timeout", func() {
+		create(garden.ContainerSpec{Handle: "some-handle"})
+
+		fakeClock.Increment(time.Minute)
+		Eventually(handles).Should(BeEmpty())
+
+		var event linux_backend.Event
+		Eventually(subscription.Events()).Should(Receive(&event))
+		Expect(event.Handle).To(Equal("some-handle"))
+		Expect(event.Action).To(Equal("destroy"))
+		Expect(event.DryRun).To(BeFalse())
+		Expect(event.Message).To(Equal("idle for 1m0s"))
+	})
+
+	It("leaves containers that have not been idle for the timeout", func() {
+		create(garden.ContainerSpec{Handle: "some-handle"})
+
+		fakeClock.Increment(30 * time.Second)
+		Consistently(handles).Should(ConsistOf("some-handle"))
+	})
+
+	It("leaves containers with active processes", func() {
+		fakeContainerPool.ContainerSetup = func(c *fake_container_pool.FakeContainer) {
+			c.ActiveProcessesValue = []garden.Process{new(fakes.FakeProcess)}
+		}
+
+		create(garden.ContainerSpec{Handle: "some-handle"})
+
+		fakeClock.Increment(time.Minute)
+		Consistently(handles).Should(ConsistOf("some-handle"))
+	})
+
+	Context("when the idle timeout is longer than the interval", func() {
+		BeforeEach(func() {
+			policy.IdleTimeout = 2 * time.Minute
+		})
+
+		It("restarts the timeout on each API call about the container", func() {
+			create(garden.ContainerSpec{Handle: "some-handle"})
+
+			fakeClock.Increment(time.Minute)
+
+			_, err := linuxBackend.Lookup("some-handle")
+			Expect(err).ToNot(HaveOccurred())
+
+			fakeClock.Increment(time.Minute)
+			Consistently(handles).Should(ConsistOf("some-handle"))
+
+			fakeClock.Increment(time.Minute)
+			Eventually(handles).Should(BeEmpty())
+		})
+	})
+
+	Context("with a TTL property", func() {
+		BeforeEach(func() {
+			policy.IdleTimeout = 0
+			policy.TTLProperty = "ttl"
+		})
+
+		It("only reaps the containers that have one, once it has passed", func() {
+			create(garden.ContainerSpec{Handle: "short-lived", Properties: garden.Properties{"ttl": "1m"}})
+			create(garden.ContainerSpec{Handle: "long-lived", Properties: garden.Properties{"ttl": "1h"}})
+			create(garden.ContainerSpec{Handle: "immortal"})
+
+			fakeClock.Increment(time.Minute)
+			Eventually(handles).Should(ConsistOf("long-lived", "immortal"))
+		})
+
+		It("leaves containers whose TTL is not a duration", func() {
+			create(garden.ContainerSpec{Handle: "some-handle", Properties: garden.Properties{"ttl": "forever"}})
+
+			fakeClock.Increment(time.Minute)
+			Consistently(handles).Should(ConsistOf("some-handle"))
+		})
+	})
+
+	Context("when the action is to stop", func() {
+		BeforeEach(func() {
+			policy.Action = linux_backend.ReapStop
+		})
+
+		It("stops idle containers, once", func() {
+			container := create(garden.ContainerSpec{Handle: "some-handle"})
+
+			fakeClock.Increment(time.Minute)
+			Eventually(container.StopCallCount).Should(Equal(1))
+			Expect(container.StopArgsForCall(0)).To(BeFalse())
+
+			var event linux_backend.Event
+			Eventually(subscription.Events()).Should(Receive(&event))
+			Expect(event.Action).To(Equal("stop"))
+
+			fakeClock.Increment(time.Minute)
+			Consistently(container.StopCallCount).Should(Equal(1))
+			Expect(handles()).To(ConsistOf("some-handle"))
+		})
+	})
+
+	Context("when dry-running", func() {
+		BeforeEach(func() {
+			policy.DryRun = true
+		})
+
+		It("only publishes an event, once", func() {
+			create(garden.ContainerSpec{Handle: "some-handle"})
+
+			fakeClock.Increment(time.Minute)
+
+			var event linux_backend.Event
+			Eventually(subscription.Events()).Should(Receive(&event))
+			Expect(event.Handle).To(Equal("some-handle"))
+			Expect(event.DryRun).To(BeTrue())
+
+			fakeClock.Increment(time.Minute)
+			Consistently(subscription.Events()).ShouldNot(Receive())
+			Expect(handles()).To(ConsistOf("some-handle"))
+		})
+	})
+})
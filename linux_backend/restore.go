@@ -0,0 +1,182 @@
+package linux_backend
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pivotal-golang/lager"
+)
+
+// restoreJob describes a single container to restore at startup: name
+// identifies it in logs and in the summary, and open provides its snapshot.
+// restored and failed, if set, are called with the outcome.
+type restoreJob struct {
+	name     string
+	open     func() (io.ReadCloser, error)
+	restored func(Container)
+	failed   func(lager.Logger)
+}
+
+type restoreSummary struct {
+	Restored []string
+	Failed   []string
+	TimedOut []string
+}
+
+type restoreResult struct {
+	container Container
+	err       error
+}
+
+// restoreAll restores the containers on a pool of restoreConcurrency workers.
+//
+// A restore that takes longer than restoreTimeout is counted as failed and
+// left to finish in the background; see finishLate.
+func (b *LinuxBackend) restoreAll(logger lager.Logger, jobs []restoreJob) restoreSummary {
+	workers := b.restoreConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var summary restoreSummary
+	var summaryMutex sync.Mutex
+
+	queue := make(chan restoreJob)
+
+	wg := new(sync.WaitGroup)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range queue {
+				jLog := logger.Session("load", lager.Data{
+					"name": job.name,
+				})
+
+				container, err := b.restoreWithTimeout(jLog, job)
+
+				summaryMutex.Lock()
+				switch err.(type) {
+				case RestoreTimedOutError:
+					summary.TimedOut = append(summary.TimedOut, job.name)
+				case nil:
+					summary.Restored = append(summary.Restored, container.ID())
+				default:
+					summary.Failed = append(summary.Failed, job.name)
+				}
+				summaryMutex.Unlock()
+
+				if err == nil && job.restored != nil {
+					job.restored(container)
+				}
+
+				if err != nil {
+					b.events.Publish(Event{
+						Type:    EventRestoreFailed,
+						Message: fmt.Sprintf("%s: %s", job.name, err),
+					})
+				}
+
+				if err != nil && job.failed != nil {
+					job.failed(jLog)
+				}
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		queue <- job
+	}
+
+	close(queue)
+
+	wg.Wait()
+
+	logger.Info("summary", lager.Data{
+		"restored":  len(summary.Restored),
+		"failed":    summary.Failed,
+		"timed-out": summary.TimedOut,
+	})
+
+	return summary
+}
+
+func (b *LinuxBackend) restoreWithTimeout(logger lager.Logger, job restoreJob) (Container, error) {
+	logger.Debug("loading")
+
+	snapshot, err := job.open()
+	if err != nil {
+		logger.Error("failed-to-open", err)
+		return nil, err
+	}
+
+	done := make(chan restoreResult, 1)
+
+	go func() {
+		defer snapshot.Close()
+
+		container, err := b.containerPool.Restore(snapshot)
+		done <- restoreResult{container, err}
+	}()
+
+	var timeout <-chan time.Time
+	if b.restoreTimeout > 0 {
+		timeout = time.After(b.restoreTimeout)
+	}
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			logger.Error("failed-to-restore", result.err)
+			return nil, result.err
+		}
+
+		b.adoptRestored(result.container)
+
+		logger.Info("restored", lager.Data{
+			"id": result.container.ID(),
+		})
+
+		return result.container, nil
+
+	case <-timeout:
+		err := RestoreTimedOutError{Name: job.name, Timeout: b.restoreTimeout}
+		logger.Error("failed-to-restore", err)
+
+		go b.finishLate(logger, done)
+
+		return nil, err
+	}
+}
+
+// adoptRestored makes a restored container visible and snapshots it from
+// then on.
+func (b *LinuxBackend) adoptRestored(container Container) {
+	container.OnEvent(b.events.Publish)
+	container.Guard(b.admission)
+
+	b.containerRepo.Add(container)
+	b.trackSnapshot(container)
+}
+
+// finishLate waits for a restore that timed out to finish. The restore has
+// been reported as failed, and its snapshot quarantined for inspection, but a
+// container restored late is running and holding resources all the same, so
+// it is adopted like any other rather than destroyed.
+func (b *LinuxBackend) finishLate(logger lager.Logger, done <-chan restoreResult) {
+	result := <-done
+	if result.err != nil {
+		return
+	}
+
+	b.adoptRestored(result.container)
+
+	logger.Info("restored-late", lager.Data{
+		"id": result.container.ID(),
+	})
+}
@@ -0,0 +1,84 @@
+package linux_backend
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
+	"github.com/pivotal-golang/lager"
+)
+
+// SignalProcess sends a signal to one of a container's processes. Unlike
+// the garden API, it accepts any of the signals process_tracker knows.
+func (b *LinuxBackend) SignalProcess(handle string, processID uint32, signal garden.Signal) error {
+	container, err := b.containerRepo.FindByHandle(handle)
+	if err != nil {
+		return err
+	}
+
+	for _, process := range container.ActiveProcesses() {
+		if process.ID() == processID {
+			return process.Signal(signal)
+		}
+	}
+
+	return process_tracker.UnknownProcessError{processID}
+}
+
+// SignalHandler sends the named signal to a container's process on POST,
+// e.g. POST /signal?handle=some-handle&process=1&signal=HUP, by calling
+// signal.
+type SignalHandler struct {
+	logger lager.Logger
+	signal func(handle string, processID uint32, signal garden.Signal) error
+}
+
+func NewSignalHandler(logger lager.Logger, signal func(string, uint32, garden.Signal) error) *SignalHandler {
+	return &SignalHandler{
+		logger: logger.Session("signal-handler"),
+		signal: signal,
+	}
+}
+
+func (h *SignalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	handle := query.Get("handle")
+
+	processID, err := strconv.ParseUint(query.Get("process"), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid process: "+query.Get("process"), http.StatusBadRequest)
+		return
+	}
+
+	signal, found := process_tracker.SignalNamed(query.Get("signal"))
+	if !found {
+		http.Error(w, "unknown signal: "+query.Get("signal"), http.StatusBadRequest)
+		return
+	}
+
+	hLog := h.logger.Session("signal", lager.Data{
+		"handle":  handle,
+		"process": processID,
+		"signal":  query.Get("signal"),
+	})
+
+	err = h.signal(handle, uint32(processID), signal)
+	switch err.(type) {
+	case nil:
+		hLog.Info("sent")
+		w.WriteHeader(http.StatusNoContent)
+	case garden.ContainerNotFoundError, process_tracker.UnknownProcessError:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		hLog.Error("failed", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
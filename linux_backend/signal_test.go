@@ -0,0 +1,146 @@
+package linux_backend_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/container_pool/fake_container_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/system_info/fake_system_info"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
+	"github.com/cloudfoundry-incubator/garden/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("Signalling processes", func() {
+	var process *fakes.FakeProcess
+	var linuxBackend *linux_backend.LinuxBackend
+
+	BeforeEach(func() {
+		process = new(fakes.FakeProcess)
+		process.IDReturns(42)
+
+		container := fake_container_pool.NewFakeContainer(garden.ContainerSpec{Handle: "some-handle"})
+		container.ActiveProcessesValue = []garden.Process{process}
+
+		containerRepo := container_repository.New()
+		containerRepo.Add(container)
+
+		linuxBackend = linux_backend.New(
+			lagertest.NewTestLogger("test"),
+			fake_container_pool.New(),
+			containerRepo,
+			fake_system_info.NewFakeProvider(),
+			"",
+			1,
+			0,
+			1,
+			0,
+			nil,
+			nil,
+			linux_backend.AdmissionPolicy{},
+			false,
+		)
+	})
+
+	It("sends the signal to the container's process", func() {
+		err := linuxBackend.SignalProcess("some-handle", 42, process_tracker.SignalHangup)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(process.SignalCallCount()).To(Equal(1))
+		Expect(process.SignalArgsForCall(0)).To(Equal(process_tracker.SignalHangup))
+	})
+
+	It("returns the error when the process is unknown", func() {
+		err := linuxBackend.SignalProcess("some-handle", 43, process_tracker.SignalHangup)
+		Expect(err).To(Equal(process_tracker.UnknownProcessError{43}))
+	})
+
+	It("returns the error when the container is unknown", func() {
+		err := linuxBackend.SignalProcess("bogus-handle", 42, process_tracker.SignalHangup)
+		Expect(err).To(Equal(garden.ContainerNotFoundError{"bogus-handle"}))
+	})
+})
+
+var _ = Describe("SignalHandler", func() {
+	type sent struct {
+		handle    string
+		processID uint32
+		signal    garden.Signal
+	}
+
+	var signalled []sent
+	var signalError error
+	var server *httptest.Server
+
+	post := func(query string) int {
+		response, err := http.Post(server.URL+"/signal?"+query, "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		return response.StatusCode
+	}
+
+	BeforeEach(func() {
+		signalled = nil
+		signalError = nil
+
+		server = httptest.NewServer(linux_backend.NewSignalHandler(lagertest.NewTestLogger("test"), func(handle string, processID uint32, signal garden.Signal) error {
+			signalled = append(signalled, sent{handle, processID, signal})
+			return signalError
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends the named signal on POST", func() {
+		Expect(post("handle=some-handle&process=42&signal=USR1")).To(Equal(http.StatusNoContent))
+		Expect(signalled).To(Equal([]sent{{"some-handle", 42, process_tracker.SignalUser1}}))
+	})
+
+	It("rejects unknown signals", func() {
+		Expect(post("handle=some-handle&process=42&signal=BOGUS")).To(Equal(http.StatusBadRequest))
+		Expect(signalled).To(BeEmpty())
+	})
+
+	It("rejects invalid process IDs", func() {
+		Expect(post("handle=some-handle&process=-1&signal=HUP")).To(Equal(http.StatusBadRequest))
+		Expect(signalled).To(BeEmpty())
+	})
+
+	It("rejects other methods", func() {
+		response, err := http.Get(server.URL + "/signal?handle=some-handle&process=42&signal=HUP")
+		Expect(err).ToNot(HaveOccurred())
+		response.Body.Close()
+
+		Expect(response.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		Expect(signalled).To(BeEmpty())
+	})
+
+	Context("when the container or process is not found", func() {
+		BeforeEach(func() {
+			signalError = process_tracker.UnknownProcessError{42}
+		})
+
+		It("responds not found", func() {
+			Expect(post("handle=some-handle&process=42&signal=HUP")).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("when signalling fails", func() {
+		BeforeEach(func() {
+			signalError = errors.New("oh no!")
+		})
+
+		It("responds with an internal server error", func() {
+			Expect(post("handle=some-handle&process=42&signal=HUP")).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
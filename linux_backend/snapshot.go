@@ -0,0 +1,71 @@
+package linux_backend
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// SaveSnapshot atomically replaces the file at snapshotPath with a snapshot
+// of the container: the snapshot is written and synced to a temporary file in
+// the same directory, which is then renamed into place. A crash at any point
+// leaves either the previous snapshot or the new one, never a truncated file.
+func SaveSnapshot(container Container, snapshotPath string) error {
+	dir, name := path.Split(snapshotPath)
+
+	tmp, err := ioutil.TempFile(dir, "."+name+".tmp")
+	if err != nil {
+		return err
+	}
+
+	err = writeSnapshot(container, tmp)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), snapshotPath)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// IsSnapshotTempFile reports whether the named file is a leftover from a
+// SaveSnapshot that was interrupted.
+func IsSnapshotTempFile(name string) bool {
+	return len(name) > 0 && name[0] == '.'
+}
+
+func writeSnapshot(container Container, file *os.File) error {
+	err := container.Snapshot(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func syncDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,326 @@
+package linux_container
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/logging"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
+	"github.com/pivotal-golang/lager"
+)
+
+var ErrCheckpointingDisabled = errors.New("container: checkpointing is disabled")
+
+// Checkpoint dumps the container's processes, and their memory, to the
+// checkpoint directory in the depot with CRIU, which kills them. They carry
+// on where they left off when the container's snapshot is restored, whether
+// after a restart of garden, a reboot, or on another host given the depot.
+//
+// The processes' pipes to the host are recorded in the snapshot along with
+// them, so that they can be re-attached to once restored.
+func (c *LinuxContainer) Checkpoint() error {
+	cLog := c.logger.Session("checkpoint")
+
+	if c.criuPath == "" {
+		return ErrCheckpointingDisabled
+	}
+
+	if state := c.State(); state != StateActive {
+		return InvalidStateError{"checkpoint", state}
+	}
+
+	cLog.Debug("checkpointing")
+
+	processes, err := c.checkpointProcesses()
+	if err != nil {
+		cLog.Error("failed-to-record-processes", err)
+		return fmt.Errorf("container: checkpoint: %v", err)
+	}
+
+	// the dump kills the processes, and every snapshot from here on must
+	// list them regardless
+	c.setCheckpointed(processes)
+
+	// nothing must change while the processes are dumped
+	frozen := c.hasFreezer()
+	if frozen {
+		err := c.freeze()
+		if err != nil {
+			c.thaw()
+			c.setCheckpointed(nil)
+
+			cLog.Error("failed-to-freeze", err)
+			return fmt.Errorf("container: checkpoint: %v", err)
+		}
+	}
+
+	err = c.runCheckpointScript(cLog, c.checkpointScript("checkpoint.sh"))
+	if err != nil {
+		if frozen {
+			c.thaw()
+		}
+
+		c.setCheckpointed(nil)
+
+		cLog.Error("failed-to-checkpoint", err)
+		return fmt.Errorf("container: checkpoint: %v", err)
+	}
+
+	c.stopOomNotifier()
+
+	c.setState(StateCheckpointed)
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventCheckpointed})
+
+	cLog.Info("checkpointed")
+
+	return nil
+}
+
+// checkpointProcesses records the container's processes, with what wsh saved
+// of each for it to be re-attached to.
+func (c *LinuxContainer) checkpointProcesses() ([]ProcessSnapshot, error) {
+	processes := []ProcessSnapshot{}
+
+	for _, p := range c.processTracker.ActiveProcesses() {
+		checkpoint := &CheckpointedProcess{}
+
+		err := scanFile(c.processFile(p.ID(), "pid"), &checkpoint.Pid)
+		if os.IsNotExist(err) {
+			// wsh removes it once the process exits
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		pipes := &checkpoint.Pipes
+
+		// only saved for processes run without a tty, which CRIU cannot
+		// dump anyway
+		err = scanFile(
+			c.processFile(p.ID(), "pipes"),
+			&pipes.Stdin, &pipes.Stdout, &pipes.Stderr, &pipes.ExitStatus,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("process %d cannot be re-attached to: %v", p.ID(), err)
+		}
+
+		processes = append(processes, ProcessSnapshot{
+			ID:         p.ID(),
+			Checkpoint: checkpoint,
+		})
+	}
+
+	return processes, nil
+}
+
+// restoreCheckpoint brings back the processes in the container's checkpoint,
+// with new pipes in place of those to the host. It returns the host's ends of
+// them for the processes to be re-attached to. The rootfs and the bridge must
+// already be in place.
+func (c *LinuxContainer) restoreCheckpoint(cLog lager.Logger, processes []ProcessSnapshot) (map[uint32]process_tracker.ProcessPipes, error) {
+	if c.criuPath == "" {
+		return nil, ErrCheckpointingDisabled
+	}
+
+	_, err := os.Stat(path.Join(c.path, "checkpoint"))
+	if err != nil {
+		cLog.Error("checkpoint-missing", err)
+		return nil, fmt.Errorf("container: restore checkpoint: %v", err)
+	}
+
+	restore := c.checkpointScript("restore.sh")
+
+	// CRIU is handed the container's ends of the new pipes, and told which
+	// of the old ones each replaces
+	inheritFds := []string{}
+	inherit := func(container *os.File, inode uint64) {
+		// restore.sh, and CRIU, have ExtraFiles[i] as fd 3+i
+		fd := 3 + len(restore.ExtraFiles)
+
+		restore.ExtraFiles = append(restore.ExtraFiles, container)
+		inheritFds = append(inheritFds, "--inherit-fd", fmt.Sprintf("fd[%d]:pipe:[%d]", fd, inode))
+	}
+
+	defer func() {
+		closeFiles(restore.ExtraFiles...)
+	}()
+
+	reattached := map[uint32]process_tracker.ProcessPipes{}
+	restoredInodes := map[uint32]PipeInodes{}
+
+	for _, p := range processes {
+		if p.Checkpoint == nil {
+			continue
+		}
+
+		pipes, containerEnds, err := newProcessPipes()
+		if err != nil {
+			closeProcessPipes(reattached)
+
+			cLog.Error("failed-to-create-pipes", err)
+			return nil, fmt.Errorf("container: restore checkpoint: %v", err)
+		}
+
+		reattached[p.ID] = pipes
+
+		old := p.Checkpoint.Pipes
+		inherit(containerEnds[0], old.Stdin)
+		inherit(containerEnds[1], old.Stdout)
+		inherit(containerEnds[2], old.Stderr)
+		inherit(containerEnds[3], old.ExitStatus)
+
+		restoredInodes[p.ID] = PipeInodes{
+			Stdin:      inode(containerEnds[0]),
+			Stdout:     inode(containerEnds[1]),
+			Stderr:     inode(containerEnds[2]),
+			ExitStatus: inode(containerEnds[3]),
+		}
+	}
+
+	restore.Env = append(restore.Env, "inherit_fds="+strings.Join(inheritFds, " "))
+
+	err = c.runCheckpointScript(cLog, restore)
+	if err != nil {
+		closeProcessPipes(reattached)
+
+		cLog.Error("failed-to-restore-checkpoint", err)
+		return nil, fmt.Errorf("container: restore checkpoint: %v", err)
+	}
+
+	c.setState(StateActive)
+
+	// wsh removed these when the dump killed it, and a later checkpoint needs
+	// them again
+	for _, p := range processes {
+		inodes, found := restoredInodes[p.ID]
+		if !found {
+			continue
+		}
+
+		err := c.saveRestoredProcess(p.ID, p.Checkpoint.Pid, inodes)
+		if err != nil {
+			closeProcessPipes(reattached)
+
+			cLog.Error("failed-to-save-restored-process", err, lager.Data{"process": p.ID})
+			return nil, fmt.Errorf("container: restore checkpoint: %v", err)
+		}
+	}
+
+	return reattached, nil
+}
+
+func (c *LinuxContainer) saveRestoredProcess(processID uint32, pid int, inodes PipeInodes) error {
+	// made by the first process's iodaemon
+	err := os.MkdirAll(path.Join(c.path, "processes"), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(c.processFile(processID, "pid"), []byte(fmt.Sprintf("%d\n", pid)), 0600)
+	if err != nil {
+		return err
+	}
+
+	pipes := fmt.Sprintf("%d %d %d %d\n", inodes.Stdin, inodes.Stdout, inodes.Stderr, inodes.ExitStatus)
+	return ioutil.WriteFile(c.processFile(processID, "pipes"), []byte(pipes), 0600)
+}
+
+func (c *LinuxContainer) checkpointScript(script string) *exec.Cmd {
+	cmd := exec.Command(path.Join(c.path, script))
+	cmd.Env = []string{
+		"id=" + c.id,
+		"criu=" + c.criuPath,
+		"PATH=" + os.Getenv("PATH"),
+	}
+
+	return cmd
+}
+
+func (c *LinuxContainer) runCheckpointScript(cLog lager.Logger, cmd *exec.Cmd) error {
+	cRunner := logging.Runner{
+		CommandRunner: c.runner,
+		Logger:        cLog,
+	}
+
+	return cRunner.Run(cmd)
+}
+
+// processFile is the path of one of the files wsh saves for the process, such
+// as "pid" or "pipes".
+func (c *LinuxContainer) processFile(processID uint32, kind string) string {
+	return path.Join(c.path, "processes", fmt.Sprintf("%d.%s", processID, kind))
+}
+
+func scanFile(name string, values ...interface{}) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = fmt.Fscan(file, values...)
+	return err
+}
+
+func inode(file *os.File) uint64 {
+	info, err := file.Stat()
+	if err != nil {
+		return 0
+	}
+
+	return info.Sys().(*syscall.Stat_t).Ino
+}
+
+// newProcessPipes returns the host's ends of new pipes to a process, and the
+// container's ends in the order of PipeInodes.
+func newProcessPipes() (process_tracker.ProcessPipes, []*os.File, error) {
+	var host process_tracker.ProcessPipes
+	container := make([]*os.File, 4)
+
+	var err error
+	container[0], host.Stdin, err = os.Pipe()
+	if err == nil {
+		host.Stdout, container[1], err = os.Pipe()
+	}
+	if err == nil {
+		host.Stderr, container[2], err = os.Pipe()
+	}
+	if err == nil {
+		host.ExitStatus, container[3], err = os.Pipe()
+	}
+
+	if err != nil {
+		closeFiles(host.Stdin, host.Stdout, host.Stderr, host.ExitStatus)
+		closeFiles(container...)
+		return process_tracker.ProcessPipes{}, nil, err
+	}
+
+	return host, container, nil
+}
+
+func closeProcessPipes(reattached map[uint32]process_tracker.ProcessPipes) {
+	for _, pipes := range reattached {
+		closeFiles(pipes.Stdin, pipes.Stdout, pipes.Stderr, pipes.ExitStatus)
+	}
+}
+
+func closeFiles(files ...*os.File) {
+	for _, f := range files {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
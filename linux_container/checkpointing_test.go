@@ -0,0 +1,597 @@
+package linux_container_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager/fake_quota_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker/fake_process_tracker"
+	wfakes "github.com/cloudfoundry-incubator/garden/fakes"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+)
+
+var _ = Describe("Checkpointing containers", func() {
+	var cgroupsPath string
+	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+	var fakeProcessTracker *fake_process_tracker.FakeProcessTracker
+	var container *linux_container.LinuxContainer
+	var containerDir string
+	var criuPath string
+	var events []linux_backend.Event
+	var changes int
+
+	BeforeEach(func() {
+		var err error
+
+		cgroupsPath, err = ioutil.TempDir("", "cgroups")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = os.MkdirAll(filepath.Join(cgroupsPath, "freezer", "instance-some-id"), 0755)
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeRunner = fake_command_runner.New()
+		fakeCgroups = fake_cgroups_manager.New(cgroupsPath, "some-id")
+		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+
+		containerDir, err = ioutil.TempDir("", "depot")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(containerDir, "run"), 0755)
+		Expect(err).ToNot(HaveOccurred())
+		err = ioutil.WriteFile(filepath.Join(containerDir, "run", "wshd.pid"), []byte("12345\n"), 0644)
+		Expect(err).ToNot(HaveOccurred())
+
+		criuPath = "/path/to/criu"
+
+		events = []linux_backend.Event{}
+		changes = 0
+	})
+
+	JustBeforeEach(func() {
+		_, subnet, _ := net.ParseCIDR("2.3.4.0/30")
+
+		container = linux_container.NewLinuxContainer(
+			lagertest.NewTestLogger("test"),
+			"some-id",
+			"some-handle",
+			containerDir,
+			nil,
+			1*time.Second,
+			linux_backend.NewResources(
+				1234,
+				1235,
+				&linux_backend.Network{
+					IP:     net.ParseIP("1.2.3.4"),
+					Subnet: subnet,
+				},
+				"some-bridge",
+				[]uint32{},
+				nil,
+			),
+			fake_port_pool.New(1000),
+			fakeRunner,
+			fakeCgroups,
+			fake_quota_manager.New(),
+			fake_bandwidth_manager.New(),
+			fakeProcessTracker,
+			process.Env{},
+			new(networkFakes.FakeFilter),
+			criuPath,
+		)
+
+		container.OnEvent(func(event linux_backend.Event) {
+			events = append(events, event)
+		})
+
+		container.OnChange(func() {
+			changes++
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cgroupsPath)
+		os.RemoveAll(containerDir)
+	})
+
+	freezerStates := func() []string {
+		states := []string{}
+		for _, value := range fakeCgroups.SetValues() {
+			if value.Subsystem == "freezer" && value.Name == "freezer.state" {
+				states = append(states, value.Value)
+			}
+		}
+
+		return states
+	}
+
+	Describe("Checkpointing", func() {
+		Context("when the container is active", func() {
+			BeforeEach(func() {
+				process1 := new(wfakes.FakeProcess)
+				process1.IDReturns(1)
+
+				process2 := new(wfakes.FakeProcess)
+				process2.IDReturns(2)
+
+				fakeProcessTracker.ActiveProcessesReturns([]garden.Process{process1, process2})
+
+				err := os.MkdirAll(filepath.Join(containerDir, "processes"), 0755)
+				Expect(err).ToNot(HaveOccurred())
+
+				for file, contents := range map[string]string{
+					"1.pid":   "42\n",
+					"1.pipes": "11 12 13 14\n",
+					"2.pid":   "43\n",
+					"2.pipes": "21 22 23 24\n",
+				} {
+					err := ioutil.WriteFile(filepath.Join(containerDir, "processes", file), []byte(contents), 0600)
+					Expect(err).ToNot(HaveOccurred())
+				}
+			})
+
+			checkpointedProcesses := []linux_container.ProcessSnapshot{
+				{
+					ID: 1,
+					Checkpoint: &linux_container.CheckpointedProcess{
+						Pid:   42,
+						Pipes: linux_container.PipeInodes{Stdin: 11, Stdout: 12, Stderr: 13, ExitStatus: 14},
+					},
+				},
+				{
+					ID: 2,
+					Checkpoint: &linux_container.CheckpointedProcess{
+						Pid:   43,
+						Pipes: linux_container.PipeInodes{Stdin: 21, Stdout: 22, Stderr: 23, ExitStatus: 24},
+					},
+				},
+			}
+
+			snapshotProcesses := func() []linux_container.ProcessSnapshot {
+				out := new(bytes.Buffer)
+				Expect(container.Snapshot(out)).To(Succeed())
+
+				snapshot, err := linux_container.DecodeSnapshot(out)
+				Expect(err).ToNot(HaveOccurred())
+
+				return snapshot.Processes
+			}
+
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+			})
+
+			It("runs checkpoint.sh with the container frozen", func() {
+				statesWhenRun := []string{}
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: containerDir + "/checkpoint.sh",
+				}, func(*exec.Cmd) error {
+					statesWhenRun = freezerStates()
+					return nil
+				})
+
+				err := container.Checkpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/checkpoint.sh",
+						Env: []string{
+							"id=some-id",
+							"criu=/path/to/criu",
+							"PATH=" + os.Getenv("PATH"),
+						},
+					},
+				))
+
+				Expect(statesWhenRun).To(Equal([]string{"FROZEN"}))
+			})
+
+			It("sets the container's state to checkpointed, and says so", func() {
+				err := container.Checkpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.State()).To(Equal(linux_container.StateCheckpointed))
+				Expect(changes).To(Equal(1))
+				Expect(events[len(events)-1].Type).To(Equal(linux_backend.EventCheckpointed))
+			})
+
+			It("records the processes, with their pids and pipes, in the container's snapshot", func() {
+				err := container.Checkpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				out := new(bytes.Buffer)
+				Expect(container.Snapshot(out)).To(Succeed())
+
+				snapshot, err := linux_container.DecodeSnapshot(out)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(snapshot.State).To(Equal("checkpointed"))
+				Expect(snapshot.Processes).To(Equal(checkpointedProcesses))
+			})
+
+			It("keeps the processes in snapshots taken after the dump kills them", func() {
+				var processesWhenDumped []linux_container.ProcessSnapshot
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: containerDir + "/checkpoint.sh",
+				}, func(*exec.Cmd) error {
+					fakeProcessTracker.ActiveProcessesReturns(nil)
+					processesWhenDumped = snapshotProcesses()
+					return nil
+				})
+
+				err := container.Checkpoint()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(processesWhenDumped).To(Equal(checkpointedProcesses))
+			})
+
+			Context("when a process has exited", func() {
+				BeforeEach(func() {
+					err := os.Remove(filepath.Join(containerDir, "processes", "2.pid"))
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("leaves it out", func() {
+					err := container.Checkpoint()
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(snapshotProcesses()).To(Equal(checkpointedProcesses[:1]))
+				})
+			})
+
+			Context("when a process's pipes were not saved, as it has a tty", func() {
+				BeforeEach(func() {
+					err := os.Remove(filepath.Join(containerDir, "processes", "2.pipes"))
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("returns an error without freezing the container or running checkpoint.sh", func() {
+					err := container.Checkpoint()
+					Expect(err).To(MatchError(HavePrefix("container: checkpoint: process 2 cannot be re-attached to: ")))
+
+					Expect(freezerStates()).To(BeEmpty())
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/checkpoint.sh",
+						},
+					))
+
+					Expect(container.State()).To(Equal(linux_container.StateActive))
+				})
+			})
+
+			Context("when checkpoint.sh fails", func() {
+				BeforeEach(func() {
+					fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+						Path: containerDir + "/checkpoint.sh",
+					}, func(*exec.Cmd) error {
+						return errors.New("oh no!")
+					})
+				})
+
+				It("returns the error, and thaws the container", func() {
+					err := container.Checkpoint()
+					Expect(err).To(MatchError("container: checkpoint: oh no!"))
+
+					Expect(freezerStates()).To(Equal([]string{"FROZEN", "THAWED"}))
+					Expect(container.State()).To(Equal(linux_container.StateActive))
+				})
+
+				It("snapshots the processes as they are again", func() {
+					err := container.Checkpoint()
+					Expect(err).To(HaveOccurred())
+
+					Expect(snapshotProcesses()).To(Equal([]linux_container.ProcessSnapshot{
+						{ID: 1},
+						{ID: 2},
+					}))
+				})
+			})
+
+			Context("when checkpointing is disabled", func() {
+				BeforeEach(func() {
+					criuPath = ""
+				})
+
+				It("returns ErrCheckpointingDisabled", func() {
+					err := container.Checkpoint()
+					Expect(err).To(Equal(linux_container.ErrCheckpointingDisabled))
+
+					Expect(fakeRunner).ToNot(HaveExecutedSerially(
+						fake_command_runner.CommandSpec{
+							Path: containerDir + "/checkpoint.sh",
+						},
+					))
+				})
+			})
+		})
+
+		Context("when the container is not active", func() {
+			It("returns an error", func() {
+				err := container.Checkpoint()
+				Expect(err).To(Equal(linux_container.InvalidStateError{
+					Operation: "checkpoint",
+					State:     linux_container.StateBorn,
+				}))
+			})
+		})
+	})
+
+	Describe("Running processes", func() {
+		BeforeEach(func() {
+			process := new(wfakes.FakeProcess)
+			process.WaitStub = func() (int, error) {
+				// still running
+				select {}
+			}
+
+			fakeProcessTracker.RunReturns(process, nil)
+		})
+
+		It("has wsh save the process's pipes, for it to be re-attached to once restored", func() {
+			_, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+			Expect(err).ToNot(HaveOccurred())
+
+			_, cmd, _, _, _ := fakeProcessTracker.RunArgsForCall(0)
+			Expect(cmd.Args).To(ContainElement("--pipesfile"))
+			Expect(cmd.Args).To(ContainElement(containerDir + "/processes/1.pipes"))
+		})
+
+		Context("with a tty", func() {
+			It("does not, as CRIU cannot dump it", func() {
+				_, err := container.Run(garden.ProcessSpec{
+					Path: "/some/script",
+					TTY:  &garden.TTYSpec{},
+				}, garden.ProcessIO{})
+				Expect(err).ToNot(HaveOccurred())
+
+				_, cmd, _, _, _ := fakeProcessTracker.RunArgsForCall(0)
+				Expect(cmd.Args).ToNot(ContainElement("--pipesfile"))
+			})
+		})
+
+		Context("when checkpointing is disabled", func() {
+			BeforeEach(func() {
+				criuPath = ""
+			})
+
+			It("does not", func() {
+				_, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+				Expect(err).ToNot(HaveOccurred())
+
+				_, cmd, _, _, _ := fakeProcessTracker.RunArgsForCall(0)
+				Expect(cmd.Args).ToNot(ContainElement("--pipesfile"))
+			})
+		})
+	})
+
+	Describe("Restoring a checkpointed container", func() {
+		snapshot := linux_container.ContainerSnapshot{
+			State: "checkpointed",
+		}
+
+		BeforeEach(func() {
+			err := os.MkdirAll(filepath.Join(containerDir, "checkpoint"), 0755)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("runs restore.sh before setting up the network", func() {
+			err := container.Restore(snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/restore.sh",
+					Env: []string{
+						"id=some-id",
+						"criu=/path/to/criu",
+						"PATH=" + os.Getenv("PATH"),
+						"inherit_fds=",
+					},
+				},
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/net.sh",
+					Args: []string{"setup"},
+				},
+			))
+		})
+
+		It("sets the container's state to active", func() {
+			err := container.Restore(snapshot)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.State()).To(Equal(linux_container.StateActive))
+		})
+
+		Context("with checkpointed processes", func() {
+			var restoreCmd *exec.Cmd
+			var restoredInodes []uint64
+			var restored *wfakes.FakeProcess
+
+			snapshot := linux_container.ContainerSnapshot{
+				State: "checkpointed",
+				Processes: []linux_container.ProcessSnapshot{
+					{
+						ID: 1,
+						Checkpoint: &linux_container.CheckpointedProcess{
+							Pid:   42,
+							Pipes: linux_container.PipeInodes{Stdin: 11, Stdout: 12, Stderr: 13, ExitStatus: 14},
+						},
+					},
+					{
+						ID: 2,
+					},
+				},
+			}
+
+			BeforeEach(func() {
+				restoreCmd = nil
+				restoredInodes = nil
+
+				restored = new(wfakes.FakeProcess)
+				restored.WaitStub = func() (int, error) {
+					// still running
+					select {}
+				}
+
+				fakeProcessTracker.ReattachReturns(restored, nil)
+				fakeProcessTracker.RestoreReturns(restored)
+
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: containerDir + "/restore.sh",
+				}, func(cmd *exec.Cmd) error {
+					restoreCmd = cmd
+
+					for _, f := range cmd.ExtraFiles {
+						info, err := f.Stat()
+						Expect(err).ToNot(HaveOccurred())
+
+						restoredInodes = append(restoredInodes, info.Sys().(*syscall.Stat_t).Ino)
+					}
+
+					// as the restored process would
+					_, err := cmd.ExtraFiles[1].Write([]byte("hello"))
+					Expect(err).ToNot(HaveOccurred())
+
+					return nil
+				})
+			})
+
+			It("hands restore.sh new pipes in place of the old ones", func() {
+				err := container.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(restoreCmd.ExtraFiles).To(HaveLen(4))
+				Expect(restoreCmd.Env).To(ContainElement(
+					"inherit_fds=" +
+						"--inherit-fd fd[3]:pipe:[11] " +
+						"--inherit-fd fd[4]:pipe:[12] " +
+						"--inherit-fd fd[5]:pipe:[13] " +
+						"--inherit-fd fd[6]:pipe:[14]",
+				))
+			})
+
+			It("re-attaches to the checkpointed processes through the host's ends of the pipes", func() {
+				var stdout []byte
+				fakeProcessTracker.ReattachStub = func(processID uint32, pipes process_tracker.ProcessPipes, signaller process_tracker.Signaller) (garden.Process, error) {
+					stdout = make([]byte, 5)
+					_, err := io.ReadFull(pipes.Stdout, stdout)
+					return restored, err
+				}
+
+				err := container.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeProcessTracker.ReattachCallCount()).To(Equal(1))
+
+				id, _, signaller := fakeProcessTracker.ReattachArgsForCall(0)
+				Expect(id).To(Equal(uint32(1)))
+				Expect(signaller).To(Equal(&linux_backend.NamespacedSignaller{
+					Runner:        fakeRunner,
+					ContainerPath: containerDir,
+					PidFilePath:   containerDir + "/processes/1.pid",
+				}))
+
+				Expect(string(stdout)).To(Equal("hello"))
+
+				Expect(fakeProcessTracker.RestoreCallCount()).To(Equal(1))
+				id, _ = fakeProcessTracker.RestoreArgsForCall(0)
+				Expect(id).To(Equal(uint32(2)))
+			})
+
+			It("saves the restored processes' pids and new pipes for them to be signalled and checkpointed again", func() {
+				err := container.Restore(snapshot)
+				Expect(err).ToNot(HaveOccurred())
+
+				pid, err := ioutil.ReadFile(filepath.Join(containerDir, "processes", "1.pid"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(pid)).To(Equal("42\n"))
+
+				pipes, err := ioutil.ReadFile(filepath.Join(containerDir, "processes", "1.pipes"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(pipes)).To(Equal(fmt.Sprintf(
+					"%d %d %d %d\n",
+					restoredInodes[0], restoredInodes[1], restoredInodes[2], restoredInodes[3],
+				)))
+			})
+
+			Context("when re-attaching fails", func() {
+				BeforeEach(func() {
+					fakeProcessTracker.ReattachReturns(nil, errors.New("oh no!"))
+				})
+
+				It("returns the error", func() {
+					err := container.Restore(snapshot)
+					Expect(err).To(MatchError("oh no!"))
+				})
+			})
+		})
+
+		Context("when restore.sh fails", func() {
+			BeforeEach(func() {
+				fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+					Path: containerDir + "/restore.sh",
+				}, func(*exec.Cmd) error {
+					return errors.New("oh no!")
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.Restore(snapshot)
+				Expect(err).To(MatchError("container: restore checkpoint: oh no!"))
+			})
+		})
+
+		Context("when the checkpoint is missing", func() {
+			BeforeEach(func() {
+				Expect(os.RemoveAll(filepath.Join(containerDir, "checkpoint"))).To(Succeed())
+			})
+
+			It("returns an error without running restore.sh", func() {
+				err := container.Restore(snapshot)
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeRunner).ToNot(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/restore.sh",
+					},
+				))
+			})
+		})
+
+		Context("when checkpointing is disabled", func() {
+			BeforeEach(func() {
+				criuPath = ""
+			})
+
+			It("returns ErrCheckpointingDisabled", func() {
+				err := container.Restore(snapshot)
+				Expect(err).To(Equal(linux_container.ErrCheckpointingDisabled))
+			})
+		})
+	})
+})
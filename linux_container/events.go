@@ -0,0 +1,105 @@
+package linux_container
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/ring"
+)
+
+// EventHistorySize is the number of its most recent events that a container
+// keeps, and saves in its snapshot.
+const EventHistorySize = 100
+
+type EventKind string
+
+const (
+	// Details: memory_usage_in_bytes, memory_max_usage_in_bytes and
+	// memory_limit_in_bytes, as far as they could be read
+	EventKindOutOfMemory = EventKind("out-of-memory")
+
+	// Details: limit, one of memory, cpu, disk or bandwidth
+	EventKindLimitDrifted = EventKind("limit-drifted")
+
+	// Details: process_ids, comma-separated, of the processes that were still
+	// running after the stop grace time
+	EventKindForceKilled = EventKind("force-killed")
+
+	// Details: message; events recorded as plain strings by versions that
+	// predate typed events are restored as these
+	EventKindMessage = EventKind("message")
+)
+
+// ContainerEvent is something notable that happened to a container.
+type ContainerEvent struct {
+	Kind    EventKind
+	Time    time.Time
+	Details map[string]string `json:",omitempty"`
+}
+
+// String renders the event as garden reports it in the container's info,
+// e.g. "out of memory".
+func (e ContainerEvent) String() string {
+	switch e.Kind {
+	case EventKindOutOfMemory:
+		return "out of memory"
+	case EventKindLimitDrifted:
+		return fmt.Sprintf("%s limit drifted; re-applied", e.Details["limit"])
+	case EventKindForceKilled:
+		return fmt.Sprintf("force-killed processes %s on stop", e.Details["process_ids"])
+	case EventKindMessage:
+		return e.Details["message"]
+	default:
+		return string(e.Kind)
+	}
+}
+
+const limitDriftedSuffix = " limit drifted; re-applied"
+
+// parseLegacyEvent converts an event recorded as a string by an older
+// version. The time it happened at was not recorded.
+func parseLegacyEvent(event string) ContainerEvent {
+	switch {
+	case event == "out of memory":
+		return ContainerEvent{Kind: EventKindOutOfMemory}
+	case strings.HasSuffix(event, limitDriftedSuffix):
+		return ContainerEvent{
+			Kind:    EventKindLimitDrifted,
+			Details: map[string]string{"limit": strings.TrimSuffix(event, limitDriftedSuffix)},
+		}
+	default:
+		return ContainerEvent{
+			Kind:    EventKindMessage,
+			Details: map[string]string{"message": event},
+		}
+	}
+}
+
+// eventHistory holds the most recent events, overwriting the oldest once it
+// is full.
+type eventHistory struct {
+	events *ring.Ring
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{
+		events: ring.New(size),
+	}
+}
+
+func (h *eventHistory) push(event ContainerEvent) {
+	h.events.Push(event)
+}
+
+// all returns the events, oldest first.
+func (h *eventHistory) all() []ContainerEvent {
+	items := h.events.Items()
+
+	events := make([]ContainerEvent, len(items))
+	for i, item := range items {
+		events[i] = item.(ContainerEvent)
+	}
+
+	return events
+}
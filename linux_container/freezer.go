@@ -0,0 +1,212 @@
+package linux_container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/logging"
+	"github.com/pivotal-golang/lager"
+)
+
+const (
+	freezerStateFrozen = "FROZEN"
+	freezerStateThawed = "THAWED"
+)
+
+// freezing may be held up by tasks in uninterruptible sleep, so the request is
+// repeated until it sticks
+const (
+	freezeAttempts      = 100
+	freezeRetryInterval = 10 * time.Millisecond
+)
+
+// InvalidStateError is returned when an operation does not apply to the
+// container in its current state.
+type InvalidStateError struct {
+	Operation string
+	State     State
+}
+
+func (e InvalidStateError) Error() string {
+	return fmt.Sprintf("cannot %s a container that is %s", e.Operation, e.State)
+}
+
+// Pause freezes every process in the container until Resume is called.
+func (c *LinuxContainer) Pause() error {
+	cLog := c.logger.Session("pause")
+
+	if state := c.State(); state != StateActive {
+		return InvalidStateError{"pause", state}
+	}
+
+	err := c.freeze()
+	if err != nil {
+		cLog.Error("failed-to-freeze", err)
+		return fmt.Errorf("container: pause: %v", err)
+	}
+
+	c.setState(StatePaused)
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventPaused})
+
+	cLog.Info("paused")
+
+	return nil
+}
+
+func (c *LinuxContainer) Resume() error {
+	cLog := c.logger.Session("resume")
+
+	if state := c.State(); state != StatePaused {
+		return InvalidStateError{"resume", state}
+	}
+
+	err := c.thaw()
+	if err != nil {
+		cLog.Error("failed-to-thaw", err)
+		return fmt.Errorf("container: resume: %v", err)
+	}
+
+	c.setState(StateActive)
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventResumed})
+
+	cLog.Info("resumed")
+
+	return nil
+}
+
+// checkNotPaused refuses operations that go through wshd, which is frozen
+// with the rest of a paused container, and would block until it is resumed.
+func (c *LinuxContainer) checkNotPaused(operation string) error {
+	if state := c.State(); state == StatePaused {
+		return InvalidStateError{operation, state}
+	}
+
+	return nil
+}
+
+// KillProcesses kills every process in the container other than wshd, which
+// is left to destroy.sh.
+func (c *LinuxContainer) KillProcesses() error {
+	cLog := c.logger.Session("kill-processes")
+
+	wshdPID, err := c.wshdPID()
+	if err != nil {
+		if os.IsNotExist(err) {
+			// never started, so nothing is running
+			return nil
+		}
+
+		return fmt.Errorf("container: kill processes: %v", err)
+	}
+
+	err = c.killProcesses(cLog, wshdPID)
+	if err != nil {
+		cLog.Error("failed", err)
+		return fmt.Errorf("container: kill processes: %v", err)
+	}
+
+	return nil
+}
+
+// killProcesses kills every task in the container's cgroup other than wshd.
+// The cgroup is frozen meanwhile, so that no task can fork out from under
+// it; containers created before the freezer cgroup was set up for them fall
+// back to killing the tasks in their cpu cgroup.
+func (c *LinuxContainer) killProcesses(cLog lager.Logger, wshdPID int) error {
+	if !c.hasFreezer() {
+		return c.killTasks(cLog, "cpu", wshdPID)
+	}
+
+	err := c.freeze()
+	if err != nil {
+		// thaw whatever was frozen
+		c.thaw()
+		return err
+	}
+
+	err = c.killTasks(cLog, "freezer", wshdPID)
+
+	// the tasks only die once thawed; a paused container's are thawed too,
+	// wshd with them
+	if thawErr := c.thaw(); err == nil {
+		err = thawErr
+	}
+
+	return err
+}
+
+func (c *LinuxContainer) killTasks(cLog lager.Logger, subsystem string, wshdPID int) error {
+	tasks, err := c.cgroupsManager.Get(subsystem, "tasks")
+	if err != nil {
+		return err
+	}
+
+	pids := []string{}
+	for _, task := range strings.Fields(tasks) {
+		if task != strconv.Itoa(wshdPID) {
+			pids = append(pids, task)
+		}
+	}
+
+	if len(pids) == 0 {
+		return nil
+	}
+
+	cRunner := logging.Runner{
+		CommandRunner: c.runner,
+		Logger:        cLog,
+	}
+
+	// tasks may exit before they are killed, which fails the command without
+	// sparing the others
+	err = cRunner.Run(exec.Command("kill", append([]string{"-9"}, pids...)...))
+	if err != nil {
+		cLog.Error("failed-to-kill-some-tasks", err, lager.Data{"tasks": pids})
+	}
+
+	return nil
+}
+
+func (c *LinuxContainer) hasFreezer() bool {
+	_, err := os.Stat(c.cgroupsManager.SubsystemPath("freezer"))
+	return err == nil
+}
+
+func (c *LinuxContainer) freeze() error {
+	var state string
+
+	for i := 0; i < freezeAttempts; i++ {
+		err := c.cgroupsManager.Set("freezer", "freezer.state", freezerStateFrozen)
+		if err != nil {
+			return err
+		}
+
+		state, err = c.cgroupsManager.Get("freezer", "freezer.state")
+		if err != nil {
+			return err
+		}
+
+		if state == freezerStateFrozen {
+			return nil
+		}
+
+		time.Sleep(freezeRetryInterval)
+	}
+
+	return fmt.Errorf("freezer state is still %s", state)
+}
+
+func (c *LinuxContainer) thaw() error {
+	return c.cgroupsManager.Set("freezer", "freezer.state", freezerStateThawed)
+}
@@ -0,0 +1,393 @@
+package linux_container_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+	networkFakes "github.com/cloudfoundry-incubator/garden-linux/network/fakes"
+	"github.com/cloudfoundry-incubator/garden-linux/old/bandwidth_manager/fake_bandwidth_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager/fake_cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/port_pool/fake_port_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager/fake_quota_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/process"
+	"github.com/cloudfoundry-incubator/garden-linux/process_tracker/fake_process_tracker"
+	wfakes "github.com/cloudfoundry-incubator/garden/fakes"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+)
+
+var _ = Describe("Freezing containers", func() {
+	var cgroupsPath string
+	var fakeCgroups *fake_cgroups_manager.FakeCgroupsManager
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+	var fakeProcessTracker *fake_process_tracker.FakeProcessTracker
+	var container *linux_container.LinuxContainer
+	var containerDir string
+	var events []linux_backend.Event
+	var changes int
+
+	BeforeEach(func() {
+		var err error
+
+		cgroupsPath, err = ioutil.TempDir("", "cgroups")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = os.MkdirAll(filepath.Join(cgroupsPath, "freezer", "instance-some-id"), 0755)
+		Expect(err).ToNot(HaveOccurred())
+
+		fakeRunner = fake_command_runner.New()
+		fakeCgroups = fake_cgroups_manager.New(cgroupsPath, "some-id")
+		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+
+		fakeCgroups.WhenGetting("freezer", "tasks", func() (string, error) {
+			return "12345\n222\n333\n", nil
+		})
+
+		containerDir, err = ioutil.TempDir("", "depot")
+		Expect(err).ToNot(HaveOccurred())
+
+		err = os.Mkdir(filepath.Join(containerDir, "run"), 0755)
+		Expect(err).ToNot(HaveOccurred())
+		err = ioutil.WriteFile(filepath.Join(containerDir, "run", "wshd.pid"), []byte("12345\n"), 0644)
+		Expect(err).ToNot(HaveOccurred())
+
+		events = []linux_backend.Event{}
+		changes = 0
+	})
+
+	JustBeforeEach(func() {
+		_, subnet, _ := net.ParseCIDR("2.3.4.0/30")
+
+		container = linux_container.NewLinuxContainer(
+			lagertest.NewTestLogger("test"),
+			"some-id",
+			"some-handle",
+			containerDir,
+			nil,
+			1*time.Second,
+			linux_backend.NewResources(
+				1234,
+				1235,
+				&linux_backend.Network{
+					IP:     net.ParseIP("1.2.3.4"),
+					Subnet: subnet,
+				},
+				"some-bridge",
+				[]uint32{},
+				nil,
+			),
+			fake_port_pool.New(1000),
+			fakeRunner,
+			fakeCgroups,
+			fake_quota_manager.New(),
+			fake_bandwidth_manager.New(),
+			fakeProcessTracker,
+			process.Env{},
+			new(networkFakes.FakeFilter),
+			"",
+		)
+
+		container.OnEvent(func(event linux_backend.Event) {
+			events = append(events, event)
+		})
+
+		container.OnChange(func() {
+			changes++
+		})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cgroupsPath)
+		os.RemoveAll(containerDir)
+	})
+
+	freezerStates := func() []string {
+		states := []string{}
+		for _, value := range fakeCgroups.SetValues() {
+			if value.Subsystem == "freezer" && value.Name == "freezer.state" {
+				states = append(states, value.Value)
+			}
+		}
+
+		return states
+	}
+
+	Describe("Pausing", func() {
+		Context("when the container is active", func() {
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+			})
+
+			It("freezes the container's cgroup", func() {
+				err := container.Pause()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(freezerStates()).To(Equal([]string{"FROZEN"}))
+			})
+
+			It("sets the container's state to paused, and says so", func() {
+				err := container.Pause()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.State()).To(Equal(linux_container.StatePaused))
+				Expect(changes).To(Equal(1))
+
+				Expect(events).To(HaveLen(2))
+				Expect(events[1].Type).To(Equal(linux_backend.EventPaused))
+			})
+
+			Context("when freezing fails", func() {
+				BeforeEach(func() {
+					fakeCgroups.SetError = errors.New("oh no!")
+				})
+
+				It("returns the error, and leaves the container active", func() {
+					err := container.Pause()
+					Expect(err).To(MatchError("container: pause: oh no!"))
+
+					Expect(container.State()).To(Equal(linux_container.StateActive))
+				})
+			})
+
+			Context("when the cgroup does not freeze", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenGetting("freezer", "freezer.state", func() (string, error) {
+						return "FREEZING", nil
+					})
+				})
+
+				It("gives up and returns an error", func() {
+					err := container.Pause()
+					Expect(err).To(MatchError("container: pause: freezer state is still FREEZING"))
+
+					Expect(container.State()).To(Equal(linux_container.StateActive))
+				})
+			})
+		})
+
+		Context("when the container is not active", func() {
+			It("returns an error", func() {
+				err := container.Pause()
+				Expect(err).To(Equal(linux_container.InvalidStateError{
+					Operation: "pause",
+					State:     linux_container.StateBorn,
+				}))
+
+				Expect(freezerStates()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Operating on a paused container", func() {
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+			Expect(container.Pause()).To(Succeed())
+		})
+
+		It("refuses to run processes in it", func() {
+			_, err := container.Run(garden.ProcessSpec{Path: "ls"}, garden.ProcessIO{})
+			Expect(err).To(Equal(linux_container.InvalidStateError{
+				Operation: "run processes in",
+				State:     linux_container.StatePaused,
+			}))
+
+			Expect(fakeProcessTracker.RunCallCount()).To(BeZero())
+		})
+
+		It("refuses to stream into it", func() {
+			err := container.StreamIn("/some/path", nil)
+			Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+		})
+
+		It("refuses to stream out of it", func() {
+			_, err := container.StreamOut("/some/path")
+			Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+		})
+
+		It("refuses to map ports into it", func() {
+			_, _, err := container.NetIn(0, 0)
+			Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidStateError{}))
+		})
+	})
+
+	Describe("Resuming", func() {
+		Context("when the container is paused", func() {
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+				Expect(container.Pause()).To(Succeed())
+			})
+
+			It("thaws the container's cgroup and sets its state to active", func() {
+				err := container.Resume()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(freezerStates()).To(Equal([]string{"FROZEN", "THAWED"}))
+
+				Expect(container.State()).To(Equal(linux_container.StateActive))
+				Expect(changes).To(Equal(2))
+				Expect(events[len(events)-1].Type).To(Equal(linux_backend.EventResumed))
+			})
+		})
+
+		Context("when the container is not paused", func() {
+			It("returns an error", func() {
+				err := container.Resume()
+				Expect(err).To(Equal(linux_container.InvalidStateError{
+					Operation: "resume",
+					State:     linux_container.StateBorn,
+				}))
+			})
+		})
+	})
+
+	Describe("Stopping a paused container", func() {
+		var process *wfakes.FakeProcess
+		var statesWhenSignalled []string
+
+		BeforeEach(func() {
+			statesWhenSignalled = nil
+
+			process = new(wfakes.FakeProcess)
+			process.IDReturns(1)
+			process.SignalStub = func(garden.Signal) error {
+				statesWhenSignalled = freezerStates()
+				return nil
+			}
+
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{process})
+		})
+
+		JustBeforeEach(func() {
+			Expect(container.Start()).To(Succeed())
+			Expect(container.Pause()).To(Succeed())
+		})
+
+		It("thaws it before terminating its processes", func() {
+			err := container.Stop(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(process.SignalCallCount()).To(Equal(1))
+			Expect(process.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+			Expect(statesWhenSignalled).To(Equal([]string{"FROZEN", "THAWED"}))
+
+			Expect(container.State()).To(Equal(linux_container.StateStopped))
+			Expect(events[len(events)-1].ForceKilledProcessIDs).To(BeEmpty())
+		})
+
+		Context("when it cannot be thawed", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("freezer", "freezer.state", func() (string, error) {
+					return "FROZEN", nil
+				})
+
+				sets := 0
+				fakeCgroups.WhenSetting("freezer", "freezer.state", func() error {
+					sets++
+					if sets > 1 {
+						return errors.New("oh no!")
+					}
+
+					return nil
+				})
+			})
+
+			It("does not signal the processes through the frozen wshd", func() {
+				container.Stop(false)
+
+				Expect(process.SignalCallCount()).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Killing processes", func() {
+		It("kills every task in the frozen cgroup but wshd, and thaws it", func() {
+			statesWhenKilled := []string{}
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "kill",
+			}, func(*exec.Cmd) error {
+				statesWhenKilled = freezerStates()
+				return nil
+			})
+
+			err := container.KillProcesses()
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "kill",
+					Args: []string{"-9", "222", "333"},
+				},
+			))
+
+			Expect(statesWhenKilled).To(Equal([]string{"FROZEN"}))
+			Expect(freezerStates()).To(Equal([]string{"FROZEN", "THAWED"}))
+		})
+
+		Context("when the container was never started", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(containerDir, "run", "wshd.pid"))).To(Succeed())
+			})
+
+			It("does nothing", func() {
+				err := container.KillProcesses()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner.ExecutedCommands()).To(BeEmpty())
+				Expect(freezerStates()).To(BeEmpty())
+			})
+		})
+
+		Context("when the container has no freezer cgroup", func() {
+			BeforeEach(func() {
+				Expect(os.RemoveAll(filepath.Join(cgroupsPath, "freezer"))).To(Succeed())
+
+				fakeCgroups.WhenGetting("cpu", "tasks", func() (string, error) {
+					return "12345\n444\n", nil
+				})
+			})
+
+			It("kills the tasks in its cpu cgroup without freezing it", func() {
+				err := container.KillProcesses()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: "kill",
+						Args: []string{"-9", "444"},
+					},
+				))
+
+				Expect(freezerStates()).To(BeEmpty())
+			})
+		})
+
+		Context("when the container is paused", func() {
+			JustBeforeEach(func() {
+				Expect(container.Start()).To(Succeed())
+				Expect(container.Pause()).To(Succeed())
+			})
+
+			It("leaves it thawed", func() {
+				err := container.Stop(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				states := freezerStates()
+				Expect(states[len(states)-1]).To(Equal("THAWED"))
+
+				Expect(container.State()).To(Equal(linux_container.StateStopped))
+			})
+		})
+	})
+})
@@ -2,11 +2,16 @@ package linux_container
 
 import (
 	"fmt"
+	"math"
+	"os"
 	"os/exec"
 	"path"
 	"strconv"
 
 	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/old/quota_manager"
+	"github.com/pivotal-golang/lager"
 )
 
 func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
@@ -18,9 +23,12 @@ func (c *LinuxContainer) LimitBandwidth(limits garden.BandwidthLimits) error {
 	}
 
 	c.bandwidthMutex.Lock()
-	defer c.bandwidthMutex.Unlock()
-
 	c.currentBandwidthLimits = &limits
+	c.bandwidthMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventLimitChanged, Limit: "bandwidth"})
 
 	return nil
 }
@@ -37,6 +45,14 @@ func (c *LinuxContainer) CurrentBandwidthLimits() (garden.BandwidthLimits, error
 }
 
 func (c *LinuxContainer) LimitDisk(limits garden.DiskLimits) error {
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.DiskInBytes = diskLimitInBytes(limits)
+	}, func() error {
+		return c.limitDisk(limits)
+	})
+}
+
+func (c *LinuxContainer) limitDisk(limits garden.DiskLimits) error {
 	cLog := c.logger.Session("limit-disk")
 
 	err := c.quotaManager.SetLimits(cLog, c.resources.UserUID, limits)
@@ -45,9 +61,12 @@ func (c *LinuxContainer) LimitDisk(limits garden.DiskLimits) error {
 	}
 
 	c.diskMutex.Lock()
-	defer c.diskMutex.Unlock()
-
 	c.currentDiskLimits = &limits
+	c.diskMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventLimitChanged, Limit: "disk"})
 
 	return nil
 }
@@ -57,51 +76,228 @@ func (c *LinuxContainer) CurrentDiskLimits() (garden.DiskLimits, error) {
 	return c.quotaManager.GetLimits(cLog, c.resources.UserUID)
 }
 
+// LimitMemory holds the container to the hard limit given, keeping the
+// other memory limits as they were last set.
 func (c *LinuxContainer) LimitMemory(limits garden.MemoryLimits) error {
+	var memoryLimits linux_backend.MemoryLimits
+
+	c.memoryMutex.RLock()
+	if c.currentMemoryLimits != nil {
+		memoryLimits = *c.currentMemoryLimits
+	}
+	c.memoryMutex.RUnlock()
+
+	memoryLimits.LimitInBytes = limits.LimitInBytes
+
+	return c.SetMemoryLimits(memoryLimits)
+}
+
+func (c *LinuxContainer) SetMemoryLimits(limits linux_backend.MemoryLimits) error {
+	err := limits.Validate()
+	if err != nil {
+		return err
+	}
+
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.MemoryInBytes = limits.LimitInBytes
+	}, func() error {
+		return c.limitMemory(limits)
+	})
+}
+
+func (c *LinuxContainer) limitMemory(limits linux_backend.MemoryLimits) error {
 	err := c.startOomNotifier()
 	if err != nil {
 		return err
 	}
 
-	limit := fmt.Sprintf("%d", limits.LimitInBytes)
+	err = c.setMemoryLimit(limits)
+	if err != nil {
+		return err
+	}
 
-	// memory.memsw.limit_in_bytes must be >= memory.limit_in_bytes
-	//
-	// however, it must be set after memory.limit_in_bytes, and if we're
-	// increasing the limit, writing memory.limit_in_bytes first will fail.
-	//
-	// so, write memory.limit_in_bytes before and after
-	c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
-	c.cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", limit)
+	softLimit := "-1"
+	if limits.SoftLimitInBytes != 0 {
+		softLimit = fmt.Sprintf("%d", limits.SoftLimitInBytes)
+	}
 
-	err = c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
+	err = c.cgroupsManager.Set("memory", "memory.soft_limit_in_bytes", softLimit)
 	if err != nil {
 		return err
 	}
 
-	c.memoryMutex.Lock()
-	defer c.memoryMutex.Unlock()
+	err = c.setKernelMemoryLimit(limits.KernelLimitInBytes)
+	if err != nil {
+		return err
+	}
 
+	if limits.Swappiness != nil {
+		err = c.cgroupsManager.Set("memory", "memory.swappiness", fmt.Sprintf("%d", *limits.Swappiness))
+		if err != nil {
+			return err
+		}
+	}
+
+	c.memoryMutex.Lock()
 	c.currentMemoryLimits = &limits
+	c.memoryMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventLimitChanged, Limit: "memory"})
 
 	return nil
 }
 
+// setMemoryLimit sets memory.limit_in_bytes and, with swap accounting,
+// memory.memsw.limit_in_bytes to the limit plus the swap allowance. A limit
+// of 0 lifts both.
+func (c *LinuxContainer) setMemoryLimit(limits linux_backend.MemoryLimits) error {
+	limit := "-1"
+	memswLimit := "-1"
+	if limits.LimitInBytes != 0 {
+		limit = fmt.Sprintf("%d", limits.LimitInBytes)
+		memswLimit = fmt.Sprintf("%d", limits.LimitInBytes+limits.SwapInBytes)
+	}
+
+	_, err := c.cgroupsManager.Get("memory", "memory.memsw.limit_in_bytes")
+	if err != nil {
+		// swap accounting is disabled on the host
+		if limits.SwapInBytes != 0 {
+			return linux_backend.InvalidMemoryLimitsError{limits, "swap accounting is disabled"}
+		}
+
+		return c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
+	}
+
+	// memory.memsw.limit_in_bytes must never be below memory.limit_in_bytes,
+	// so it goes first when the limits are raised and last when they are
+	// lowered.
+	//
+	// raising is tried first; lowering the memsw limit below the current
+	// limit is refused, in which case the limit is lowered first instead
+	err = c.cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", memswLimit)
+	if err == nil {
+		return c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
+	}
+
+	err = c.cgroupsManager.Set("memory", "memory.limit_in_bytes", limit)
+	if err != nil {
+		return err
+	}
+
+	return c.cgroupsManager.Set("memory", "memory.memsw.limit_in_bytes", memswLimit)
+}
+
+// setKernelMemoryLimit only writes memory.kmem.limit_in_bytes when the limit
+// changes: older kernels refuse to start accounting kernel memory once the
+// cgroup has tasks, even to lift the limit.
+func (c *LinuxContainer) setKernelMemoryLimit(limit uint64) error {
+	current, err := c.memoryCgroupLimit("memory.kmem.limit_in_bytes")
+	if err != nil {
+		if limit == 0 {
+			// no kernel memory accounting, so nothing to lift
+			return nil
+		}
+
+		return err
+	}
+
+	if current == limit {
+		return nil
+	}
+
+	kmemLimit := "-1"
+	if limit != 0 {
+		kmemLimit = fmt.Sprintf("%d", limit)
+	}
+
+	return c.cgroupsManager.Set("memory", "memory.kmem.limit_in_bytes", kmemLimit)
+}
+
+// CurrentMemoryLimits reads the hard limit back from the memory cgroup, as
+// zero if there is none.
 func (c *LinuxContainer) CurrentMemoryLimits() (garden.MemoryLimits, error) {
-	limitInBytes, err := c.cgroupsManager.Get("memory", "memory.limit_in_bytes")
+	limitInBytes, err := c.memoryCgroupLimit("memory.limit_in_bytes")
 	if err != nil {
 		return garden.MemoryLimits{}, err
 	}
 
-	numericLimit, err := strconv.ParseUint(limitInBytes, 10, 0)
+	return garden.MemoryLimits{LimitInBytes: limitInBytes}, nil
+}
+
+// MemoryLimits reads all of the limits back from the memory cgroup. The swap
+// and kernel memory limits are left zero on hosts that do not account for
+// them.
+func (c *LinuxContainer) MemoryLimits() (linux_backend.MemoryLimits, error) {
+	limitInBytes, err := c.memoryCgroupLimit("memory.limit_in_bytes")
 	if err != nil {
-		return garden.MemoryLimits{}, err
+		return linux_backend.MemoryLimits{}, err
+	}
+
+	softLimit, err := c.memoryCgroupLimit("memory.soft_limit_in_bytes")
+	if err != nil {
+		return linux_backend.MemoryLimits{}, err
+	}
+
+	swappiness, err := c.memoryCgroupValue("memory.swappiness")
+	if err != nil {
+		return linux_backend.MemoryLimits{}, err
+	}
+
+	limits := linux_backend.MemoryLimits{
+		LimitInBytes:     limitInBytes,
+		SoftLimitInBytes: softLimit,
+		Swappiness:       &swappiness,
+	}
+
+	memswLimit, err := c.memoryCgroupLimit("memory.memsw.limit_in_bytes")
+	if err == nil && memswLimit > limitInBytes {
+		limits.SwapInBytes = memswLimit - limitInBytes
+	}
+
+	kmemLimit, err := c.memoryCgroupLimit("memory.kmem.limit_in_bytes")
+	if err == nil {
+		limits.KernelLimitInBytes = kmemLimit
 	}
 
-	return garden.MemoryLimits{uint64(numericLimit)}, nil
+	return limits, nil
+}
+
+func (c *LinuxContainer) memoryCgroupValue(name string) (uint64, error) {
+	value, err := c.cgroupsManager.Get("memory", name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// memoryCgroupLimit reads a limit from the memory cgroup, as zero if there
+// is none. The kernel reports no limit as the largest value its counters
+// hold, rounded down to a page on newer kernels.
+func (c *LinuxContainer) memoryCgroupLimit(name string) (uint64, error) {
+	limit, err := c.memoryCgroupValue(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit >= math.MaxInt64&^uint64(os.Getpagesize()-1) {
+		return 0, nil
+	}
+
+	return limit, nil
 }
 
 func (c *LinuxContainer) LimitCPU(limits garden.CPULimits) error {
+	return c.changeLimits(func(applied *linux_backend.AdmissionLimits) {
+		applied.CPUShares = limits.LimitInShares
+	}, func() error {
+		return c.limitCPU(limits)
+	})
+}
+
+func (c *LinuxContainer) limitCPU(limits garden.CPULimits) error {
 	limit := fmt.Sprintf("%d", limits.LimitInShares)
 
 	err := c.cgroupsManager.Set("cpu", "cpu.shares", limit)
@@ -110,9 +306,12 @@ func (c *LinuxContainer) LimitCPU(limits garden.CPULimits) error {
 	}
 
 	c.cpuMutex.Lock()
-	defer c.cpuMutex.Unlock()
-
 	c.currentCPULimits = &limits
+	c.cpuMutex.Unlock()
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{Type: linux_backend.EventLimitChanged, Limit: "cpu"})
 
 	return nil
 }
@@ -131,6 +330,70 @@ func (c *LinuxContainer) CurrentCPULimits() (garden.CPULimits, error) {
 	return garden.CPULimits{uint64(numericLimit)}, nil
 }
 
+// AppliedLimits returns the memory, disk and CPU limits last applied to the
+// container; 0 for those never applied.
+func (c *LinuxContainer) AppliedLimits() linux_backend.AdmissionLimits {
+	var applied linux_backend.AdmissionLimits
+
+	c.memoryMutex.RLock()
+	if c.currentMemoryLimits != nil {
+		applied.MemoryInBytes = c.currentMemoryLimits.LimitInBytes
+	}
+	c.memoryMutex.RUnlock()
+
+	c.diskMutex.RLock()
+	if c.currentDiskLimits != nil {
+		applied.DiskInBytes = diskLimitInBytes(*c.currentDiskLimits)
+	}
+	c.diskMutex.RUnlock()
+
+	c.cpuMutex.RLock()
+	if c.currentCPULimits != nil {
+		applied.CPUShares = c.currentCPULimits.LimitInShares
+	}
+	c.cpuMutex.RUnlock()
+
+	return applied
+}
+
+func (c *LinuxContainer) Guard(guard linux_backend.ChangeGuard) {
+	c.guardMutex.Lock()
+	defer c.guardMutex.Unlock()
+
+	c.guard = guard
+}
+
+func (c *LinuxContainer) changeGuard() linux_backend.ChangeGuard {
+	c.guardMutex.RLock()
+	defer c.guardMutex.RUnlock()
+
+	return c.guard
+}
+
+// changeLimits has the guard vet the limits the container would be held to
+// after the change, and makes the change through limit if they pass.
+func (c *LinuxContainer) changeLimits(change func(*linux_backend.AdmissionLimits), limit func() error) error {
+	guard := c.changeGuard()
+	if guard == nil {
+		return limit()
+	}
+
+	limits := c.AppliedLimits()
+	change(&limits)
+
+	return guard.ChangeLimits(c, limits, limit)
+}
+
+// diskLimitInBytes returns the hard limit on the container's disk usage,
+// whether given in bytes or in blocks.
+func diskLimitInBytes(limits garden.DiskLimits) uint64 {
+	if limits.ByteHard != 0 {
+		return limits.ByteHard
+	}
+
+	return limits.BlockHard * quota_manager.QUOTA_BLOCK_SIZE
+}
+
 func (c *LinuxContainer) startOomNotifier() error {
 	c.oomMutex.Lock()
 	defer c.oomMutex.Unlock()
@@ -165,9 +428,225 @@ func (c *LinuxContainer) stopOomNotifier() {
 func (c *LinuxContainer) watchForOom(oom *exec.Cmd) {
 	err := c.runner.Wait(oom)
 	if err == nil {
-		c.registerEvent("out of memory")
+		c.registerEvent(EventKindOutOfMemory, c.memoryUsageDetails())
+		c.emit(linux_backend.Event{Type: linux_backend.EventOutOfMemory})
 		c.Stop(false)
 	}
 
 	// TODO: handle case where oom notifier itself failed? kill container?
 }
+
+// memoryUsageDetails describes the memory cgroup's usage, e.g. for an
+// out-of-memory event, leaving out whatever cannot be read.
+func (c *LinuxContainer) memoryUsageDetails() map[string]string {
+	details := map[string]string{}
+
+	for detail, file := range map[string]string{
+		"memory_usage_in_bytes":     "memory.usage_in_bytes",
+		"memory_max_usage_in_bytes": "memory.max_usage_in_bytes",
+		"memory_limit_in_bytes":     "memory.limit_in_bytes",
+	} {
+		value, err := c.cgroupsManager.Get("memory", file)
+		if err == nil {
+			details[detail] = value
+		}
+	}
+
+	return details
+}
+
+// restoreLimits reconciles each limit saved in the snapshot against the value
+// currently enforced on the host, re-applying any that have drifted (or can
+// no longer be read) and recording an event for each correction.
+func (c *LinuxContainer) restoreLimits(logger lager.Logger, limits LimitsSnapshot) error {
+	if limits.Memory != nil {
+		err := c.restoreMemoryLimits(logger, *limits.Memory)
+		if err != nil {
+			logger.Error("failed-to-limit-memory", err)
+			return err
+		}
+	}
+
+	if limits.CPU != nil {
+		err := c.restoreCPULimits(logger, *limits.CPU)
+		if err != nil {
+			logger.Error("failed-to-limit-cpu", err)
+			return err
+		}
+	}
+
+	if limits.Disk != nil {
+		err := c.restoreDiskLimits(logger, *limits.Disk)
+		if err != nil {
+			logger.Error("failed-to-limit-disk", err)
+			return err
+		}
+	}
+
+	if limits.Bandwidth != nil {
+		err := c.restoreBandwidthLimits(logger, *limits.Bandwidth)
+		if err != nil {
+			logger.Error("failed-to-limit-bandwidth", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *LinuxContainer) restoreMemoryLimits(logger lager.Logger, limits linux_backend.MemoryLimits) error {
+	actual, err := c.MemoryLimits()
+	if err == nil && enforcedMemoryLimits(limits, actual) {
+		c.memoryMutex.Lock()
+		c.currentMemoryLimits = &limits
+		c.memoryMutex.Unlock()
+
+		return c.startOomNotifier()
+	}
+
+	logDrift(logger, "memory", limits, actual, err)
+
+	err = c.SetMemoryLimits(limits)
+	if err != nil {
+		return err
+	}
+
+	c.registerEvent(EventKindLimitDrifted, map[string]string{"limit": "memory"})
+
+	return nil
+}
+
+func (c *LinuxContainer) restoreCPULimits(logger lager.Logger, limits garden.CPULimits) error {
+	actual, err := c.CurrentCPULimits()
+	if err == nil && actual == limits {
+		c.cpuMutex.Lock()
+		c.currentCPULimits = &limits
+		c.cpuMutex.Unlock()
+
+		return nil
+	}
+
+	logDrift(logger, "cpu", limits, actual, err)
+
+	err = c.LimitCPU(limits)
+	if err != nil {
+		return err
+	}
+
+	c.registerEvent(EventKindLimitDrifted, map[string]string{"limit": "cpu"})
+
+	return nil
+}
+
+func (c *LinuxContainer) restoreDiskLimits(logger lager.Logger, limits garden.DiskLimits) error {
+	actual, err := c.CurrentDiskLimits()
+	if !c.quotaManager.IsEnabled() || (err == nil && actual == enforcedDiskLimits(limits)) {
+		c.diskMutex.Lock()
+		c.currentDiskLimits = &limits
+		c.diskMutex.Unlock()
+
+		return nil
+	}
+
+	logDrift(logger, "disk", limits, actual, err)
+
+	err = c.LimitDisk(limits)
+	if err != nil {
+		return err
+	}
+
+	c.registerEvent(EventKindLimitDrifted, map[string]string{"limit": "disk"})
+
+	return nil
+}
+
+func (c *LinuxContainer) restoreBandwidthLimits(logger lager.Logger, limits garden.BandwidthLimits) error {
+	actual, err := c.bandwidthManager.GetLimits(logger)
+	if err == nil && actual == enforcedBandwidthStat(limits) {
+		c.bandwidthMutex.Lock()
+		c.currentBandwidthLimits = &limits
+		c.bandwidthMutex.Unlock()
+
+		return nil
+	}
+
+	logDrift(logger, "bandwidth", limits, actual, err)
+
+	err = c.LimitBandwidth(limits)
+	if err != nil {
+		return err
+	}
+
+	c.registerEvent(EventKindLimitDrifted, map[string]string{"limit": "bandwidth"})
+
+	return nil
+}
+
+// enforcedDiskLimits returns the limits as the quota manager reports them
+// back: byte limits are converted to blocks, and bytes are not reported.
+func enforcedDiskLimits(limits garden.DiskLimits) garden.DiskLimits {
+	if limits.ByteSoft != 0 {
+		limits.BlockSoft = (limits.ByteSoft + quota_manager.QUOTA_BLOCK_SIZE - 1) / quota_manager.QUOTA_BLOCK_SIZE
+	}
+
+	if limits.ByteHard != 0 {
+		limits.BlockHard = (limits.ByteHard + quota_manager.QUOTA_BLOCK_SIZE - 1) / quota_manager.QUOTA_BLOCK_SIZE
+	}
+
+	limits.ByteSoft = 0
+	limits.ByteHard = 0
+
+	return limits
+}
+
+// enforcedMemoryLimits reports whether the limits read back from the cgroup
+// are the ones given. The kernel keeps the limits in whole pages, so they
+// only need to agree to within a page. The swappiness is always read back,
+// but only compared if it was given.
+func enforcedMemoryLimits(limits, actual linux_backend.MemoryLimits) bool {
+	if limits.Swappiness != nil {
+		if actual.Swappiness == nil || *actual.Swappiness != *limits.Swappiness {
+			return false
+		}
+	}
+
+	return samePages(limits.LimitInBytes, actual.LimitInBytes) &&
+		samePages(limits.SoftLimitInBytes, actual.SoftLimitInBytes) &&
+		samePages(limits.SwapInBytes, actual.SwapInBytes) &&
+		samePages(limits.KernelLimitInBytes, actual.KernelLimitInBytes)
+}
+
+// samePages reports whether the two sizes in bytes differ by less than a
+// page, as when one is the other rounded to a page.
+func samePages(a, b uint64) bool {
+	if a < b {
+		a, b = b, a
+	}
+
+	return a-b < uint64(os.Getpagesize())
+}
+
+// enforcedBandwidthStat returns the limits as the bandwidth manager reports
+// them back: the same rate and burst shape both ingress and egress.
+func enforcedBandwidthStat(limits garden.BandwidthLimits) garden.ContainerBandwidthStat {
+	return garden.ContainerBandwidthStat{
+		InRate:   limits.RateInBytesPerSecond,
+		InBurst:  limits.BurstRateInBytesPerSecond,
+		OutRate:  limits.RateInBytesPerSecond,
+		OutBurst: limits.BurstRateInBytesPerSecond,
+	}
+}
+
+func logDrift(logger lager.Logger, limit string, expected, actual interface{}, err error) {
+	data := lager.Data{
+		"limit":    limit,
+		"expected": expected,
+		"actual":   actual,
+	}
+
+	if err != nil {
+		data["error"] = err.Error()
+	}
+
+	logger.Info("limit-drifted", data)
+}
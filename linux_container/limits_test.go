@@ -3,9 +3,10 @@ package linux_container_test
 import (
 	"errors"
 	"io/ioutil"
-	"math"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -47,6 +48,11 @@ var _ = Describe("Linux containers", func() {
 		containerDir, err = ioutil.TempDir("", "depot")
 		Expect(err).ToNot(HaveOccurred())
 
+		err = os.Mkdir(filepath.Join(containerDir, "run"), 0755)
+		Expect(err).ToNot(HaveOccurred())
+		err = ioutil.WriteFile(filepath.Join(containerDir, "run", "wshd.pid"), []byte("12345\n"), 0644)
+		Expect(err).ToNot(HaveOccurred())
+
 		_, subnet, _ := net.ParseCIDR("2.3.4.0/30")
 		containerResources = linux_backend.NewResources(
 			1234,
@@ -78,6 +84,7 @@ var _ = Describe("Linux containers", func() {
 			new(fake_process_tracker.FakeProcessTracker),
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			"",
 		)
 	})
 
@@ -167,7 +174,7 @@ var _ = Describe("Linux containers", func() {
 
 		})
 
-		It("sets memory.limit_in_bytes and then memory.memsw.limit_in_bytes", func() {
+		It("sets memory.memsw.limit_in_bytes, memory.limit_in_bytes and memory.soft_limit_in_bytes", func() {
 			limits := garden.MemoryLimits{
 				LimitInBytes: 102400,
 			}
@@ -179,24 +186,219 @@ var _ = Describe("Linux containers", func() {
 				[]fake_cgroups_manager.SetValue{
 					{
 						Subsystem: "memory",
-						Name:      "memory.limit_in_bytes",
+						Name:      "memory.memsw.limit_in_bytes",
 						Value:     "102400",
 					},
 					{
 						Subsystem: "memory",
-						Name:      "memory.memsw.limit_in_bytes",
+						Name:      "memory.limit_in_bytes",
 						Value:     "102400",
 					},
 					{
 						Subsystem: "memory",
-						Name:      "memory.limit_in_bytes",
-						Value:     "102400",
+						Name:      "memory.soft_limit_in_bytes",
+						Value:     "-1",
 					},
 				},
 			))
 
 		})
 
+		It("allows swap on top of the limit", func() {
+			err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+				LimitInBytes: 102400,
+				SwapInBytes:  4096,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.memsw.limit_in_bytes",
+				Value:     "106496",
+			}))
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.limit_in_bytes",
+				Value:     "102400",
+			}))
+		})
+
+		It("lifts the limit, swap and all, when it is 0", func() {
+			err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+				SwapInBytes: 4096,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.memsw.limit_in_bytes",
+				Value:     "-1",
+			}))
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.limit_in_bytes",
+				Value:     "-1",
+			}))
+		})
+
+		It("sets the soft limit", func() {
+			err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+				LimitInBytes:     102400,
+				SoftLimitInBytes: 51200,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.soft_limit_in_bytes",
+				Value:     "51200",
+			}))
+		})
+
+		It("sets the swappiness", func() {
+			swappiness := uint64(0)
+
+			err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+				LimitInBytes: 102400,
+				Swappiness:   &swappiness,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+				Subsystem: "memory",
+				Name:      "memory.swappiness",
+				Value:     "0",
+			}))
+		})
+
+		It("keeps the other limits as they were last set when only the limit is given", func() {
+			swappiness := uint64(0)
+
+			err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+				LimitInBytes:     102400,
+				SoftLimitInBytes: 51200,
+				Swappiness:       &swappiness,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			err = container.LimitMemory(garden.MemoryLimits{LimitInBytes: 204800})
+			Expect(err).ToNot(HaveOccurred())
+
+			values := fakeCgroups.SetValues()
+			Expect(values[len(values)-2:]).To(Equal([]fake_cgroups_manager.SetValue{
+				{
+					Subsystem: "memory",
+					Name:      "memory.soft_limit_in_bytes",
+					Value:     "51200",
+				},
+				{
+					Subsystem: "memory",
+					Name:      "memory.swappiness",
+					Value:     "0",
+				},
+			}))
+		})
+
+		Context("when the soft limit exceeds the limit", func() {
+			It("returns an error without setting anything", func() {
+				limits := linux_backend.MemoryLimits{
+					LimitInBytes:     102400,
+					SoftLimitInBytes: 204800,
+				}
+
+				err := container.SetMemoryLimits(limits)
+				Expect(err).To(Equal(linux_backend.InvalidMemoryLimitsError{
+					Limits: limits,
+					Reason: "soft limit exceeds limit",
+				}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Context("when the swappiness exceeds 100", func() {
+			It("returns an error without setting anything", func() {
+				swappiness := uint64(101)
+
+				err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+					LimitInBytes: 102400,
+					Swappiness:   &swappiness,
+				})
+				Expect(err).To(BeAssignableToTypeOf(linux_backend.InvalidMemoryLimitsError{}))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
+		Describe("the kernel memory limit", func() {
+			var kmemLimitInBytes string
+
+			BeforeEach(func() {
+				kmemLimitInBytes = "9223372036854771712"
+
+				fakeCgroups.WhenGetting("memory", "memory.kmem.limit_in_bytes", func() (string, error) {
+					return kmemLimitInBytes, nil
+				})
+			})
+
+			kmemValues := func() []string {
+				values := []string{}
+				for _, value := range fakeCgroups.SetValues() {
+					if value.Name == "memory.kmem.limit_in_bytes" {
+						values = append(values, value.Value)
+					}
+				}
+
+				return values
+			}
+
+			It("is set when given", func() {
+				err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+					LimitInBytes:       102400,
+					KernelLimitInBytes: 51200,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(kmemValues()).To(Equal([]string{"51200"}))
+			})
+
+			It("is left alone when neither given nor set", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(kmemValues()).To(BeEmpty())
+			})
+
+			Context("when it is already set", func() {
+				BeforeEach(func() {
+					kmemLimitInBytes = "51200"
+				})
+
+				It("is not set again", func() {
+					err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+						LimitInBytes:       102400,
+						KernelLimitInBytes: 51200,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(kmemValues()).To(BeEmpty())
+				})
+
+				It("is lifted when not given", func() {
+					err := container.LimitMemory(garden.MemoryLimits{
+						LimitInBytes: 102400,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					Expect(kmemValues()).To(Equal([]string{"-1"}))
+				})
+			})
+		})
+
 		Context("when the oom notifier is already running", func() {
 			It("does not start another", func() {
 				started := 0
@@ -239,11 +441,7 @@ var _ = Describe("Linux containers", func() {
 				err := container.LimitMemory(limits)
 				Expect(err).ToNot(HaveOccurred())
 
-				Eventually(fakeRunner).Should(HaveExecutedSerially(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/stop.sh",
-					},
-				))
+				Eventually(container.State).Should(Equal(linux_container.StateStopped))
 			})
 
 			It("registers an 'out of memory' event", func() {
@@ -258,74 +456,137 @@ var _ = Describe("Linux containers", func() {
 					return container.Events()
 				}).Should(ContainElement("out of memory"))
 			})
-		})
 
-		Context("when setting memory.memsw.limit_in_bytes fails", func() {
-			disaster := errors.New("oh no!")
+			It("records the memory usage at the time", func() {
+				fakeCgroups.WhenGetting("memory", "memory.usage_in_bytes", func() (string, error) {
+					return "102300", nil
+				})
 
-			JustBeforeEach(func() {
-				fakeCgroups.WhenSetting("memory", "memory.memsw.limit_in_bytes", func() error {
-					return disaster
+				fakeCgroups.WhenGetting("memory", "memory.max_usage_in_bytes", func() (string, error) {
+					return "", errors.New("oh no!")
 				})
-			})
 
-			It("does not fail", func() {
 				err := container.LimitMemory(garden.MemoryLimits{
 					LimitInBytes: 102400,
 				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(container.EventHistory).Should(HaveLen(1))
+
+				event := container.EventHistory()[0]
+				Expect(event.Kind).To(Equal(linux_container.EventKindOutOfMemory))
+				Expect(event.Details).To(Equal(map[string]string{
+					"memory_usage_in_bytes": "102300",
+					"memory_limit_in_bytes": "102400",
+				}))
+			})
 
+			It("emits an oom event", func() {
+				events := make(chan linux_backend.Event, 10)
+				container.OnEvent(func(event linux_backend.Event) {
+					events <- event
+				})
+
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
 				Expect(err).ToNot(HaveOccurred())
+
+				types := []linux_backend.EventType{}
+				Eventually(func() []linux_backend.EventType {
+					select {
+					case event := <-events:
+						types = append(types, event.Type)
+					default:
+					}
+
+					return types
+				}).Should(ContainElement(linux_backend.EventOutOfMemory))
 			})
 		})
 
-		Context("when setting memory.limit_in_bytes fails only the first time", func() {
-			disaster := errors.New("oh no!")
+		Context("when the memsw limit would be below the current limit", func() {
+			var written []string
 
 			BeforeEach(func() {
-				numSet := 0
+				written = []string{}
+				refused := false
 
-				fakeCgroups.WhenSetting("memory", "memory.limit_in_bytes", func() error {
-					numSet++
-
-					if numSet == 1 {
-						return disaster
+				fakeCgroups.WhenSetting("memory", "memory.memsw.limit_in_bytes", func() error {
+					if !refused {
+						refused = true
+						return errors.New("invalid argument")
 					}
 
+					written = append(written, "memory.memsw.limit_in_bytes")
 					return nil
 				})
-			})
 
-			It("succeeds", func() {
-				fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
-					return "123", nil
+				fakeCgroups.WhenSetting("memory", "memory.limit_in_bytes", func() error {
+					written = append(written, "memory.limit_in_bytes")
+					return nil
 				})
+			})
 
+			It("lowers the limit first", func() {
 				err := container.LimitMemory(garden.MemoryLimits{
 					LimitInBytes: 102400,
 				})
-
 				Expect(err).ToNot(HaveOccurred())
+
+				Expect(written).To(Equal([]string{
+					"memory.limit_in_bytes",
+					"memory.memsw.limit_in_bytes",
+				}))
 			})
 		})
 
-		Context("when setting memory.limit_in_bytes fails the second time", func() {
+		Context("when setting memory.memsw.limit_in_bytes fails", func() {
 			disaster := errors.New("oh no!")
 
-			JustBeforeEach(func() {
-				numSet := 0
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("memory", "memory.memsw.limit_in_bytes", func() error {
+					return disaster
+				})
+			})
+
+			It("returns the error", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
 
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when setting memory.limit_in_bytes fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
 				fakeCgroups.WhenSetting("memory", "memory.limit_in_bytes", func() error {
-					numSet++
+					return disaster
+				})
+			})
 
-					if numSet == 2 {
-						return disaster
-					}
+			It("returns the error", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
 
-					return nil
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when setting memory.soft_limit_in_bytes fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenSetting("memory", "memory.soft_limit_in_bytes", func() error {
+					return disaster
 				})
 			})
 
-			It("returns the error and no limits", func() {
+			It("returns the error", func() {
 				err := container.LimitMemory(garden.MemoryLimits{
 					LimitInBytes: 102400,
 				})
@@ -334,6 +595,57 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 
+		Context("when swap accounting is disabled", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+					return "", errors.New("no such file or directory")
+				})
+			})
+
+			It("only sets memory.limit_in_bytes", func() {
+				err := container.LimitMemory(garden.MemoryLimits{
+					LimitInBytes: 102400,
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(Equal(
+					[]fake_cgroups_manager.SetValue{
+						{
+							Subsystem: "memory",
+							Name:      "memory.limit_in_bytes",
+							Value:     "102400",
+						},
+						{
+							Subsystem: "memory",
+							Name:      "memory.soft_limit_in_bytes",
+							Value:     "-1",
+						},
+					},
+				))
+			})
+
+			It("lifts the limit when it is 0", func() {
+				err := container.LimitMemory(garden.MemoryLimits{})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(fake_cgroups_manager.SetValue{
+					Subsystem: "memory",
+					Name:      "memory.limit_in_bytes",
+					Value:     "-1",
+				}))
+			})
+
+			It("refuses to allow swap", func() {
+				err := container.SetMemoryLimits(linux_backend.MemoryLimits{
+					LimitInBytes: 102400,
+					SwapInBytes:  4096,
+				})
+				Expect(err).To(MatchError("container: invalid memory limits: swap accounting is disabled"))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+			})
+		})
+
 		Context("when starting the oom notifier fails", func() {
 			disaster := errors.New("oh no!")
 
@@ -355,35 +667,149 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
-	Describe("Getting the current memory limit", func() {
-		It("returns the limited memory", func() {
+	Describe("Getting the current memory limits", func() {
+		JustBeforeEach(func() {
 			fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
-				return "18446744073709551615", nil
+				return "102400", nil
+			})
+
+			fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+				return "51200", nil
+			})
+
+			fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+				return "106496", nil
+			})
+
+			fakeCgroups.WhenGetting("memory", "memory.kmem.limit_in_bytes", func() (string, error) {
+				return "20480", nil
 			})
 
+			fakeCgroups.WhenGetting("memory", "memory.swappiness", func() (string, error) {
+				return "60", nil
+			})
+		})
+
+		It("returns the limit in the memory cgroup", func() {
 			limits, err := container.CurrentMemoryLimits()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(limits.LimitInBytes).To(Equal(uint64(math.MaxUint64)))
+			Expect(limits).To(Equal(garden.MemoryLimits{LimitInBytes: 102400}))
+		})
+
+		It("returns all of the limits in the memory cgroup", func() {
+			swappiness := uint64(60)
+
+			limits, err := container.MemoryLimits()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(limits).To(Equal(linux_backend.MemoryLimits{
+				LimitInBytes:       102400,
+				SoftLimitInBytes:   51200,
+				SwapInBytes:        4096,
+				KernelLimitInBytes: 20480,
+				Swappiness:         &swappiness,
+			}))
+		})
+
+		Context("when the memory is unlimited", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
+					return "18446744073709551615", nil
+				})
+
+				fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+					return "18446744073709551615", nil
+				})
+			})
+
+			It("returns it as zero", func() {
+				limits, err := container.CurrentMemoryLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits.LimitInBytes).To(BeZero())
+
+				memoryLimits, err := container.MemoryLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(memoryLimits.LimitInBytes).To(BeZero())
+				Expect(memoryLimits.SwapInBytes).To(BeZero())
+			})
+		})
+
+		Context("when there is no soft or kernel memory limit", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+					return "9223372036854771712", nil
+				})
+
+				fakeCgroups.WhenGetting("memory", "memory.kmem.limit_in_bytes", func() (string, error) {
+					return "9223372036854775807", nil
+				})
+			})
+
+			It("returns them as zero", func() {
+				limits, err := container.MemoryLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits.SoftLimitInBytes).To(BeZero())
+				Expect(limits.KernelLimitInBytes).To(BeZero())
+			})
+		})
+
+		Context("when swap and kernel memory are not accounted for", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+					return "", errors.New("no such file or directory")
+				})
+
+				fakeCgroups.WhenGetting("memory", "memory.kmem.limit_in_bytes", func() (string, error) {
+					return "", errors.New("no such file or directory")
+				})
+			})
+
+			It("returns them as zero", func() {
+				limits, err := container.MemoryLimits()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(limits.LimitInBytes).To(Equal(uint64(102400)))
+				Expect(limits.SwapInBytes).To(BeZero())
+				Expect(limits.KernelLimitInBytes).To(BeZero())
+			})
 		})
 
 		Context("when getting the limit fails", func() {
-			It("returns the error", func() {
-				disaster := errors.New("oh no!")
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
 				fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
 					return "", disaster
 				})
+			})
 
+			It("returns the error", func() {
 				_, err := container.CurrentMemoryLimits()
 				Expect(err).To(Equal(disaster))
 			})
 		})
 
-		Context("when the returned memory limit is malformed", func() {
+		Context("when getting the soft limit fails", func() {
+			disaster := errors.New("oh no!")
+
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+					return "", disaster
+				})
+			})
+
 			It("returns the error", func() {
+				_, err := container.MemoryLimits()
+				Expect(err).To(Equal(disaster))
+			})
+		})
+
+		Context("when the returned memory limit is malformed", func() {
+			BeforeEach(func() {
 				fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
 					return "500M", nil
 				})
+			})
 
+			It("returns the error", func() {
 				_, err := container.CurrentMemoryLimits()
 				Expect(err.Error()).To(HaveSuffix("invalid syntax"))
 			})
@@ -535,4 +961,89 @@ var _ = Describe("Linux containers", func() {
 			})
 		})
 	})
+
+	Describe("Guarding changes", func() {
+		var guard *fakeChangeGuard
+
+		JustBeforeEach(func() {
+			guard = &fakeChangeGuard{}
+			container.Guard(guard)
+		})
+
+		It("reports the limits applied", func() {
+			Expect(container.LimitMemory(garden.MemoryLimits{LimitInBytes: 100})).To(Succeed())
+			Expect(container.LimitDisk(garden.DiskLimits{BlockHard: 2})).To(Succeed())
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 10})).To(Succeed())
+
+			Expect(container.AppliedLimits()).To(Equal(linux_backend.AdmissionLimits{
+				MemoryInBytes: 100,
+				DiskInBytes:   2048,
+				CPUShares:     10,
+			}))
+		})
+
+		It("has the guard vet the limits the container would be held to", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 10})).To(Succeed())
+			Expect(container.LimitMemory(garden.MemoryLimits{LimitInBytes: 100})).To(Succeed())
+
+			Expect(guard.limits).To(Equal([]linux_backend.AdmissionLimits{
+				{CPUShares: 10},
+				{CPUShares: 10, MemoryInBytes: 100},
+			}))
+		})
+
+		Context("when the guard rejects a change to the limits", func() {
+			disaster := errors.New("no room")
+
+			JustBeforeEach(func() {
+				guard.limitsError = disaster
+			})
+
+			It("does not apply it", func() {
+				err := container.LimitCPU(garden.CPULimits{LimitInShares: 10})
+				Expect(err).To(Equal(disaster))
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+				Expect(container.AppliedLimits()).To(BeZero())
+			})
+		})
+
+		Context("when the guard rejects a change to a property", func() {
+			disaster := errors.New("read only")
+
+			JustBeforeEach(func() {
+				guard.propertyError = disaster
+			})
+
+			It("neither sets nor removes it", func() {
+				Expect(container.SetProperty("some-property", "some-value")).To(Equal(disaster))
+				Expect(container.RemoveProperty("some-property")).To(Equal(disaster))
+
+				Expect(guard.properties).To(Equal([]string{"some-property", "some-property"}))
+			})
+		})
+	})
 })
+
+type fakeChangeGuard struct {
+	limits      []linux_backend.AdmissionLimits
+	limitsError error
+
+	properties    []string
+	propertyError error
+}
+
+func (g *fakeChangeGuard) ChangeLimits(container linux_backend.Container, limits linux_backend.AdmissionLimits, apply func() error) error {
+	g.limits = append(g.limits, limits)
+
+	if g.limitsError != nil {
+		return g.limitsError
+	}
+
+	return apply()
+}
+
+func (g *fakeChangeGuard) ChangeProperty(container linux_backend.Container, key string) error {
+	g.properties = append(g.properties, key)
+	return g.propertyError
+}
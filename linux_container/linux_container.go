@@ -45,12 +45,20 @@ type LinuxContainer struct {
 	properties      garden.Properties
 	propertiesMutex sync.RWMutex
 
-	graceTime time.Duration
+	graceTime     time.Duration
+	stopGraceTime time.Duration
 
 	state      State
 	stateMutex sync.RWMutex
 
-	events      []string
+	// the processes being dumped, or already dumped, by Checkpoint, which
+	// kills them; guarded by stateMutex
+	checkpointedProcesses []ProcessSnapshot
+
+	// empty if checkpointing is disabled
+	criuPath string
+
+	events      *eventHistory
 	eventsMutex sync.RWMutex
 
 	resources *linux_backend.Resources
@@ -76,12 +84,15 @@ type LinuxContainer struct {
 	currentDiskLimits *garden.DiskLimits
 	diskMutex         sync.RWMutex
 
-	currentMemoryLimits *garden.MemoryLimits
+	currentMemoryLimits *linux_backend.MemoryLimits
 	memoryMutex         sync.RWMutex
 
 	currentCPULimits *garden.CPULimits
 	cpuMutex         sync.RWMutex
 
+	guard      linux_backend.ChangeGuard
+	guardMutex sync.RWMutex
+
 	netIns      []NetInSpec
 	netInsMutex sync.RWMutex
 
@@ -93,6 +104,12 @@ type LinuxContainer struct {
 	env process.Env
 
 	processIDPool *ProcessIDPool
+
+	changeHandlers      []func()
+	changeHandlersMutex sync.RWMutex
+
+	eventHandlers      []func(linux_backend.Event)
+	eventHandlersMutex sync.RWMutex
 }
 
 type ProcessIDPool struct {
@@ -134,6 +151,9 @@ const (
 	StateBorn    = State("born")
 	StateActive  = State("active")
 	StateStopped = State("stopped")
+	StatePaused  = State("paused")
+
+	StateCheckpointed = State("checkpointed")
 )
 
 func NewLinuxContainer(
@@ -150,7 +170,14 @@ func NewLinuxContainer(
 	processTracker process_tracker.ProcessTracker,
 	env process.Env,
 	filter network.Filter,
+	criuPath string,
 ) *LinuxContainer {
+	// the pool has already validated the properties
+	stopGraceTime, err := ParseStopGraceTime(properties)
+	if err != nil {
+		stopGraceTime = DefaultStopGraceTime
+	}
+
 	return &LinuxContainer{
 		logger: logger,
 
@@ -160,10 +187,13 @@ func NewLinuxContainer(
 
 		properties: properties,
 
-		graceTime: graceTime,
+		graceTime:     graceTime,
+		stopGraceTime: stopGraceTime,
 
 		state:  StateBorn,
-		events: []string{},
+		events: newEventHistory(EventHistorySize),
+
+		criuPath: criuPath,
 
 		resources: resources,
 
@@ -203,15 +233,36 @@ func (c *LinuxContainer) State() State {
 	return c.state
 }
 
+// Events returns the container's recent events as garden reports them.
 func (c *LinuxContainer) Events() []string {
+	events := []string{}
+	for _, event := range c.EventHistory() {
+		events = append(events, event.String())
+	}
+
+	return events
+}
+
+// EventHistory returns the container's recent events, oldest first.
+func (c *LinuxContainer) EventHistory() []ContainerEvent {
 	c.eventsMutex.RLock()
 	defer c.eventsMutex.RUnlock()
 
-	events := make([]string, len(c.events))
+	return c.events.all()
+}
 
-	copy(events, c.events)
+func (c *LinuxContainer) OnChange(handler func()) {
+	c.changeHandlersMutex.Lock()
+	defer c.changeHandlersMutex.Unlock()
 
-	return events
+	c.changeHandlers = append(c.changeHandlers, handler)
+}
+
+func (c *LinuxContainer) OnEvent(handler func(linux_backend.Event)) {
+	c.eventHandlersMutex.Lock()
+	defer c.eventHandlersMutex.Unlock()
+
+	c.eventHandlers = append(c.eventHandlers, handler)
 }
 
 func (c *LinuxContainer) Resources() *linux_backend.Resources {
@@ -241,25 +292,33 @@ func (c *LinuxContainer) Snapshot(out io.Writer) error {
 	c.netOutsMutex.RLock()
 	defer c.netOutsMutex.RUnlock()
 
-	processSnapshots := []ProcessSnapshot{}
-
-	for _, p := range c.processTracker.ActiveProcesses() {
-		processSnapshots = append(
-			processSnapshots,
-			ProcessSnapshot{
-				ID: p.ID(),
-			},
-		)
+	// the processes of a container being checkpointed are taken from before
+	// the dump, as it kills them
+	processSnapshots := c.checkpointed()
+	if processSnapshots == nil {
+		processSnapshots = []ProcessSnapshot{}
+
+		for _, p := range c.processTracker.ActiveProcesses() {
+			processSnapshots = append(
+				processSnapshots,
+				ProcessSnapshot{
+					ID: p.ID(),
+				},
+			)
+		}
 	}
 
 	snapshot := ContainerSnapshot{
+		Version: CurrentSnapshotVersion,
+
 		ID:     c.id,
 		Handle: c.handle,
 
-		GraceTime: c.graceTime,
+		GraceTime:     c.graceTime,
+		StopGraceTime: c.stopGraceTime,
 
 		State:  string(c.State()),
-		Events: c.Events(),
+		Events: c.EventHistory(),
 
 		Limits: LimitsSnapshot{
 			Bandwidth: c.currentBandwidthLimits,
@@ -315,6 +374,8 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 
 	c.setState(State(snapshot.State))
 
+	c.stopGraceTime = snapshot.StopGraceTime
+
 	snapshotEnv, err := process.NewEnv(snapshot.EnvVars)
 	if err != nil {
 		cLog.Error("restoring-env", err, lager.Data{
@@ -324,16 +385,27 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 	}
 	c.env = snapshotEnv
 
+	c.eventsMutex.Lock()
 	for _, ev := range snapshot.Events {
-		c.registerEvent(ev)
+		c.events.push(ev)
 	}
+	c.eventsMutex.Unlock()
 
-	if snapshot.Limits.Memory != nil {
-		err := c.LimitMemory(*snapshot.Limits.Memory)
+	var reattached map[uint32]process_tracker.ProcessPipes
+	if State(snapshot.State) == StateCheckpointed {
+		// brings back the cgroups the limits are set in, too
+		reattached, err = c.restoreCheckpoint(cLog, snapshot.Processes)
 		if err != nil {
-			cLog.Error("failed-to-limit-memory", err)
 			return err
 		}
+
+		// the process tracker's iodaemons have their own copies
+		defer closeProcessPipes(reattached)
+	}
+
+	err = c.restoreLimits(cLog, snapshot.Limits)
+	if err != nil {
+		return err
 	}
 
 	for _, process := range snapshot.Processes {
@@ -351,7 +423,21 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 			PidFilePath:   pidfile,
 		}
 
-		c.processTracker.Restore(process.ID, signaller)
+		if pipes, found := reattached[process.ID]; found {
+			restored, err := c.processTracker.Reattach(process.ID, pipes, signaller)
+			if err != nil {
+				cLog.Error("failed-to-reattach-process", err, lager.Data{
+					"process": process.ID,
+				})
+				return err
+			}
+
+			go c.awaitExit(process.ID, restored)
+
+			continue
+		}
+
+		go c.awaitExit(process.ID, c.processTracker.Restore(process.ID, signaller))
 	}
 
 	net := exec.Command(path.Join(c.path, "net.sh"), "setup")
@@ -362,8 +448,10 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 		return err
 	}
 
+	// the container may be paused, and replaying what it had before is not a
+	// change to snapshot or report
 	for _, in := range snapshot.NetIns {
-		_, _, err = c.NetIn(in.HostPort, in.ContainerPort)
+		_, _, err = c.netIn(in.HostPort, in.ContainerPort)
 		if err != nil {
 			cLog.Error("failed-to-reenforce-port-mapping", err)
 			return err
@@ -371,7 +459,7 @@ func (c *LinuxContainer) Restore(snapshot ContainerSnapshot) error {
 	}
 
 	for _, out := range snapshot.NetOuts {
-		if err := c.NetOut(out); err != nil {
+		if err := c.netOut(out); err != nil {
 			cLog.Error("failed-to-reenforce-net-out", err)
 			return err
 		}
@@ -406,6 +494,8 @@ func (c *LinuxContainer) Start() error {
 
 	c.setState(StateActive)
 
+	c.emit(linux_backend.Event{Type: linux_backend.EventStarted})
+
 	cLog.Info("started")
 
 	return nil
@@ -420,25 +510,6 @@ func (c *LinuxContainer) Cleanup() {
 	cLog.Info("done")
 }
 
-func (c *LinuxContainer) Stop(kill bool) error {
-	stop := exec.Command(path.Join(c.path, "stop.sh"))
-
-	if kill {
-		stop.Args = append(stop.Args, "-w", "0")
-	}
-
-	err := c.runner.Run(stop)
-	if err != nil {
-		return err
-	}
-
-	c.stopOomNotifier()
-
-	c.setState(StateStopped)
-
-	return nil
-}
-
 func (c *LinuxContainer) Properties() garden.Properties {
 	c.propertiesMutex.RLock()
 	defer c.propertiesMutex.RUnlock()
@@ -463,8 +534,13 @@ func (c *LinuxContainer) GetProperty(key string) (string, error) {
 }
 
 func (c *LinuxContainer) SetProperty(key string, value string) error {
+	if guard := c.changeGuard(); guard != nil {
+		if err := guard.ChangeProperty(c, key); err != nil {
+			return err
+		}
+	}
+
 	c.propertiesMutex.Lock()
-	defer c.propertiesMutex.Unlock()
 
 	props := garden.Properties{}
 	for k, v := range c.properties {
@@ -475,19 +551,33 @@ func (c *LinuxContainer) SetProperty(key string, value string) error {
 
 	c.properties = props
 
+	c.propertiesMutex.Unlock()
+
+	c.notifyChanged()
+
 	return nil
 }
 
 func (c *LinuxContainer) RemoveProperty(key string) error {
+	if guard := c.changeGuard(); guard != nil {
+		if err := guard.ChangeProperty(c, key); err != nil {
+			return err
+		}
+	}
+
 	c.propertiesMutex.Lock()
-	defer c.propertiesMutex.Unlock()
 
 	if _, found := c.properties[key]; !found {
+		c.propertiesMutex.Unlock()
 		return UndefinedPropertyError{key}
 	}
 
 	delete(c.properties, key)
 
+	c.propertiesMutex.Unlock()
+
+	c.notifyChanged()
+
 	return nil
 }
 
@@ -504,6 +594,10 @@ func (c *LinuxContainer) HasProperties(properties garden.Properties) bool {
 	return true
 }
 
+func (c *LinuxContainer) ActiveProcesses() []garden.Process {
+	return c.processTracker.ActiveProcesses()
+}
+
 func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
 	mappedPorts := []garden.PortMapping{}
 
@@ -540,6 +634,10 @@ func (c *LinuxContainer) Info() (garden.ContainerInfo, error) {
 }
 
 func (c *LinuxContainer) StreamIn(dstPath string, tarStream io.Reader) error {
+	if err := c.checkNotPaused("stream into"); err != nil {
+		return err
+	}
+
 	nsTarPath := path.Join(c.path, "bin", "nstar")
 	pidPath := path.Join(c.path, "run", "wshd.pid")
 
@@ -574,6 +672,10 @@ func (c *LinuxContainer) StreamIn(dstPath string, tarStream io.Reader) error {
 }
 
 func (c *LinuxContainer) StreamOut(srcPath string) (io.ReadCloser, error) {
+	if err := c.checkNotPaused("stream out of"); err != nil {
+		return nil, err
+	}
+
 	workingDir := filepath.Dir(srcPath)
 	compressArg := filepath.Base(srcPath)
 	if strings.HasSuffix(srcPath, "/") {
@@ -624,6 +726,28 @@ func (c *LinuxContainer) StreamOut(srcPath string) (io.ReadCloser, error) {
 }
 
 func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, uint32, error) {
+	if err := c.checkNotPaused("map ports into"); err != nil {
+		return 0, 0, err
+	}
+
+	hostPort, containerPort, err := c.netIn(hostPort, containerPort)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{
+		Type:          linux_backend.EventNetInAdded,
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+	})
+
+	return hostPort, containerPort, nil
+}
+
+// netIn maps the port and records the mapping, without notifying anyone.
+func (c *LinuxContainer) netIn(hostPort uint32, containerPort uint32) (uint32, uint32, error) {
 	if hostPort == 0 {
 		randomPort, err := c.portPool.Acquire()
 		if err != nil {
@@ -652,23 +776,33 @@ func (c *LinuxContainer) NetIn(hostPort uint32, containerPort uint32) (uint32, u
 	}
 
 	c.netInsMutex.Lock()
-	defer c.netInsMutex.Unlock()
-
 	c.netIns = append(c.netIns, NetInSpec{hostPort, containerPort})
+	c.netInsMutex.Unlock()
 
 	return hostPort, containerPort, nil
 }
 
 func (c *LinuxContainer) NetOut(r garden.NetOutRule) error {
+	err := c.netOut(r)
+	if err != nil {
+		return err
+	}
+
+	c.notifyChanged()
+
+	return nil
+}
+
+// netOut applies the rule and records it, without notifying anyone.
+func (c *LinuxContainer) netOut(r garden.NetOutRule) error {
 	err := c.filter.NetOut(r)
 	if err != nil {
 		return err
 	}
 
 	c.netOutsMutex.Lock()
-	defer c.netOutsMutex.Unlock()
-
 	c.netOuts = append(c.netOuts, r)
+	c.netOutsMutex.Unlock()
 
 	return nil
 }
@@ -684,9 +818,78 @@ func (c *LinuxContainer) setState(state State) {
 	c.state = state
 }
 
-func (c *LinuxContainer) registerEvent(event string) {
+func (c *LinuxContainer) checkpointed() []ProcessSnapshot {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	return c.checkpointedProcesses
+}
+
+func (c *LinuxContainer) isCheckpointed(processID uint32) bool {
+	for _, p := range c.checkpointed() {
+		if p.ID == processID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *LinuxContainer) setCheckpointed(processes []ProcessSnapshot) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+
+	c.checkpointedProcesses = processes
+}
+
+// notifyChanged must be called without holding any of the container's locks,
+// as handlers will typically take a snapshot.
+func (c *LinuxContainer) notifyChanged() {
+	c.changeHandlersMutex.RLock()
+	handlers := make([]func(), len(c.changeHandlers))
+	copy(handlers, c.changeHandlers)
+	c.changeHandlersMutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// emit stamps the event with the container's handle, its properties and the
+// current time, and passes it to the event handlers. Like notifyChanged, it
+// must be called without holding any of the container's locks.
+func (c *LinuxContainer) emit(event linux_backend.Event) {
+	c.eventHandlersMutex.RLock()
+	handlers := make([]func(linux_backend.Event), len(c.eventHandlers))
+	copy(handlers, c.eventHandlers)
+	c.eventHandlersMutex.RUnlock()
+
+	if len(handlers) == 0 {
+		return
+	}
+
+	event.Time = time.Now()
+	event.Handle = c.handle
+
+	c.propertiesMutex.RLock()
+	event.Properties = garden.Properties{}
+	for k, v := range c.properties {
+		event.Properties[k] = v
+	}
+	c.propertiesMutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+func (c *LinuxContainer) registerEvent(kind EventKind, details map[string]string) {
 	c.eventsMutex.Lock()
 	defer c.eventsMutex.Unlock()
 
-	c.events = append(c.events, event)
+	c.events.push(ContainerEvent{
+		Kind:    kind,
+		Time:    time.Now(),
+		Details: details,
+	})
 }
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -102,6 +103,7 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			fakeFilter,
+			"",
 		)
 	})
 
@@ -176,17 +178,85 @@ var _ = Describe("Linux containers", func() {
 	})
 
 	Describe("Stopping", func() {
-		It("executes the container's stop.sh with the appropriate arguments", func() {
+		var exiting *wfakes.FakeProcess
+		var stubborn *wfakes.FakeProcess
+		var events []linux_backend.Event
+		var tasksErr error
+
+		BeforeEach(func() {
+			containerProps[linux_container.StopGraceTimeProperty] = "100ms"
+
+			exiting = new(wfakes.FakeProcess)
+			exiting.IDReturns(1)
+
+			exited := make(chan struct{})
+			exitOnce := &sync.Once{}
+			exiting.SignalStub = func(garden.Signal) error {
+				exitOnce.Do(func() { close(exited) })
+				return nil
+			}
+			exiting.WaitStub = func() (int, error) {
+				<-exited
+				return 143, nil
+			}
+
+			stubborn = new(wfakes.FakeProcess)
+			stubborn.IDReturns(2)
+			stubborn.WaitStub = func() (int, error) {
+				select {}
+			}
+
+			fakeProcessTracker.ActiveProcessesReturns([]garden.Process{exiting, stubborn})
+
+			tasksErr = nil
+			fakeCgroups.WhenGetting("cpu", "tasks", func() (string, error) {
+				return "12345\n222\n333\n", tasksErr
+			})
+
+			events = []linux_backend.Event{}
+		})
+
+		JustBeforeEach(func() {
+			container.OnEvent(func(event linux_backend.Event) {
+				events = append(events, event)
+			})
+		})
+
+		It("terminates the container's processes, and kills those still running after the stop grace time", func() {
+			err := container.Stop(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(exiting.SignalCallCount()).To(Equal(1))
+			Expect(exiting.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+
+			Expect(stubborn.SignalCallCount()).To(Equal(2))
+			Expect(stubborn.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+			Expect(stubborn.SignalArgsForCall(1)).To(Equal(garden.SignalKill))
+		})
+
+		It("kills every task left in the container but wshd", func() {
 			err := container.Stop(false)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(fakeRunner).To(HaveExecutedSerially(
 				fake_command_runner.CommandSpec{
-					Path: containerDir + "/stop.sh",
+					Path: "kill",
+					Args: []string{"-9", "222", "333"},
 				},
 			))
 		})
 
+		It("records the processes that had to be killed", func() {
+			err := container.Stop(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.Events()).To(ContainElement("force-killed processes 2 on stop"))
+
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(linux_backend.EventStopped))
+			Expect(events[0].ForceKilledProcessIDs).To(Equal([]uint32{2}))
+		})
+
 		It("sets the container's state to stopped", func() {
 			Expect(container.State()).To(Equal(linux_container.StateBorn))
 
@@ -194,40 +264,90 @@ var _ = Describe("Linux containers", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(container.State()).To(Equal(linux_container.StateStopped))
+		})
 
+		It("notifies of the change once the stop and its events are recorded", func() {
+			var stateWhenChanged linux_container.State
+			var eventsWhenChanged []string
+			container.OnChange(func() {
+				stateWhenChanged = container.State()
+				eventsWhenChanged = container.Events()
+			})
+
+			err := container.Stop(false)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(stateWhenChanged).To(Equal(linux_container.StateStopped))
+			Expect(eventsWhenChanged).To(ContainElement("force-killed processes 2 on stop"))
+		})
+
+		Context("when every process exits within the stop grace time", func() {
+			BeforeEach(func() {
+				fakeProcessTracker.ActiveProcessesReturns([]garden.Process{exiting})
+			})
+
+			It("does not record any as killed", func() {
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(exiting.SignalCallCount()).To(Equal(1))
+
+				Expect(container.Events()).To(BeEmpty())
+				Expect(events[0].ForceKilledProcessIDs).To(BeEmpty())
+			})
 		})
 
 		Context("when kill is true", func() {
-			It("executes stop.sh with -w 0", func() {
+			It("kills the processes without terminating them first", func() {
 				err := container.Stop(true)
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(fakeRunner).To(HaveExecutedSerially(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/stop.sh",
-						Args: []string{"-w", "0"},
-					},
-				))
+				Expect(exiting.SignalCallCount()).To(Equal(1))
+				Expect(exiting.SignalArgsForCall(0)).To(Equal(garden.SignalKill))
 
+				Expect(stubborn.SignalCallCount()).To(Equal(1))
+				Expect(stubborn.SignalArgsForCall(0)).To(Equal(garden.SignalKill))
+			})
+
+			It("does not record any as force-killed, as none had the chance to exit", func() {
+				err := container.Stop(true)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.Events()).To(BeEmpty())
+				Expect(events[0].ForceKilledProcessIDs).To(BeEmpty())
 			})
 		})
 
-		Context("when stop.sh fails", func() {
-			nastyError := errors.New("oh no!")
+		Context("when the stop grace time is 0", func() {
+			BeforeEach(func() {
+				containerProps[linux_container.StopGraceTimeProperty] = "0s"
+			})
 
-			JustBeforeEach(func() {
-				fakeRunner.WhenRunning(
-					fake_command_runner.CommandSpec{
-						Path: containerDir + "/stop.sh",
-					}, func(*exec.Cmd) error {
-						return nastyError
-					},
-				)
+			It("kills the processes without terminating them first", func() {
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(stubborn.SignalCallCount()).To(Equal(1))
+				Expect(stubborn.SignalArgsForCall(0)).To(Equal(garden.SignalKill))
+			})
+
+			It("does not record any as force-killed, as none had the chance to exit", func() {
+				err := container.Stop(false)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(container.Events()).To(BeEmpty())
+				Expect(events[0].ForceKilledProcessIDs).To(BeEmpty())
+			})
+		})
+
+		Context("when the container's tasks cannot be read", func() {
+			BeforeEach(func() {
+				tasksErr = errors.New("oh no!")
 			})
 
 			It("returns the error", func() {
 				err := container.Stop(false)
-				Expect(err).To(Equal(nastyError))
+				Expect(err).To(MatchError("container: stop: oh no!"))
 			})
 
 			It("does not change the container's state", func() {
@@ -681,6 +801,120 @@ var _ = Describe("Linux containers", func() {
 		})
 	})
 
+	Describe("Change notifications", func() {
+		var changes int
+
+		JustBeforeEach(func() {
+			changes = 0
+			container.OnChange(func() {
+				changes++
+			})
+		})
+
+		It("notifies after a property is set", func() {
+			Expect(container.SetProperty("some-property", "some-value")).To(Succeed())
+			Expect(changes).To(Equal(1))
+		})
+
+		It("notifies after a property is removed", func() {
+			Expect(container.RemoveProperty("property-name")).To(Succeed())
+			Expect(changes).To(Equal(1))
+		})
+
+		It("does not notify when removing an undefined property fails", func() {
+			Expect(container.RemoveProperty("some-other-property")).ToNot(Succeed())
+			Expect(changes).To(Equal(0))
+		})
+
+		It("notifies after a port is mapped", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(changes).To(Equal(1))
+		})
+
+		It("notifies after a net out rule is added", func() {
+			Expect(container.NetOut(garden.NetOutRule{})).To(Succeed())
+			Expect(changes).To(Equal(1))
+		})
+
+		It("notifies after a limit is changed", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 5})).To(Succeed())
+			Expect(changes).To(Equal(1))
+		})
+
+		It("can take a snapshot from within the handler", func() {
+			container.OnChange(func() {
+				Expect(container.Snapshot(new(bytes.Buffer))).To(Succeed())
+			})
+
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("Lifecycle events", func() {
+		var events chan linux_backend.Event
+
+		JustBeforeEach(func() {
+			events = make(chan linux_backend.Event, 10)
+			container.OnEvent(func(event linux_backend.Event) {
+				events <- event
+			})
+		})
+
+		It("emits a started event, stamped with the handle and properties", func() {
+			Expect(container.Start()).To(Succeed())
+
+			var event linux_backend.Event
+			Expect(events).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventStarted))
+			Expect(event.Handle).To(Equal("some-handle"))
+			Expect(event.Properties).To(Equal(garden.Properties{"property-name": "property-value"}))
+			Expect(event.Time).ToNot(BeZero())
+		})
+
+		It("emits a stopped event", func() {
+			Expect(container.Stop(false)).To(Succeed())
+
+			var event linux_backend.Event
+			Expect(events).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventStopped))
+		})
+
+		It("emits a net-in-added event with the mapped ports", func() {
+			_, _, err := container.NetIn(123, 456)
+			Expect(err).ToNot(HaveOccurred())
+
+			var event linux_backend.Event
+			Expect(events).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventNetInAdded))
+			Expect(event.HostPort).To(Equal(uint32(123)))
+			Expect(event.ContainerPort).To(Equal(uint32(456)))
+		})
+
+		It("emits a limit-changed event naming the limit", func() {
+			Expect(container.LimitCPU(garden.CPULimits{LimitInShares: 5})).To(Succeed())
+
+			var event linux_backend.Event
+			Expect(events).To(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventLimitChanged))
+			Expect(event.Limit).To(Equal("cpu"))
+		})
+
+		It("does not emit an event when starting fails", func() {
+			fakeRunner.WhenRunning(
+				fake_command_runner.CommandSpec{
+					Path: containerDir + "/start.sh",
+				}, func(*exec.Cmd) error {
+					return errors.New("oh no!")
+				},
+			)
+
+			Expect(container.Start()).ToNot(Succeed())
+			Expect(events).ToNot(Receive())
+		})
+	})
+
 	Describe("Info", func() {
 		It("returns the container's state", func() {
 			info, err := container.Info()
@@ -63,6 +63,7 @@ var _ = Describe("Linux containers", func() {
 			new(fake_process_tracker.FakeProcessTracker),
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			"",
 		)
 	})
 
@@ -12,6 +12,10 @@ import (
 )
 
 func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+	if err := c.checkNotPaused("run processes in"); err != nil {
+		return nil, err
+	}
+
 	wshPath := path.Join(c.path, "bin", "wsh")
 	sockPath := path.Join(c.path, "run", "wshd.sock")
 
@@ -48,9 +52,14 @@ func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO
 
 	processID := c.processIDPool.Next()
 
-	pidfile := path.Join(c.path, "processes", fmt.Sprintf("%d.pid", processID))
+	pidfile := c.processFile(processID, "pid")
 	args = append(args, "--pidfile", pidfile)
 
+	// for the process to be re-attached to once restored from a checkpoint
+	if c.criuPath != "" && spec.TTY == nil {
+		args = append(args, "--pipesfile", c.processFile(processID, "pipes"))
+	}
+
 	signaller := &linux_backend.NamespacedSignaller{
 		Runner:        c.runner,
 		ContainerPath: c.path,
@@ -63,7 +72,43 @@ func (c *LinuxContainer) Run(spec garden.ProcessSpec, processIO garden.ProcessIO
 
 	setRLimitsEnv(wsh, spec.Limits)
 
-	return c.processTracker.Run(processID, wsh, processIO, spec.TTY, signaller)
+	process, err := c.processTracker.Run(processID, wsh, processIO, spec.TTY, signaller)
+	if err != nil {
+		return nil, err
+	}
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{
+		Type:      linux_backend.EventProcessSpawned,
+		ProcessID: processID,
+	})
+
+	go c.awaitExit(processID, process)
+
+	return process, nil
+}
+
+// awaitExit snapshots and reports the process's exit, once it happens.
+func (c *LinuxContainer) awaitExit(processID uint32, process garden.Process) {
+	exitStatus, err := process.Wait()
+	c.notifyChanged()
+
+	// it carries on once the container is restored
+	if c.isCheckpointed(processID) {
+		return
+	}
+
+	exited := linux_backend.Event{
+		Type:      linux_backend.EventProcessExited,
+		ProcessID: processID,
+	}
+
+	if err == nil {
+		exited.ExitStatus = &exitStatus
+	}
+
+	c.emit(exited)
 }
 
 func (c *LinuxContainer) Attach(processID uint32, processIO garden.ProcessIO) (garden.Process, error) {
@@ -40,6 +40,7 @@ var _ = Describe("Linux containers", func() {
 		fakeRunner = fake_command_runner.New()
 
 		fakeProcessTracker = new(fake_process_tracker.FakeProcessTracker)
+		fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
 
 		var err error
 		containerDir, err = ioutil.TempDir("", "depot")
@@ -76,6 +77,7 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			new(networkFakes.FakeFilter),
+			"",
 		)
 	})
 
@@ -489,6 +491,74 @@ var _ = Describe("Linux containers", func() {
 				}, garden.ProcessIO{})
 				Expect(err).To(Equal(disaster))
 			})
+
+			It("does not notify of a change", func() {
+				changes := 0
+				container.OnChange(func() {
+					changes++
+				})
+
+				container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+				Expect(changes).To(Equal(0))
+			})
+		})
+
+		It("notifies of a change when the process starts and again when it exits", func() {
+			exit := make(chan struct{})
+
+			fakeProcess := new(wfakes.FakeProcess)
+			fakeProcess.WaitStub = func() (int, error) {
+				<-exit
+				return 0, nil
+			}
+
+			fakeProcessTracker.RunReturns(fakeProcess, nil)
+
+			changes := make(chan struct{}, 2)
+			container.OnChange(func() {
+				changes <- struct{}{}
+			})
+
+			_, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(changes).To(HaveLen(1))
+
+			close(exit)
+			Eventually(changes).Should(HaveLen(2))
+		})
+
+		It("emits events when the process spawns and when it exits, with its exit status", func() {
+			exit := make(chan struct{})
+
+			fakeProcess := new(wfakes.FakeProcess)
+			fakeProcess.WaitStub = func() (int, error) {
+				<-exit
+				return 42, nil
+			}
+
+			fakeProcessTracker.RunReturns(fakeProcess, nil)
+
+			events := make(chan linux_backend.Event, 2)
+			container.OnEvent(func(event linux_backend.Event) {
+				events <- event
+			})
+
+			_, err := container.Run(garden.ProcessSpec{Path: "/some/script"}, garden.ProcessIO{})
+			Expect(err).ToNot(HaveOccurred())
+
+			var spawned linux_backend.Event
+			Expect(events).To(Receive(&spawned))
+			Expect(spawned.Type).To(Equal(linux_backend.EventProcessSpawned))
+			Expect(spawned.ProcessID).To(Equal(uint32(1)))
+
+			close(exit)
+
+			var exited linux_backend.Event
+			Eventually(events).Should(Receive(&exited))
+			Expect(exited.Type).To(Equal(linux_backend.EventProcessExited))
+			Expect(exited.ProcessID).To(Equal(uint32(1)))
+			Expect(*exited.ExitStatus).To(Equal(42))
 		})
 	})
 
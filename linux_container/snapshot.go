@@ -1,20 +1,93 @@
 package linux_container
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
 	"time"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
 )
 
+// CurrentSnapshotVersion is the version of the snapshot schema written by
+// this version of garden-linux. Bump it, and register a migration from the
+// previous version, whenever the schema changes.
+const CurrentSnapshotVersion = 4
+
+// snapshotMigrations[v] upgrades a decoded snapshot from version v to v+1.
+// Snapshots written before versioning was introduced are version 0.
+var snapshotMigrations = []SnapshotMigration{
+	0: func(snapshot map[string]interface{}) error {
+		// version 1 only introduced the Version field itself
+		return nil
+	},
+
+	1: func(snapshot map[string]interface{}) error {
+		// version 2 replaced the event strings with typed events
+		events, _ := snapshot["Events"].([]interface{})
+
+		for i, e := range events {
+			if event, ok := e.(string); ok {
+				events[i] = parseLegacyEvent(event)
+			}
+		}
+
+		return nil
+	},
+
+	2: func(snapshot map[string]interface{}) error {
+		// version 3 introduced StopGraceTime; containers stopped after the
+		// default grace time before then
+		if _, found := snapshot["StopGraceTime"]; !found {
+			snapshot["StopGraceTime"] = DefaultStopGraceTime
+		}
+
+		return nil
+	},
+
+	3: func(snapshot map[string]interface{}) error {
+		// version 4 introduced Checkpoint on processes; checkpointed
+		// containers left their processes out of the snapshot before then
+		return nil
+	},
+}
+
+type SnapshotMigration func(snapshot map[string]interface{}) error
+
+type UnsupportedSnapshotVersionError struct {
+	Version          int
+	SupportedVersion int
+}
+
+func (e UnsupportedSnapshotVersionError) Error() string {
+	return fmt.Sprintf(
+		"snapshot version %d is newer than the latest supported version %d",
+		e.Version,
+		e.SupportedVersion,
+	)
+}
+
+type InvalidSnapshotVersionError struct {
+	Version interface{}
+}
+
+func (e InvalidSnapshotVersionError) Error() string {
+	return fmt.Sprintf("snapshot version %v is not a non-negative integer", e.Version)
+}
+
 type ContainerSnapshot struct {
+	Version int
+
 	ID     string
 	Handle string
 
-	GraceTime time.Duration
+	GraceTime     time.Duration
+	StopGraceTime time.Duration
 
 	State  string
-	Events []string
+	Events []ContainerEvent
 
 	Limits LimitsSnapshot
 
@@ -31,7 +104,7 @@ type ContainerSnapshot struct {
 }
 
 type LimitsSnapshot struct {
-	Memory    *garden.MemoryLimits
+	Memory    *linux_backend.MemoryLimits
 	Disk      *garden.DiskLimits
 	Bandwidth *garden.BandwidthLimits
 	CPU       *garden.CPULimits
@@ -48,4 +121,91 @@ type ResourcesSnapshot struct {
 type ProcessSnapshot struct {
 	ID  uint32
 	TTY bool
+
+	// only set for the processes of a checkpointed container
+	Checkpoint *CheckpointedProcess
+}
+
+// CheckpointedProcess is what is needed to re-attach to a process once it is
+// restored from the container's checkpoint.
+type CheckpointedProcess struct {
+	// PID in the container, which CRIU keeps
+	Pid int
+
+	// CRIU restores the process with new pipes to the host in place of these
+	Pipes PipeInodes
+}
+
+type PipeInodes struct {
+	Stdin      uint64
+	Stdout     uint64
+	Stderr     uint64
+	ExitStatus uint64
+}
+
+// DecodeSnapshot reads a snapshot of any supported version, upgrading it
+// step by step to the current version.
+func DecodeSnapshot(r io.Reader) (ContainerSnapshot, error) {
+	var raw map[string]interface{}
+
+	// numbers are kept as written, so that the migrations do not round the
+	// ones too large for a float64
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	version, err := snapshotVersion(raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	if version > CurrentSnapshotVersion {
+		return ContainerSnapshot{}, UnsupportedSnapshotVersionError{
+			Version:          version,
+			SupportedVersion: CurrentSnapshotVersion,
+		}
+	}
+
+	for ; version < CurrentSnapshotVersion; version++ {
+		err := snapshotMigrations[version](raw)
+		if err != nil {
+			return ContainerSnapshot{}, fmt.Errorf("linux_container: migrating snapshot from version %d: %v", version, err)
+		}
+
+		raw["Version"] = version + 1
+	}
+
+	upgraded, err := json.Marshal(raw)
+	if err != nil {
+		return ContainerSnapshot{}, err
+	}
+
+	var snapshot ContainerSnapshot
+	err = json.Unmarshal(upgraded, &snapshot)
+	return snapshot, err
+}
+
+// snapshotVersion returns the version of a decoded snapshot, which is 0 if it
+// was written before versioning was introduced.
+func snapshotVersion(raw map[string]interface{}) (int, error) {
+	v, found := raw["Version"]
+	if !found || v == nil {
+		return 0, nil
+	}
+
+	number, ok := v.(json.Number)
+	if !ok {
+		return 0, InvalidSnapshotVersionError{Version: v}
+	}
+
+	version, err := strconv.ParseUint(number.String(), 10, 31)
+	if err != nil {
+		return 0, InvalidSnapshotVersionError{Version: v}
+	}
+
+	return int(version), nil
 }
@@ -0,0 +1,125 @@
+package linux_container_test
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/linux_container"
+)
+
+var _ = Describe("Decoding snapshots", func() {
+	Context("when the snapshot has no version", func() {
+		It("upgrades it to the current version", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(`{
+				"ID": "some-id",
+				"Handle": "some-handle",
+				"GraceTime": 1000000000,
+				"State": "active",
+				"Properties": {"a": "b"},
+				"Processes": [{"ID": 5, "TTY": true}]
+			}`))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+			Expect(snapshot.ID).To(Equal("some-id"))
+			Expect(snapshot.Handle).To(Equal("some-handle"))
+			Expect(snapshot.GraceTime).To(Equal(time.Second))
+			Expect(snapshot.State).To(Equal("active"))
+			Expect(snapshot.Properties).To(HaveKeyWithValue("a", "b"))
+			Expect(snapshot.Processes).To(Equal([]linux_container.ProcessSnapshot{{ID: 5, TTY: true}}))
+		})
+	})
+
+	Context("when the snapshot has events recorded as strings", func() {
+		It("converts them to typed events", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(`{
+				"Version": 1,
+				"Events": ["out of memory", "cpu limit drifted; re-applied", "foo"]
+			}`))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Events).To(Equal([]linux_container.ContainerEvent{
+				{Kind: linux_container.EventKindOutOfMemory},
+				{Kind: linux_container.EventKindLimitDrifted, Details: map[string]string{"limit": "cpu"}},
+				{Kind: linux_container.EventKindMessage, Details: map[string]string{"message": "foo"}},
+			}))
+		})
+	})
+
+	Context("when the snapshot predates the stop grace time", func() {
+		It("gives the container the default", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(`{"Version": 2}`))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.StopGraceTime).To(Equal(linux_container.DefaultStopGraceTime))
+		})
+	})
+
+	Context("when the snapshot is of the current version", func() {
+		It("decodes it", func() {
+			in := new(bytes.Buffer)
+			fmt.Fprintf(in, `{"Version": %d, "ID": "some-id"}`, linux_container.CurrentSnapshotVersion)
+
+			snapshot, err := linux_container.DecodeSnapshot(in)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+			Expect(snapshot.ID).To(Equal("some-id"))
+		})
+	})
+
+	Context("when the snapshot is of a future version", func() {
+		It("returns an UnsupportedSnapshotVersionError", func() {
+			in := new(bytes.Buffer)
+			fmt.Fprintf(in, `{"Version": %d, "ID": "some-id"}`, linux_container.CurrentSnapshotVersion+1)
+
+			_, err := linux_container.DecodeSnapshot(in)
+			Expect(err).To(Equal(linux_container.UnsupportedSnapshotVersionError{
+				Version:          linux_container.CurrentSnapshotVersion + 1,
+				SupportedVersion: linux_container.CurrentSnapshotVersion,
+			}))
+			Expect(err).To(MatchError(fmt.Sprintf(
+				"snapshot version %d is newer than the latest supported version %d",
+				linux_container.CurrentSnapshotVersion+1,
+				linux_container.CurrentSnapshotVersion,
+			)))
+		})
+	})
+
+	Context("when the snapshot version is not a non-negative integer", func() {
+		It("returns an InvalidSnapshotVersionError", func() {
+			for _, version := range []string{`-1`, `1.5`, `"3"`, `true`} {
+				_, err := linux_container.DecodeSnapshot(strings.NewReader(`{"Version": ` + version + `}`))
+				Expect(err).To(BeAssignableToTypeOf(linux_container.InvalidSnapshotVersionError{}), version)
+			}
+		})
+	})
+
+	Context("when the snapshot has integers too large for a float64", func() {
+		It("keeps them exact through the migrations", func() {
+			snapshot, err := linux_container.DecodeSnapshot(strings.NewReader(`{
+				"Limits": {
+					"Memory": {"limit_in_bytes": 18446744073709551615},
+					"Disk": {"byte_hard": 9007199254740993}
+				}
+			}`))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(snapshot.Limits.Memory.LimitInBytes).To(Equal(uint64(math.MaxUint64)))
+			Expect(snapshot.Limits.Disk.ByteHard).To(Equal(uint64(9007199254740993)))
+		})
+	})
+
+	Context("when the snapshot is not valid JSON", func() {
+		It("returns an error", func() {
+			_, err := linux_container.DecodeSnapshot(strings.NewReader("{"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -73,6 +76,11 @@ var _ = Describe("Linux containers", func() {
 		containerDir, err = ioutil.TempDir("", "depot")
 		Expect(err).ToNot(HaveOccurred())
 
+		err = os.Mkdir(filepath.Join(containerDir, "run"), 0755)
+		Expect(err).ToNot(HaveOccurred())
+		err = ioutil.WriteFile(filepath.Join(containerDir, "run", "wshd.pid"), []byte("12345\n"), 0644)
+		Expect(err).ToNot(HaveOccurred())
+
 		_, subnet, err := net.ParseCIDR("2.3.4.0/30")
 		containerResources = linux_backend.NewResources(
 			1234,
@@ -108,12 +116,18 @@ var _ = Describe("Linux containers", func() {
 			fakeProcessTracker,
 			process.Env{"env1": "env1Value", "env2": "env2Value"},
 			fakeFilter,
+			"",
 		)
 	})
 
 	Describe("Snapshotting", func() {
-		memoryLimits := garden.MemoryLimits{
-			LimitInBytes: 1,
+		swappiness := uint64(10)
+
+		memoryLimits := linux_backend.MemoryLimits{
+			LimitInBytes:     2,
+			SoftLimitInBytes: 1,
+			SwapInBytes:      5,
+			Swappiness:       &swappiness,
 		}
 
 		diskLimits := garden.DiskLimits{
@@ -174,10 +188,13 @@ var _ = Describe("Linux containers", func() {
 			err = json.NewDecoder(out).Decode(&snapshot)
 			Expect(err).ToNot(HaveOccurred())
 
+			Expect(snapshot.Version).To(Equal(linux_container.CurrentSnapshotVersion))
+
 			Expect(snapshot.ID).To(Equal("some-id"))
 			Expect(snapshot.Handle).To(Equal("some-handle"))
 
 			Expect(snapshot.GraceTime).To(Equal(1 * time.Second))
+			Expect(snapshot.StopGraceTime).To(Equal(linux_container.DefaultStopGraceTime))
 
 			Expect(snapshot.State).To(Equal("active"))
 
@@ -239,7 +256,7 @@ var _ = Describe("Linux containers", func() {
 
 		Context("with limits set", func() {
 			JustBeforeEach(func() {
-				err := container.LimitMemory(memoryLimits)
+				err := container.SetMemoryLimits(memoryLimits)
 				Expect(err).ToNot(HaveOccurred())
 
 				// oom exits immediately since it's faked out; should see event,
@@ -269,7 +286,9 @@ var _ = Describe("Linux containers", func() {
 				Expect(err).ToNot(HaveOccurred())
 
 				Expect(snapshot.State).To(Equal("stopped"))
-				Expect(snapshot.Events).To(Equal([]string{"out of memory"}))
+				Expect(snapshot.Events).To(HaveLen(1))
+				Expect(snapshot.Events[0].Kind).To(Equal(linux_container.EventKindOutOfMemory))
+				Expect(snapshot.Events[0].Time).ToNot(BeZero())
 
 				Expect(snapshot.Limits).To(Equal(
 					linux_container.LimitsSnapshot{
@@ -308,10 +327,30 @@ var _ = Describe("Linux containers", func() {
 	})
 
 	Describe("Restoring", func() {
+		BeforeEach(func() {
+			restored := new(wfakes.FakeProcess)
+			restored.WaitStub = func() (int, error) {
+				// still running
+				select {}
+			}
+
+			fakeProcessTracker.RestoreReturns(restored)
+		})
+
 		It("sets the container's state and events", func() {
+			oom := linux_container.ContainerEvent{
+				Kind: linux_container.EventKindOutOfMemory,
+				Time: time.Unix(1000, 0),
+			}
+
+			foo := linux_container.ContainerEvent{
+				Kind:    linux_container.EventKindMessage,
+				Details: map[string]string{"message": "foo"},
+			}
+
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{"out of memory", "foo"},
+				Events: []linux_container.ContainerEvent{oom, foo},
 			})
 			Expect(err).ToNot(HaveOccurred())
 
@@ -320,13 +359,34 @@ var _ = Describe("Linux containers", func() {
 				"out of memory",
 				"foo",
 			}))
+			Expect(container.EventHistory()).To(Equal([]linux_container.ContainerEvent{oom, foo}))
+		})
+
+		It("keeps only the most recent events", func() {
+			events := []linux_container.ContainerEvent{}
+			for i := 0; i < linux_container.EventHistorySize+10; i++ {
+				events = append(events, linux_container.ContainerEvent{
+					Kind:    linux_container.EventKindMessage,
+					Details: map[string]string{"message": fmt.Sprintf("event-%d", i)},
+				})
+			}
+
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: events,
+			})
+			Expect(err).ToNot(HaveOccurred())
 
+			restored := container.Events()
+			Expect(restored).To(HaveLen(linux_container.EventHistorySize))
+			Expect(restored[0]).To(Equal("event-10"))
+			Expect(restored[linux_container.EventHistorySize-1]).To(Equal(fmt.Sprintf("event-%d", linux_container.EventHistorySize+9)))
 		})
 
 		It("restores process state", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.ContainerEvent{},
 
 				Processes: []linux_container.ProcessSnapshot{
 					{
@@ -348,10 +408,51 @@ var _ = Describe("Linux containers", func() {
 			Expect(pid).To(Equal(uint32(1)))
 		})
 
+		It("snapshots and reports the exit of a restored process", func() {
+			exited := make(chan struct{})
+			restored := new(wfakes.FakeProcess)
+			restored.WaitStub = func() (int, error) {
+				<-exited
+				return 42, nil
+			}
+
+			fakeProcessTracker.RestoreReturns(restored)
+
+			changed := make(chan struct{}, 1)
+			container.OnChange(func() {
+				changed <- struct{}{}
+			})
+
+			reported := make(chan linux_backend.Event, 1)
+			container.OnEvent(func(event linux_backend.Event) {
+				reported <- event
+			})
+
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State: "active",
+				Processes: []linux_container.ProcessSnapshot{
+					{ID: 3},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Consistently(changed).ShouldNot(Receive())
+
+			close(exited)
+
+			Eventually(changed).Should(Receive())
+
+			var event linux_backend.Event
+			Eventually(reported).Should(Receive(&event))
+			Expect(event.Type).To(Equal(linux_backend.EventProcessExited))
+			Expect(event.ProcessID).To(Equal(uint32(3)))
+			Expect(*event.ExitStatus).To(Equal(42))
+		})
+
 		It("makes the next process ID be higher than the highest restored ID", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.ContainerEvent{},
 
 				Processes: []linux_container.ProcessSnapshot{
 					{
@@ -366,6 +467,8 @@ var _ = Describe("Linux containers", func() {
 			})
 			Expect(err).ToNot(HaveOccurred())
 
+			fakeProcessTracker.RunReturns(new(wfakes.FakeProcess), nil)
+
 			_, err = container.Run(garden.ProcessSpec{
 				Path: "/some/script",
 			}, garden.ProcessIO{})
@@ -379,7 +482,7 @@ var _ = Describe("Linux containers", func() {
 		It("configures a signaller with the correct pidfile for the process", func() {
 			Expect(container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.ContainerEvent{},
 
 				Processes: []linux_container.ProcessSnapshot{
 					{
@@ -397,6 +500,15 @@ var _ = Describe("Linux containers", func() {
 			}))
 		})
 
+		It("restores the stop grace time", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				StopGraceTime: 30 * time.Second,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.StopGraceTime()).To(Equal(30 * time.Second))
+		})
+
 		It("restores environment variables", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				EnvVars: []string{"env1=env1value", "env2=env2Value"},
@@ -430,7 +542,7 @@ var _ = Describe("Linux containers", func() {
 		It("redoes network setup and net-ins", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.ContainerEvent{},
 
 				NetIns: []linux_container.NetInSpec{
 					{
@@ -461,6 +573,56 @@ var _ = Describe("Linux containers", func() {
 			))
 		})
 
+		Context("when the container was paused", func() {
+			It("redoes its net-ins without notifying anyone", func() {
+				changes := 0
+				container.OnChange(func() {
+					changes++
+				})
+
+				events := []linux_backend.Event{}
+				container.OnEvent(func(event linux_backend.Event) {
+					events = append(events, event)
+				})
+
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "paused",
+					Events: []linux_container.ContainerEvent{},
+
+					NetIns: []linux_container.NetInSpec{
+						{
+							HostPort:      1234,
+							ContainerPort: 5678,
+						},
+					},
+
+					NetOuts: []garden.NetOutRule{netOutRule1},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeRunner).To(HaveExecutedSerially(
+					fake_command_runner.CommandSpec{
+						Path: containerDir + "/net.sh",
+						Args: []string{"in"},
+						Env:  []string{"HOST_PORT=1234", "CONTAINER_PORT=5678", "PATH=" + os.Getenv("PATH")},
+					},
+				))
+
+				out := new(bytes.Buffer)
+				Expect(container.Snapshot(out)).To(Succeed())
+
+				var snapshot linux_container.ContainerSnapshot
+				Expect(json.NewDecoder(out).Decode(&snapshot)).To(Succeed())
+
+				Expect(snapshot.State).To(Equal("paused"))
+				Expect(snapshot.NetIns).To(Equal([]linux_container.NetInSpec{{HostPort: 1234, ContainerPort: 5678}}))
+				Expect(snapshot.NetOuts).To(Equal([]garden.NetOutRule{netOutRule1}))
+
+				Expect(changes).To(BeZero())
+				Expect(events).To(BeEmpty())
+			})
+		})
+
 		for _, cmd := range []string{"setup", "in"} {
 			command := cmd
 
@@ -481,7 +643,7 @@ var _ = Describe("Linux containers", func() {
 				It("returns the error", func() {
 					err := container.Restore(linux_container.ContainerSnapshot{
 						State:  "active",
-						Events: []string{},
+						Events: []linux_container.ContainerEvent{},
 
 						NetIns: []linux_container.NetInSpec{
 							{
@@ -504,10 +666,10 @@ var _ = Describe("Linux containers", func() {
 		It("re-enforces the memory limit", func() {
 			err := container.Restore(linux_container.ContainerSnapshot{
 				State:  "active",
-				Events: []string{},
+				Events: []linux_container.ContainerEvent{},
 
 				Limits: linux_container.LimitsSnapshot{
-					Memory: &garden.MemoryLimits{
+					Memory: &linux_backend.MemoryLimits{
 						LimitInBytes: 1024,
 					},
 				},
@@ -534,11 +696,327 @@ var _ = Describe("Linux containers", func() {
 			Eventually(container.Events).Should(ContainElement("out of memory"))
 		})
 
+		It("records an event for the drifted memory limit", func() {
+			err := container.Restore(linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []linux_container.ContainerEvent{},
+
+				Limits: linux_container.LimitsSnapshot{
+					Memory: &linux_backend.MemoryLimits{
+						LimitInBytes: 1024,
+					},
+				},
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(container.Events()).To(ContainElement("memory limit drifted; re-applied"))
+		})
+
+		Context("when the memory limit is still enforced", func() {
+			BeforeEach(func() {
+				fakeCgroups.WhenGetting("memory", "memory.limit_in_bytes", func() (string, error) {
+					return "1024", nil
+				})
+
+				fakeCgroups.WhenGetting("memory", "memory.soft_limit_in_bytes", func() (string, error) {
+					return "9223372036854771712", nil
+				})
+
+				fakeCgroups.WhenGetting("memory", "memory.memsw.limit_in_bytes", func() (string, error) {
+					return "1024", nil
+				})
+
+				fakeCgroups.WhenGetting("memory", "memory.swappiness", func() (string, error) {
+					return "60", nil
+				})
+			})
+
+			It("does not re-apply it, but still reports it and watches for oom", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []linux_container.ContainerEvent{},
+
+					Limits: linux_container.LimitsSnapshot{
+						Memory: &linux_backend.MemoryLimits{
+							LimitInBytes: 1024,
+						},
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+				Expect(container.Events()).ToNot(ContainElement("memory limit drifted; re-applied"))
+
+				snapshot := new(bytes.Buffer)
+				Expect(container.Snapshot(snapshot)).To(Succeed())
+
+				var restored linux_container.ContainerSnapshot
+				Expect(json.NewDecoder(snapshot).Decode(&restored)).To(Succeed())
+				Expect(restored.Limits.Memory).To(Equal(&linux_backend.MemoryLimits{LimitInBytes: 1024}))
+
+				Eventually(container.Events).Should(ContainElement("out of memory"))
+			})
+
+			It("does not re-apply it when the kernel rounded it to a page", func() {
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []linux_container.ContainerEvent{},
+
+					Limits: linux_container.LimitsSnapshot{
+						Memory: &linux_backend.MemoryLimits{
+							LimitInBytes: 1000,
+						},
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(BeEmpty())
+				Expect(container.Events()).ToNot(ContainElement("memory limit drifted; re-applied"))
+			})
+
+			It("re-applies it if the swappiness has drifted", func() {
+				swappiness := uint64(10)
+
+				err := container.Restore(linux_container.ContainerSnapshot{
+					State:  "active",
+					Events: []linux_container.ContainerEvent{},
+
+					Limits: linux_container.LimitsSnapshot{
+						Memory: &linux_backend.MemoryLimits{
+							LimitInBytes: 1024,
+							Swappiness:   &swappiness,
+						},
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeCgroups.SetValues()).To(ContainElement(
+					fake_cgroups_manager.SetValue{
+						Subsystem: "memory",
+						Name:      "memory.swappiness",
+						Value:     "10",
+					},
+				))
+
+				Expect(container.Events()).To(ContainElement("memory limit drifted; re-applied"))
+			})
+		})
+
+		Describe("cpu limits", func() {
+			snapshot := linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []linux_container.ContainerEvent{},
+
+				Limits: linux_container.LimitsSnapshot{
+					CPU: &garden.CPULimits{
+						LimitInShares: 512,
+					},
+				},
+			}
+
+			Context("when the cgroup has drifted", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenGetting("cpu", "cpu.shares", func() (string, error) {
+						return "1024", nil
+					})
+				})
+
+				It("re-applies the limit and records an event", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeCgroups.SetValues()).To(ContainElement(
+						fake_cgroups_manager.SetValue{
+							Subsystem: "cpu",
+							Name:      "cpu.shares",
+							Value:     "512",
+						},
+					))
+
+					Expect(container.Events()).To(ContainElement("cpu limit drifted; re-applied"))
+				})
+
+				Context("and re-applying it fails", func() {
+					disaster := errors.New("oh no!")
+
+					JustBeforeEach(func() {
+						fakeCgroups.WhenSetting("cpu", "cpu.shares", func() error {
+							return disaster
+						})
+					})
+
+					It("returns the error", func() {
+						Expect(container.Restore(snapshot)).To(Equal(disaster))
+					})
+				})
+			})
+
+			Context("when the cgroup still matches", func() {
+				BeforeEach(func() {
+					fakeCgroups.WhenGetting("cpu", "cpu.shares", func() (string, error) {
+						return "512", nil
+					})
+				})
+
+				It("leaves it alone", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeCgroups.SetValues()).To(BeEmpty())
+					Expect(container.Events()).To(BeEmpty())
+				})
+			})
+		})
+
+		Describe("disk limits", func() {
+			limits := garden.DiskLimits{
+				ByteSoft:  2048,
+				ByteHard:  4096,
+				InodeSoft: 10,
+				InodeHard: 20,
+			}
+
+			snapshot := linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []linux_container.ContainerEvent{},
+
+				Limits: linux_container.LimitsSnapshot{
+					Disk: &limits,
+				},
+			}
+
+			Context("when the quota has drifted", func() {
+				BeforeEach(func() {
+					fakeQuotaManager.GetLimitsResult = garden.DiskLimits{
+						BlockSoft: 1,
+						BlockHard: 2,
+					}
+				})
+
+				It("re-applies the limit and records an event", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeQuotaManager.Limited).To(HaveKeyWithValue(containerResources.UserUID, limits))
+					Expect(container.Events()).To(ContainElement("disk limit drifted; re-applied"))
+				})
+
+				Context("and re-applying it fails", func() {
+					disaster := errors.New("oh no!")
+
+					BeforeEach(func() {
+						fakeQuotaManager.SetLimitsError = disaster
+					})
+
+					It("returns the error", func() {
+						Expect(container.Restore(snapshot)).To(Equal(disaster))
+					})
+				})
+			})
+
+			Context("when the quota still matches", func() {
+				BeforeEach(func() {
+					fakeQuotaManager.GetLimitsResult = garden.DiskLimits{
+						BlockSoft: 2,
+						BlockHard: 4,
+						InodeSoft: 10,
+						InodeHard: 20,
+					}
+				})
+
+				It("leaves it alone", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeQuotaManager.Limited).To(BeEmpty())
+					Expect(container.Events()).To(BeEmpty())
+				})
+			})
+
+			Context("when quotas are disabled", func() {
+				BeforeEach(func() {
+					fakeQuotaManager.Disable()
+				})
+
+				It("does not report drift", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeQuotaManager.Limited).To(BeEmpty())
+					Expect(container.Events()).To(BeEmpty())
+				})
+			})
+		})
+
+		Describe("bandwidth limits", func() {
+			limits := garden.BandwidthLimits{
+				RateInBytesPerSecond:      128,
+				BurstRateInBytesPerSecond: 256,
+			}
+
+			snapshot := linux_container.ContainerSnapshot{
+				State:  "active",
+				Events: []linux_container.ContainerEvent{},
+
+				Limits: linux_container.LimitsSnapshot{
+					Bandwidth: &limits,
+				},
+			}
+
+			Context("when the shaping has drifted", func() {
+				It("re-applies the limit and records an event", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeBandwidthManager.EnforcedLimits).To(ConsistOf(limits))
+					Expect(container.Events()).To(ContainElement("bandwidth limit drifted; re-applied"))
+					Expect(container.CurrentBandwidthLimits()).To(Equal(limits))
+				})
+
+				Context("and re-applying it fails", func() {
+					disaster := errors.New("oh no!")
+
+					BeforeEach(func() {
+						fakeBandwidthManager.SetLimitsError = disaster
+					})
+
+					It("returns the error", func() {
+						Expect(container.Restore(snapshot)).To(Equal(disaster))
+					})
+				})
+			})
+
+			Context("when the live limits cannot be read", func() {
+				BeforeEach(func() {
+					fakeBandwidthManager.GetLimitsError = errors.New("no tc")
+				})
+
+				It("re-applies the limit", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeBandwidthManager.EnforcedLimits).To(ConsistOf(limits))
+				})
+			})
+
+			Context("when the shaping still matches", func() {
+				BeforeEach(func() {
+					fakeBandwidthManager.GetLimitsResult = garden.ContainerBandwidthStat{
+						InRate:   128,
+						InBurst:  256,
+						OutRate:  128,
+						OutBurst: 256,
+					}
+				})
+
+				It("leaves it alone, but reports it as current", func() {
+					Expect(container.Restore(snapshot)).To(Succeed())
+
+					Expect(fakeBandwidthManager.EnforcedLimits).To(BeEmpty())
+					Expect(container.Events()).To(BeEmpty())
+					Expect(container.CurrentBandwidthLimits()).To(Equal(limits))
+				})
+			})
+		})
+
 		Context("when no memory limit is present", func() {
 			It("does not set a limit", func() {
 				err := container.Restore(linux_container.ContainerSnapshot{
 					State:  "active",
-					Events: []string{},
+					Events: []linux_container.ContainerEvent{},
 				})
 				Expect(err).ToNot(HaveOccurred())
 
@@ -558,10 +1036,10 @@ var _ = Describe("Linux containers", func() {
 			It("returns the error", func() {
 				err := container.Restore(linux_container.ContainerSnapshot{
 					State:  "active",
-					Events: []string{},
+					Events: []linux_container.ContainerEvent{},
 
 					Limits: linux_container.LimitsSnapshot{
-						Memory: &garden.MemoryLimits{
+						Memory: &linux_backend.MemoryLimits{
 							LimitInBytes: 1024,
 						},
 					},
@@ -0,0 +1,212 @@
+package linux_container
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/pivotal-golang/lager"
+)
+
+// DefaultStopGraceTime is how long Stop waits for the container's processes
+// to exit after SIGTERM before killing them, unless the container was created
+// with StopGraceTimeProperty.
+const DefaultStopGraceTime = 10 * time.Second
+
+// StopGraceTimeProperty, given as a duration such as "30s", overrides
+// DefaultStopGraceTime for the container. It is only read when the container
+// is created.
+const StopGraceTimeProperty = "garden.stop-grace-time"
+
+// ParseStopGraceTime returns the stop grace time the properties ask for, or
+// DefaultStopGraceTime if they do not.
+func ParseStopGraceTime(properties garden.Properties) (time.Duration, error) {
+	value, found := properties[StopGraceTimeProperty]
+	if !found {
+		return DefaultStopGraceTime, nil
+	}
+
+	graceTime, err := time.ParseDuration(value)
+	if err != nil || graceTime < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a duration of at least 0s", StopGraceTimeProperty, value)
+	}
+
+	return graceTime, nil
+}
+
+func (c *LinuxContainer) StopGraceTime() time.Duration {
+	return c.stopGraceTime
+}
+
+// Stop sends SIGTERM to the container's processes and, once they have exited
+// or the stop grace time has passed, SIGKILL to those still running, followed
+// by every other task left in the container (apart from wshd itself). With
+// kill, SIGTERM is skipped. Only the processes that outlived the grace time
+// are reported as force-killed.
+func (c *LinuxContainer) Stop(kill bool) error {
+	cLog := c.logger.Session("stop", lager.Data{
+		"kill": kill,
+	})
+
+	cLog.Debug("stopping")
+
+	wshdPID, err := c.wshdPID()
+	if err != nil {
+		cLog.Error("failed-to-read-wshd-pid", err)
+		return fmt.Errorf("container: stop: %v", err)
+	}
+
+	remaining := c.processTracker.ActiveProcesses()
+
+	// the processes are signalled through wshd, which is frozen with the
+	// rest of a paused container; if it cannot be thawed, they are left to
+	// be killed through the cgroup
+	if c.State() == StatePaused {
+		err := c.thaw()
+		if err != nil {
+			cLog.Error("failed-to-thaw", err)
+			return c.finishStop(cLog, wshdPID, []uint32{})
+		}
+	}
+
+	forceKilled := []uint32{}
+
+	if !kill && c.stopGraceTime > 0 {
+		for _, p := range remaining {
+			err := p.Signal(garden.SignalTerminate)
+			if err != nil {
+				cLog.Error("failed-to-terminate-process", err, lager.Data{"process": p.ID()})
+			}
+		}
+
+		remaining = waitForExit(remaining, c.stopGraceTime)
+
+		// the rest were never given the chance to exit
+		forceKilled = processIDs(remaining)
+	}
+
+	for _, p := range remaining {
+		err := p.Signal(garden.SignalKill)
+		if err != nil {
+			cLog.Error("failed-to-kill-process", err, lager.Data{"process": p.ID()})
+		}
+	}
+
+	return c.finishStop(cLog, wshdPID, forceKilled)
+}
+
+func (c *LinuxContainer) finishStop(cLog lager.Logger, wshdPID int, forceKilled []uint32) error {
+	// processes that left their group, or were never tracked, are only found
+	// in the cgroup
+	err := c.killProcesses(cLog, wshdPID)
+	if err != nil {
+		cLog.Error("failed-to-kill-tasks", err)
+		return fmt.Errorf("container: stop: %v", err)
+	}
+
+	c.stopOomNotifier()
+
+	c.setState(StateStopped)
+
+	if len(forceKilled) > 0 {
+		cLog.Info("force-killed", lager.Data{"processes": forceKilled})
+		c.registerEvent(EventKindForceKilled, map[string]string{"process_ids": joinIDs(forceKilled)})
+	}
+
+	c.notifyChanged()
+
+	c.emit(linux_backend.Event{
+		Type:                  linux_backend.EventStopped,
+		ForceKilledProcessIDs: forceKilled,
+	})
+
+	cLog.Info("stopped")
+
+	return nil
+}
+
+// waitForExit waits up to the timeout for the processes to exit, and returns
+// those that have not.
+func waitForExit(processes []garden.Process, timeout time.Duration) []garden.Process {
+	exited := make([]bool, len(processes))
+	exitedMutex := &sync.Mutex{}
+
+	wg := &sync.WaitGroup{}
+	for i, p := range processes {
+		wg.Add(1)
+
+		go func(i int, p garden.Process) {
+			defer wg.Done()
+
+			p.Wait()
+
+			exitedMutex.Lock()
+			exited[i] = true
+			exitedMutex.Unlock()
+		}(i, p)
+	}
+
+	allExited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allExited)
+	}()
+
+	select {
+	case <-allExited:
+	case <-time.After(timeout):
+	}
+
+	exitedMutex.Lock()
+	defer exitedMutex.Unlock()
+
+	remaining := []garden.Process{}
+	for i, p := range processes {
+		if !exited[i] {
+			remaining = append(remaining, p)
+		}
+	}
+
+	return remaining
+}
+
+func (c *LinuxContainer) wshdPID() (int, error) {
+	pidFile, err := os.Open(path.Join(c.path, "run", "wshd.pid"))
+	if err != nil {
+		return 0, err
+	}
+
+	defer pidFile.Close()
+
+	var pid int
+	_, err = fmt.Fscanf(pidFile, "%d", &pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return pid, nil
+}
+
+func processIDs(processes []garden.Process) []uint32 {
+	ids := []uint32{}
+	for _, p := range processes {
+		ids = append(ids, p.ID())
+	}
+
+	return ids
+}
+
+func joinIDs(ids []uint32) string {
+	strs := []string{}
+	for _, id := range ids {
+		strs = append(strs, strconv.FormatUint(uint64(id), 10))
+	}
+
+	return strings.Join(strs, ",")
+}
@@ -0,0 +1,88 @@
+package metrics_exporter
+
+import (
+	"sort"
+	"sync"
+)
+
+// Counter counts daemon-level occurrences, e.g. failures, to be exported as
+// an OpenMetrics counter. The counts may be split by the value of a label.
+type Counter struct {
+	name  string
+	help  string
+	label string
+
+	mutex  *sync.Mutex
+	counts map[string]uint64
+}
+
+// NewCounter returns a counter split by the given label, or not split if it
+// is empty.
+func NewCounter(name, help, label string) *Counter {
+	return &Counter{
+		name:  name,
+		help:  help,
+		label: label,
+
+		mutex:  new(sync.Mutex),
+		counts: map[string]uint64{},
+	}
+}
+
+// Increment counts one occurrence with the given value for the label; the
+// value is ignored if the counter is not split.
+func (c *Counter) Increment(labelValue string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.label == "" {
+		labelValue = ""
+	}
+
+	c.counts[labelValue]++
+}
+
+// Count returns the occurrences counted with the given value for the label.
+func (c *Counter) Count(labelValue string) uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.label == "" {
+		labelValue = ""
+	}
+
+	return c.counts[labelValue]
+}
+
+func counterFamily(c *Counter) family {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	f := family{
+		name: c.name,
+		typ:  "counter",
+		help: c.help,
+	}
+
+	if c.label == "" {
+		f.samples = []sample{{suffix: "_total", value: float64(c.counts[""])}}
+		return f
+	}
+
+	values := []string{}
+	for value := range c.counts {
+		values = append(values, value)
+	}
+
+	sort.Strings(values)
+
+	for _, value := range values {
+		f.samples = append(f.samples, sample{
+			suffix: "_total",
+			labels: []label{{c.label, value}},
+			value:  float64(c.counts[value]),
+		})
+	}
+
+	return f
+}
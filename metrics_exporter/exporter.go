@@ -0,0 +1,308 @@
+package metrics_exporter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/pivotal-golang/lager"
+)
+
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// PropertyLabelPrefix is prepended to the (sanitized) name of each property
+// that containers are labeled with, so that it cannot clash with the handle.
+const PropertyLabelPrefix = "property_"
+
+// ContainerSource is the part of the backend that containers and their
+// metrics are collected from.
+type ContainerSource interface {
+	Containers(garden.Properties) ([]garden.Container, error)
+	BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error)
+}
+
+// Gauge is a daemon-level value, read every time the metrics are exported.
+type Gauge struct {
+	Name  string
+	Help  string
+	Value func() float64
+}
+
+// Exporter serves the metrics of every container, along with the given
+// gauges, counters and histograms, in the OpenMetrics text format.
+type Exporter struct {
+	logger lager.Logger
+
+	containers      ContainerSource
+	labelProperties []string
+
+	gauges     []Gauge
+	counters   []*Counter
+	histograms []*Histogram
+}
+
+func New(logger lager.Logger, containers ContainerSource, labelProperties []string, gauges []Gauge, counters []*Counter, histograms []*Histogram) *Exporter {
+	return &Exporter{
+		logger: logger.Session("metrics-exporter"),
+
+		containers:      containers,
+		labelProperties: labelProperties,
+
+		gauges:     gauges,
+		counters:   counters,
+		histograms: histograms,
+	}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buf := new(bytes.Buffer)
+
+	if err := e.WriteMetrics(buf); err != nil {
+		e.logger.Error("failed-to-collect-metrics", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	buf.WriteTo(w)
+}
+
+// WriteMetrics writes a complete exposition, terminated by "# EOF".
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	families, err := e.containerFamilies()
+	if err != nil {
+		return err
+	}
+
+	for _, gauge := range e.gauges {
+		families = append(families, family{
+			name:    gauge.Name,
+			typ:     "gauge",
+			help:    gauge.Help,
+			samples: []sample{{value: gauge.Value()}},
+		})
+	}
+
+	for _, counter := range e.counters {
+		families = append(families, counterFamily(counter))
+	}
+
+	for _, histogram := range e.histograms {
+		families = append(families, histogramFamily(histogram))
+	}
+
+	out := bufio.NewWriter(w)
+
+	for _, f := range families {
+		f.write(out)
+	}
+
+	fmt.Fprintln(out, "# EOF")
+
+	return out.Flush()
+}
+
+func (e *Exporter) containerFamilies() ([]family, error) {
+	containers, err := e.containers.Containers(nil)
+	if err != nil {
+		return nil, fmt.Errorf("metrics_exporter: listing containers: %v", err)
+	}
+
+	sort.Sort(byHandle(containers))
+
+	handles := make([]string, len(containers))
+	for i, container := range containers {
+		handles[i] = container.Handle()
+	}
+
+	entries, err := e.containers.BulkMetrics(handles)
+	if err != nil {
+		return nil, fmt.Errorf("metrics_exporter: collecting container metrics: %v", err)
+	}
+
+	families := []family{
+		{name: "garden_container_memory_rss_bytes", typ: "gauge", help: "Resident set size of the container's processes."},
+		{name: "garden_container_memory_cache_bytes", typ: "gauge", help: "Page cache used by the container's processes."},
+		{name: "garden_container_memory_swap_bytes", typ: "gauge", help: "Swap used by the container's processes."},
+		{name: "garden_container_cpu_usage_seconds", typ: "counter", help: "CPU time consumed by the container's processes."},
+		{name: "garden_container_disk_used_bytes", typ: "gauge", help: "Disk space used by the container."},
+		{name: "garden_container_disk_used_inodes", typ: "gauge", help: "Inodes used by the container."},
+	}
+
+	for _, container := range containers {
+		handle := container.Handle()
+
+		entry, found := entries[handle]
+		if !found {
+			continue
+		}
+
+		if entry.Err != nil {
+			e.logger.Error("failed-to-collect-container-metrics", entry.Err, lager.Data{
+				"handle": handle,
+			})
+
+			continue
+		}
+
+		labels := e.containerLabels(container)
+		metrics := entry.Metrics
+
+		values := []float64{
+			float64(metrics.MemoryStat.TotalRss),
+			float64(metrics.MemoryStat.TotalCache),
+			float64(metrics.MemoryStat.TotalSwap),
+			float64(metrics.CPUStat.Usage) / 1e9,
+			float64(metrics.DiskStat.BytesUsed),
+			float64(metrics.DiskStat.InodesUsed),
+		}
+
+		for i, value := range values {
+			s := sample{labels: labels, value: value}
+			if families[i].typ == "counter" {
+				s.suffix = "_total"
+			}
+
+			families[i].samples = append(families[i].samples, s)
+		}
+	}
+
+	return families, nil
+}
+
+func (e *Exporter) containerLabels(container garden.Container) []label {
+	labels := []label{{"handle", container.Handle()}}
+
+	if len(e.labelProperties) == 0 {
+		return labels
+	}
+
+	properties, err := container.GetProperties()
+	if err != nil {
+		e.logger.Error("failed-to-get-properties", err, lager.Data{
+			"handle": container.Handle(),
+		})
+
+		return labels
+	}
+
+	for _, property := range e.labelProperties {
+		value, found := properties[property]
+		if !found {
+			continue
+		}
+
+		labels = append(labels, label{PropertyLabelPrefix + sanitizeLabelName(property), value})
+	}
+
+	return labels
+}
+
+func histogramFamily(h *Histogram) family {
+	snapshot := h.snapshot()
+
+	f := family{
+		name: h.name,
+		typ:  "histogram",
+		help: h.help,
+	}
+
+	for i, bound := range snapshot.buckets {
+		f.samples = append(f.samples, sample{
+			suffix: "_bucket",
+			labels: []label{{"le", formatFloat(bound)}},
+			value:  float64(snapshot.counts[i]),
+		})
+	}
+
+	f.samples = append(f.samples,
+		sample{suffix: "_bucket", labels: []label{{"le", "+Inf"}}, value: float64(snapshot.count)},
+		sample{suffix: "_sum", value: snapshot.sum},
+		sample{suffix: "_count", value: float64(snapshot.count)},
+	)
+
+	return f
+}
+
+type family struct {
+	name    string
+	typ     string
+	help    string
+	samples []sample
+}
+
+type sample struct {
+	suffix string
+	labels []label
+	value  float64
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+func (f family) write(w io.Writer) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escape(f.help))
+
+	for _, s := range f.samples {
+		fmt.Fprint(w, f.name, s.suffix)
+
+		if len(s.labels) > 0 {
+			pairs := make([]string, len(s.labels))
+			for i, l := range s.labels {
+				pairs[i] = fmt.Sprintf("%s=\"%s\"", l.name, escape(l.value))
+			}
+
+			fmt.Fprintf(w, "{%s}", strings.Join(pairs, ","))
+		}
+
+		fmt.Fprintf(w, " %s\n", formatFloat(s.value))
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// sanitizeLabelName replaces the characters that may not appear in a label
+// name with underscores.
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+type byHandle []garden.Container
+
+func (s byHandle) Len() int           { return len(s) }
+func (s byHandle) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byHandle) Less(i, j int) bool { return s[i].Handle() < s[j].Handle() }
@@ -0,0 +1,203 @@
+package metrics_exporter_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden"
+	"github.com/cloudfoundry-incubator/garden-linux/metrics_exporter"
+	"github.com/cloudfoundry-incubator/garden/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("Exporter", func() {
+	var fakeBackend *fakes.FakeBackend
+	var labelProperties []string
+	var gauges []metrics_exporter.Gauge
+	var counters []*metrics_exporter.Counter
+	var histograms []*metrics_exporter.Histogram
+
+	var exporter *metrics_exporter.Exporter
+
+	container := func(handle string, properties garden.Properties) garden.Container {
+		c := new(fakes.FakeContainer)
+		c.HandleReturns(handle)
+		c.GetPropertiesReturns(properties, nil)
+		return c
+	}
+
+	export := func() string {
+		buf := new(bytes.Buffer)
+		Expect(exporter.WriteMetrics(buf)).To(Succeed())
+		return buf.String()
+	}
+
+	BeforeEach(func() {
+		fakeBackend = new(fakes.FakeBackend)
+
+		fakeBackend.ContainersReturns([]garden.Container{
+			container("handle-b", garden.Properties{"tenant": "blue"}),
+			container("handle-a", garden.Properties{"tenant": "green", "app.name": `say "hi"`}),
+		}, nil)
+
+		fakeBackend.BulkMetricsReturns(map[string]garden.ContainerMetricsEntry{
+			"handle-a": {
+				Metrics: garden.Metrics{
+					MemoryStat: garden.ContainerMemoryStat{TotalRss: 1024, TotalCache: 2048, TotalSwap: 0},
+					CPUStat:    garden.ContainerCPUStat{Usage: uint64(1500 * time.Millisecond)},
+					DiskStat:   garden.ContainerDiskStat{BytesUsed: 4096, InodesUsed: 12},
+				},
+			},
+			"handle-b": {
+				Err: errors.New("oh no!"),
+			},
+		}, nil)
+
+		labelProperties = nil
+		gauges = nil
+		counters = nil
+		histograms = nil
+	})
+
+	JustBeforeEach(func() {
+		exporter = metrics_exporter.New(lagertest.NewTestLogger("test"), fakeBackend, labelProperties, gauges, counters, histograms)
+	})
+
+	It("collects the metrics of every container", func() {
+		export()
+
+		Expect(fakeBackend.ContainersCallCount()).To(Equal(1))
+		Expect(fakeBackend.BulkMetricsArgsForCall(0)).To(Equal([]string{"handle-a", "handle-b"}))
+	})
+
+	It("exports the memory, cpu and disk usage of each container labeled by handle", func() {
+		output := export()
+
+		Expect(output).To(ContainSubstring("# TYPE garden_container_memory_rss_bytes gauge\n"))
+		Expect(output).To(ContainSubstring(`garden_container_memory_rss_bytes{handle="handle-a"} 1024` + "\n"))
+		Expect(output).To(ContainSubstring(`garden_container_memory_cache_bytes{handle="handle-a"} 2048` + "\n"))
+		Expect(output).To(ContainSubstring(`garden_container_memory_swap_bytes{handle="handle-a"} 0` + "\n"))
+
+		Expect(output).To(ContainSubstring("# TYPE garden_container_cpu_usage_seconds counter\n"))
+		Expect(output).To(ContainSubstring(`garden_container_cpu_usage_seconds_total{handle="handle-a"} 1.5` + "\n"))
+
+		Expect(output).To(ContainSubstring(`garden_container_disk_used_bytes{handle="handle-a"} 4096` + "\n"))
+		Expect(output).To(ContainSubstring(`garden_container_disk_used_inodes{handle="handle-a"} 12` + "\n"))
+	})
+
+	It("skips containers whose metrics could not be collected", func() {
+		Expect(export()).ToNot(ContainSubstring("handle-b"))
+	})
+
+	It("terminates the exposition", func() {
+		Expect(export()).To(HaveSuffix("# EOF\n"))
+	})
+
+	Context("when labeling by properties", func() {
+		BeforeEach(func() {
+			labelProperties = []string{"tenant", "app.name"}
+		})
+
+		It("adds a sanitized label for each property the container has", func() {
+			Expect(export()).To(ContainSubstring(
+				`garden_container_memory_rss_bytes{handle="handle-a",property_tenant="green",property_app_name="say \"hi\""} 1024` + "\n",
+			))
+		})
+	})
+
+	Context("when listing the containers fails", func() {
+		BeforeEach(func() {
+			fakeBackend.ContainersReturns(nil, errors.New("oh no!"))
+		})
+
+		It("returns an error", func() {
+			Expect(exporter.WriteMetrics(new(bytes.Buffer))).To(MatchError("metrics_exporter: listing containers: oh no!"))
+		})
+
+		It("serves a 500", func() {
+			recorder := httptest.NewRecorder()
+			exporter.ServeHTTP(recorder, &http.Request{})
+
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+
+	Context("with gauges", func() {
+		BeforeEach(func() {
+			gauges = []metrics_exporter.Gauge{
+				{Name: "garden_uid_pool_free", Help: "Free UIDs.", Value: func() float64 { return 42 }},
+			}
+		})
+
+		It("exports their current values", func() {
+			output := export()
+
+			Expect(output).To(ContainSubstring("# TYPE garden_uid_pool_free gauge\n# HELP garden_uid_pool_free Free UIDs.\n"))
+			Expect(output).To(ContainSubstring("garden_uid_pool_free 42\n"))
+		})
+	})
+
+	Context("with counters", func() {
+		BeforeEach(func() {
+			removed := metrics_exporter.NewCounter("garden_reconciler_removed", "Resources removed.", "kind")
+			removed.Increment("veth")
+			removed.Increment("cgroup")
+			removed.Increment("veth")
+
+			failures := metrics_exporter.NewCounter("garden_reconciler_failures", "Failed passes.", "")
+
+			counters = []*metrics_exporter.Counter{removed, failures}
+		})
+
+		It("exports a total for every label value, in order", func() {
+			Expect(export()).To(ContainSubstring(`# TYPE garden_reconciler_removed counter
+# HELP garden_reconciler_removed Resources removed.
+garden_reconciler_removed_total{kind="cgroup"} 1
+garden_reconciler_removed_total{kind="veth"} 2
+`))
+		})
+
+		It("exports the total of a counter that is not split, even if it is zero", func() {
+			Expect(export()).To(ContainSubstring(`# TYPE garden_reconciler_failures counter
+# HELP garden_reconciler_failures Failed passes.
+garden_reconciler_failures_total 0
+`))
+		})
+	})
+
+	Context("with histograms", func() {
+		BeforeEach(func() {
+			histogram := metrics_exporter.NewHistogram("garden_container_create_duration_seconds", "Create latency.", []float64{1, 0.5})
+			histogram.Observe(0.25)
+			histogram.Observe(0.75)
+			histogram.Observe(2)
+
+			histograms = []*metrics_exporter.Histogram{histogram}
+		})
+
+		It("exports cumulative buckets, the sum and the count", func() {
+			Expect(export()).To(ContainSubstring(`# TYPE garden_container_create_duration_seconds histogram
+# HELP garden_container_create_duration_seconds Create latency.
+garden_container_create_duration_seconds_bucket{le="0.5"} 1
+garden_container_create_duration_seconds_bucket{le="1"} 2
+garden_container_create_duration_seconds_bucket{le="+Inf"} 3
+garden_container_create_duration_seconds_sum 3
+garden_container_create_duration_seconds_count 3
+`))
+		})
+	})
+
+	It("serves the exposition with the OpenMetrics content type", func() {
+		recorder := httptest.NewRecorder()
+		exporter.ServeHTTP(recorder, &http.Request{})
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.HeaderMap.Get("Content-Type")).To(Equal(metrics_exporter.ContentType))
+		Expect(recorder.Body.String()).To(HaveSuffix("# EOF\n"))
+	})
+})
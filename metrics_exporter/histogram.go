@@ -0,0 +1,82 @@
+package metrics_exporter
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the buckets used for
+// the latency of container operations.
+var LatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Histogram counts observations into buckets, to be exported as an
+// OpenMetrics histogram.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mutex  *sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: sorted,
+
+		mutex:  new(sync.Mutex),
+		counts: make([]uint64, len(sorted)),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// ObserveSince observes the number of seconds elapsed since start.
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+type histogramSnapshot struct {
+	buckets []float64
+
+	// cumulative, as in the exposition format
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *Histogram) snapshot() histogramSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+
+	return histogramSnapshot{
+		buckets: h.buckets,
+		counts:  counts,
+		count:   h.count,
+		sum:     h.sum,
+	}
+}
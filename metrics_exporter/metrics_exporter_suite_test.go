@@ -0,0 +1,13 @@
+package metrics_exporter_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMetricsExporter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Metrics Exporter Suite")
+}
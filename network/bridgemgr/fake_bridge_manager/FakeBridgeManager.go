@@ -44,6 +44,12 @@ type FakeBridgeManager struct {
 	pruneReturns     struct {
 		result1 error
 	}
+	CountStub        func() int
+	countMutex       sync.RWMutex
+	countArgsForCall []struct{}
+	countReturns     struct {
+		result1 int
+	}
 }
 
 func (fake *FakeBridgeManager) Reserve(subnet *net.IPNet, containerId string) (string, error) {
@@ -171,4 +177,28 @@ func (fake *FakeBridgeManager) PruneReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeBridgeManager) Count() int {
+	fake.countMutex.Lock()
+	fake.countArgsForCall = append(fake.countArgsForCall, struct{}{})
+	fake.countMutex.Unlock()
+	if fake.CountStub != nil {
+		return fake.CountStub()
+	} else {
+		return fake.countReturns.result1
+	}
+}
+
+func (fake *FakeBridgeManager) CountCallCount() int {
+	fake.countMutex.RLock()
+	defer fake.countMutex.RUnlock()
+	return len(fake.countArgsForCall)
+}
+
+func (fake *FakeBridgeManager) CountReturns(result1 int) {
+	fake.CountStub = nil
+	fake.countReturns = struct {
+		result1 int
+	}{result1}
+}
+
 var _ bridgemgr.BridgeManager = new(FakeBridgeManager)
@@ -34,6 +34,9 @@ type BridgeManager interface {
 
 	// Prune deletes all bridges starting with prefix, that are unknown.
 	Prune() error
+
+	// Count returns the number of bridges that are currently reserved.
+	Count() int
 }
 
 type mgr struct {
@@ -140,6 +143,13 @@ func (m *mgr) Prune() error {
 	return nil
 }
 
+func (m *mgr) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.bridgeSubnet)
+}
+
 func (m *mgr) isReserved(r string) bool {
 	_, ok := m.bridgeSubnet[r]
 	return ok
@@ -200,6 +200,27 @@ var _ = Describe("BridgeNamePool", func() {
 		})
 	})
 
+	Describe("counting", func() {
+		It("returns the number of reserved bridges", func() {
+			Expect(mgr.Count()).To(Equal(0))
+
+			name, err := mgr.Reserve(subnet1, "container1")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = mgr.Reserve(subnet1, "container2")
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = mgr.Reserve(subnet2, "container3")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(mgr.Count()).To(Equal(2))
+
+			Expect(mgr.Release(name, "container1")).To(Succeed())
+			Expect(mgr.Release(name, "container2")).To(Succeed())
+			Expect(mgr.Count()).To(Equal(1))
+		})
+	})
+
 	Describe("pruning", func() {
 		Context("when listing bridges fails", func() {
 			BeforeEach(func() {
@@ -78,6 +78,26 @@ func (Link) List() (names []string, err error) {
 	return names, nil
 }
 
+// Delete deletes the link, if it exists. Deleting either end of a veth pair
+// deletes both.
+func (Link) Delete(name string) error {
+	netlinkMu.Lock()
+	defer netlinkMu.Unlock()
+
+	intfs, err := net.Interfaces()
+	if err != nil {
+		return errF(err)
+	}
+
+	for _, i := range intfs {
+		if i.Name == name {
+			return errF(netlink.NetworkLinkDel(name))
+		}
+	}
+
+	return nil
+}
+
 func errF(err error) error {
 	if err == nil {
 		return err
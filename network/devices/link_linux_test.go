@@ -166,4 +166,20 @@ var _ = Describe("Link Management", func() {
 			Expect(names).To(ContainElement(name))
 		})
 	})
+
+	Describe("Delete", func() {
+		It("deletes the interface", func() {
+			Expect(l.Delete(name)).To(Succeed())
+
+			_, found, err := l.InterfaceByName(name)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		Context("when the interface does not exist", func() {
+			It("does not return an error", func() {
+				Expect(l.Delete("sandwich")).To(Succeed())
+			})
+		})
+	})
 })
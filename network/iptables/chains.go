@@ -0,0 +1,99 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// ListChains returns the names of every chain in the table, including the
+// built-in ones.
+func ListChains(runner command_runner.CommandRunner, table Type) ([]string, error) {
+	rules, err := listRules(runner, table)
+	if err != nil {
+		return nil, err
+	}
+
+	chains := []string{}
+	for _, rule := range rules {
+		if len(rule) >= 2 && (rule[0] == "-N" || rule[0] == "-P") {
+			chains = append(chains, rule[1])
+		}
+	}
+
+	return chains, nil
+}
+
+// DeleteChain deletes the rules in the table that jump to the chain, and
+// then the chain itself.
+func DeleteChain(runner command_runner.CommandRunner, table Type, chain string) error {
+	rules, err := listRules(runner, table)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if len(rule) < 2 || rule[0] != "-A" || rule[1] == chain || !jumpsTo(rule, chain) {
+			continue
+		}
+
+		rule[0] = "-D"
+
+		err := run(runner, table, rule...)
+		if err != nil {
+			return fmt.Errorf("iptables: deleting rule jumping to %s: %v", chain, err)
+		}
+	}
+
+	err = run(runner, table, "-F", chain)
+	if err != nil {
+		return fmt.Errorf("iptables: flushing chain %s: %v", chain, err)
+	}
+
+	err = run(runner, table, "-X", chain)
+	if err != nil {
+		return fmt.Errorf("iptables: deleting chain %s: %v", chain, err)
+	}
+
+	return nil
+}
+
+func jumpsTo(rule []string, chain string) bool {
+	for i := 0; i+1 < len(rule); i++ {
+		if (rule[i] == "-j" || rule[i] == "-g") && rule[i+1] == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listRules returns each line of `iptables -S` for the table, split into its
+// arguments.
+func listRules(runner command_runner.CommandRunner, table Type) ([][]string, error) {
+	out := new(bytes.Buffer)
+
+	list := exec.Command("/sbin/iptables", "-w", "-t", string(table), "-S")
+	list.Stdout = out
+
+	err := runner.Run(list)
+	if err != nil {
+		return nil, fmt.Errorf("iptables: listing %s rules: %v", table, err)
+	}
+
+	rules := [][]string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			rules = append(rules, fields)
+		}
+	}
+
+	return rules, nil
+}
+
+func run(runner command_runner.CommandRunner, table Type, args ...string) error {
+	return runner.Run(exec.Command("/sbin/iptables", append([]string{"-w", "-t", string(table)}, args...)...))
+}
@@ -327,5 +327,6 @@ const (
 type Type string
 
 const (
-	Nat Type = "nat"
+	Nat    Type = "nat"
+	Filter Type = "filter"
 )
@@ -31,6 +31,9 @@ type Subnets interface {
 
 	// Returns the number of /30 subnets which can be Acquired by a DynamicSubnetSelector.
 	Capacity() int
+
+	// Returns the number of subnets which currently have at least one IP address allocated.
+	Allocated() int
 }
 
 type pool struct {
@@ -132,6 +135,15 @@ func (m *pool) Capacity() int {
 	return int(math.Pow(2, float64(total-masked)) / 4)
 }
 
+// Allocated returns the number of subnets, dynamic or static, which have at
+// least one IP address allocated.
+func (p *pool) Allocated() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.allocated)
+}
+
 // Returns the gateway IP of a given subnet, which is always the maximum valid IP
 func GatewayIP(subnet *net.IPNet) net.IP {
 	m := max(subnet)
@@ -58,6 +58,27 @@ var _ = Describe("Subnet Pool", func() {
 		})
 	})
 
+	Describe("Allocated", func() {
+		BeforeEach(func() {
+			defaultSubnetPool = subnetPool("10.2.3.0/27")
+		})
+
+		It("returns the number of subnets with allocated IPs", func() {
+			Expect(subnetpool.Allocated()).To(Equal(0))
+
+			network, err := subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			_, err = subnetpool.Acquire(subnets.DynamicSubnetSelector, subnets.DynamicIPSelector)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(subnetpool.Allocated()).To(Equal(2))
+
+			Expect(subnetpool.Release(network)).To(Succeed())
+			Expect(subnetpool.Allocated()).To(Equal(1))
+		})
+	})
+
 	Describe("Allocating and Releasing", func() {
 		Describe("Static Subnet Allocation", func() {
 			Context("when the requested subnet is within the dynamic allocation range", func() {
@@ -0,0 +1,86 @@
+package cgroups_manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const instancePrefix = "instance-"
+
+// InstanceCgroups returns the cgroups of every container, in every subsystem
+// under cgroupsPath, keyed by container ID.
+func InstanceCgroups(cgroupsPath string) (map[string][]string, error) {
+	instances := map[string][]string{}
+
+	subsystems, err := ioutil.ReadDir(cgroupsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return instances, nil
+		}
+
+		return nil, fmt.Errorf("cgroups_manager: reading cgroups: %v", err)
+	}
+
+	for _, subsystem := range subsystems {
+		if !subsystem.IsDir() {
+			continue
+		}
+
+		subsystemPath := path.Join(cgroupsPath, subsystem.Name())
+
+		entries, err := ioutil.ReadDir(subsystemPath)
+		if err != nil {
+			return nil, fmt.Errorf("cgroups_manager: reading cgroups: %v", err)
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || !strings.HasPrefix(entry.Name(), instancePrefix) {
+				continue
+			}
+
+			id := strings.TrimPrefix(entry.Name(), instancePrefix)
+			instances[id] = append(instances[id], path.Join(subsystemPath, entry.Name()))
+		}
+	}
+
+	for _, paths := range instances {
+		sort.Strings(paths)
+	}
+
+	return instances, nil
+}
+
+// Remove removes the cgroup and any nested under it, deepest first; a cgroup
+// can only be removed once it has no children.
+func Remove(cgroupPath string) error {
+	dirs := []string{}
+
+	err := filepath.Walk(cgroupPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cgroups_manager: walking cgroup %s: %v", cgroupPath, err)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := os.Remove(dirs[i])
+		if err != nil {
+			return fmt.Errorf("cgroups_manager: removing cgroup %s: %v", dirs[i], err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package cgroups_manager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
+)
+
+var _ = Describe("Instance cgroups", func() {
+	var cgroupsPath string
+
+	BeforeEach(func() {
+		tmpdir, err := ioutil.TempDir(os.TempDir(), "some-cgroups")
+		Expect(err).ToNot(HaveOccurred())
+
+		cgroupsPath = tmpdir
+
+		Expect(os.MkdirAll(path.Join(cgroupsPath, "cpu", "instance-a"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(path.Join(cgroupsPath, "cpu", "instance-b", "nested"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(path.Join(cgroupsPath, "memory", "instance-a"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(path.Join(cgroupsPath, "memory", "not-an-instance"), 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cgroupsPath)
+	})
+
+	It("lists each container's cgroups by container ID", func() {
+		instances, err := cgroups_manager.InstanceCgroups(cgroupsPath)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(instances).To(Equal(map[string][]string{
+			"a": {
+				path.Join(cgroupsPath, "cpu", "instance-a"),
+				path.Join(cgroupsPath, "memory", "instance-a"),
+			},
+			"b": {
+				path.Join(cgroupsPath, "cpu", "instance-b"),
+			},
+		}))
+	})
+
+	Context("when the cgroups path does not exist", func() {
+		It("lists nothing", func() {
+			instances, err := cgroups_manager.InstanceCgroups(path.Join(cgroupsPath, "bogus"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(instances).To(BeEmpty())
+		})
+	})
+
+	It("removes a cgroup along with the ones nested under it", func() {
+		err := cgroups_manager.Remove(path.Join(cgroupsPath, "cpu", "instance-b"))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = os.Stat(path.Join(cgroupsPath, "cpu", "instance-b"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+
+		_, err = os.Stat(path.Join(cgroupsPath, "cpu", "instance-a"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
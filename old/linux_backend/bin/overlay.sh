@@ -69,6 +69,11 @@ function setup_fs() {
   mkdir -p $overlay_path
   mkdir -p $rootfs_path
 
+  # still mounted, e.g. when restoring a checkpoint without a reboot
+  if mountpoint -q $rootfs_path; then
+    return 0
+  fi
+
   if should_use_aufs; then
     mount -n -t aufs -o br:$overlay_path=rw:$base_path=ro+wh none $rootfs_path
   elif should_use_overlayfs; then
@@ -0,0 +1,48 @@
+#!/bin/bash
+
+[ -n "$DEBUG" ] && set -o xtrace
+set -o nounset
+set -o errexit
+shopt -s nullglob
+
+cd $(dirname $0)
+
+source ./etc/config
+
+cgroup_path="${GARDEN_CGROUP_PATH}"
+images=./checkpoint
+
+rm -rf $images
+mkdir -p $images/container
+
+freeze_args=""
+if [ -d ${cgroup_path}/freezer/instance-$id ]
+then
+  freeze_args="--freeze-cgroup ${cgroup_path}/freezer/instance-$id"
+fi
+
+# wshd is the init of the container's pid namespace, so every process in the
+# container is dumped (and killed) with it.
+$criu dump \
+  --tree $(cat ./run/wshd.pid) \
+  --images-dir $images/container \
+  --log-file dump.log \
+  --root $rootfs_path \
+  --manage-cgroups \
+  --tcp-established \
+  --ext-unix-sk \
+  --file-locks \
+  $freeze_args
+
+rm -f ./run/wshd.pid
+
+# CRIU recreates the container's cgroups when it is restored.
+for system_path in ${cgroup_path}/*
+do
+  path=$system_path/instance-$id
+
+  if [ -d $path ]
+  then
+    find $path -type d -delete
+  fi
+done
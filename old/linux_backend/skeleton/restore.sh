@@ -0,0 +1,44 @@
+#!/bin/bash
+
+[ -n "$DEBUG" ] && set -o xtrace
+set -o nounset
+set -o errexit
+shopt -s nullglob
+
+cd $(dirname $0)
+
+source ./etc/config
+
+images=./checkpoint
+
+if [ -f ./run/wshd.pid ]
+then
+  echo "wshd is already running..."
+  exit 1
+fi
+
+# The bridge does not survive a reboot, or a move to another host.
+if ! ip link show $bridge_iface > /dev/null 2>&1
+then
+  ip link add name $bridge_iface type bridge
+  ip address add ${network_host_ip}/${network_cidr_suffix} dev $bridge_iface
+  ip link set $bridge_iface up
+fi
+
+# The pipes between the host and the container's processes are replaced with
+# new ones, passed in by garden (see inherit_fds).
+$criu restore \
+  --images-dir $images/container \
+  --log-file restore.log \
+  --root $rootfs_path \
+  --manage-cgroups \
+  --tcp-established \
+  --ext-unix-sk \
+  --file-locks \
+  --veth-pair ${network_container_iface}=${network_host_iface} \
+  --restore-detached \
+  --pidfile $PWD/run/wshd.pid \
+  ${inherit_fds:-}
+
+ip link set $network_host_iface master $bridge_iface
+ip link set $network_host_iface up
@@ -8,6 +8,7 @@
 #include <stdlib.h>
 #include <string.h>
 #include <sys/ioctl.h>
+#include <sys/stat.h>
 #include <termios.h>
 #include <unistd.h>
 
@@ -37,6 +38,9 @@ struct wsh_s {
 
   /* File to save container-namespaced pid of spawned process in to */
   const char *pid_file;
+
+  /* File to save the inodes of the spawned process's pipes in to */
+  const char *pipes_file;
 };
 
 int wsh__usage(wsh_t *w) {
@@ -64,6 +68,11 @@ int wsh__usage(wsh_t *w) {
     "File to save container-namespaced pid of spawned process to"
     "\n");
 
+  fprintf(stderr, "  --pipesfile PIPESFILE  "
+    "File to save the inodes of the spawned process's stdin, stdout, stderr "
+    "and exit status pipes to"
+    "\n");
+
   fprintf(stderr, "  --rsh           "
     "RSH compatibility mode"
     "\n");
@@ -75,6 +84,7 @@ int wsh__getopt(wsh_t *w) {
   int j = w->argc - i;
 
   w->pid_file = 0;
+  w->pipes_file = 0;
 
   while (i < w->argc) {
     if (w->argv[i][0] != '-') {
@@ -100,6 +110,10 @@ int wsh__getopt(wsh_t *w) {
       w->pid_file = strdup(w->argv[i+1]);
       i += 2;
       j -= 2;
+    } else if (j >= 2 && strcmp(w->argv[i], "--pipesfile") == 0) {
+      w->pipes_file = strdup(w->argv[i+1]);
+      i += 2;
+      j -= 2;
     } else if (j >= 2 && strcmp(w->argv[i], "--env") == 0) {
       w->environment_variable_count++;
       w->environment_variables = realloc(w->environment_variables, w->environment_variable_count * sizeof(char *));
@@ -158,6 +172,7 @@ invalid:
 }
 
 static pid_t pid;
+static const char *pipes_file;
 
 void cleanup_pidfile(const char *pidfile, int pid_fd) {
   int rv;
@@ -169,6 +184,46 @@ void cleanup_pidfile(const char *pidfile, int pid_fd) {
       exit(255);
     }
   }
+
+  if (pipes_file) {
+    rv = unlink(pipes_file);
+    if (rv != 0) {
+      perror("unlink pipesfile");
+      exit(255);
+    }
+  }
+}
+
+/* Save the inodes of the pipes to the process, which a checkpoint of the
+ * container needs to hand the process new ones when it is restored. */
+void write_pipesfile(const char *pipesfile, int *fds, int fdslen) {
+  struct stat st;
+  FILE *f;
+  int i, rv;
+
+  f = fopen(pipesfile, "w");
+  if (f == NULL) {
+    perror("open pipesfile");
+    exit(1);
+  }
+
+  for (i = 0; i < fdslen; i++) {
+    rv = fstat(fds[i], &st);
+    if (rv == -1) {
+      perror("fstat");
+      exit(1);
+    }
+
+    fprintf(f, i == 0 ? "%lu" : " %lu", (unsigned long)st.st_ino);
+  }
+
+  fprintf(f, "\n");
+
+  rv = fclose(f);
+  if (rv != 0) {
+    perror("close pipesfile");
+    exit(1);
+  }
 }
 
 void pump_loop(const char *pid_file, pump_t *p, int pid_fd, int exit_status_fd, pump_pair_t *pp, int pplen) {
@@ -335,7 +390,7 @@ void loop_interactive(const char *pidfile, int fd) {
   pump_loop(pidfile, &p, fds[2], fds[1], pp, 2);
 }
 
-void loop_noninteractive(const char* pidfile, int fd) {
+void loop_noninteractive(const char* pidfile, const char *pipesfile, int fd) {
   msg_response_t res;
   int fds[5];
   size_t fdslen = sizeof(fds)/sizeof(fds[0]);
@@ -349,6 +404,12 @@ void loop_noninteractive(const char* pidfile, int fd) {
 
   assert(rv == sizeof(res));
 
+  if (pipesfile) {
+    /* stdin, stdout, stderr and exit status */
+    write_pipesfile(pipesfile, fds, 4);
+    pipes_file = pipesfile;
+  }
+
   pump_t p;
   pump_pair_t pp[3];
 
@@ -433,7 +494,7 @@ int main(int argc, char **argv) {
   if (req.tty) {
     loop_interactive(w->pid_file, fd);
   } else {
-    loop_noninteractive(w->pid_file, fd);
+    loop_noninteractive(w->pid_file, w->pipes_file, fd);
   }
 
   perror("unreachable");
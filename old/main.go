@@ -5,12 +5,15 @@ import (
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/gunk/command_runner"
 	"github.com/cloudfoundry/gunk/localip"
@@ -27,6 +30,7 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/container_pool"
 	"github.com/cloudfoundry-incubator/garden-linux/container_repository"
 	"github.com/cloudfoundry-incubator/garden-linux/linux_backend"
+	"github.com/cloudfoundry-incubator/garden-linux/metrics_exporter"
 	"github.com/cloudfoundry-incubator/garden-linux/network"
 	"github.com/cloudfoundry-incubator/garden-linux/network/bridgemgr"
 	"github.com/cloudfoundry-incubator/garden-linux/network/devices"
@@ -39,6 +43,7 @@ import (
 	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
 	"github.com/cloudfoundry-incubator/garden-linux/old/system_info"
 	"github.com/cloudfoundry-incubator/garden-linux/old/uid_pool"
+	"github.com/cloudfoundry-incubator/garden-linux/reconciler"
 	"github.com/cloudfoundry-incubator/garden/server"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/cloudfoundry/gunk/command_runner/linux_command_runner"
@@ -67,6 +72,12 @@ var snapshotsPath = flag.String(
 	"directory in which to store container state to persist through restarts",
 )
 
+var persistContainers = flag.Bool(
+	"persistContainers",
+	false,
+	"record container state in the depot on every change, so that containers survive an unclean exit",
+)
+
 var binPath = flag.String(
 	"bin",
 	"",
@@ -103,6 +114,204 @@ var containerGraceTime = flag.Duration(
 	"time after which to destroy idle containers",
 )
 
+var restoreConcurrency = flag.Int(
+	"restoreConcurrency",
+	8,
+	"number of containers to restore in parallel on startup",
+)
+
+var restoreTimeout = flag.Duration(
+	"restoreTimeout",
+	2*time.Minute,
+	"time after which restoring a single container on startup is abandoned",
+)
+
+var bulkConcurrency = flag.Int(
+	"bulkConcurrency",
+	16,
+	"number of containers to query in parallel for bulk info and metrics",
+)
+
+var bulkTimeout = flag.Duration(
+	"bulkTimeout",
+	10*time.Second,
+	"time after which querying a single container for bulk info or metrics is abandoned (0 waits indefinitely)",
+)
+
+var metricsSampleInterval = flag.Duration(
+	"metricsSampleInterval",
+	0,
+	"interval at which container metrics are sampled in the background for bulk metrics requests and the control API's metrics history (0 disables sampling)",
+)
+
+var metricsHistorySize = flag.Int(
+	"metricsHistorySize",
+	60,
+	"number of metrics samples to keep for each container",
+)
+
+var metricsListenAddr = flag.String(
+	"metricsListenAddr",
+	"",
+	"address on which to serve container and daemon metrics at /metrics in the OpenMetrics text format (empty disables the listener)",
+)
+
+var metricsLabelProperties = flag.String(
+	"metricsLabelProperties",
+	"",
+	"comma-separated list of container properties to label exported container metrics with",
+)
+
+var eventsListenNetwork = flag.String(
+	"eventsListenNetwork",
+	"unix",
+	"how to listen on the events address (unix, tcp, etc.)",
+)
+
+var eventsListenAddr = flag.String(
+	"eventsListenAddr",
+	"",
+	"address on which to stream container lifecycle events at /events as server-sent events (empty disables the listener)",
+)
+
+var drainTimeout = flag.Duration(
+	"drainTimeout",
+	15*time.Minute,
+	"how long to wait, once draining, for every container to be destroyed before exiting anyway (0 waits indefinitely)",
+)
+
+var controlListenNetwork = flag.String(
+	"controlListenNetwork",
+	"unix",
+	"how to listen on the control address (unix, tcp, etc.)",
+)
+
+var controlListenAddr = flag.String(
+	"controlListenAddr",
+	"",
+	"address on which to start draining the daemon by POSTing to /drain, signal processes by POSTing to /signal, get and set memory limits at /memory-limits, and get sampled metrics at /metrics-history (empty disables the listener)",
+)
+
+var reapInterval = flag.Duration(
+	"reapInterval",
+	0,
+	"interval at which to look for idle containers to reap (0 disables reaping)",
+)
+
+var reapIdleTimeout = flag.Duration(
+	"reapIdleTimeout",
+	0,
+	"how long a container may go without running processes or API calls before it is reaped (0 only reaps containers with a TTL property)",
+)
+
+var reapTTLProperty = flag.String(
+	"reapTTLProperty",
+	"",
+	"container property whose value, a duration such as 72h, overrides reapIdleTimeout for the container",
+)
+
+var reapAction = flag.String(
+	"reapAction",
+	"destroy",
+	"what to do with idle containers (destroy or stop)",
+)
+
+var reapDryRun = flag.Bool(
+	"reapDryRun",
+	false,
+	"only publish events for the idle containers that would be reaped",
+)
+
+var criuBin = flag.String(
+	"criuBin",
+	"",
+	"path to the criu binary used to checkpoint containers' processes and restore them (checkpointing is disabled if empty)",
+)
+
+var checkpointOnStop = flag.Bool(
+	"checkpointOnStop",
+	false,
+	"checkpoint running containers with criuBin when the server stops, and restore their processes when it starts again, e.g. after a reboot",
+)
+
+var reconcileInterval = flag.Duration(
+	"reconcileInterval",
+	0,
+	"interval at which to remove the iptables chains, veths, cgroups and overlay mounts left behind by containers that are gone (0 disables reconciling)",
+)
+
+var warmPoolSize = flag.Int(
+	"warmPoolSize",
+	0,
+	"number of prebuilt containers to keep ready for each of -warmPoolRootFSPaths (0 disables the warm pool)",
+)
+
+var warmPoolRootFSPaths = flag.String(
+	"warmPoolRootFSPaths",
+	"",
+	"comma-separated list of rootfs paths to keep prebuilt containers for (the empty path is the default rootfs)",
+)
+
+var warmPoolTTL = flag.Duration(
+	"warmPoolTTL",
+	time.Hour,
+	"time after which an idle prebuilt container is replaced (0 keeps them indefinitely)",
+)
+
+var containerIDScheme = flag.String(
+	"containerIDScheme",
+	"time",
+	"how to generate container IDs, one of 'time' or 'random' (default: time)",
+)
+
+var memoryOvercommitRatio = flag.Float64(
+	"memoryOvercommitRatio",
+	0,
+	"reject containers whose declared memory limits would add up to more than this many times the total memory (0 disables the check)",
+)
+
+var diskOvercommitRatio = flag.Float64(
+	"diskOvercommitRatio",
+	0,
+	"reject containers whose declared disk limits would add up to more than this many times the total disk (0 disables the check)",
+)
+
+var maxCPUShares = flag.Uint64(
+	"maxCPUShares",
+	0,
+	"reject containers whose declared CPU shares would add up to more than this (0 disables the check)",
+)
+
+var tenantProperty = flag.String(
+	"tenantProperty",
+	"",
+	"container property whose value groups containers into tenants, each held to the -tenant*Quota flags",
+)
+
+var tenantMemoryQuota = flag.Uint64(
+	"tenantMemoryQuota",
+	0,
+	"maximum total declared memory limit, in bytes, of each tenant's containers (0 for no quota)",
+)
+
+var tenantDiskQuota = flag.Uint64(
+	"tenantDiskQuota",
+	0,
+	"maximum total declared disk limit, in bytes, of each tenant's containers (0 for no quota)",
+)
+
+var tenantCPUSharesQuota = flag.Uint64(
+	"tenantCPUSharesQuota",
+	0,
+	"maximum total declared CPU shares of each tenant's containers (0 for no quota)",
+)
+
+var admissionQueueTimeout = flag.Duration(
+	"admissionQueueTimeout",
+	0,
+	"time for which a create that would overcommit waits for containers to be destroyed before being rejected (0 rejects it immediately)",
+)
+
 var portPoolStart = flag.Uint(
 	"portPoolStart",
 	61001,
@@ -228,6 +437,10 @@ func Main() {
 		missing("-overlays")
 	}
 
+	if *checkpointOnStop && *criuBin == "" {
+		missing("-criuBin")
+	}
+
 	if len(*tag) > 2 {
 		println("-tag parameter must be less than 3 characters long")
 		println()
@@ -321,17 +534,33 @@ func Main() {
 		panic(fmt.Sprintf("Value of -externalIP %s could not be converted to an IP", *externalIP))
 	}
 
+	var idGenerator container_pool.IDGenerator
+	switch *containerIDScheme {
+	case "time":
+		idGenerator = container_pool.NewTimeIDGenerator()
+	case "random":
+		idGenerator = container_pool.NewRandomIDGenerator()
+	default:
+		println("-containerIDScheme value not recognized")
+		println()
+		flag.Usage()
+		return
+	}
+
+	bridges := bridgemgr.New("w"+config.Tag+"b-", &devices.Bridge{}, &devices.Link{})
+
 	pool := container_pool.New(
 		logger,
 		*binPath,
 		*depotPath,
+		*snapshotsPath,
 		config,
 		rootFSProviders,
 		uidPool,
 		parsedExternalIP,
 		*mtu,
 		subnetPool,
-		bridgemgr.New("w"+config.Tag+"b-", &devices.Bridge{}, &devices.Link{}),
+		bridges,
 		filterProvider,
 		iptables.NewGlobalChain(config.IPTables.Filter.DefaultChain, runner, logger.Session("global-chain")),
 		portPool,
@@ -339,11 +568,84 @@ func Main() {
 		strings.Split(*allowNetworks, ","),
 		runner,
 		quotaManager,
+		idGenerator,
+		*criuBin,
 	)
 
+	var containerPool linux_backend.ContainerPool = pool
+
+	var warmPool *container_pool.WarmContainerPool
+	if *warmPoolSize > 0 {
+		warmPool = container_pool.NewWarm(
+			logger,
+			pool,
+			strings.Split(*warmPoolRootFSPaths, ","),
+			*warmPoolSize,
+			*warmPoolTTL,
+			clock.NewClock(),
+		)
+
+		containerPool = warmPool
+	}
+
 	systemInfo := system_info.NewProvider(*depotPath)
 
-	backend := linux_backend.New(logger, pool, container_repository.New(), systemInfo, *snapshotsPath)
+	var containerRepo linux_backend.ContainerRepository = container_repository.New()
+	if *persistContainers {
+		containerRepo = container_repository.NewPersistent(logger, *depotPath)
+	}
+
+	var metricsSampler *linux_backend.MetricsSampler
+	if *metricsSampleInterval > 0 {
+		metricsSampler = linux_backend.NewMetricsSampler(logger, clock.NewClock(), *metricsSampleInterval, *metricsHistorySize)
+	}
+
+	var reaper *linux_backend.Reaper
+	if *reapInterval > 0 {
+		action := linux_backend.ReapAction(*reapAction)
+		if action != linux_backend.ReapDestroy && action != linux_backend.ReapStop {
+			println("-reapAction value not recognized")
+			println()
+			flag.Usage()
+			return
+		}
+
+		reaper = linux_backend.NewReaper(logger, clock.NewClock(), *reapInterval, linux_backend.ReaperPolicy{
+			IdleTimeout: *reapIdleTimeout,
+			TTLProperty: *reapTTLProperty,
+			Action:      action,
+			DryRun:      *reapDryRun,
+		})
+	}
+
+	backend := linux_backend.New(
+		logger,
+		containerPool,
+		containerRepo,
+		systemInfo,
+		*snapshotsPath,
+		*restoreConcurrency,
+		*restoreTimeout,
+		*bulkConcurrency,
+		*bulkTimeout,
+		metricsSampler,
+		reaper,
+		linux_backend.AdmissionPolicy{
+			MemoryOvercommitRatio: *memoryOvercommitRatio,
+			DiskOvercommitRatio:   *diskOvercommitRatio,
+			MaxCPUShares:          *maxCPUShares,
+
+			TenantProperty: *tenantProperty,
+			TenantQuota: linux_backend.AdmissionLimits{
+				MemoryInBytes: *tenantMemoryQuota,
+				DiskInBytes:   *tenantDiskQuota,
+				CPUShares:     *tenantCPUSharesQuota,
+			},
+
+			QueueTimeout: *admissionQueueTimeout,
+		},
+		*checkpointOnStop,
+	)
 
 	err = backend.Setup()
 	if err != nil {
@@ -359,16 +661,117 @@ func Main() {
 		logger.Fatal("failed-to-start-server", err)
 	}
 
+	var reconcilerCounters []*metrics_exporter.Counter
+
+	if *reconcileInterval > 0 {
+		r := reconciler.New(
+			logger,
+			clock.NewClock(),
+			*reconcileInterval,
+			config,
+			*depotPath,
+			*overlaysPath,
+			"/proc/mounts",
+			runner,
+			devices.Link{},
+		)
+
+		r.Start()
+
+		reconcilerCounters = r.Counters()
+	}
+
 	signals := make(chan os.Signal, 1)
 
 	go func() {
 		<-signals
+
+		if warmPool != nil {
+			warmPool.Stop()
+		}
+
 		gardenServer.Stop()
 		os.Exit(0)
 	}()
 
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
+	var drainOnce sync.Once
+	drain := func() {
+		drainOnce.Do(func() {
+			if warmPool != nil {
+				warmPool.Stop()
+			}
+
+			go exitWhenDrained(logger, gardenServer, backend.Drain(), *drainTimeout)
+		})
+	}
+
+	drainSignals := make(chan os.Signal, 1)
+
+	go func() {
+		for {
+			<-drainSignals
+			drain()
+		}
+	}()
+
+	signal.Notify(drainSignals, syscall.SIGUSR1)
+
+	if *metricsListenAddr != "" {
+		exporter := metrics_exporter.New(
+			logger,
+			backend,
+			splitNonEmpty(*metricsLabelProperties),
+			[]metrics_exporter.Gauge{
+				{
+					Name:  "garden_uid_pool_free",
+					Help:  "Number of container user ids that can still be acquired.",
+					Value: func() float64 { return float64(uidPool.Available()) },
+				},
+				{
+					Name:  "garden_port_pool_free",
+					Help:  "Number of ports that can still be mapped into containers.",
+					Value: func() float64 { return float64(portPool.Available()) },
+				},
+				{
+					Name:  "garden_subnets_allocated",
+					Help:  "Number of container subnets with at least one container.",
+					Value: func() float64 { return float64(subnetPool.Allocated()) },
+				},
+				{
+					Name:  "garden_bridges",
+					Help:  "Number of bridges reserved for container subnets.",
+					Value: func() float64 { return float64(bridges.Count()) },
+				},
+			},
+			append(pool.Counters(), reconcilerCounters...),
+			pool.LatencyHistograms(),
+		)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+
+		serveHTTP(logger.Session("metrics-listener"), "tcp", *metricsListenAddr, mux)
+	}
+
+	if *eventsListenAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/events", linux_backend.NewEventStreamHandler(logger, backend.Events()))
+
+		serveHTTP(logger.Session("events-listener"), *eventsListenNetwork, *eventsListenAddr, mux)
+	}
+
+	if *controlListenAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/drain", linux_backend.NewDrainHandler(logger, drain))
+		mux.Handle("/signal", linux_backend.NewSignalHandler(logger, backend.SignalProcess))
+		mux.Handle("/memory-limits", linux_backend.NewMemoryLimitsHandler(logger, backend))
+		mux.Handle("/metrics-history", linux_backend.NewMetricsHistoryHandler(logger, backend.MetricsHistory))
+
+		serveHTTP(logger.Session("control-listener"), *controlListenNetwork, *controlListenAddr, mux)
+	}
+
 	logger.Info("started", lager.Data{
 		"network": *listenNetwork,
 		"addr":    *listenAddr,
@@ -377,6 +780,25 @@ func Main() {
 	select {}
 }
 
+// exitWhenDrained stops the server and exits once the backend has drained, or
+// the timeout (if any) expires, whichever comes first.
+func exitWhenDrained(logger lager.Logger, gardenServer *server.GardenServer, drained <-chan struct{}, timeout time.Duration) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	select {
+	case <-drained:
+		logger.Info("drained")
+	case <-expired:
+		logger.Info("drain-timed-out", lager.Data{"timeout": timeout.String()})
+	}
+
+	gardenServer.Stop()
+	os.Exit(0)
+}
+
 func getMountPoint(logger lager.Logger, depotPath string) string {
 	dfOut := new(bytes.Buffer)
 
@@ -394,6 +816,39 @@ func getMountPoint(logger lager.Logger, depotPath string) string {
 	return strings.Trim(dfOutputWords[len(dfOutputWords)-1], "\n")
 }
 
+func serveHTTP(logger lager.Logger, network, addr string, handler http.Handler) {
+	if network == "unix" {
+		// clean up a socket left behind by a previous run
+		os.Remove(addr)
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		logger.Fatal("failed-to-listen", err)
+	}
+
+	go func() {
+		err := http.Serve(listener, handler)
+		logger.Error("exited", err)
+	}()
+
+	logger.Info("listening", lager.Data{
+		"network": network,
+		"addr":    addr,
+	})
+}
+
+func splitNonEmpty(list string) []string {
+	parts := []string{}
+	for _, part := range strings.Split(list, ",") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return parts
+}
+
 func missing(flagName string) {
 	println("missing " + flagName)
 	println()
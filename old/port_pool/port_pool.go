@@ -42,6 +42,14 @@ func New(start, size uint32) *PortPool {
 	}
 }
 
+// Available returns the number of ports that can still be acquired.
+func (p *PortPool) Available() int {
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	return len(p.pool)
+}
+
 func (p *PortPool) Acquire() (uint32, error) {
 	p.poolMutex.Lock()
 	defer p.poolMutex.Unlock()
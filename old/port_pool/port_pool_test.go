@@ -119,4 +119,18 @@ var _ = Describe("Port pool", func() {
 			})
 		})
 	})
+
+	Describe("counting available ports", func() {
+		It("returns the number that can still be acquired", func() {
+			pool := port_pool.New(10000, 5)
+			Expect(pool.Available()).To(Equal(5))
+
+			acquired, err := pool.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.Available()).To(Equal(4))
+
+			pool.Release(acquired)
+			Expect(pool.Available()).To(Equal(5))
+		})
+	})
 })
@@ -58,9 +58,13 @@ func (provider *dockerRootFSProvider) ProvideRootFS(logger lager.Logger, id stri
 		return "", nil, err
 	}
 
-	err = provider.graphDriver.Create(id, imageID)
-	if err != nil {
-		return "", nil, err
+	// the entry is still there when a checkpointed container's rootfs is
+	// provided again
+	if !provider.graphDriver.Exists(id) {
+		err = provider.graphDriver.Create(id, imageID)
+		if err != nil {
+			return "", nil, err
+		}
 	}
 
 	rootPath, err := provider.graphDriver.Get(id, "")
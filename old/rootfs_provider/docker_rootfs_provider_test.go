@@ -220,6 +220,25 @@ var _ = Describe("DockerRootFSProvider", func() {
 			})
 		})
 
+		Context("when the graph entry already exists", func() {
+			BeforeEach(func() {
+				fakeGraphDriver.ExistsReturns(true)
+				fakeGraphDriver.GetReturns("/some/graph/driver/mount/point", nil)
+			})
+
+			It("mounts it without creating it again", func() {
+				mountpoint, _, err := provider.ProvideRootFS(
+					logger,
+					"some-id",
+					parseURL("docker:///some-repository-name"),
+				)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeGraphDriver.CreateCallCount()).To(BeZero())
+				Expect(mountpoint).To(Equal("/some/graph/driver/mount/point"))
+			})
+		})
+
 		Context("but getting the graph entry fails", func() {
 			disaster := errors.New("oh no!")
 
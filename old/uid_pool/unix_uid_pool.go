@@ -49,6 +49,14 @@ func (p *UnixUIDPool) InitialSize() int {
 	return p.initialPoolSize
 }
 
+// Available returns the number of UIDs that can still be acquired.
+func (p *UnixUIDPool) Available() int {
+	p.poolMutex.Lock()
+	defer p.poolMutex.Unlock()
+
+	return len(p.pool)
+}
+
 func (p *UnixUIDPool) Acquire() (uint32, error) {
 	p.poolMutex.Lock()
 	defer p.poolMutex.Unlock()
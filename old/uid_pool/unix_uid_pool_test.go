@@ -95,4 +95,18 @@ var _ = Describe("Unix UID pool", func() {
 			})
 		})
 	})
+
+	Describe("counting available UIDs", func() {
+		It("returns the number that can still be acquired", func() {
+			pool := uid_pool.New(10000, 5)
+			Expect(pool.Available()).To(Equal(5))
+
+			acquired, err := pool.Acquire()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.Available()).To(Equal(4))
+
+			pool.Release(acquired)
+			Expect(pool.Available()).To(Equal(5))
+		})
+	})
 })
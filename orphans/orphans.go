@@ -0,0 +1,102 @@
+// Package orphans finds the resources garden-linux creates on the host for
+// each container that belong to no container in the depot.
+package orphans
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry/gunk/command_runner"
+)
+
+// Chain is an iptables chain.
+type Chain struct {
+	Table iptables.Type
+	Name  string
+}
+
+// DepotIDs returns the IDs of the containers with a directory in the depot.
+//
+// A container's depot directory is created before any of its other resources
+// and removed after them, so it is there for as long as the container holds
+// any of them, whether it has been handed out yet or not.
+func DepotIDs(depotPath string) (map[string]bool, error) {
+	entries, err := ioutil.ReadDir(depotPath)
+	if err != nil {
+		return nil, fmt.Errorf("orphans: reading depot: %v", err)
+	}
+
+	ids := map[string]bool{}
+	for _, entry := range entries {
+		// the depot's temporary directory is not a container
+		if entry.IsDir() && entry.Name() != "tmp" {
+			ids[entry.Name()] = true
+		}
+	}
+
+	return ids, nil
+}
+
+// Chains returns the instance chains in the filter and nat tables, and their
+// log chains, that belong to none of the given containers.
+func Chains(runner command_runner.CommandRunner, config sysconfig.Config, ids map[string]bool) ([]Chain, error) {
+	prefixes := map[iptables.Type]string{
+		iptables.Filter: config.IPTables.Filter.InstancePrefix,
+		iptables.Nat:    config.IPTables.NAT.InstancePrefix,
+	}
+
+	orphans := []Chain{}
+
+	for _, table := range []iptables.Type{iptables.Filter, iptables.Nat} {
+		chains, err := iptables.ListChains(runner, table)
+		if err != nil {
+			return nil, fmt.Errorf("orphans: %v", err)
+		}
+
+		for _, chain := range chains {
+			if !strings.HasPrefix(chain, prefixes[table]) {
+				continue
+			}
+
+			// instance chains may come with a log chain
+			id := strings.TrimSuffix(strings.TrimPrefix(chain, prefixes[table]), "-log")
+			if ids[id] {
+				continue
+			}
+
+			orphans = append(orphans, Chain{Table: table, Name: chain})
+		}
+	}
+
+	return orphans, nil
+}
+
+// Cgroups returns the paths of the cgroups, in every subsystem, that belong
+// to none of the given containers, sorted by container ID.
+func Cgroups(cgroupPath string, ids map[string]bool) ([]string, error) {
+	instances, err := cgroups_manager.InstanceCgroups(cgroupPath)
+	if err != nil {
+		return nil, fmt.Errorf("orphans: %v", err)
+	}
+
+	orphanIDs := []string{}
+	for id := range instances {
+		if !ids[id] {
+			orphanIDs = append(orphanIDs, id)
+		}
+	}
+
+	sort.Strings(orphanIDs)
+
+	orphans := []string{}
+	for _, id := range orphanIDs {
+		orphans = append(orphans, instances[id]...)
+	}
+
+	return orphans, nil
+}
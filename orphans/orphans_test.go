@@ -0,0 +1,120 @@
+package orphans_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry-incubator/garden-linux/orphans"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Orphans", func() {
+	var tmpdir string
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "orphans")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	Describe("DepotIDs", func() {
+		It("returns the directories in the depot, except its temporary directory", func() {
+			Expect(os.MkdirAll(path.Join(tmpdir, "some-id"), 0755)).To(Succeed())
+			Expect(os.MkdirAll(path.Join(tmpdir, "tmp"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path.Join(tmpdir, "some-file"), []byte{}, 0644)).To(Succeed())
+
+			Expect(orphans.DepotIDs(tmpdir)).To(Equal(map[string]bool{"some-id": true}))
+		})
+
+		Context("when the depot cannot be read", func() {
+			It("returns an error", func() {
+				_, err := orphans.DepotIDs(path.Join(tmpdir, "missing"))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Chains", func() {
+		var config sysconfig.Config
+		var fakeRunner *fake_command_runner.FakeCommandRunner
+		var natError error
+
+		BeforeEach(func() {
+			natError = nil
+
+			config = sysconfig.NewConfig("t", false)
+			fakeRunner = fake_command_runner.New()
+
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-t", "filter", "-S"},
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stdout.Write([]byte(`-P FORWARD ACCEPT
+-N w-t-forward
+-N w-t-instance-live-id
+-N w-t-instance-live-id-log
+-N w-t-instance-dead-id
+-N w-t-instance-dead-id-log
+`))
+				return nil
+			})
+
+			fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+				Path: "/sbin/iptables",
+				Args: []string{"-w", "-t", "nat", "-S"},
+			}, func(cmd *exec.Cmd) error {
+				cmd.Stdout.Write([]byte(`-N w-t-prerouting
+-N w-t-instance-live-id
+-N w-t-instance-dead-id
+`))
+				return natError
+			})
+		})
+
+		It("returns the instance chains, and their log chains, of the other containers", func() {
+			Expect(orphans.Chains(fakeRunner, config, map[string]bool{"live-id": true})).To(Equal([]orphans.Chain{
+				{Table: iptables.Filter, Name: "w-t-instance-dead-id"},
+				{Table: iptables.Filter, Name: "w-t-instance-dead-id-log"},
+				{Table: iptables.Nat, Name: "w-t-instance-dead-id"},
+			}))
+		})
+
+		Context("when the chains cannot be listed", func() {
+			It("returns an error", func() {
+				natError = errors.New("oh no")
+
+				_, err := orphans.Chains(fakeRunner, config, map[string]bool{})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Cgroups", func() {
+		It("returns the cgroups of the other containers in every subsystem, sorted by ID", func() {
+			for _, subsystem := range []string{"cpu", "memory"} {
+				for _, id := range []string{"live-id", "dead-id-2", "dead-id-1"} {
+					Expect(os.MkdirAll(path.Join(tmpdir, subsystem, "instance-"+id), 0755)).To(Succeed())
+				}
+			}
+
+			Expect(orphans.Cgroups(tmpdir, map[string]bool{"live-id": true})).To(Equal([]string{
+				path.Join(tmpdir, "cpu", "instance-dead-id-1"),
+				path.Join(tmpdir, "memory", "instance-dead-id-1"),
+				path.Join(tmpdir, "cpu", "instance-dead-id-2"),
+				path.Join(tmpdir, "memory", "instance-dead-id-2"),
+			}))
+		})
+	})
+})
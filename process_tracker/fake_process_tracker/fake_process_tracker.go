@@ -33,12 +33,26 @@ type FakeProcessTracker struct {
 		result1 garden.Process
 		result2 error
 	}
-	RestoreStub        func(processID uint32, signaller process_tracker.Signaller)
+	RestoreStub        func(processID uint32, signaller process_tracker.Signaller) garden.Process
 	restoreMutex       sync.RWMutex
 	restoreArgsForCall []struct {
 		processID uint32
 		signaller process_tracker.Signaller
 	}
+	restoreReturns struct {
+		result1 garden.Process
+	}
+	ReattachStub        func(processID uint32, pipes process_tracker.ProcessPipes, signaller process_tracker.Signaller) (garden.Process, error)
+	reattachMutex       sync.RWMutex
+	reattachArgsForCall []struct {
+		processID uint32
+		pipes     process_tracker.ProcessPipes
+		signaller process_tracker.Signaller
+	}
+	reattachReturns struct {
+		result1 garden.Process
+		result2 error
+	}
 	ActiveProcessesStub        func() []garden.Process
 	activeProcessesMutex       sync.RWMutex
 	activeProcessesArgsForCall []struct{}
@@ -118,7 +132,7 @@ func (fake *FakeProcessTracker) AttachReturns(result1 garden.Process, result2 er
 	}{result1, result2}
 }
 
-func (fake *FakeProcessTracker) Restore(processID uint32, signaller process_tracker.Signaller) {
+func (fake *FakeProcessTracker) Restore(processID uint32, signaller process_tracker.Signaller) garden.Process {
 	fake.restoreMutex.Lock()
 	fake.restoreArgsForCall = append(fake.restoreArgsForCall, struct {
 		processID uint32
@@ -126,7 +140,9 @@ func (fake *FakeProcessTracker) Restore(processID uint32, signaller process_trac
 	}{processID, signaller})
 	fake.restoreMutex.Unlock()
 	if fake.RestoreStub != nil {
-		fake.RestoreStub(processID, signaller)
+		return fake.RestoreStub(processID, signaller)
+	} else {
+		return fake.restoreReturns.result1
 	}
 }
 
@@ -142,6 +158,48 @@ func (fake *FakeProcessTracker) RestoreArgsForCall(i int) (uint32, process_track
 	return fake.restoreArgsForCall[i].processID, fake.restoreArgsForCall[i].signaller
 }
 
+func (fake *FakeProcessTracker) RestoreReturns(result1 garden.Process) {
+	fake.RestoreStub = nil
+	fake.restoreReturns = struct {
+		result1 garden.Process
+	}{result1}
+}
+
+func (fake *FakeProcessTracker) Reattach(processID uint32, pipes process_tracker.ProcessPipes, signaller process_tracker.Signaller) (garden.Process, error) {
+	fake.reattachMutex.Lock()
+	fake.reattachArgsForCall = append(fake.reattachArgsForCall, struct {
+		processID uint32
+		pipes     process_tracker.ProcessPipes
+		signaller process_tracker.Signaller
+	}{processID, pipes, signaller})
+	fake.reattachMutex.Unlock()
+	if fake.ReattachStub != nil {
+		return fake.ReattachStub(processID, pipes, signaller)
+	} else {
+		return fake.reattachReturns.result1, fake.reattachReturns.result2
+	}
+}
+
+func (fake *FakeProcessTracker) ReattachCallCount() int {
+	fake.reattachMutex.RLock()
+	defer fake.reattachMutex.RUnlock()
+	return len(fake.reattachArgsForCall)
+}
+
+func (fake *FakeProcessTracker) ReattachArgsForCall(i int) (uint32, process_tracker.ProcessPipes, process_tracker.Signaller) {
+	fake.reattachMutex.RLock()
+	defer fake.reattachMutex.RUnlock()
+	return fake.reattachArgsForCall[i].processID, fake.reattachArgsForCall[i].pipes, fake.reattachArgsForCall[i].signaller
+}
+
+func (fake *FakeProcessTracker) ReattachReturns(result1 garden.Process, result2 error) {
+	fake.ReattachStub = nil
+	fake.reattachReturns = struct {
+		result1 garden.Process
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeProcessTracker) ActiveProcesses() []garden.Process {
 	fake.activeProcessesMutex.Lock()
 	fake.activeProcessesArgsForCall = append(fake.activeProcessesArgsForCall, struct{}{})
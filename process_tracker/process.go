@@ -7,7 +7,6 @@ import (
 	"os/exec"
 	"path"
 	"sync"
-	"syscall"
 
 	"github.com/cloudfoundry-incubator/garden"
 	"github.com/cloudfoundry/gunk/command_runner"
@@ -76,6 +75,17 @@ func (p *Process) Wait() (int, error) {
 	return p.exitStatus, p.exitErr
 }
 
+// hasExited is true from the moment Wait would return, even if the process
+// has not yet been unregistered from its tracker.
+func (p *Process) hasExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Process) SetTTY(tty garden.TTYSpec) error {
 	<-p.linked
 
@@ -87,14 +97,12 @@ func (p *Process) SetTTY(tty garden.TTYSpec) error {
 }
 
 func (p *Process) Signal(s garden.Signal) error {
-	switch s {
-	case garden.SignalKill:
-		return p.signaller.Signal(os.Kill)
-	case garden.SignalTerminate:
-		return p.signaller.Signal(syscall.SIGTERM)
-	default:
+	signal, found := signals[s]
+	if !found {
 		return fmt.Errorf("process_tracker: failed to send signal: unknown signal: %d", s)
 	}
+
+	return p.signaller.Signal(signal)
 }
 
 func (p *Process) Spawn(cmd *exec.Cmd, tty *garden.TTYSpec) (ready, active chan error) {
@@ -165,6 +173,50 @@ func (p *Process) Spawn(cmd *exec.Cmd, tty *garden.TTYSpec) (ready, active chan
 	return
 }
 
+// Reattach starts an iodaemon serving the given pipes to the process, which
+// it passes on as file descriptors 3 to 6. It returns once the iodaemon is
+// ready to be linked to.
+func (p *Process) Reattach(pipes ProcessPipes) error {
+	spawnPath := path.Join(p.containerPath, "bin", "iodaemon")
+	processSock := path.Join(p.containerPath, "processes", fmt.Sprintf("%d.sock", p.ID()))
+
+	reattach := exec.Command(
+		"bash",
+		"-c",
+		// spawn but not as a child process (fork off in the bash subprocess).
+		`"$0" "$@" &`,
+		spawnPath,
+		"reattach",
+		processSock,
+	)
+
+	reattach.ExtraFiles = []*os.File{
+		pipes.Stdin,
+		pipes.Stdout,
+		pipes.Stderr,
+		pipes.ExitStatus,
+	}
+
+	reattachR, err := reattach.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = p.runner.Start(reattach)
+	if err != nil {
+		return err
+	}
+
+	defer reattach.Wait()
+
+	_, err = bufio.NewReader(reattachR).ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read ready: %s", err)
+	}
+
+	return nil
+}
+
 func (p *Process) Link() {
 	p.runningLink.Do(p.runLinker)
 }
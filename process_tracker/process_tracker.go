@@ -2,6 +2,7 @@ package process_tracker
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"sync"
 
@@ -12,7 +13,8 @@ import (
 type ProcessTracker interface {
 	Run(processID uint32, cmd *exec.Cmd, io garden.ProcessIO, tty *garden.TTYSpec, signaller Signaller) (garden.Process, error)
 	Attach(processID uint32, io garden.ProcessIO) (garden.Process, error)
-	Restore(processID uint32, signaller Signaller)
+	Restore(processID uint32, signaller Signaller) garden.Process
+	Reattach(processID uint32, pipes ProcessPipes, signaller Signaller) (garden.Process, error)
 	ActiveProcesses() []garden.Process
 }
 
@@ -24,6 +26,16 @@ type processTracker struct {
 	processesMutex *sync.RWMutex
 }
 
+// ProcessPipes are the host's ends of the pipes to a process that is already
+// running: the write end of its stdin, and the read ends of its stdout, stderr
+// and exit status.
+type ProcessPipes struct {
+	Stdin      *os.File
+	Stdout     *os.File
+	Stderr     *os.File
+	ExitStatus *os.File
+}
+
 type UnknownProcessError struct {
 	ProcessID uint32
 }
@@ -83,7 +95,7 @@ func (t *processTracker) Attach(processID uint32, processIO garden.ProcessIO) (g
 	return process, nil
 }
 
-func (t *processTracker) Restore(processID uint32, signaller Signaller) {
+func (t *processTracker) Restore(processID uint32, signaller Signaller) garden.Process {
 	t.processesMutex.Lock()
 
 	process := NewProcess(processID, t.containerPath, t.runner, signaller)
@@ -93,18 +105,43 @@ func (t *processTracker) Restore(processID uint32, signaller Signaller) {
 	go t.link(processID)
 
 	t.processesMutex.Unlock()
+
+	return process
+}
+
+// Reattach tracks a process that is already running but has no iodaemon, such
+// as one restored from a checkpoint, by starting one on the given pipes.
+func (t *processTracker) Reattach(processID uint32, pipes ProcessPipes, signaller Signaller) (garden.Process, error) {
+	process := NewProcess(processID, t.containerPath, t.runner, signaller)
+
+	err := process.Reattach(pipes)
+	if err != nil {
+		return nil, err
+	}
+
+	t.processesMutex.Lock()
+
+	t.processes[processID] = process
+
+	go t.link(processID)
+
+	t.processesMutex.Unlock()
+
+	return process, nil
 }
 
 func (t *processTracker) ActiveProcesses() []garden.Process {
 	t.processesMutex.RLock()
 	defer t.processesMutex.RUnlock()
 
-	processes := make([]garden.Process, len(t.processes))
+	processes := make([]garden.Process, 0, len(t.processes))
 
-	i := 0
 	for _, process := range t.processes {
-		processes[i] = process
-		i++
+		if process.hasExited() {
+			continue
+		}
+
+		processes = append(processes, process)
 	}
 
 	return processes
@@ -2,7 +2,9 @@ package process_tracker_test
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -82,6 +84,37 @@ var _ = Describe("Running processes", func() {
 			Expect(signaller.sent).To(Equal([]os.Signal{syscall.SIGTERM}))
 		})
 
+		for gardenSignal, signal := range map[garden.Signal]syscall.Signal{
+			process_tracker.SignalHangup:    syscall.SIGHUP,
+			process_tracker.SignalInterrupt: syscall.SIGINT,
+			process_tracker.SignalQuit:      syscall.SIGQUIT,
+			process_tracker.SignalUser1:     syscall.SIGUSR1,
+			process_tracker.SignalUser2:     syscall.SIGUSR2,
+		} {
+			gardenSignal := gardenSignal
+			signal := signal
+
+			It(fmt.Sprintf("sends the process %s", signal), func() {
+				Expect(process.Signal(gardenSignal)).To(Succeed())
+				Expect(signaller.sent).To(Equal([]os.Signal{signal}))
+			})
+		}
+
+		It("looks signals up by name", func() {
+			for name, signal := range map[string]garden.Signal{
+				"HUP":     process_tracker.SignalHangup,
+				"sigusr1": process_tracker.SignalUser1,
+				"TERM":    garden.SignalTerminate,
+			} {
+				named, found := process_tracker.SignalNamed(name)
+				Expect(found).To(BeTrue())
+				Expect(named).To(Equal(signal))
+			}
+
+			_, found := process_tracker.SignalNamed("BOGUS")
+			Expect(found).To(BeFalse())
+		})
+
 		It("errors when an unsupported signal is sent", func() {
 			Expect(process.Signal(garden.Signal(999))).To(MatchError(HaveSuffix("failed to send signal: unknown signal: 999")))
 			Expect(signaller.sent).To(BeNil())
@@ -254,11 +287,11 @@ var _ = Describe("Restoring processes", func() {
 	})
 
 	It("tracks the restored process", func() {
-		processTracker.Restore(2, nil)
+		process := processTracker.Restore(2, nil)
+		Expect(process.ID()).To(Equal(uint32(2)))
 
 		activeProcesses := processTracker.ActiveProcesses()
-		Expect(activeProcesses).To(HaveLen(1))
-		Expect(activeProcesses[0].ID()).To(Equal(uint32(2)))
+		Expect(activeProcesses).To(ConsistOf(process))
 	})
 
 	It("assigns the signaller to the process", func() {
@@ -273,6 +306,103 @@ var _ = Describe("Restoring processes", func() {
 	})
 })
 
+var _ = Describe("Re-attaching to running processes", func() {
+	var (
+		pipes process_tracker.ProcessPipes
+
+		stdinR, stdoutW, stderrW, exitStatusW *os.File
+	)
+
+	BeforeEach(func() {
+		processTracker = process_tracker.New(tmpdir, linux_command_runner.New())
+
+		var err error
+
+		stdinR, pipes.Stdin, err = os.Pipe()
+		Expect(err).NotTo(HaveOccurred())
+		pipes.Stdout, stdoutW, err = os.Pipe()
+		Expect(err).NotTo(HaveOccurred())
+		pipes.Stderr, stderrW, err = os.Pipe()
+		Expect(err).NotTo(HaveOccurred())
+		pipes.ExitStatus, exitStatusW, err = os.Pipe()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	exit := func(status int32) {
+		Expect(binary.Write(exitStatusW, binary.LittleEndian, status)).To(Succeed())
+		exitStatusW.Close()
+		stdoutW.Close()
+		stderrW.Close()
+	}
+
+	It("tracks the process until it exits", func() {
+		process, err := processTracker.Reattach(2, pipes, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(process.ID()).To(Equal(uint32(2)))
+
+		Expect(processTracker.ActiveProcesses()).To(ConsistOf(process))
+
+		exit(42)
+
+		Expect(process.Wait()).To(Equal(42))
+		Expect(processTracker.ActiveProcesses()).To(BeEmpty())
+	})
+
+	It("streams stdout, stdin, and stderr", func() {
+		_, err := processTracker.Reattach(2, pipes, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		stdout := gbytes.NewBuffer()
+		stderr := gbytes.NewBuffer()
+
+		_, err = processTracker.Attach(2, garden.ProcessIO{
+			Stdin:  bytes.NewBufferString("this-is-stdin"),
+			Stdout: stdout,
+			Stderr: stderr,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		stdin := make([]byte, len("this-is-stdin"))
+		_, err = io.ReadFull(stdinR, stdin)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(stdin)).To(Equal("this-is-stdin"))
+
+		stdoutW.Write([]byte("hi stdout\n"))
+		stderrW.Write([]byte("hi stderr\n"))
+
+		Eventually(stdout).Should(gbytes.Say("hi stdout"))
+		Eventually(stderr).Should(gbytes.Say("hi stderr"))
+
+		exit(0)
+	})
+
+	It("assigns the signaller to the process", func() {
+		signaller := &FakeSignaller{}
+		_, err := processTracker.Reattach(2, pipes, signaller)
+		Expect(err).NotTo(HaveOccurred())
+
+		activeProcesses := processTracker.ActiveProcesses()
+		Expect(activeProcesses).To(HaveLen(1))
+
+		Expect(activeProcesses[0].Signal(garden.SignalKill)).To(Succeed())
+		Expect(signaller.sent).To(Equal([]os.Signal{os.Kill}))
+
+		exit(0)
+	})
+
+	Context("when the iodaemon cannot be started", func() {
+		BeforeEach(func() {
+			Expect(os.Remove(filepath.Join(tmpdir, "bin", "iodaemon"))).To(Succeed())
+		})
+
+		It("returns an error, and does not track the process", func() {
+			_, err := processTracker.Reattach(2, pipes, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(processTracker.ActiveProcesses()).To(BeEmpty())
+		})
+	})
+})
+
 var _ = Describe("Attaching to running processes", func() {
 	BeforeEach(func() {
 		processTracker = process_tracker.New(tmpdir, linux_command_runner.New())
@@ -334,6 +464,16 @@ var _ = Describe("Listing active process IDs", func() {
 		stdinWriter2.Close()
 		Eventually(processTracker.ActiveProcesses).Should(BeEmpty())
 	})
+
+	It("excludes a process as soon as it can be waited for", func() {
+		process, err := processTracker.Run(57, exec.Command("true"), garden.ProcessIO{}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = process.Wait()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(processTracker.ActiveProcesses()).To(BeEmpty())
+	})
 })
 
 type FakeSignaller struct {
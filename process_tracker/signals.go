@@ -0,0 +1,110 @@
+package process_tracker
+
+import (
+	"strings"
+	"syscall"
+
+	"github.com/cloudfoundry-incubator/garden"
+)
+
+// Garden only has values for SIGTERM and SIGKILL, and its API only forwards
+// those. These stand for the rest of the POSIX signals, which are sent
+// through the control API instead. They are numbered well clear of any
+// values garden may add.
+const (
+	SignalHangup garden.Signal = 100 + iota
+	SignalInterrupt
+	SignalQuit
+	SignalUser1
+	SignalUser2
+	SignalAbort
+	SignalAlarm
+	SignalBus
+	SignalChild
+	SignalContinue
+	SignalFloatingPoint
+	SignalIllegal
+	SignalPipe
+	SignalPoll
+	SignalProfile
+	SignalSegmentation
+	SignalStop
+	SignalSystem
+	SignalTrap
+	SignalTerminalStop
+	SignalTerminalInput
+	SignalTerminalOutput
+	SignalUrgent
+	SignalVirtualAlarm
+	SignalCPULimit
+	SignalFileSizeLimit
+)
+
+// signals maps each signal to the one delivered to the process.
+var signals = map[garden.Signal]syscall.Signal{
+	garden.SignalTerminate: syscall.SIGTERM,
+	garden.SignalKill:      syscall.SIGKILL,
+	SignalHangup:           syscall.SIGHUP,
+	SignalInterrupt:        syscall.SIGINT,
+	SignalQuit:             syscall.SIGQUIT,
+	SignalUser1:            syscall.SIGUSR1,
+	SignalUser2:            syscall.SIGUSR2,
+	SignalAbort:            syscall.SIGABRT,
+	SignalAlarm:            syscall.SIGALRM,
+	SignalBus:              syscall.SIGBUS,
+	SignalChild:            syscall.SIGCHLD,
+	SignalContinue:         syscall.SIGCONT,
+	SignalFloatingPoint:    syscall.SIGFPE,
+	SignalIllegal:          syscall.SIGILL,
+	SignalPipe:             syscall.SIGPIPE,
+	SignalPoll:             syscall.SIGIO,
+	SignalProfile:          syscall.SIGPROF,
+	SignalSegmentation:     syscall.SIGSEGV,
+	SignalStop:             syscall.SIGSTOP,
+	SignalSystem:           syscall.SIGSYS,
+	SignalTrap:             syscall.SIGTRAP,
+	SignalTerminalStop:     syscall.SIGTSTP,
+	SignalTerminalInput:    syscall.SIGTTIN,
+	SignalTerminalOutput:   syscall.SIGTTOU,
+	SignalUrgent:           syscall.SIGURG,
+	SignalVirtualAlarm:     syscall.SIGVTALRM,
+	SignalCPULimit:         syscall.SIGXCPU,
+	SignalFileSizeLimit:    syscall.SIGXFSZ,
+}
+
+var signalNames = map[string]garden.Signal{
+	"TERM":   garden.SignalTerminate,
+	"KILL":   garden.SignalKill,
+	"HUP":    SignalHangup,
+	"INT":    SignalInterrupt,
+	"QUIT":   SignalQuit,
+	"USR1":   SignalUser1,
+	"USR2":   SignalUser2,
+	"ABRT":   SignalAbort,
+	"ALRM":   SignalAlarm,
+	"BUS":    SignalBus,
+	"CHLD":   SignalChild,
+	"CONT":   SignalContinue,
+	"FPE":    SignalFloatingPoint,
+	"ILL":    SignalIllegal,
+	"PIPE":   SignalPipe,
+	"IO":     SignalPoll,
+	"PROF":   SignalProfile,
+	"SEGV":   SignalSegmentation,
+	"STOP":   SignalStop,
+	"SYS":    SignalSystem,
+	"TRAP":   SignalTrap,
+	"TSTP":   SignalTerminalStop,
+	"TTIN":   SignalTerminalInput,
+	"TTOU":   SignalTerminalOutput,
+	"URG":    SignalUrgent,
+	"VTALRM": SignalVirtualAlarm,
+	"XCPU":   SignalCPULimit,
+	"XFSZ":   SignalFileSizeLimit,
+}
+
+// SignalNamed returns the signal with the given name, e.g. HUP or SIGHUP.
+func SignalNamed(name string) (garden.Signal, bool) {
+	signal, found := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	return signal, found
+}
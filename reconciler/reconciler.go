@@ -0,0 +1,405 @@
+package reconciler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/metrics_exporter"
+	"github.com/cloudfoundry-incubator/garden-linux/network/iptables"
+	"github.com/cloudfoundry-incubator/garden-linux/old/cgroups_manager"
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry-incubator/garden-linux/orphans"
+	"github.com/cloudfoundry/gunk/command_runner"
+	"github.com/pivotal-golang/clock"
+	"github.com/pivotal-golang/lager"
+)
+
+// the longest name the kernel allows for an interface
+const maxInterfaceNameLen = 15
+
+type LinkManager interface {
+	List() ([]string, error)
+	Delete(name string) error
+}
+
+type ResourceKind string
+
+const (
+	ResourceChain   = ResourceKind("iptables-chain")
+	ResourceVeth    = ResourceKind("veth")
+	ResourceCgroup  = ResourceKind("cgroup")
+	ResourceOverlay = ResourceKind("overlay")
+)
+
+// orphan is a resource on the host that belongs to no live container.
+type orphan struct {
+	kind ResourceKind
+
+	// the chain or interface name, the cgroup path, or the container's
+	// directory under the overlays path
+	name string
+
+	// the iptables table the chain is in
+	table iptables.Type
+
+	// the overlay's mount points, deepest first
+	mountPoints []string
+}
+
+func (o orphan) key() string {
+	return string(o.kind) + ":" + string(o.table) + ":" + o.name
+}
+
+// Reconciler periodically compares the resources garden-linux creates in the
+// kernel for each container against the containers in the depot, and removes
+// those left behind by containers that are gone (after a crash, say).
+//
+// A resource is only removed once it has been seen orphaned on two passes in
+// a row, so that containers still being created or destroyed are left alone.
+type Reconciler struct {
+	logger   lager.Logger
+	clock    clock.Clock
+	interval time.Duration
+
+	config       sysconfig.Config
+	depotPath    string
+	overlaysPath string
+	mountsPath   string
+
+	runner command_runner.CommandRunner
+	links  LinkManager
+
+	// the orphans seen on the previous pass, by key
+	suspects       map[string]bool
+	reconcileMutex *sync.Mutex
+
+	removed  *metrics_exporter.Counter
+	failures *metrics_exporter.Counter
+
+	stop     chan struct{}
+	stopOnce *sync.Once
+}
+
+func New(
+	logger lager.Logger,
+	clock clock.Clock,
+	interval time.Duration,
+	config sysconfig.Config,
+	depotPath string,
+	overlaysPath string,
+	mountsPath string,
+	runner command_runner.CommandRunner,
+	links LinkManager,
+) *Reconciler {
+	return &Reconciler{
+		logger:   logger.Session("reconciler"),
+		clock:    clock,
+		interval: interval,
+
+		config:       config,
+		depotPath:    depotPath,
+		overlaysPath: path.Clean(overlaysPath),
+		mountsPath:   mountsPath,
+
+		runner: runner,
+		links:  links,
+
+		suspects:       map[string]bool{},
+		reconcileMutex: &sync.Mutex{},
+
+		removed: metrics_exporter.NewCounter(
+			"garden_reconciler_removed",
+			"Number of resources left behind by containers that are gone that the reconciler removed.",
+			"kind",
+		),
+		failures: metrics_exporter.NewCounter(
+			"garden_reconciler_failures",
+			"Number of times the reconciler failed to list or remove resources.",
+			"",
+		),
+
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+	}
+}
+
+// Counters returns the counter of the resources removed, by kind, followed by
+// the counter of failures to list or remove them.
+func (r *Reconciler) Counters() []*metrics_exporter.Counter {
+	return []*metrics_exporter.Counter{r.removed, r.failures}
+}
+
+// Start reconciles once every interval until Stop is called.
+func (r *Reconciler) Start() {
+	ticker := r.clock.NewTicker(r.interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C():
+				r.Reconcile()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (r *Reconciler) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// Reconcile removes the orphaned resources that were already orphaned on the
+// previous pass, and remembers the rest for the next one.
+func (r *Reconciler) Reconcile() {
+	r.reconcileMutex.Lock()
+	defer r.reconcileMutex.Unlock()
+
+	rLog := r.logger.Session("reconcile")
+
+	// a container is live for as long as its depot directory is there, which
+	// covers those being created, restored or prebuilt for the warm pool
+	live, err := orphans.DepotIDs(r.depotPath)
+	if err != nil {
+		rLog.Error("failed-to-list-containers", err)
+		r.failures.Increment("")
+		return
+	}
+
+	found := []orphan{}
+
+	finders := []func(map[string]bool) ([]orphan, error){
+		r.orphanChains,
+		r.orphanVeths,
+		r.orphanCgroups,
+		r.orphanOverlays,
+	}
+
+	for _, find := range finders {
+		orphans, err := find(live)
+		if err != nil {
+			rLog.Error("failed-to-list-resources", err)
+			r.failures.Increment("")
+			continue
+		}
+
+		found = append(found, orphans...)
+	}
+
+	suspects := map[string]bool{}
+
+	for _, o := range found {
+		oLog := rLog.WithData(lager.Data{
+			"kind":  o.kind,
+			"name":  o.name,
+			"table": o.table,
+		})
+
+		if !r.suspects[o.key()] {
+			oLog.Info("suspected")
+			suspects[o.key()] = true
+			continue
+		}
+
+		err := r.remove(o)
+		if err != nil {
+			oLog.Error("failed-to-remove", err)
+			r.failures.Increment("")
+
+			// try again on the next pass
+			suspects[o.key()] = true
+			continue
+		}
+
+		oLog.Info("removed")
+		r.removed.Increment(string(o.kind))
+	}
+
+	r.suspects = suspects
+}
+
+func (r *Reconciler) remove(o orphan) error {
+	switch o.kind {
+	case ResourceChain:
+		return iptables.DeleteChain(r.runner, o.table, o.name)
+	case ResourceVeth:
+		// takes the container's end of the pair with it
+		return r.links.Delete(o.name)
+	case ResourceCgroup:
+		return cgroups_manager.Remove(o.name)
+	case ResourceOverlay:
+		return r.removeOverlay(o)
+	default:
+		return fmt.Errorf("reconciler: unknown resource kind: %s", o.kind)
+	}
+}
+
+func (r *Reconciler) orphanChains(live map[string]bool) ([]orphan, error) {
+	chains, err := orphans.Chains(r.runner, r.config, live)
+	if err != nil {
+		return nil, fmt.Errorf("reconciler: %v", err)
+	}
+
+	found := []orphan{}
+	for _, chain := range chains {
+		found = append(found, orphan{kind: ResourceChain, name: chain.Name, table: chain.Table})
+	}
+
+	return found, nil
+}
+
+// orphanVeths returns the host ends of the veth pairs of no live container.
+// They are named after the end of the container's ID, cut short to fit; see
+// skeleton/setup.sh.
+func (r *Reconciler) orphanVeths(live map[string]bool) ([]orphan, error) {
+	prefix := r.config.NetworkInterfacePrefix
+	bridgePrefix := prefix + "b-"
+
+	owned := map[string]bool{}
+	for id := range live {
+		owned[r.hostInterfaceName(id)] = true
+	}
+
+	links, err := r.links.List()
+	if err != nil {
+		return nil, fmt.Errorf("reconciler: listing links: %v", err)
+	}
+
+	sort.Strings(links)
+
+	orphans := []orphan{}
+	for _, link := range links {
+		if !strings.HasPrefix(link, prefix) || strings.HasPrefix(link, bridgePrefix) || !strings.HasSuffix(link, "-0") {
+			continue
+		}
+
+		if !owned[link] {
+			orphans = append(orphans, orphan{kind: ResourceVeth, name: link})
+		}
+	}
+
+	return orphans, nil
+}
+
+func (r *Reconciler) hostInterfaceName(id string) string {
+	prefix := r.config.NetworkInterfacePrefix
+
+	maxIDLen := maxInterfaceNameLen - len(prefix) - len("-0")
+	if len(id) > maxIDLen {
+		id = id[len(id)-maxIDLen:]
+	}
+
+	return prefix + id + "-0"
+}
+
+func (r *Reconciler) orphanCgroups(live map[string]bool) ([]orphan, error) {
+	cgroupPaths, err := orphans.Cgroups(r.config.CgroupPath, live)
+	if err != nil {
+		return nil, fmt.Errorf("reconciler: %v", err)
+	}
+
+	found := []orphan{}
+	for _, cgroupPath := range cgroupPaths {
+		found = append(found, orphan{kind: ResourceCgroup, name: cgroupPath})
+	}
+
+	return found, nil
+}
+
+// orphanOverlays returns the containers' directories under the overlays path
+// that still have something mounted in them, but belong to no live container.
+func (r *Reconciler) orphanOverlays(live map[string]bool) ([]orphan, error) {
+	mountPoints, err := r.mountPoints()
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string][]string{}
+	for _, mountPoint := range mountPoints {
+		if !strings.HasPrefix(mountPoint, r.overlaysPath+"/") {
+			continue
+		}
+
+		id := strings.SplitN(strings.TrimPrefix(mountPoint, r.overlaysPath+"/"), "/", 2)[0]
+		if live[id] {
+			continue
+		}
+
+		byID[id] = append(byID[id], mountPoint)
+	}
+
+	ids := []string{}
+	for id := range byID {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	orphans := []orphan{}
+	for _, id := range ids {
+		mountPoints := byID[id]
+
+		// nested mounts must be unmounted first
+		sort.Sort(sort.Reverse(sort.StringSlice(mountPoints)))
+
+		orphans = append(orphans, orphan{
+			kind:        ResourceOverlay,
+			name:        path.Join(r.overlaysPath, id),
+			mountPoints: mountPoints,
+		})
+	}
+
+	return orphans, nil
+}
+
+func (r *Reconciler) mountPoints() ([]string, error) {
+	mounts, err := os.Open(r.mountsPath)
+	if err != nil {
+		return nil, fmt.Errorf("reconciler: reading mounts: %v", err)
+	}
+
+	defer mounts.Close()
+
+	mountPoints := []string{}
+
+	scanner := bufio.NewScanner(mounts)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			mountPoints = append(mountPoints, fields[1])
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reconciler: reading mounts: %v", err)
+	}
+
+	return mountPoints, nil
+}
+
+func (r *Reconciler) removeOverlay(o orphan) error {
+	for _, mountPoint := range o.mountPoints {
+		err := r.runner.Run(exec.Command("umount", mountPoint))
+		if err != nil {
+			return fmt.Errorf("reconciler: unmounting %s: %v", mountPoint, err)
+		}
+	}
+
+	err := os.RemoveAll(o.name)
+	if err != nil {
+		return fmt.Errorf("reconciler: removing %s: %v", o.name, err)
+	}
+
+	return nil
+}
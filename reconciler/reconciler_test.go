@@ -0,0 +1,333 @@
+package reconciler_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-incubator/garden-linux/old/sysconfig"
+	"github.com/cloudfoundry-incubator/garden-linux/reconciler"
+	"github.com/cloudfoundry/gunk/command_runner/fake_command_runner"
+	. "github.com/cloudfoundry/gunk/command_runner/fake_command_runner/matchers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-golang/clock/fakeclock"
+	"github.com/pivotal-golang/lager/lagertest"
+)
+
+var _ = Describe("Reconciler", func() {
+	var tmpdir string
+	var depotPath string
+	var overlaysPath string
+	var mountsPath string
+	var config sysconfig.Config
+	var fakeClock *fakeclock.FakeClock
+	var fakeRunner *fake_command_runner.FakeCommandRunner
+	var fakeLinks *links
+	var r *reconciler.Reconciler
+
+	filterRules := ""
+	natRules := ""
+
+	BeforeEach(func() {
+		var err error
+
+		tmpdir, err = ioutil.TempDir("", "reconciler")
+		Expect(err).ToNot(HaveOccurred())
+
+		depotPath = path.Join(tmpdir, "depot")
+		overlaysPath = path.Join(tmpdir, "overlays")
+		mountsPath = path.Join(tmpdir, "mounts")
+
+		config = sysconfig.NewConfig("t", false)
+		config.CgroupPath = path.Join(tmpdir, "cgroup")
+
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+		fakeRunner = fake_command_runner.New()
+		fakeLinks = &links{deleted: []string{}}
+
+		for _, id := range []string{"live-id", "1234567890abcdefg", "tmp"} {
+			Expect(os.MkdirAll(path.Join(depotPath, id), 0755)).To(Succeed())
+		}
+
+		filterRules = `-P FORWARD ACCEPT
+-N w-t-forward
+-N w-t-instance-live-id
+-N w-t-instance-live-id-log
+-N w-t-instance-dead-id
+-N w-t-instance-dead-id-log
+-A w-t-forward -i wtb-foo -s 10.254.0.6/32 -g w-t-instance-dead-id
+-A w-t-instance-dead-id -g w-t-instance-dead-id-log
+`
+		natRules = `-N w-t-prerouting
+-N w-t-instance-live-id
+-N w-t-instance-dead-id
+-A w-t-prerouting -j w-t-instance-dead-id
+`
+
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{"-w", "-t", "filter", "-S"},
+		}, func(cmd *exec.Cmd) error {
+			cmd.Stdout.Write([]byte(filterRules))
+			return nil
+		})
+
+		fakeRunner.WhenRunning(fake_command_runner.CommandSpec{
+			Path: "/sbin/iptables",
+			Args: []string{"-w", "-t", "nat", "-S"},
+		}, func(cmd *exec.Cmd) error {
+			cmd.Stdout.Write([]byte(natRules))
+			return nil
+		})
+
+		// the IDs are cut short to fit the interface name
+		fakeLinks.names = []string{"eth0", "wtb-foo", "wtlive-id-0", "wtlive-id-1", "wt7890abcdefg-0", "wtdead-id-0"}
+
+		Expect(os.MkdirAll(path.Join(config.CgroupPath, "cpu", "instance-live-id"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(path.Join(config.CgroupPath, "cpu", "instance-dead-id", "nested"), 0755)).To(Succeed())
+
+		Expect(os.MkdirAll(path.Join(overlaysPath, "live-id", "rootfs"), 0755)).To(Succeed())
+		Expect(os.MkdirAll(path.Join(overlaysPath, "dead-id", "rootfs", "proc"), 0755)).To(Succeed())
+
+		mounts := "none " + path.Join(overlaysPath, "live-id", "rootfs") + " aufs rw 0 0\n" +
+			"none " + path.Join(overlaysPath, "dead-id", "rootfs") + " aufs rw 0 0\n" +
+			"proc " + path.Join(overlaysPath, "dead-id", "rootfs", "proc") + " proc rw 0 0\n" +
+			"none /somewhere/else aufs rw 0 0\n"
+		Expect(ioutil.WriteFile(mountsPath, []byte(mounts), 0644)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		r = reconciler.New(
+			lagertest.NewTestLogger("test"),
+			fakeClock,
+			time.Minute,
+			config,
+			depotPath,
+			overlaysPath,
+			mountsPath,
+			fakeRunner,
+			fakeLinks,
+		)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpdir)
+	})
+
+	removed := func(kind reconciler.ResourceKind) uint64 {
+		return r.Counters()[0].Count(string(kind))
+	}
+
+	failures := func() uint64 {
+		return r.Counters()[1].Count("")
+	}
+
+	deletedChains := func() []string {
+		chains := []string{}
+		for _, cmd := range fakeRunner.ExecutedCommands() {
+			if len(cmd.Args) == 6 && cmd.Args[4] == "-X" {
+				chains = append(chains, cmd.Args[3]+" "+cmd.Args[5])
+			}
+		}
+
+		return chains
+	}
+
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	Context("on the first pass", func() {
+		It("leaves everything alone", func() {
+			r.Reconcile()
+
+			Expect(deletedChains()).To(BeEmpty())
+			Expect(fakeLinks.deleted).To(BeEmpty())
+			Expect(exists(path.Join(config.CgroupPath, "cpu", "instance-dead-id"))).To(BeTrue())
+			Expect(exists(path.Join(overlaysPath, "dead-id"))).To(BeTrue())
+		})
+	})
+
+	Context("when a resource is still orphaned on the second pass", func() {
+		JustBeforeEach(func() {
+			r.Reconcile()
+			r.Reconcile()
+		})
+
+		It("deletes the chains of no live container, after the rules jumping to them", func() {
+			Expect(deletedChains()).To(Equal([]string{
+				"filter w-t-instance-dead-id",
+				"filter w-t-instance-dead-id-log",
+				"nat w-t-instance-dead-id",
+			}))
+
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-D", "w-t-prerouting", "-j", "w-t-instance-dead-id"},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "/sbin/iptables",
+					Args: []string{"-w", "-t", "nat", "-X", "w-t-instance-dead-id"},
+				},
+			))
+
+			Expect(removed(reconciler.ResourceChain)).To(Equal(uint64(3)))
+		})
+
+		It("deletes the host ends of the veth pairs of no live container", func() {
+			Expect(fakeLinks.deleted).To(Equal([]string{"wtdead-id-0"}))
+			Expect(removed(reconciler.ResourceVeth)).To(Equal(uint64(1)))
+		})
+
+		It("removes the cgroups of no live container", func() {
+			Expect(exists(path.Join(config.CgroupPath, "cpu", "instance-dead-id"))).To(BeFalse())
+			Expect(exists(path.Join(config.CgroupPath, "cpu", "instance-live-id"))).To(BeTrue())
+			Expect(removed(reconciler.ResourceCgroup)).To(Equal(uint64(1)))
+		})
+
+		It("unmounts the overlays of no live container, nested mounts first, and removes them", func() {
+			Expect(fakeRunner).To(HaveExecutedSerially(
+				fake_command_runner.CommandSpec{
+					Path: "umount",
+					Args: []string{path.Join(overlaysPath, "dead-id", "rootfs", "proc")},
+				},
+				fake_command_runner.CommandSpec{
+					Path: "umount",
+					Args: []string{path.Join(overlaysPath, "dead-id", "rootfs")},
+				},
+			))
+
+			Expect(exists(path.Join(overlaysPath, "dead-id"))).To(BeFalse())
+			Expect(exists(path.Join(overlaysPath, "live-id"))).To(BeTrue())
+			Expect(removed(reconciler.ResourceOverlay)).To(Equal(uint64(1)))
+		})
+
+		It("does not count any failures", func() {
+			Expect(failures()).To(BeZero())
+		})
+	})
+
+	Context("when a container's depot directory appears between the passes", func() {
+		It("leaves its resources alone", func() {
+			r.Reconcile()
+
+			Expect(os.MkdirAll(path.Join(depotPath, "dead-id"), 0755)).To(Succeed())
+			r.Reconcile()
+
+			Expect(deletedChains()).To(BeEmpty())
+			Expect(fakeLinks.deleted).To(BeEmpty())
+			Expect(exists(path.Join(config.CgroupPath, "cpu", "instance-dead-id"))).To(BeTrue())
+		})
+	})
+
+	Context("when removing a resource fails", func() {
+		BeforeEach(func() {
+			fakeLinks.deleteError = errors.New("oh no")
+		})
+
+		It("counts the failure, and tries again on the next pass", func() {
+			r.Reconcile()
+			r.Reconcile()
+
+			Expect(failures()).To(Equal(uint64(1)))
+
+			fakeLinks.deleteError = nil
+			r.Reconcile()
+
+			Expect(fakeLinks.deleted).To(Equal([]string{"wtdead-id-0"}))
+		})
+	})
+
+	Context("when the depot cannot be read", func() {
+		BeforeEach(func() {
+			Expect(os.RemoveAll(depotPath)).To(Succeed())
+		})
+
+		It("counts the failure, and leaves everything alone", func() {
+			r.Reconcile()
+			r.Reconcile()
+
+			Expect(failures()).To(Equal(uint64(2)))
+			Expect(deletedChains()).To(BeEmpty())
+			Expect(fakeLinks.deleted).To(BeEmpty())
+			Expect(exists(path.Join(config.CgroupPath, "cpu", "instance-dead-id"))).To(BeTrue())
+			Expect(exists(path.Join(overlaysPath, "dead-id"))).To(BeTrue())
+		})
+	})
+
+	Context("when listing a kind of resource fails", func() {
+		BeforeEach(func() {
+			fakeLinks.listError = errors.New("oh no")
+		})
+
+		It("counts the failure, and reconciles the others", func() {
+			r.Reconcile()
+			r.Reconcile()
+
+			Expect(failures()).To(Equal(uint64(2)))
+			Expect(exists(path.Join(config.CgroupPath, "cpu", "instance-dead-id"))).To(BeFalse())
+		})
+	})
+
+	Describe("Start", func() {
+		JustBeforeEach(func() {
+			r.Start()
+		})
+
+		AfterEach(func() {
+			r.Stop()
+		})
+
+		It("reconciles once every interval", func() {
+			Eventually(fakeClock.WatcherCount).Should(Equal(1))
+
+			fakeClock.Increment(time.Minute)
+			Eventually(fakeRunner.ExecutedCommands).ShouldNot(BeEmpty())
+
+			fakeClock.Increment(time.Minute)
+
+			Eventually(fakeLinks.Deleted).Should(Equal([]string{"wtdead-id-0"}))
+		})
+	})
+})
+
+type links struct {
+	names     []string
+	listError error
+
+	deleted     []string
+	deleteError error
+
+	mutex sync.Mutex
+}
+
+func (l *links) List() ([]string, error) {
+	return l.names, l.listError
+}
+
+func (l *links) Delete(name string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.deleteError != nil {
+		return l.deleteError
+	}
+
+	l.deleted = append(l.deleted, name)
+
+	return nil
+}
+
+func (l *links) Deleted() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return append([]string{}, l.deleted...)
+}
@@ -0,0 +1,45 @@
+// Package ring provides a fixed-size buffer of the most recent items pushed
+// to it, overwriting the oldest once it is full.
+package ring
+
+type Ring struct {
+	items []interface{}
+	next  int
+	count int
+}
+
+func New(size int) *Ring {
+	return &Ring{
+		items: make([]interface{}, size),
+	}
+}
+
+func (r *Ring) Push(item interface{}) {
+	r.items[r.next] = item
+	r.next = (r.next + 1) % len(r.items)
+
+	if r.count < len(r.items) {
+		r.count++
+	}
+}
+
+// Latest returns the most recently pushed item, or false if there is none.
+func (r *Ring) Latest() (interface{}, bool) {
+	if r.count == 0 {
+		return nil, false
+	}
+
+	return r.items[(r.next-1+len(r.items))%len(r.items)], true
+}
+
+// Items returns the items, oldest first.
+func (r *Ring) Items() []interface{} {
+	items := make([]interface{}, r.count)
+
+	oldest := (r.next - r.count + len(r.items)) % len(r.items)
+	for i := range items {
+		items[i] = r.items[(oldest+i)%len(r.items)]
+	}
+
+	return items
+}
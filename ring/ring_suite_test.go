@@ -0,0 +1,13 @@
+package ring_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ring Suite")
+}
@@ -0,0 +1,62 @@
+package ring_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudfoundry-incubator/garden-linux/ring"
+)
+
+var _ = Describe("Ring", func() {
+	var r *ring.Ring
+
+	BeforeEach(func() {
+		r = ring.New(3)
+	})
+
+	Context("when empty", func() {
+		It("has no items", func() {
+			Expect(r.Items()).To(BeEmpty())
+		})
+
+		It("has no latest item", func() {
+			_, found := r.Latest()
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Context("when not yet full", func() {
+		BeforeEach(func() {
+			r.Push(1)
+			r.Push(2)
+		})
+
+		It("returns the items oldest first", func() {
+			Expect(r.Items()).To(Equal([]interface{}{1, 2}))
+		})
+
+		It("returns the latest item", func() {
+			latest, found := r.Latest()
+			Expect(found).To(BeTrue())
+			Expect(latest).To(Equal(2))
+		})
+	})
+
+	Context("when more items are pushed than it can hold", func() {
+		BeforeEach(func() {
+			for i := 1; i <= 5; i++ {
+				r.Push(i)
+			}
+		})
+
+		It("keeps only the most recent items, oldest first", func() {
+			Expect(r.Items()).To(Equal([]interface{}{3, 4, 5}))
+		})
+
+		It("returns the latest item", func() {
+			latest, found := r.Latest()
+			Expect(found).To(BeTrue())
+			Expect(latest).To(Equal(5))
+		})
+	})
+})